@@ -3,8 +3,115 @@
 // Code generated by github.com/oapi-codegen/oapi-codegen/v2 version v2.4.1 DO NOT EDIT.
 package v1
 
+import (
+	"time"
+)
+
+// Defines values for FileType.
+const (
+	FileTypeDirectory FileType = "Directory"
+	FileTypeFile      FileType = "File"
+	FileTypeOther     FileType = "Other"
+	FileTypeSymlink   FileType = "Symlink"
+)
+
+// Defines values for MountType.
+const (
+	MountTypeBind   MountType = "bind"
+	MountTypeVolume MountType = "volume"
+)
+
+// Defines values for ProcessState.
+const (
+	ProcessStateExited  ProcessState = "Exited"
+	ProcessStateRunning ProcessState = "Running"
+)
+
+// Defines values for SandboxCassetteMode.
+const (
+	CassetteModeAuto   SandboxCassetteMode = "auto"
+	CassetteModeRecord SandboxCassetteMode = "record"
+	CassetteModeReplay SandboxCassetteMode = "replay"
+)
+
+// Defines values for SandboxEventType.
+const (
+	SandboxEventCreated   SandboxEventType = "Created"
+	SandboxEventDeleted   SandboxEventType = "Deleted"
+	SandboxEventExpired   SandboxEventType = "Expired"
+	SandboxEventFailed    SandboxEventType = "Failed"
+	SandboxEventOOMKilled SandboxEventType = "OOMKilled"
+	SandboxEventPaused    SandboxEventType = "Paused"
+	SandboxEventReady     SandboxEventType = "Ready"
+	SandboxEventResumed   SandboxEventType = "Resumed"
+	SandboxEventStopped   SandboxEventType = "Stopped"
+)
+
+// Defines values for SandboxNetworkMode.
+const (
+	NetworkModeBridge   SandboxNetworkMode = "bridge"
+	NetworkModeInternal SandboxNetworkMode = "internal"
+	NetworkModeNone     SandboxNetworkMode = "none"
+)
+
+// Defines values for SandboxPhase.
+const (
+	SandboxPhaseExpired SandboxPhase = "Expired"
+	SandboxPhaseFailed  SandboxPhase = "Failed"
+	SandboxPhasePaused  SandboxPhase = "Paused"
+	SandboxPhasePending SandboxPhase = "Pending"
+	SandboxPhaseReady   SandboxPhase = "Ready"
+	SandboxPhaseStopped SandboxPhase = "Stopped"
+)
+
+// Defines values for SandboxSpecIdlePolicy.
+const (
+	IdlePolicyDelete SandboxSpecIdlePolicy = "delete"
+	IdlePolicyStop   SandboxSpecIdlePolicy = "stop"
+)
+
+// Defines values for TerminalMessageType.
+const (
+	TerminalMessageExit   TerminalMessageType = "Exit"
+	TerminalMessageResize TerminalMessageType = "Resize"
+)
+
+// Defines values for ToolOutputStream.
+const (
+	ToolOutputStreamOutput ToolOutputStream = "output"
+	ToolOutputStreamStderr ToolOutputStream = "stderr"
+	ToolOutputStreamStdout ToolOutputStream = "stdout"
+)
+
+// Defines values for ToolStreamEventType.
+const (
+	ToolStreamEventError  ToolStreamEventType = "Error"
+	ToolStreamEventOutput ToolStreamEventType = "Output"
+	ToolStreamEventResult ToolStreamEventType = "Result"
+)
+
+// Defines values for GetSandboxParamsWait.
+const (
+	GetSandboxParamsWaitReady GetSandboxParamsWait = "Ready"
+)
+
+// Defines values for GetProcessOutputParamsStream.
+const (
+	GetProcessOutputParamsStreamStderr GetProcessOutputParamsStream = "stderr"
+	GetProcessOutputParamsStreamStdout GetProcessOutputParamsStream = "stdout"
+)
+
+// CreateProcessRequest The process to start.
+type CreateProcessRequest struct {
+	// Spec The command to run. Exactly one of command or argv must be set.
+	Spec ProcessSpec `json:"spec"`
+}
+
 // CreateSandboxRequest defines model for CreateSandboxRequest.
 type CreateSandboxRequest struct {
+	// Labels Key/value pairs that can be used to organize and select sandboxes.
+	Labels map[string]string `json:"labels,omitempty"`
+
 	// Name The name of the sandbox. If not specified, will be generated automatically.
 	Name string `json:"name,omitempty"`
 
@@ -12,10 +119,279 @@ type CreateSandboxRequest struct {
 	Spec SandboxSpec `json:"spec"`
 }
 
+// CreateSnapshotRequest defines model for CreateSnapshotRequest.
+type CreateSnapshotRequest struct {
+	// Labels Labels for the snapshot.
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Name The name of the snapshot, following the same rules as volume names. Generated from the name of the volume and the time if empty.
+	Name string `json:"name,omitempty"`
+}
+
+// CreateSpaceRequest defines model for CreateSpaceRequest.
+type CreateSpaceRequest struct {
+	// Name The name of the space. Must consist of lower case alphanumeric characters or '-', and must start and end with an alphanumeric character.
+	Name string `json:"name"`
+
+	// Spec The specification of a Space.
+	Spec *SpaceSpec `json:"spec,omitempty"`
+}
+
+// CreateVolumeRequest defines model for CreateVolumeRequest.
+type CreateVolumeRequest struct {
+	// Labels Labels for the volume.
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Name The name of the volume. Must consist of lower case alphanumeric characters or '-', and must start and end with an alphanumeric character.
+	Name string `json:"name"`
+
+	// Snapshot The name of a snapshot in the space to fill the volume with.
+	Snapshot string `json:"snapshot,omitempty"`
+}
+
+// DeletePathRequest The path to delete.
+type DeletePathRequest struct {
+	// Path The absolute path of the file or directory. Symlinks are not followed (the link itself is deleted).
+	Path string `json:"path"`
+
+	// Recursive Delete directories and their contents. If not set, only empty directories can be deleted.
+	Recursive bool `json:"recursive,omitempty"`
+}
+
+// EgressConnection A connection that a sandbox made (or tried to make) through the egress proxy.
+type EgressConnection struct {
+	// Address The IP address that was connected to (allowed connections only).
+	Address string `json:"address,omitempty"`
+
+	// Allowed True if the connection was allowed.
+	Allowed bool `json:"allowed"`
+
+	// Host The requested host.
+	Host string `json:"host"`
+
+	// Message Why the connection was denied or failed.
+	Message string `json:"message,omitempty"`
+
+	// Method CONNECT for tunnelled (HTTPS) connections, otherwise the method of the HTTP request.
+	Method string `json:"method"`
+
+	// Port The requested port.
+	Port int `json:"port"`
+
+	// Time The time of the connection.
+	Time time.Time `json:"time"`
+
+	// URL The requested URL (HTTP requests only).
+	URL string `json:"url,omitempty"`
+}
+
+// EgressConnectionList The most recent egress connections of a sandbox, oldest first.
+type EgressConnectionList struct {
+	Items []EgressConnection `json:"items"`
+}
+
 // Error defines model for Error.
 type Error struct {
 	// Message The error message.
 	Message string `json:"message"`
+
+	// Reason A machine readable reason for the error, set when the status code alone is ambiguous.
+	//
+	// * PathNotFound - The path was not found in the sandbox.
+	// * ProcessNotFound - The process was not found in the sandbox.
+	// * PortNotListening - Nothing in the sandbox is listening on the port.
+	// * SandboxPaused - The sandbox is paused and must be resumed before tool calls can be made.
+	// * SandboxNotRunning - The sandbox is not running, for example because it is still Pending or was stopped. The message includes its phase and reason.
+	Reason string `json:"reason,omitempty"`
+}
+
+// FileInfo Information about a file in a sandbox.
+type FileInfo struct {
+	// LinkTarget The target of the link (Symlink only).
+	LinkTarget string `json:"link_target,omitempty"`
+
+	// Mode The Unix permission bits of the file, for example 420 (0644).
+	Mode uint32 `json:"mode"`
+
+	// ModifiedAt The last modification time of the file.
+	ModifiedAt time.Time `json:"modified_at"`
+
+	// Name The base name of the file.
+	Name string `json:"name"`
+
+	// Path The absolute path of the file.
+	Path string `json:"path"`
+
+	// Size The size of the file in bytes.
+	Size int64 `json:"size"`
+
+	// Type The type of a file.
+	Type FileType `json:"type"`
+}
+
+// FileType The type of a file.
+type FileType string
+
+// IPythonCellStreamEvent An event in the output stream of an IPython cell.
+type IPythonCellStreamEvent struct {
+	// Data The output chunk (Output events) or error message (Error events).
+	Data string `json:"data,omitempty"`
+
+	// Result The result from the IPython kernel.
+	Result *RunIPythonCellResult `json:"result,omitempty"`
+
+	// Stream The stream that a chunk of output was written to. When split_output
+	// is false, stdout and stderr are interleaved and reported as output.
+	Stream *ToolOutputStream `json:"stream,omitempty"`
+
+	// Type The type of a tool stream event.
+	//
+	// * Output - A chunk of output was written.
+	// * Result - The tool finished. This is always the last event.
+	// * Error - The tool could not be run. This is always the last event.
+	Type ToolStreamEventType `json:"type"`
+}
+
+// ListDirRequest The directory to list.
+type ListDirRequest struct {
+	// Path The absolute path of the directory. Symlinks are followed.
+	Path string `json:"path"`
+}
+
+// ListDirResult The entries in a directory, sorted by name.
+type ListDirResult struct {
+	Entries []FileInfo `json:"entries"`
+
+	// Truncated True if the directory has more entries than the maximum that can be listed (10000).
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// Mount A volume or host path that is mounted into a sandbox.
+type Mount struct {
+	// ReadOnly Mount read-only.
+	ReadOnly bool `json:"read_only,omitempty"`
+
+	// Source The name of the volume or the absolute path on the host.
+	Source string `json:"source"`
+
+	// Target The absolute path in the sandbox to mount at.
+	Target string `json:"target"`
+
+	// Type What to mount.
+	//
+	// * volume - A volume in the space of the sandbox.
+	// * bind - A path on the Docker host. Only paths in the
+	//   directories that sandboxaid allows (SANDBOXAID_BIND_MOUNT_ALLOW)
+	//   can be mounted, after resolving symlinks, so sandboxaid must
+	//   run on the Docker host.
+	Type MountType `json:"type"`
+}
+
+// MountType What to mount.
+//
+//   - volume - A volume in the space of the sandbox.
+//   - bind - A path on the Docker host. Only paths in the
+//     directories that sandboxaid allows (SANDBOXAID_BIND_MOUNT_ALLOW)
+//     can be mounted, after resolving symlinks, so sandboxaid must
+//     run on the Docker host.
+type MountType string
+
+// Process A background process running in a sandbox.
+type Process struct {
+	// ID The ID of the process, assigned when it is started.
+	ID string `json:"id,omitempty"`
+
+	// Spec The command to run. Exactly one of command or argv must be set.
+	Spec ProcessSpec `json:"spec"`
+
+	// Status The status of a background process.
+	Status *ProcessStatus `json:"status,omitempty"`
+}
+
+// ProcessList The background processes in a sandbox, oldest first.
+type ProcessList struct {
+	Items []Process `json:"items"`
+}
+
+// ProcessOutput A chunk of the buffered output of a background process.
+type ProcessOutput struct {
+	// Data The output, decoded as UTF-8 (invalid sequences are replaced).
+	Data string `json:"data"`
+
+	// EOF True if the process has exited and all of its output has been read.
+	EOF bool `json:"eof,omitempty"`
+
+	// NextOffset The byte offset to read from next.
+	NextOffset int64 `json:"next_offset"`
+
+	// Offset The byte offset of the start of data in the stream. Greater than the requested offset if truncated is set.
+	Offset int64 `json:"offset"`
+
+	// Stream The stream the output was read from (stdout or stderr).
+	Stream string `json:"stream"`
+
+	// Truncated True if output between the requested offset and offset is no longer buffered.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// ProcessSpec The command to run. Exactly one of command or argv must be set.
+type ProcessSpec struct {
+	// Argv The program and arguments to execute directly, without a shell.
+	Argv []string `json:"argv,omitempty"`
+
+	// Command The command to execute with the shell (/bin/sh -c).
+	Command string `json:"command,omitempty"`
+
+	// Cwd The working directory to run the process in. Defaults to the working directory of the sandbox.
+	Cwd string `json:"cwd,omitempty"`
+
+	// Env Environment variables to set for the process, in addition to (or overriding) the environment of the sandbox.
+	Env map[string]string `json:"env,omitempty"`
+
+	// User The name of the user to run the process as. Defaults to the user that the sandbox runs as.
+	User string `json:"user,omitempty"`
+}
+
+// ProcessState The state of a background process.
+//
+// * Running - The process is running.
+// * Exited - The process has exited (or was killed).
+type ProcessState string
+
+// ProcessStatus The status of a background process.
+type ProcessStatus struct {
+	// ExitCode The exit code of the process. Only set once the process has exited. Processes killed by a signal report 128 plus the signal number.
+	ExitCode *int `json:"exit_code,omitempty"`
+
+	// ExitedAt The time the process exited.
+	ExitedAt *time.Time `json:"exited_at,omitempty"`
+
+	// PID The process ID inside the sandbox.
+	PID int `json:"pid"`
+
+	// StartedAt The time the process was started.
+	StartedAt time.Time `json:"started_at"`
+
+	// State The state of a background process.
+	//
+	// * Running - The process is running.
+	// * Exited - The process has exited (or was killed).
+	State ProcessState `json:"state"`
+}
+
+// ReadFileRequest The file to read.
+type ReadFileRequest struct {
+	// Path The absolute path of the file. Symlinks are followed.
+	Path string `json:"path"`
+}
+
+// ReadFileResult The file that was read.
+type ReadFileResult struct {
+	// Content The content of the file (base64 encoded).
+	Content []byte `json:"content"`
+
+	// Info Information about a file in a sandbox.
+	Info FileInfo `json:"info"`
 }
 
 // RunIPythonCellRequest The cell to run.
@@ -25,10 +401,19 @@ type RunIPythonCellRequest struct {
 
 	// SplitOutput Set to true to split the output into stdout and stderr. If set, the output field in the response will be empty and the stdout and stderr fields will be populated.
 	SplitOutput bool `json:"split_output,omitempty"`
+
+	// TimeoutSeconds Interrupt the kernel if the cell runs for longer than this many seconds. Zero (the default) means no timeout.
+	TimeoutSeconds int `json:"timeout_seconds,omitempty"`
 }
 
 // RunIPythonCellResult The result from the IPython kernel.
 type RunIPythonCellResult struct {
+	// ErrorName The name of the exception raised by the cell, for example "ZeroDivisionError".
+	ErrorName string `json:"error_name,omitempty"`
+
+	// ErrorValue The string value of the exception raised by the cell.
+	ErrorValue string `json:"error_value,omitempty"`
+
 	// Output The stdout and stderr from the IPython kernel interleaved.
 	Output string `json:"output,omitempty"`
 
@@ -37,19 +422,46 @@ type RunIPythonCellResult struct {
 
 	// Stdout The stdout from the IPython kernel.
 	Stdout string `json:"stdout,omitempty"`
+
+	// Success False if the cell raised an exception (or failed to compile).
+	Success bool `json:"success"`
+
+	// TimedOut True if the kernel was interrupted because the cell exceeded timeout_seconds.
+	TimedOut bool `json:"timed_out,omitempty"`
 }
 
-// RunShellCommandRequest The command to run.
+// RunShellCommandRequest The command to run. Exactly one of command or argv must be set.
 type RunShellCommandRequest struct {
-	// Command The command to execute.
-	Command string `json:"command"`
+	// Argv The program and arguments to execute directly, without a shell. Use this to avoid quoting issues.
+	Argv []string `json:"argv,omitempty"`
+
+	// Command The command to execute with the shell (/bin/sh -c).
+	Command string `json:"command,omitempty"`
+
+	// Cwd The working directory to run the command in. Defaults to the working directory of the sandbox.
+	Cwd string `json:"cwd,omitempty"`
+
+	// Env Environment variables to set for the command, in addition to (or overriding) the environment of the sandbox.
+	Env map[string]string `json:"env,omitempty"`
 
 	// SplitOutput Set to true to split the output into stdout and stderr. If set, the output field in the response will be empty and the stdout and stderr fields will be populated.
 	SplitOutput bool `json:"split_output,omitempty"`
+
+	// Stdin Data to write to the standard input of the command. If not set, standard input is empty.
+	Stdin string `json:"stdin,omitempty"`
+
+	// TimeoutSeconds Kill the command if it runs for longer than this many seconds. Zero (the default) means no timeout.
+	TimeoutSeconds int `json:"timeout_seconds,omitempty"`
+
+	// User The name of the user to run the command as. Defaults to the user that the sandbox runs as.
+	User string `json:"user,omitempty"`
 }
 
 // RunShellCommandResult The result from the shell command.
 type RunShellCommandResult struct {
+	// ExitCode The exit code of the shell command.
+	ExitCode int `json:"exit_code"`
+
 	// Output The stdout and stderr from the shell command.
 	Output string `json:"output,omitempty"`
 
@@ -58,10 +470,16 @@ type RunShellCommandResult struct {
 
 	// Stdout The stdout from the shell command
 	Stdout string `json:"stdout,omitempty"`
+
+	// TimedOut True if the command was killed because it exceeded timeout_seconds.
+	TimedOut bool `json:"timed_out,omitempty"`
 }
 
 // Sandbox A sandbox environment for running code and commands.
 type Sandbox struct {
+	// Labels Key/value pairs that can be used to organize and select sandboxes.
+	Labels map[string]string `json:"labels,omitempty"`
+
 	// Name The name of the sandbox.
 	Name string `json:"name,omitempty"`
 
@@ -75,23 +493,632 @@ type Sandbox struct {
 	UID string `json:"uid,omitempty"`
 }
 
+// SandboxCassette Records the HTTP exchanges that the sandbox makes through the egress
+// proxy to a cassette, or replays them from it, so that runs are
+// reproducible. HTTPS is intercepted with a certificate authority of
+// sandboxaid that the sandbox is configured to trust (SSL_CERT_FILE,
+// REQUESTS_CA_BUNDLE, CURL_CA_BUNDLE and NODE_EXTRA_CA_CERTS).
+//
+// While recording, connections are allowed by egress_allow, or to any
+// public address if it is empty. While replaying, nothing is
+// connected to: each request is answered with the first unused
+// recorded exchange with the same method, URL and body, and requests
+// without one fail with status 502. The proxy must be the only way
+// out, so cassettes cannot be used with the bridge or none network
+// modes.
+//
+// Cassettes are stored by sandboxaid per space, as JSON lines with
+// an exchange per line. Responses are buffered, so streamed responses
+// are only passed on once complete.
+type SandboxCassette struct {
+	// Mode Defaults to auto. Sandboxes report the mode that was chosen.
+	//
+	// * auto - Replay if the cassette exists, otherwise record it.
+	// * record - Record the cassette, replacing it if it exists.
+	// * replay - Replay the cassette, which must exist.
+	Mode SandboxCassetteMode `json:"mode,omitempty"`
+
+	// Name The name of the cassette.
+	Name string `json:"name"`
+}
+
+// SandboxCassetteMode Defaults to auto. Sandboxes report the mode that was chosen.
+//
+// * auto - Replay if the cassette exists, otherwise record it.
+// * record - Record the cassette, replacing it if it exists.
+// * replay - Replay the cassette, which must exist.
+type SandboxCassetteMode string
+
+// SandboxEvent A change in the lifecycle of a sandbox.
+type SandboxEvent struct {
+	// ExitCode The exit code of the sandbox container (Stopped events only).
+	ExitCode *int `json:"exit_code,omitempty"`
+
+	// Message A human readable message with details about the event.
+	Message string `json:"message,omitempty"`
+
+	// Name The name of the sandbox.
+	Name string `json:"name"`
+
+	// Reason A brief CamelCase reason for the event (for example "IdleTimeout" for Expired events).
+	Reason string `json:"reason,omitempty"`
+
+	// ResumeToken An opaque token that can be used to resume a watch after this event.
+	ResumeToken string `json:"resume_token,omitempty"`
+
+	// Space The space the sandbox lives in.
+	Space string `json:"space"`
+
+	// Time The time the event occurred.
+	Time time.Time `json:"time"`
+
+	// Type The type of a sandbox event.
+	//
+	// * Created - The sandbox was created.
+	// * Ready - The sandbox became ready for tool calls.
+	// * Failed - The sandbox failed to become ready.
+	// * Stopped - The sandbox container exited.
+	// * OOMKilled - A process in the sandbox was killed because the sandbox ran out of memory.
+	// * Deleted - The sandbox was deleted.
+	// * Expired - The ttl or idle_timeout of the sandbox passed and it is being deleted. The reason is "TTLExpired" or "IdleTimeout".
+	// * Paused - The sandbox was paused.
+	// * Resumed - The sandbox was resumed.
+	Type SandboxEventType `json:"type"`
+
+	// UID The UID of the sandbox.
+	UID string `json:"uid,omitempty"`
+}
+
+// SandboxEventType The type of a sandbox event.
+//
+// * Created - The sandbox was created.
+// * Ready - The sandbox became ready for tool calls.
+// * Failed - The sandbox failed to become ready.
+// * Stopped - The sandbox container exited.
+// * OOMKilled - A process in the sandbox was killed because the sandbox ran out of memory.
+// * Deleted - The sandbox was deleted.
+// * Expired - The ttl or idle_timeout of the sandbox passed and it is being deleted. The reason is "TTLExpired" or "IdleTimeout".
+// * Paused - The sandbox was paused.
+// * Resumed - The sandbox was resumed.
+type SandboxEventType string
+
+// SandboxList A page of sandboxes.
+type SandboxList struct {
+	// Items The sandboxes in this page.
+	Items []Sandbox `json:"items"`
+
+	// NextPageToken A token that can be passed as page_token to retrieve the next page. Empty if there are no more results.
+	NextPageToken string `json:"next_page_token,omitempty"`
+}
+
+// SandboxNetwork The network configuration of a sandbox.
+type SandboxNetwork struct {
+	// Cassette Records the HTTP exchanges that the sandbox makes through the egress
+	// proxy to a cassette, or replays them from it, so that runs are
+	// reproducible. HTTPS is intercepted with a certificate authority of
+	// sandboxaid that the sandbox is configured to trust (SSL_CERT_FILE,
+	// REQUESTS_CA_BUNDLE, CURL_CA_BUNDLE and NODE_EXTRA_CA_CERTS).
+	//
+	// While recording, connections are allowed by egress_allow, or to any
+	// public address if it is empty. While replaying, nothing is
+	// connected to: each request is answered with the first unused
+	// recorded exchange with the same method, URL and body, and requests
+	// without one fail with status 502. The proxy must be the only way
+	// out, so cassettes cannot be used with the bridge or none network
+	// modes.
+	//
+	// Cassettes are stored by sandboxaid per space, as JSON lines with
+	// an exchange per line. Responses are buffered, so streamed responses
+	// are only passed on once complete.
+	Cassette *SandboxCassette `json:"cassette,omitempty"`
+
+	// EgressAllow Destinations that the sandbox can connect to through the egress
+	// proxy of sandboxaid, which is configured with the HTTP_PROXY and
+	// HTTPS_PROXY environment variables. Each entry is a domain (for
+	// example "pypi.org"), a wildcard matching its subdomains (for
+	// example "*.pythonhosted.org"), an IP address, or a CIDR range.
+	// Domains only allow public addresses, private and loopback
+	// addresses must be allowed by address. The proxy must be the only
+	// way out, so it cannot be used with the bridge or none modes.
+	//
+	// Sandboxes reach the proxy at the gateway of their network, so
+	// sandboxaid must run on the Docker host. Creating the sandbox
+	// fails with status 400 when Docker runs in a VM (for example with
+	// Docker Desktop or colima).
+	EgressAllow []string `json:"egress_allow,omitempty"`
+
+	// Mode How the sandbox is connected to the network. Defaults to
+	// internal if egress_allow or a cassette is set, otherwise to
+	// bridge.
+	//
+	// * none - No network access. The sandbox only has a loopback interface and is reached by sandboxaid through docker exec.
+	// * internal - No internet access. The sandbox can reach the other internal sandboxes in its space and be reached by sandboxaid.
+	// * bridge - Full network access.
+	//
+	// Each space has a dedicated Docker network for the internal and
+	// bridge modes. Sandboxes that are not bridged cannot publish
+	// ports, sandboxaid reaches them through docker exec instead.
+	Mode SandboxNetworkMode `json:"mode,omitempty"`
+}
+
+// SandboxNetworkMode How the sandbox is connected to the network. Defaults to
+// internal if egress_allow or a cassette is set, otherwise to
+// bridge.
+//
+// * none - No network access. The sandbox only has a loopback interface and is reached by sandboxaid through docker exec.
+// * internal - No internet access. The sandbox can reach the other internal sandboxes in its space and be reached by sandboxaid.
+// * bridge - Full network access.
+//
+// Each space has a dedicated Docker network for the internal and
+// bridge modes. Sandboxes that are not bridged cannot publish
+// ports, sandboxaid reaches them through docker exec instead.
+type SandboxNetworkMode string
+
+// SandboxPhase The lifecycle phase of a Sandbox.
+//
+// * Pending - The sandbox is starting up and is not yet ready for tool calls.
+// * Ready - The sandbox is ready for tool calls.
+// * Stopped - The sandbox container exited successfully, or was stopped by the idle_policy (with the reason "IdleStopped").
+// * Failed - The sandbox container exited with an error or never became ready.
+// * Paused - The sandbox is paused and does not serve tool calls until it is resumed.
+// * Expired - The ttl or idle_timeout of the sandbox passed (with the reason "TTLExpired" or "IdleTimeout") and it is being deleted. Expired sandboxes can still be gotten for 10 minutes after they are deleted, but do not serve tool calls.
+type SandboxPhase string
+
+// SandboxResources Resource limits of a sandbox. Unset (zero) values fall back to the
+// default and then the maximum of the space, and are unlimited if
+// neither is set. Sandboxes returned by the API report the effective
+// limits.
+type SandboxResources struct {
+	// CPUs The number of CPU cores the sandbox can use, may be fractional (for example 0.5).
+	CPUs float64 `json:"cpus,omitempty"`
+
+	// Memory The memory limit in bytes.
+	Memory int64 `json:"memory,omitempty"`
+
+	// PIDs The maximum number of processes (and threads).
+	PIDs int64 `json:"pids,omitempty"`
+
+	// ShmSize The size of /dev/shm in bytes. Defaults to 64 MiB.
+	ShmSize int64 `json:"shm_size,omitempty"`
+
+	// Swap The swap that can be used in addition to memory, in bytes. Only applies if memory is limited, in which case no swap is allowed by default.
+	Swap int64 `json:"swap,omitempty"`
+
+	// Ulimits Process limits (see setrlimit(2)).
+	Ulimits []Ulimit `json:"ulimits,omitempty"`
+}
+
 // SandboxSpec The specification of a Sandbox.
 type SandboxSpec struct {
 	// Env Environment variables for the sandbox.
 	Env map[string]string `json:"env,omitempty"`
 
+	// IdlePolicy What happens to the sandbox once its idle_timeout passes. Defaults
+	// to delete.
+	//
+	// * delete - Delete the sandbox.
+	// * stop - Stop the sandbox container without deleting it. Its
+	//   filesystem is kept (but not its memory or processes) and the
+	//   next tool call starts it again, waiting for it to become ready
+	//   before the call is made (the call fails with a 503 if it does
+	//   not). The ttl still applies.
+	IdlePolicy SandboxSpecIdlePolicy `json:"idle_policy,omitempty"`
+
+	// IdleTimeout Apply the idle_policy once the sandbox has not served a tool call
+	// for this long, as a duration (for example "10m"). Idleness is
+	// measured from the last tool call (or from when the sandbox became
+	// ready if it never served one). Unset means nothing happens to idle
+	// sandboxes.
+	IdleTimeout string `json:"idle_timeout,omitempty"`
+
 	// Image The container image the sandbox will run with.
 	Image string `json:"image,omitempty"`
+
+	// Mounts Volumes and host paths that are mounted into the sandbox.
+	Mounts []Mount `json:"mounts,omitempty"`
+
+	// Network The network configuration of a sandbox.
+	Network *SandboxNetwork `json:"network,omitempty"`
+
+	// Resources Resource limits of a sandbox. Unset (zero) values fall back to the
+	// default and then the maximum of the space, and are unlimited if
+	// neither is set. Sandboxes returned by the API report the effective
+	// limits.
+	Resources *SandboxResources `json:"resources,omitempty"`
+
+	// TTL Delete the sandbox once it has existed for this long, as a duration
+	// (for example "1h"). Unset means the sandbox is never deleted because
+	// of its age.
+	TTL string `json:"ttl,omitempty"`
+}
+
+// SandboxSpecIdlePolicy What happens to the sandbox once its idle_timeout passes. Defaults
+// to delete.
+//
+//   - delete - Delete the sandbox.
+//   - stop - Stop the sandbox container without deleting it. Its
+//     filesystem is kept (but not its memory or processes) and the
+//     next tool call starts it again, waiting for it to become ready
+//     before the call is made (the call fails with a 503 if it does
+//     not). The ttl still applies.
+type SandboxSpecIdlePolicy string
+
+// SandboxStartupTiming A breakdown of the time it took for the sandbox to become ready.
+type SandboxStartupTiming struct {
+	// CreateContainerMs Time spent creating the container.
+	CreateContainerMs int64 `json:"create_container_ms,omitempty"`
+
+	// StartContainerMs Time spent starting the container.
+	StartContainerMs int64 `json:"start_container_ms,omitempty"`
+
+	// TotalMs Total time from the create request to the sandbox becoming ready.
+	TotalMs int64 `json:"total_ms,omitempty"`
+
+	// WaitForReadyMs Time spent waiting for the sandbox to pass its health check after starting.
+	WaitForReadyMs int64 `json:"wait_for_ready_ms,omitempty"`
 }
 
 // SandboxStatus The status of the Sandbox.
-type SandboxStatus = map[string]interface{}
+type SandboxStatus struct {
+	// CreatedAt The time the sandbox was created.
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+
+	// ExitCode The exit code of the sandbox container. Only set once the container has exited.
+	ExitCode *int `json:"exit_code,omitempty"`
+
+	// ExpiresAt The time the sandbox will be deleted because of its ttl or idle_timeout, if no tool calls are made before then.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+
+	// FinishedAt The time the sandbox container last exited.
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+
+	// LastActivityAt The time of the last tool call made to the sandbox.
+	LastActivityAt *time.Time `json:"last_activity_at,omitempty"`
+
+	// Message A human readable message with details about the current phase.
+	Message string `json:"message,omitempty"`
+
+	// OomKilled True if the sandbox container was killed because it ran out of memory.
+	OomKilled bool `json:"oom_killed,omitempty"`
+
+	// Phase The lifecycle phase of a Sandbox.
+	//
+	// * Pending - The sandbox is starting up and is not yet ready for tool calls.
+	// * Ready - The sandbox is ready for tool calls.
+	// * Stopped - The sandbox container exited successfully, or was stopped by the idle_policy (with the reason "IdleStopped").
+	// * Failed - The sandbox container exited with an error or never became ready.
+	// * Paused - The sandbox is paused and does not serve tool calls until it is resumed.
+	// * Expired - The ttl or idle_timeout of the sandbox passed (with the reason "TTLExpired" or "IdleTimeout") and it is being deleted. Expired sandboxes can still be gotten for 10 minutes after they are deleted, but do not serve tool calls.
+	Phase *SandboxPhase `json:"phase,omitempty"`
+
+	// ReadyAt The time the sandbox became ready to serve tool calls.
+	ReadyAt *time.Time `json:"ready_at,omitempty"`
+
+	// Reason A brief CamelCase reason for the current phase (for example "OOMKilled", "IdleStopped", or "TTLExpired" and "IdleTimeout" for expired sandboxes).
+	Reason string `json:"reason,omitempty"`
+
+	// StartedAt The time the sandbox container was last started.
+	StartedAt *time.Time `json:"started_at,omitempty"`
+
+	// Startup A breakdown of the time it took for the sandbox to become ready.
+	Startup *SandboxStartupTiming `json:"startup,omitempty"`
+}
+
+// ShellCommandStreamEvent An event in the output stream of a shell command.
+type ShellCommandStreamEvent struct {
+	// Data The output chunk (Output events) or error message (Error events).
+	Data string `json:"data,omitempty"`
+
+	// Result The result from the shell command.
+	Result *RunShellCommandResult `json:"result,omitempty"`
+
+	// Stream The stream that a chunk of output was written to. When split_output
+	// is false, stdout and stderr are interleaved and reported as output.
+	Stream *ToolOutputStream `json:"stream,omitempty"`
+
+	// Type The type of a tool stream event.
+	//
+	// * Output - A chunk of output was written.
+	// * Result - The tool finished. This is always the last event.
+	// * Error - The tool could not be run. This is always the last event.
+	Type ToolStreamEventType `json:"type"`
+}
+
+// SignalProcessRequest The signal to send.
+type SignalProcessRequest struct {
+	// Signal The name of the signal, for example "SIGTERM" or "SIGINT".
+	Signal string `json:"signal"`
+}
+
+// Snapshot A tarball of the data in a volume, stored by sandboxaid.
+type Snapshot struct {
+	// CreatedAt The time the snapshot was taken.
+	CreatedAt time.Time `json:"created_at"`
+
+	// Labels Labels for the snapshot.
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Name The name of the snapshot.
+	Name string `json:"name"`
+
+	// SizeBytes The size of the stored (compressed) tarball.
+	SizeBytes int64 `json:"size_bytes"`
+
+	// Volume The name of the volume that the snapshot was taken of.
+	Volume string `json:"volume"`
+}
+
+// SnapshotList Snapshots, oldest first.
+type SnapshotList struct {
+	Items []Snapshot `json:"items"`
+}
+
+// Space A space is a namespace that sandboxes live in.
+type Space struct {
+	// Name The name of the space.
+	Name string `json:"name,omitempty"`
+
+	// Spec The specification of a Space.
+	Spec SpaceSpec `json:"spec"`
+
+	// Status The status of the Space.
+	Status *SpaceStatus `json:"status,omitempty"`
+}
+
+// SpaceResources Resource policy for the sandboxes in a space. Limits that a sandbox
+// does not set are taken from default and then from max. Sandboxes
+// that request more than max are rejected.
+type SpaceResources struct {
+	// Default Resource limits of a sandbox. Unset (zero) values fall back to the
+	// default and then the maximum of the space, and are unlimited if
+	// neither is set. Sandboxes returned by the API report the effective
+	// limits.
+	Default *SandboxResources `json:"default,omitempty"`
+
+	// Max Resource limits of a sandbox. Unset (zero) values fall back to the
+	// default and then the maximum of the space, and are unlimited if
+	// neither is set. Sandboxes returned by the API report the effective
+	// limits.
+	Max *SandboxResources `json:"max,omitempty"`
+}
+
+// SpaceSpec The specification of a Space.
+type SpaceSpec struct {
+	// Description A human readable description of the space.
+	Description string `json:"description,omitempty"`
+
+	// Resources Resource policy for the sandboxes in a space. Limits that a sandbox
+	// does not set are taken from default and then from max. Sandboxes
+	// that request more than max are rejected.
+	Resources *SpaceResources `json:"resources,omitempty"`
+}
+
+// SpaceStatus The status of the Space.
+type SpaceStatus struct {
+	// CreatedAt The time the space was created.
+	CreatedAt time.Time `json:"created_at,omitempty"`
+}
+
+// StatRequest The path to get information about.
+type StatRequest struct {
+	// Path The absolute path. Symlinks are not followed.
+	Path string `json:"path"`
+}
+
+// StatResult Information about a path.
+type StatResult struct {
+	// Info Information about a file in a sandbox.
+	Info FileInfo `json:"info"`
+}
+
+// TerminalMessage A control message sent over a terminal WebSocket.
+type TerminalMessage struct {
+	// Cols The width of the terminal (Resize only).
+	Cols int `json:"cols,omitempty"`
+
+	// ExitCode The exit code of the process (Exit only).
+	ExitCode int `json:"exit_code,omitempty"`
+
+	// Rows The height of the terminal (Resize only).
+	Rows int `json:"rows,omitempty"`
+
+	// Type The type of a terminal control message.
+	//
+	// * Resize - Sent by the client when its terminal is resized.
+	// * Exit - Sent by the server when the process exits.
+	Type TerminalMessageType `json:"type"`
+}
+
+// TerminalMessageType The type of a terminal control message.
+//
+// * Resize - Sent by the client when its terminal is resized.
+// * Exit - Sent by the server when the process exits.
+type TerminalMessageType string
+
+// ToolOutputStream The stream that a chunk of output was written to. When split_output
+// is false, stdout and stderr are interleaved and reported as output.
+type ToolOutputStream string
+
+// ToolStreamEventType The type of a tool stream event.
+//
+// * Output - A chunk of output was written.
+// * Result - The tool finished. This is always the last event.
+// * Error - The tool could not be run. This is always the last event.
+type ToolStreamEventType string
+
+// Ulimit A process limit (see setrlimit(2)).
+type Ulimit struct {
+	// Hard The hard limit. Must be at least the soft limit.
+	Hard int64 `json:"hard"`
+
+	// Name The name of the limit without the RLIMIT_ prefix, in lower case (for example "nofile").
+	Name string `json:"name"`
+
+	// Soft The soft limit.
+	Soft int64 `json:"soft"`
+}
+
+// Volume Persistent storage that sandboxes can mount.
+type Volume struct {
+	// Labels Labels for the volume.
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Name The name of the volume.
+	Name   string       `json:"name"`
+	Status VolumeStatus `json:"status"`
+}
+
+// VolumeList defines model for VolumeList.
+type VolumeList struct {
+	Items []Volume `json:"items"`
+}
+
+// VolumeStatus defines model for VolumeStatus.
+type VolumeStatus struct {
+	// CreatedAt The time the volume was created.
+	CreatedAt time.Time `json:"created_at"`
+
+	// MountedBy The names of the sandboxes that mount the volume.
+	MountedBy []string `json:"mounted_by"`
+}
+
+// WriteFileRequest The file to write.
+type WriteFileRequest struct {
+	// Content The content of the file (base64 encoded).
+	Content []byte `json:"content"`
+
+	// Mode The Unix permission bits of the file. Defaults to 420 (0644).
+	Mode uint32 `json:"mode,omitempty"`
+
+	// Path The absolute path of the file.
+	Path string `json:"path"`
+}
+
+// WriteFileResult The file that was written.
+type WriteFileResult struct {
+	// Info Information about a file in a sandbox.
+	Info FileInfo `json:"info"`
+}
+
+// ListSandboxesParams defines parameters for ListSandboxes.
+type ListSandboxesParams struct {
+	// LabelSelector A comma-separated list of label requirements (for example "team=ml,env!=prod,owner"). Only sandboxes matching all requirements are returned.
+	LabelSelector string `form:"label_selector,omitempty" json:"label_selector,omitempty"`
+
+	// Limit The maximum number of sandboxes to return. If not specified, a server-side default is used.
+	Limit int `form:"limit,omitempty" json:"limit,omitempty"`
+
+	// PageToken The next_page_token from a previous list response. Used to retrieve the next page of results.
+	PageToken string `form:"page_token,omitempty" json:"page_token,omitempty"`
+}
+
+// GetSandboxParams defines parameters for GetSandbox.
+type GetSandboxParams struct {
+	// Wait Set to "Ready" to wait (long-poll) until the sandbox is no longer Pending before responding. The sandbox is returned in its latest state if the timeout passes first.
+	Wait GetSandboxParamsWait `form:"wait,omitempty" json:"wait,omitempty"`
+
+	// Timeout The maximum time to wait when wait is set, as a duration (for example "30s"). Defaults to 30s, with a maximum of 5m.
+	Timeout string `form:"timeout,omitempty" json:"timeout,omitempty"`
+}
+
+// GetSandboxParamsWait defines parameters for GetSandbox.
+type GetSandboxParamsWait string
+
+// GetArchiveParams defines parameters for GetArchive.
+type GetArchiveParams struct {
+	// Path The absolute path of a directory (PUT) or a file or directory (GET) in the sandbox.
+	Path string `form:"path" json:"path"`
+}
+
+// PutArchiveParams defines parameters for PutArchive.
+type PutArchiveParams struct {
+	// Path The absolute path of a directory (PUT) or a file or directory (GET) in the sandbox.
+	Path string `form:"path" json:"path"`
+}
+
+// GetProcessOutputParams defines parameters for GetProcessOutput.
+type GetProcessOutputParams struct {
+	// Stream The stream to read.
+	Stream GetProcessOutputParamsStream `form:"stream,omitempty" json:"stream,omitempty"`
+
+	// Offset The byte offset in the stream to read from.
+	Offset int64 `form:"offset,omitempty" json:"offset,omitempty"`
+}
+
+// GetProcessOutputParamsStream defines parameters for GetProcessOutput.
+type GetProcessOutputParamsStream string
+
+// AttachTerminalParams defines parameters for AttachTerminal.
+type AttachTerminalParams struct {
+	// Command The program and arguments to run (repeat the parameter for each argument). Defaults to bash, or sh if bash is not installed.
+	Command []string `form:"command,omitempty" json:"command,omitempty"`
+
+	// User The name of the user to run the process as. Defaults to the user that the sandbox runs as.
+	User string `form:"user,omitempty" json:"user,omitempty"`
+
+	// Rows The initial height of the terminal.
+	Rows int `form:"rows,omitempty" json:"rows,omitempty"`
+
+	// Cols The initial width of the terminal.
+	Cols int `form:"cols,omitempty" json:"cols,omitempty"`
+}
+
+// WatchSandboxesParams defines parameters for WatchSandboxes.
+type WatchSandboxesParams struct {
+	// LabelSelector A comma-separated list of label requirements. Only events for sandboxes matching all requirements are sent.
+	LabelSelector string `form:"label_selector,omitempty" json:"label_selector,omitempty"`
+
+	// ResumeToken Resume the watch after the event with this token. If not specified, only new events are sent.
+	ResumeToken string `form:"resume_token,omitempty" json:"resume_token,omitempty"`
+}
+
+// ListSnapshotsParams defines parameters for ListSnapshots.
+type ListSnapshotsParams struct {
+	// Volume Only list the snapshots of this volume.
+	Volume string `form:"volume,omitempty" json:"volume,omitempty"`
+}
+
+// CreateSpaceJSONRequestBody defines body for CreateSpace for application/json ContentType.
+type CreateSpaceJSONRequestBody = CreateSpaceRequest
 
 // CreateSandboxJSONRequestBody defines body for CreateSandbox for application/json ContentType.
 type CreateSandboxJSONRequestBody = CreateSandboxRequest
 
+// CreateProcessJSONRequestBody defines body for CreateProcess for application/json ContentType.
+type CreateProcessJSONRequestBody = CreateProcessRequest
+
+// SignalProcessJSONRequestBody defines body for SignalProcess for application/json ContentType.
+type SignalProcessJSONRequestBody = SignalProcessRequest
+
+// DeletePathJSONRequestBody defines body for DeletePath for application/json ContentType.
+type DeletePathJSONRequestBody = DeletePathRequest
+
+// ListDirJSONRequestBody defines body for ListDir for application/json ContentType.
+type ListDirJSONRequestBody = ListDirRequest
+
+// ReadFileJSONRequestBody defines body for ReadFile for application/json ContentType.
+type ReadFileJSONRequestBody = ReadFileRequest
+
 // RunIPythonCellJSONRequestBody defines body for RunIPythonCell for application/json ContentType.
 type RunIPythonCellJSONRequestBody = RunIPythonCellRequest
 
+// RunIPythonCellStreamJSONRequestBody defines body for RunIPythonCellStream for application/json ContentType.
+type RunIPythonCellStreamJSONRequestBody = RunIPythonCellRequest
+
 // RunShellCommandJSONRequestBody defines body for RunShellCommand for application/json ContentType.
 type RunShellCommandJSONRequestBody = RunShellCommandRequest
+
+// RunShellCommandStreamJSONRequestBody defines body for RunShellCommandStream for application/json ContentType.
+type RunShellCommandStreamJSONRequestBody = RunShellCommandRequest
+
+// StatJSONRequestBody defines body for Stat for application/json ContentType.
+type StatJSONRequestBody = StatRequest
+
+// WriteFileJSONRequestBody defines body for WriteFile for application/json ContentType.
+type WriteFileJSONRequestBody = WriteFileRequest
+
+// CreateVolumeJSONRequestBody defines body for CreateVolume for application/json ContentType.
+type CreateVolumeJSONRequestBody = CreateVolumeRequest
+
+// SnapshotVolumeJSONRequestBody defines body for SnapshotVolume for application/json ContentType.
+type SnapshotVolumeJSONRequestBody = CreateSnapshotRequest
@@ -0,0 +1,204 @@
+package v1
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PutArchive extracts a tar archive into a directory in the sandbox,
+// creating the directory if it does not exist.
+func (c *Client) PutArchive(ctx context.Context, space, name, path string, archive io.Reader) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, c.archiveURL(space, name, path), archive)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-tar")
+
+	resp, err := c.httpc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return notFoundError(resp)
+	}
+	return validateResponse(resp, http.StatusNoContent)
+}
+
+// GetArchive returns a tar archive of a file or directory in the sandbox.
+// The entries are prefixed with the base name of the path.
+// The caller must close the returned reader.
+func (c *Client) GetArchive(ctx context.Context, space, name, path string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.archiveURL(space, name, path), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		defer resp.Body.Close()
+		return nil, notFoundError(resp)
+	}
+	if err := validateResponse(resp, http.StatusOK); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// UploadDir copies the contents of a local directory into a directory in
+// the sandbox. For example, UploadDir(ctx, space, name, "./repo", "/work")
+// creates /work/README.md from ./repo/README.md.
+func (c *Client) UploadDir(ctx context.Context, space, name, localDir, remoteDir string) error {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(writeTar(pw, localDir))
+	}()
+	defer pr.Close()
+	return c.PutArchive(ctx, space, name, remoteDir, pr)
+}
+
+// DownloadDir copies the contents of a directory in the sandbox into a
+// local directory, creating it if needed. For example,
+// DownloadDir(ctx, space, name, "/work/results", "./results") creates
+// ./results/a.txt from /work/results/a.txt.
+func (c *Client) DownloadDir(ctx context.Context, space, name, remoteDir, localDir string) error {
+	archive, err := c.GetArchive(ctx, space, name, remoteDir)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+	return extractTar(archive, localDir, 1)
+}
+
+func (c *Client) archiveURL(space, name, path string) string {
+	return fmt.Sprintf("%s/spaces/%s/sandboxes/%s/archive?%s", c.BaseURL, space, name, url.Values{"path": {path}}.Encode())
+}
+
+// writeTar writes the contents of dir as a tar archive with paths relative
+// to dir.
+func writeTar(w io.Writer, dir string) error {
+	tw := tar.NewWriter(w)
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		var link string
+		if info.Mode()&fs.ModeSymlink != 0 {
+			if link, err = os.Readlink(p); err != nil {
+				return err
+			}
+		}
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("archiving %q: %w", dir, err)
+	}
+	return tw.Close()
+}
+
+// extractTar extracts a tar archive into dir after removing the given number
+// of leading path elements from each entry. Entries that would be written
+// outside of dir and symlinks that point outside of dir are rejected.
+func extractTar(r io.Reader, dir string, stripComponents int) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading archive: %w", err)
+		}
+
+		parts := strings.Split(strings.Trim(hdr.Name, "/"), "/")
+		if len(parts) <= stripComponents {
+			continue
+		}
+		rel := filepath.FromSlash(strings.Join(parts[stripComponents:], "/"))
+		if !filepath.IsLocal(rel) {
+			return fmt.Errorf("archive entry %q: path escapes the destination", hdr.Name)
+		}
+		target := filepath.Join(dir, rel)
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+				return err
+			}
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, hdr.FileInfo().Mode().Perm())
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(f, tr)
+			f.Close()
+			if err != nil {
+				return fmt.Errorf("extracting %q: %w", hdr.Name, err)
+			}
+		case tar.TypeSymlink:
+			if filepath.IsAbs(hdr.Linkname) || !filepath.IsLocal(filepath.Join(filepath.Dir(rel), hdr.Linkname)) {
+				return fmt.Errorf("archive entry %q: symlink target %q escapes the destination", hdr.Name, hdr.Linkname)
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+				return err
+			}
+			os.Remove(target)
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return err
+			}
+		default:
+			// Skip other types (hardlinks, devices, ...).
+		}
+	}
+}
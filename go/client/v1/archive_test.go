@@ -0,0 +1,74 @@
+package v1
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTarRoundTrip(t *testing.T) {
+	parent := t.TempDir()
+	src := filepath.Join(parent, "top")
+	require.NoError(t, os.MkdirAll(filepath.Join(src, "sub", "empty"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "a.txt"), []byte("a"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "sub", "run.sh"), []byte("#!/bin/sh"), 0o755))
+	require.NoError(t, os.Symlink("../a.txt", filepath.Join(src, "sub", "link")))
+
+	var buf bytes.Buffer
+	require.NoError(t, writeTar(&buf, src))
+	dst := filepath.Join(t.TempDir(), "out")
+	require.NoError(t, extractTar(&buf, dst, 0))
+	assertTree(t, dst)
+
+	// The Docker archive endpoint prefixes entries with the base name of
+	// the directory.
+	buf.Reset()
+	require.NoError(t, writeTar(&buf, parent))
+	dst = filepath.Join(t.TempDir(), "out")
+	require.NoError(t, extractTar(&buf, dst, 1))
+	assertTree(t, dst)
+}
+
+func assertTree(t *testing.T, dir string) {
+	t.Helper()
+	content, err := os.ReadFile(filepath.Join(dir, "a.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "a", string(content))
+
+	info, err := os.Stat(filepath.Join(dir, "sub", "run.sh"))
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o755), info.Mode().Perm())
+
+	target, err := os.Readlink(filepath.Join(dir, "sub", "link"))
+	require.NoError(t, err)
+	require.Equal(t, "../a.txt", target)
+
+	require.DirExists(t, filepath.Join(dir, "sub", "empty"))
+}
+
+func TestExtractTarRejectsEscapes(t *testing.T) {
+	cases := []struct {
+		name string
+		hdr  tar.Header
+	}{
+		{name: "parent path", hdr: tar.Header{Name: "../evil", Typeflag: tar.TypeReg}},
+		{name: "nested parent path", hdr: tar.Header{Name: "a/../../evil", Typeflag: tar.TypeReg}},
+		{name: "absolute symlink", hdr: tar.Header{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"}},
+		{name: "relative symlink", hdr: tar.Header{Name: "a/link", Typeflag: tar.TypeSymlink, Linkname: "../../etc"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tw := tar.NewWriter(&buf)
+			require.NoError(t, tw.WriteHeader(&c.hdr))
+			require.NoError(t, tw.Close())
+
+			dir := t.TempDir()
+			require.Error(t, extractTar(&buf, dir, 0))
+		})
+	}
+}
@@ -6,12 +6,33 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"iter"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/gorilla/websocket"
 	v1 "github.com/substratusai/sandboxai/go/api/v1"
 )
 
 var ErrSandboxNotFound = fmt.Errorf("sandbox not found")
+var ErrSpaceNotFound = fmt.Errorf("space not found")
+var ErrSandboxNotReady = fmt.Errorf("sandbox will not become ready")
+var ErrResumeTokenExpired = fmt.Errorf("resume token expired")
+var ErrToolFailed = fmt.Errorf("tool failed")
+var ErrPathNotFound = fmt.Errorf("path not found")
+var ErrFileTooLarge = fmt.Errorf("file too large")
+var ErrProcessNotFound = fmt.Errorf("process not found")
+var ErrPortNotListening = fmt.Errorf("port not listening")
+var ErrSandboxPaused = fmt.Errorf("sandbox is paused")
+var ErrSandboxNotRunning = fmt.Errorf("sandbox is not running")
+var ErrVolumeNotFound = fmt.Errorf("volume not found")
+var ErrVolumeAlreadyExists = fmt.Errorf("volume already exists")
+var ErrVolumeInUse = fmt.Errorf("volume is mounted by a sandbox")
+var ErrSnapshotNotFound = fmt.Errorf("snapshot not found")
+var ErrSnapshotAlreadyExists = fmt.Errorf("snapshot already exists")
 
 // Client represents a client for interacting with the SandboxAI API.
 // See the OpenAPI spec for API details.
@@ -19,6 +40,8 @@ type Client struct {
 	// BaseURL to send requests to, for example "http://localhost:5000/v1".
 	BaseURL string
 	httpc   *http.Client
+
+	nonZeroExitError bool
 }
 
 type ClientOption func(*Client)
@@ -29,6 +52,27 @@ func WithHTTPClient(httpClient *http.Client) ClientOption {
 	}
 }
 
+// WithNonZeroExitError makes shell commands that exit with a non-zero code
+// return a *CommandFailedError.
+func WithNonZeroExitError() ClientOption {
+	return func(c *Client) {
+		c.nonZeroExitError = true
+	}
+}
+
+// CommandFailedError is returned when a shell command exits with a non-zero
+// code and the client was created with WithNonZeroExitError.
+type CommandFailedError struct {
+	Command  string
+	ExitCode int
+	// Result contains the output of the command.
+	Result *v1.RunShellCommandResult
+}
+
+func (e *CommandFailedError) Error() string {
+	return fmt.Sprintf("command %q exited with code %d", e.Command, e.ExitCode)
+}
+
 func NewClient(baseURL string, opts ...ClientOption) *Client {
 	c := &Client{
 		BaseURL: baseURL,
@@ -61,6 +105,87 @@ func (c *Client) CheckHealth(ctx context.Context) error {
 	return nil
 }
 
+func (c *Client) CreateSpace(ctx context.Context, request *v1.CreateSpaceRequest) (*v1.Space, error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	url := fmt.Sprintf("%s/spaces", c.BaseURL)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := validateResponse(resp, http.StatusCreated); err != nil {
+		return nil, err
+	}
+
+	var response v1.Space
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+func (c *Client) GetSpace(ctx context.Context, space string) (*v1.Space, error) {
+	url := fmt.Sprintf("%s/spaces/%s", c.BaseURL, space)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrSpaceNotFound
+	}
+	if err := validateResponse(resp, http.StatusOK); err != nil {
+		return nil, err
+	}
+
+	var response v1.Space
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// DeleteSpace deletes a space and all of the sandboxes in it.
+func (c *Client) DeleteSpace(ctx context.Context, space string) error {
+	url := fmt.Sprintf("%s/spaces/%s", c.BaseURL, space)
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.httpc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrSpaceNotFound
+	}
+	if err := validateResponse(resp, http.StatusNoContent); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// CreateSandbox creates a sandbox and returns it without waiting for it to
+// start up (the returned sandbox will typically be in the Pending phase).
+// Use WaitForReady to wait for the sandbox before using it.
 func (c *Client) CreateSandbox(ctx context.Context, space string, request *v1.CreateSandboxRequest) (*v1.Sandbox, error) {
 	body, err := json.Marshal(request)
 	if err != nil {
@@ -79,7 +204,7 @@ func (c *Client) CreateSandbox(ctx context.Context, space string, request *v1.Cr
 		return nil, err
 	}
 	defer resp.Body.Close()
-	if err := validateResponse(resp, http.StatusCreated); err != nil {
+	if err := validateResponse(resp, http.StatusAccepted); err != nil {
 		return nil, err
 	}
 
@@ -91,7 +216,52 @@ func (c *Client) CreateSandbox(ctx context.Context, space string, request *v1.Cr
 }
 
 func (c *Client) GetSandbox(ctx context.Context, space, name string) (*v1.Sandbox, error) {
+	return c.getSandbox(ctx, space, name, nil)
+}
+
+// WaitForReady blocks until the sandbox is Ready. If the sandbox will not
+// become ready on its own (it is Failed, Stopped, Paused or Expired) an error
+// wrapping ErrSandboxNotReady is returned along with the sandbox.
+func (c *Client) WaitForReady(ctx context.Context, space, name string) (*v1.Sandbox, error) {
+	params := &v1.GetSandboxParams{
+		Wait:    v1.GetSandboxParamsWaitReady,
+		Timeout: "30s",
+	}
+	for {
+		sbx, err := c.getSandbox(ctx, space, name, params)
+		if err != nil {
+			return nil, err
+		}
+		if sbx.Status == nil || sbx.Status.Phase == nil {
+			// Older servers only return sandboxes once they are ready.
+			return sbx, nil
+		}
+		switch *sbx.Status.Phase {
+		case v1.SandboxPhaseReady:
+			return sbx, nil
+		case v1.SandboxPhaseFailed, v1.SandboxPhaseStopped, v1.SandboxPhasePaused, v1.SandboxPhaseExpired:
+			return sbx, fmt.Errorf("%w: sandbox %q is %s: %s: %s", ErrSandboxNotReady, name, *sbx.Status.Phase, sbx.Status.Reason, sbx.Status.Message)
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (c *Client) getSandbox(ctx context.Context, space, name string, params *v1.GetSandboxParams) (*v1.Sandbox, error) {
+	query := url.Values{}
+	if params != nil {
+		if params.Wait != "" {
+			query.Set("wait", string(params.Wait))
+		}
+		if params.Timeout != "" {
+			query.Set("timeout", params.Timeout)
+		}
+	}
 	url := fmt.Sprintf("%s/spaces/%s/sandboxes/%s", c.BaseURL, space, name)
+	if len(query) > 0 {
+		url += "?" + query.Encode()
+	}
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, err
@@ -116,6 +286,136 @@ func (c *Client) GetSandbox(ctx context.Context, space, name string) (*v1.Sandbo
 	return &response, nil
 }
 
+// ListSandboxes returns a page of sandboxes in a space. Pass the returned
+// NextPageToken as params.PageToken to retrieve the next page.
+func (c *Client) ListSandboxes(ctx context.Context, space string, params *v1.ListSandboxesParams) (*v1.SandboxList, error) {
+	query := url.Values{}
+	if params != nil {
+		if params.LabelSelector != "" {
+			query.Set("label_selector", params.LabelSelector)
+		}
+		if params.Limit != 0 {
+			query.Set("limit", strconv.Itoa(params.Limit))
+		}
+		if params.PageToken != "" {
+			query.Set("page_token", params.PageToken)
+		}
+	}
+	url := fmt.Sprintf("%s/spaces/%s/sandboxes", c.BaseURL, space)
+	if len(query) > 0 {
+		url += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := validateResponse(resp, http.StatusOK); err != nil {
+		return nil, err
+	}
+
+	var response v1.SandboxList
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// WatchSandboxes returns an iterator over sandbox lifecycle events in a space.
+// The watch is resumed automatically if an established stream is interrupted.
+// Iteration stops when ctx is done or after an error is yielded. An error
+// wrapping ErrResumeTokenExpired is yielded if the watch can not be resumed,
+// in which case sandboxes should be listed again before starting a new watch.
+func (c *Client) WatchSandboxes(ctx context.Context, space string, params *v1.WatchSandboxesParams) iter.Seq2[*v1.SandboxEvent, error] {
+	return func(yield func(*v1.SandboxEvent, error) bool) {
+		var p v1.WatchSandboxesParams
+		if params != nil {
+			p = *params
+		}
+		for {
+			stopped, err := c.watchSandboxes(ctx, space, &p, yield)
+			if stopped || ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			// The stream ended, reconnect after a short delay.
+			select {
+			case <-time.After(time.Second):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// watchSandboxes streams events until the stream ends. It returns true if
+// iteration was stopped by yield. The resume token in params is updated as
+// events are received. A nil error is returned if the stream can be resumed.
+func (c *Client) watchSandboxes(ctx context.Context, space string, params *v1.WatchSandboxesParams, yield func(*v1.SandboxEvent, error) bool) (bool, error) {
+	query := url.Values{}
+	if params.LabelSelector != "" {
+		query.Set("label_selector", params.LabelSelector)
+	}
+	if params.ResumeToken != "" {
+		query.Set("resume_token", params.ResumeToken)
+	}
+	url := fmt.Sprintf("%s/spaces/%s/sandboxes:watch", c.BaseURL, space)
+	if len(query) > 0 {
+		url += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := c.httpc.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusGone {
+		plainBody, _ := io.ReadAll(resp.Body)
+		return false, fmt.Errorf("%w: %s", ErrResumeTokenExpired, string(plainBody))
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return false, ErrSpaceNotFound
+	}
+	if err := validateResponse(resp, http.StatusOK); err != nil {
+		return false, err
+	}
+
+	var (
+		stopped   bool
+		decodeErr error
+	)
+	// Read errors (i.e. dropped connections) are resumable so they are ignored.
+	_ = readSSE(resp.Body, func(sse sseEvent) bool {
+		var ev v1.SandboxEvent
+		if err := json.Unmarshal([]byte(sse.data), &ev); err != nil {
+			decodeErr = fmt.Errorf("decoding event: %w", err)
+			return false
+		}
+		if sse.id != "" {
+			params.ResumeToken = sse.id
+		}
+		if !yield(&ev, nil) {
+			stopped = true
+			return false
+		}
+		return true
+	})
+	return stopped, decodeErr
+}
+
 func (c *Client) DeleteSandbox(ctx context.Context, space, name string) error {
 	url := fmt.Sprintf("%s/spaces/%s/sandboxes/%s", c.BaseURL, space, name)
 	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, url, nil)
@@ -135,6 +435,33 @@ func (c *Client) DeleteSandbox(ctx context.Context, space, name string) error {
 	return nil
 }
 
+// PauseSandbox freezes the processes in a sandbox so that it stops using CPU.
+// Tool calls fail with ErrSandboxPaused until it is resumed.
+// ErrSandboxNotRunning is returned if the sandbox is not running.
+func (c *Client) PauseSandbox(ctx context.Context, space, name string) (*v1.Sandbox, error) {
+	var sbx v1.Sandbox
+	url := fmt.Sprintf("%s/spaces/%s/sandboxes/%s:pause", c.BaseURL, space, name)
+	if err := c.callJSONStatus(ctx, http.MethodPost, url, nil, http.StatusOK, &sbx, map[int]error{
+		http.StatusConflict: ErrSandboxNotRunning,
+	}); err != nil {
+		return nil, err
+	}
+	return &sbx, nil
+}
+
+// ResumeSandbox unfreezes the processes in a paused sandbox.
+// ErrSandboxNotRunning is returned if the sandbox is not running.
+func (c *Client) ResumeSandbox(ctx context.Context, space, name string) (*v1.Sandbox, error) {
+	var sbx v1.Sandbox
+	url := fmt.Sprintf("%s/spaces/%s/sandboxes/%s:resume", c.BaseURL, space, name)
+	if err := c.callJSONStatus(ctx, http.MethodPost, url, nil, http.StatusOK, &sbx, map[int]error{
+		http.StatusConflict: ErrSandboxNotRunning,
+	}); err != nil {
+		return nil, err
+	}
+	return &sbx, nil
+}
+
 func (c *Client) RunIPythonCell(ctx context.Context, space, name string, request *v1.RunIPythonCellRequest) (*v1.RunIPythonCellResult, error) {
 	body, err := json.Marshal(request)
 	if err != nil {
@@ -164,6 +491,9 @@ func (c *Client) RunIPythonCell(ctx context.Context, space, name string, request
 }
 
 func (c *Client) RunShellCommand(ctx context.Context, space, name string, request *v1.RunShellCommandRequest) (*v1.RunShellCommandResult, error) {
+	if err := validateShellCommandRequest(request); err != nil {
+		return nil, err
+	}
 	body, err := json.Marshal(request)
 	if err != nil {
 		return nil, err
@@ -188,12 +518,369 @@ func (c *Client) RunShellCommand(ctx context.Context, space, name string, reques
 	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
 		return nil, err
 	}
+	if err := c.checkExitCode(request, &response); err != nil {
+		return nil, err
+	}
 	return &response, nil
 }
 
+func validateShellCommandRequest(request *v1.RunShellCommandRequest) error {
+	if (request.Command == "") == (len(request.Argv) == 0) {
+		return fmt.Errorf("exactly one of command or argv must be set")
+	}
+	return nil
+}
+
+func (c *Client) checkExitCode(request *v1.RunShellCommandRequest, result *v1.RunShellCommandResult) error {
+	if c.nonZeroExitError && result.ExitCode != 0 {
+		command := request.Command
+		if command == "" {
+			command = shellJoin(request.Argv)
+		}
+		return &CommandFailedError{Command: command, ExitCode: result.ExitCode, Result: result}
+	}
+	return nil
+}
+
+// shellJoin joins argv into a command that a shell would split back into
+// the same arguments.
+func shellJoin(argv []string) string {
+	quoted := make([]string, len(argv))
+	for i, arg := range argv {
+		if arg != "" && strings.IndexFunc(arg, needsQuote) < 0 {
+			quoted[i] = arg
+			continue
+		}
+		quoted[i] = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+	}
+	return strings.Join(quoted, " ")
+}
+
+func needsQuote(r rune) bool {
+	return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./=:,+@%", r))
+}
+
+// RunIPythonCellStream runs a cell and yields its output as it is produced.
+// The last event yielded is a Result event. If the cell could not be run,
+// an error wrapping ErrToolFailed is yielded instead.
+func (c *Client) RunIPythonCellStream(ctx context.Context, space, name string, request *v1.RunIPythonCellRequest) iter.Seq2[*v1.IPythonCellStreamEvent, error] {
+	return streamTool(ctx, c, space, name, "run_ipython_cell_stream", request, func(*v1.IPythonCellStreamEvent) error { return nil })
+}
+
+// RunShellCommandStream runs a command and yields its output as it is produced.
+// The last event yielded is a Result event. If the command could not be run,
+// an error wrapping ErrToolFailed is yielded instead. If the client was created
+// with WithNonZeroExitError, a *CommandFailedError is yielded in place of the
+// Result event for non-zero exits.
+func (c *Client) RunShellCommandStream(ctx context.Context, space, name string, request *v1.RunShellCommandRequest) iter.Seq2[*v1.ShellCommandStreamEvent, error] {
+	if err := validateShellCommandRequest(request); err != nil {
+		return func(yield func(*v1.ShellCommandStreamEvent, error) bool) {
+			yield(nil, err)
+		}
+	}
+	return streamTool(ctx, c, space, name, "run_shell_command_stream", request, func(ev *v1.ShellCommandStreamEvent) error {
+		if ev.Result == nil {
+			return fmt.Errorf("result event is missing the result")
+		}
+		return c.checkExitCode(request, ev.Result)
+	})
+}
+
+// streamTool runs a tool and yields the events from its output stream.
+// checkResult is called with the Result event before it is yielded.
+func streamTool[E any](ctx context.Context, c *Client, space, name, tool string, request any, checkResult func(*E) error) iter.Seq2[*E, error] {
+	return func(yield func(*E, error) bool) {
+		body, err := json.Marshal(request)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		url := fmt.Sprintf("%s/spaces/%s/sandboxes/%s/tools:%s", c.BaseURL, space, name, tool)
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "text/event-stream")
+
+		resp, err := c.httpc.Do(req)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		defer resp.Body.Close()
+		if err := validateResponse(resp, http.StatusOK); err != nil {
+			yield(nil, err)
+			return
+		}
+
+		var (
+			done      bool
+			stopped   bool
+			streamErr error
+		)
+		readErr := readSSE(resp.Body, func(sse sseEvent) bool {
+			// Decode the fields common to all tool events to find the end of the stream.
+			var common struct {
+				Type v1.ToolStreamEventType `json:"type"`
+				Data string                 `json:"data"`
+			}
+			if err := json.Unmarshal([]byte(sse.data), &common); err != nil {
+				streamErr = fmt.Errorf("decoding event: %w", err)
+				return false
+			}
+			if common.Type == v1.ToolStreamEventError {
+				done = true
+				streamErr = fmt.Errorf("%w: %s", ErrToolFailed, common.Data)
+				return false
+			}
+			var ev E
+			if err := json.Unmarshal([]byte(sse.data), &ev); err != nil {
+				streamErr = fmt.Errorf("decoding event: %w", err)
+				return false
+			}
+			if common.Type == v1.ToolStreamEventResult {
+				done = true
+				if err := checkResult(&ev); err != nil {
+					streamErr = err
+					return false
+				}
+			}
+			if !yield(&ev, nil) {
+				stopped = true
+				return false
+			}
+			return !done
+		})
+		switch {
+		case stopped:
+		case streamErr != nil:
+			yield(nil, streamErr)
+		case readErr != nil:
+			yield(nil, readErr)
+		case !done:
+			yield(nil, fmt.Errorf("stream ended before the result was received: %w", io.ErrUnexpectedEOF))
+		}
+	}
+}
+
+func (c *Client) WriteFile(ctx context.Context, space, name string, request *v1.WriteFileRequest) (*v1.WriteFileResult, error) {
+	var result v1.WriteFileResult
+	if err := c.callFileTool(ctx, space, name, "write_file", request, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) ReadFile(ctx context.Context, space, name string, request *v1.ReadFileRequest) (*v1.ReadFileResult, error) {
+	var result v1.ReadFileResult
+	if err := c.callFileTool(ctx, space, name, "read_file", request, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) ListDir(ctx context.Context, space, name string, request *v1.ListDirRequest) (*v1.ListDirResult, error) {
+	var result v1.ListDirResult
+	if err := c.callFileTool(ctx, space, name, "list_dir", request, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) Stat(ctx context.Context, space, name string, request *v1.StatRequest) (*v1.StatResult, error) {
+	var result v1.StatResult
+	if err := c.callFileTool(ctx, space, name, "stat", request, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) DeletePath(ctx context.Context, space, name string, request *v1.DeletePathRequest) error {
+	return c.callFileTool(ctx, space, name, "delete_path", request, nil)
+}
+
+// callFileTool calls a file API tool and decodes the result into result
+// (if not nil). ErrPathNotFound, ErrSandboxNotFound and ErrFileTooLarge are
+// returned for the corresponding errors.
+func (c *Client) callFileTool(ctx context.Context, space, name, tool string, request, result any) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	url := fmt.Sprintf("%s/spaces/%s/sandboxes/%s/tools:%s", c.BaseURL, space, name, tool)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	expectedStatus := http.StatusOK
+	if result == nil {
+		expectedStatus = http.StatusNoContent
+	}
+	switch resp.StatusCode {
+	case expectedStatus:
+	case http.StatusNotFound:
+		return notFoundError(resp)
+	case http.StatusRequestEntityTooLarge:
+		var apiErr v1.Error
+		json.NewDecoder(resp.Body).Decode(&apiErr)
+		return fmt.Errorf("%w: %s", ErrFileTooLarge, apiErr.Message)
+	default:
+		return validateResponse(resp, expectedStatus)
+	}
+
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// callJSON sends the request (if not nil) as JSON and decodes the response
+// into result. 404 responses are converted with notFoundError.
+func (c *Client) callJSON(ctx context.Context, method, url string, request any, expectedStatus int, result any) error {
+	return c.callJSONStatus(ctx, method, url, request, expectedStatus, result, nil)
+}
+
+// callJSONStatus is like callJSON but converts responses with the statuses
+// in statusErrs to those errors (with the message of the response). Nothing
+// is decoded if result is nil.
+func (c *Client) callJSONStatus(ctx context.Context, method, url string, request any, expectedStatus int, result any, statusErrs map[int]error) error {
+	var body io.Reader
+	if request != nil {
+		data, err := json.Marshal(request)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return err
+	}
+	if request != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err, ok := statusErrs[resp.StatusCode]; ok {
+		var apiErr v1.Error
+		json.NewDecoder(resp.Body).Decode(&apiErr)
+		return fmt.Errorf("%w: %s", err, apiErr.Message)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return notFoundError(resp)
+	}
+	if err := validateResponse(resp, expectedStatus); err != nil {
+		return err
+	}
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// notFoundError distinguishes a missing path or process from a missing
+// sandbox in a 404 response.
+func notFoundError(resp *http.Response) error {
+	var apiErr v1.Error
+	json.NewDecoder(resp.Body).Decode(&apiErr)
+	switch apiErr.Reason {
+	case "PathNotFound":
+		return fmt.Errorf("%w: %s", ErrPathNotFound, apiErr.Message)
+	case "ProcessNotFound":
+		return fmt.Errorf("%w: %s", ErrProcessNotFound, apiErr.Message)
+	}
+	return ErrSandboxNotFound
+}
+
+// ListEgressConnections returns the most recent connections that the sandbox
+// made through the egress proxy, oldest first.
+func (c *Client) ListEgressConnections(ctx context.Context, space, name string) (*v1.EgressConnectionList, error) {
+	var list v1.EgressConnectionList
+	url := fmt.Sprintf("%s/spaces/%s/sandboxes/%s/egress", c.BaseURL, space, name)
+	if err := c.callJSON(ctx, http.MethodGet, url, nil, http.StatusOK, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// dialWebSocket opens a WebSocket connection to an http(s) URL.
+func (c *Client) dialWebSocket(ctx context.Context, rawURL string, query url.Values) (*websocket.Conn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http":
+		u.Scheme = "ws"
+	case "https":
+		u.Scheme = "wss"
+	}
+	u.RawQuery = query.Encode()
+
+	dialer := *websocket.DefaultDialer
+	if t, ok := c.httpc.Transport.(*http.Transport); ok && t.TLSClientConfig != nil {
+		dialer.TLSClientConfig = t.TLSClientConfig.Clone()
+	}
+	conn, resp, err := dialer.DialContext(ctx, u.String(), nil)
+	if err != nil {
+		if resp == nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		switch resp.StatusCode {
+		case http.StatusNotFound:
+			return nil, ErrSandboxNotFound
+		case http.StatusConflict:
+			var apiErr v1.Error
+			json.NewDecoder(resp.Body).Decode(&apiErr)
+			switch apiErr.Reason {
+			case "SandboxPaused":
+				return nil, fmt.Errorf("%w: %s", ErrSandboxPaused, apiErr.Message)
+			case "SandboxNotRunning":
+				return nil, fmt.Errorf("%w: %s", ErrSandboxNotRunning, apiErr.Message)
+			}
+			return nil, ErrSandboxNotRunning
+		case http.StatusBadGateway:
+			var apiErr v1.Error
+			json.NewDecoder(resp.Body).Decode(&apiErr)
+			if apiErr.Reason == "PortNotListening" {
+				return nil, fmt.Errorf("%w: %s", ErrPortNotListening, apiErr.Message)
+			}
+			return nil, fmt.Errorf("expected status %d, got %d: %s", http.StatusSwitchingProtocols, resp.StatusCode, apiErr.Message)
+		}
+		return nil, validateResponse(resp, http.StatusSwitchingProtocols)
+	}
+	return conn, nil
+}
+
 func validateResponse(resp *http.Response, expectedStatus int) error {
 	if resp.StatusCode != expectedStatus {
 		plainBody, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode == http.StatusConflict {
+			var apiErr v1.Error
+			if json.Unmarshal(plainBody, &apiErr) == nil {
+				switch apiErr.Reason {
+				case "SandboxPaused":
+					return fmt.Errorf("%w: %s", ErrSandboxPaused, apiErr.Message)
+				case "SandboxNotRunning":
+					return fmt.Errorf("%w: %s", ErrSandboxNotRunning, apiErr.Message)
+				}
+			}
+		}
 		return fmt.Errorf("expected status %d, got %d: %s", expectedStatus, resp.StatusCode, string(plainBody))
 	}
 	return nil
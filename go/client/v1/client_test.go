@@ -0,0 +1,248 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	v1 "github.com/substratusai/sandboxai/go/api/v1"
+)
+
+func TestRunShellCommandStream(t *testing.T) {
+	cases := []struct {
+		name      string
+		body      string
+		expOutput string
+		expResult bool
+		expErr    error
+	}{
+		{
+			name: "result",
+			body: `data: {"type":"Output","stream":"output","data":"hello "}` + "\n\n" +
+				`data: {"type":"Output","stream":"output","data":"world"}` + "\n\n" +
+				`data: {"type":"Result","result":{}}` + "\n\n",
+			expOutput: "hello world",
+			expResult: true,
+		},
+		{
+			name: "error",
+			body: `data: {"type":"Output","stream":"output","data":"partial"}` + "\n\n" +
+				`data: {"type":"Error","data":"boom"}` + "\n\n",
+			expOutput: "partial",
+			expErr:    ErrToolFailed,
+		},
+		{
+			name:      "truncated",
+			body:      `data: {"type":"Output","stream":"output","data":"partial"}` + "\n\n",
+			expOutput: "partial",
+			expErr:    io.ErrUnexpectedEOF,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				require.Equal(t, "/spaces/default/sandboxes/box/tools:run_shell_command_stream", r.URL.Path)
+				w.Header().Set("Content-Type", "text/event-stream")
+				fmt.Fprint(w, tc.body)
+			}))
+			defer srv.Close()
+
+			c := NewClient(srv.URL)
+			var (
+				output    string
+				gotResult bool
+				gotErr    error
+			)
+			for ev, err := range c.RunShellCommandStream(context.Background(), "default", "box", &v1.RunShellCommandRequest{Command: "echo"}) {
+				if err != nil {
+					gotErr = err
+					break
+				}
+				switch ev.Type {
+				case v1.ToolStreamEventOutput:
+					output += ev.Data
+				case v1.ToolStreamEventResult:
+					gotResult = true
+				}
+			}
+			require.Equal(t, tc.expOutput, output)
+			require.Equal(t, tc.expResult, gotResult)
+			if tc.expErr != nil {
+				require.ErrorIs(t, gotErr, tc.expErr)
+			} else {
+				require.NoError(t, gotErr)
+			}
+		})
+	}
+}
+
+func TestRunShellCommandNonZeroExit(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/spaces/default/sandboxes/box/tools:run_shell_command":
+			fmt.Fprint(w, `{"output":"oops\n","exit_code":3}`)
+		case "/spaces/default/sandboxes/box/tools:run_shell_command_stream":
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, `data: {"type":"Result","result":{"exit_code":3}}`+"\n\n")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+	req := &v1.RunShellCommandRequest{Command: "exit 3"}
+
+	result, err := NewClient(srv.URL).RunShellCommand(ctx, "default", "box", req)
+	require.NoError(t, err, "non-zero exits are not errors by default")
+	require.Equal(t, 3, result.ExitCode)
+
+	c := NewClient(srv.URL, WithNonZeroExitError())
+	_, err = c.RunShellCommand(ctx, "default", "box", req)
+	var cmdErr *CommandFailedError
+	require.ErrorAs(t, err, &cmdErr)
+	require.Equal(t, 3, cmdErr.ExitCode)
+	require.Equal(t, "exit 3", cmdErr.Command)
+	require.Equal(t, "oops\n", cmdErr.Result.Output)
+
+	var streamErr error
+	for _, err := range c.RunShellCommandStream(ctx, "default", "box", req) {
+		streamErr = err
+	}
+	require.ErrorAs(t, streamErr, &cmdErr)
+	require.Equal(t, 3, cmdErr.ExitCode)
+
+	_, err = c.RunShellCommand(ctx, "default", "box", &v1.RunShellCommandRequest{Argv: []string{"sh", "-c", "echo 'hi'; exit 3"}})
+	require.ErrorAs(t, err, &cmdErr)
+	require.Equal(t, `sh -c 'echo '\''hi'\''; exit 3'`, cmdErr.Command, "argv should be quoted")
+}
+
+func Test_shellJoin(t *testing.T) {
+	require.Equal(t, "ls -la /tmp", shellJoin([]string{"ls", "-la", "/tmp"}))
+	require.Equal(t, `echo '' 'a b' '$HOME'`, shellJoin([]string{"echo", "", "a b", "$HOME"}))
+}
+
+func TestFileToolErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/spaces/default/sandboxes/box/tools:read_file":
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message":"stat \"/missing\": path not found","reason":"PathNotFound"}`)
+		case "/spaces/default/sandboxes/box/tools:write_file":
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			fmt.Fprint(w, `{"message":"file too large"}`)
+		case "/spaces/default/sandboxes/box/tools:delete_path":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message":"sandbox not found"}`)
+		}
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+	c := NewClient(srv.URL)
+
+	_, err := c.ReadFile(ctx, "default", "box", &v1.ReadFileRequest{Path: "/missing"})
+	require.ErrorIs(t, err, ErrPathNotFound)
+
+	_, err = c.WriteFile(ctx, "default", "box", &v1.WriteFileRequest{Path: "/big"})
+	require.ErrorIs(t, err, ErrFileTooLarge)
+
+	_, err = c.ListDir(ctx, "default", "other", &v1.ListDirRequest{Path: "/"})
+	require.ErrorIs(t, err, ErrSandboxNotFound)
+
+	require.NoError(t, c.DeletePath(ctx, "default", "box", &v1.DeletePathRequest{Path: "/tmp/x"}))
+}
+
+func TestProcesses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/spaces/default/sandboxes/box/processes/p1/output":
+			require.Equal(t, "stderr", r.URL.Query().Get("stream"))
+			require.Equal(t, "5", r.URL.Query().Get("offset"))
+			fmt.Fprint(w, `{"stream":"stderr","offset":5,"next_offset":8,"data":"abc","eof":true}`)
+		case "/spaces/default/sandboxes/box/processes/p2:kill":
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message":"process not found: p2","reason":"ProcessNotFound"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message":"sandbox not found"}`)
+		}
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+	c := NewClient(srv.URL)
+
+	output, err := c.GetProcessOutput(ctx, "default", "box", "p1", &v1.GetProcessOutputParams{
+		Stream: v1.GetProcessOutputParamsStreamStderr,
+		Offset: 5,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "abc", output.Data)
+	require.EqualValues(t, 8, output.NextOffset)
+	require.True(t, output.EOF)
+
+	_, err = c.KillProcess(ctx, "default", "box", "p2")
+	require.ErrorIs(t, err, ErrProcessNotFound)
+
+	_, err = c.GetProcess(ctx, "default", "other", "p1")
+	require.ErrorIs(t, err, ErrSandboxNotFound)
+
+	_, err = c.CreateProcess(ctx, "default", "box", &v1.CreateProcessRequest{})
+	require.Error(t, err, "Either command or argv is required")
+}
+
+func TestPausedSandbox(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/spaces/default/sandboxes/box:pause":
+			fmt.Fprint(w, `{"name":"box","spec":{},"status":{"phase":"Paused"}}`)
+		case "/spaces/default/sandboxes/stopped:resume":
+			w.WriteHeader(http.StatusConflict)
+			fmt.Fprint(w, `{"message":"sandbox is not running"}`)
+		case "/spaces/default/sandboxes/pending/tools:run_shell_command":
+			w.WriteHeader(http.StatusConflict)
+			fmt.Fprint(w, `{"message":"sandbox \"pending\" is Pending","reason":"SandboxNotRunning"}`)
+		default:
+			w.WriteHeader(http.StatusConflict)
+			fmt.Fprint(w, `{"message":"sandbox is paused","reason":"SandboxPaused"}`)
+		}
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+	c := NewClient(srv.URL)
+
+	sbx, err := c.PauseSandbox(ctx, "default", "box")
+	require.NoError(t, err)
+	require.Equal(t, v1.SandboxPhasePaused, *sbx.Status.Phase)
+
+	_, err = c.ResumeSandbox(ctx, "default", "stopped")
+	require.ErrorIs(t, err, ErrSandboxNotRunning)
+
+	_, err = c.RunShellCommand(ctx, "default", "box", &v1.RunShellCommandRequest{Command: "true"})
+	require.ErrorIs(t, err, ErrSandboxPaused)
+	_, err = c.ReadFile(ctx, "default", "box", &v1.ReadFileRequest{Path: "/etc/hosts"})
+	require.ErrorIs(t, err, ErrSandboxPaused)
+	_, err = c.RunShellCommand(ctx, "default", "pending", &v1.RunShellCommandRequest{Command: "true"})
+	require.ErrorIs(t, err, ErrSandboxNotRunning)
+}
+
+func TestWaitForReadyPaused(t *testing.T) {
+	var gets int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gets++
+		// Paused sandboxes are returned right away by ?wait=Ready.
+		fmt.Fprint(w, `{"name":"box","spec":{},"status":{"phase":"Paused"}}`)
+	}))
+	defer srv.Close()
+	c := NewClient(srv.URL)
+
+	sbx, err := c.WaitForReady(context.Background(), "default", "box")
+	require.ErrorIs(t, err, ErrSandboxNotReady)
+	require.Equal(t, v1.SandboxPhasePaused, *sbx.Status.Phase)
+	require.Equal(t, 1, gets, "Paused sandboxes are not polled")
+}
@@ -0,0 +1,142 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+
+	"github.com/substratusai/sandboxai/go/internal/tunnel"
+)
+
+// PortConn is a TCP connection to a port in a sandbox.
+type PortConn interface {
+	net.Conn
+	// CloseWrite signals the end of the data sent to the port.
+	CloseWrite() error
+}
+
+// DialPort opens a TCP connection to a port on the loopback interface of the
+// sandbox, tunnelled over a WebSocket. ErrPortNotListening is returned if
+// nothing is listening on the port. The caller must close the connection.
+func (c *Client) DialPort(ctx context.Context, space, name string, port int) (PortConn, error) {
+	ws, err := c.dialWebSocket(ctx, fmt.Sprintf("%s/spaces/%s/sandboxes/%s/ports/%d:connect", c.BaseURL, space, name, port), nil)
+	if err != nil {
+		return nil, err
+	}
+	return tunnel.NewConn(ws), nil
+}
+
+// PortForwardOption configures PortForward and ServePortForward.
+type PortForwardOption func(*portForwardOptions)
+
+type portForwardOptions struct {
+	onError func(local net.Conn, err error)
+}
+
+// WithPortForwardErrorHandler sets a function that is called when a
+// forwarded connection fails, for example because nothing is listening on
+// the remote port. The local connection has been closed. By default the
+// errors are logged with the standard logger.
+func WithPortForwardErrorHandler(fn func(local net.Conn, err error)) PortForwardOption {
+	return func(o *portForwardOptions) {
+		o.onError = fn
+	}
+}
+
+// PortForward listens on localAddr (for example "127.0.0.1:5432") and
+// forwards every accepted connection to remotePort of the sandbox, like
+// kubectl port-forward. It blocks until ctx is done and then returns nil.
+func (c *Client) PortForward(ctx context.Context, space, name string, remotePort int, localAddr string, opts ...PortForwardOption) error {
+	if _, err := c.GetSandbox(ctx, space, name); err != nil {
+		return err
+	}
+	var lc net.ListenConfig
+	ln, err := lc.Listen(ctx, "tcp", localAddr)
+	if err != nil {
+		return err
+	}
+	return c.ServePortForward(ctx, space, name, remotePort, ln, opts...)
+}
+
+// ServePortForward is like PortForward but accepts connections from ln,
+// which is closed when it returns. This is useful to listen on a port
+// chosen by the system ("127.0.0.1:0").
+//
+// The connections are multiplexed over a single WebSocket. Connections that
+// cannot be forwarded, for example because nothing is listening on
+// remotePort yet, are closed and reported to the error handler (see
+// WithPortForwardErrorHandler). Open connections are closed when ctx is
+// done. An error is returned if the WebSocket fails, for example because
+// the sandbox was deleted.
+func (c *Client) ServePortForward(ctx context.Context, space, name string, remotePort int, ln net.Listener, opts ...PortForwardOption) error {
+	defer ln.Close()
+	o := portForwardOptions{
+		onError: func(local net.Conn, err error) {
+			log.Printf("Forwarding connection from %s to port %d: %v", local.RemoteAddr(), remotePort, err)
+		},
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	ws, err := c.dialWebSocket(ctx, fmt.Sprintf("%s/spaces/%s/sandboxes/%s/ports/%d:forward", c.BaseURL, space, name, remotePort), nil)
+	if err != nil {
+		return err
+	}
+	sess := tunnel.NewClientSession(ws)
+	defer sess.Close()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(ctx, func() { ln.Close() })
+	defer stop()
+	go func() {
+		select {
+		case <-sess.Done():
+			ln.Close()
+		case <-ctx.Done():
+		}
+	}()
+
+	for {
+		local, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			if sessErr := sess.Err(); sessErr != nil {
+				return sessErr
+			}
+			return err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := forwardConn(ctx, sess, local); err != nil {
+				o.onError(local, err)
+			}
+		}()
+	}
+}
+
+func forwardConn(ctx context.Context, sess *tunnel.Session, local net.Conn) error {
+	remote, err := sess.Open()
+	if err != nil {
+		local.Close()
+		return err
+	}
+	stop := context.AfterFunc(ctx, func() {
+		local.Close()
+		remote.Close()
+	})
+	defer stop()
+
+	if err := tunnel.Relay(tunnel.AsHalfCloser(local), remote); err != nil && ctx.Err() == nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/substratusai/sandboxai/go/internal/tunnel"
+)
+
+func TestPortForward(t *testing.T) {
+	// Acts like sandboxaid tunnelling to a server on port 5432 that
+	// upper-cases its input once the input has ended. Nothing listens on
+	// port 9.
+	var upgrader websocket.Upgrader
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/spaces/default/sandboxes/box":
+			fmt.Fprint(w, `{"name":"box"}`)
+		case "/spaces/default/sandboxes/box/ports/5432:connect":
+			ws, err := upgrader.Upgrade(w, r, nil)
+			if !assert.NoError(t, err) {
+				return
+			}
+			upperCase(t, tunnel.NewConn(ws))
+		case "/spaces/default/sandboxes/box/ports/5432:forward", "/spaces/default/sandboxes/box/ports/9:forward":
+			ws, err := upgrader.Upgrade(w, r, nil)
+			if !assert.NoError(t, err) {
+				return
+			}
+			sess := tunnel.NewServerSession(ws)
+			for {
+				stream, err := sess.Accept()
+				if err != nil {
+					return
+				}
+				if strings.Contains(r.URL.Path, "/9:") {
+					stream.CloseWithError(errors.New("port 9: nothing is listening on the port"))
+					continue
+				}
+				go upperCase(t, stream)
+			}
+		case "/spaces/default/sandboxes/box/ports/9:connect":
+			w.WriteHeader(http.StatusBadGateway)
+			fmt.Fprint(w, `{"message":"port 9: port not listening","reason":"PortNotListening"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message":"sandbox not found"}`)
+		}
+	}))
+	defer srv.Close()
+	c := NewClient(srv.URL)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	_, err := c.DialPort(ctx, "default", "box", 9)
+	require.ErrorIs(t, err, ErrPortNotListening)
+
+	require.ErrorIs(t, c.PortForward(ctx, "default", "other", 5432, "127.0.0.1:0"), ErrSandboxNotFound)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	served := make(chan error, 1)
+	go func() { served <- c.ServePortForward(ctx, "default", "box", 5432, ln) }()
+
+	// Several connections are forwarded concurrently.
+	results := make(chan string, 3)
+	for i := range 3 {
+		go func() {
+			conn, err := net.Dial("tcp", ln.Addr().String())
+			if err != nil {
+				results <- err.Error()
+				return
+			}
+			defer conn.Close()
+			fmt.Fprintf(conn, "hello %d", i)
+			conn.(*net.TCPConn).CloseWrite()
+			output, _ := io.ReadAll(conn)
+			results <- string(output)
+		}()
+	}
+	var got []string
+	for range 3 {
+		got = append(got, <-results)
+	}
+	require.ElementsMatch(t, []string{"HELLO 0", "HELLO 1", "HELLO 2"}, got)
+
+	cancel()
+	require.NoError(t, <-served)
+
+	// Connections that cannot be forwarded are reported.
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	ln, err = net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	forwardErrs := make(chan error, 1)
+	go c.ServePortForward(ctx, "default", "box", 9, ln, WithPortForwardErrorHandler(func(_ net.Conn, err error) {
+		forwardErrs <- err
+	}))
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	require.ErrorContains(t, <-forwardErrs, "nothing is listening on the port")
+	_, err = io.ReadAll(conn)
+	require.NoError(t, err, "the local connection should be closed")
+}
+
+// upperCase writes the upper-cased input of conn back once the input has ended.
+func upperCase(t *testing.T, conn tunnel.HalfCloser) {
+	defer conn.Close()
+	input, err := io.ReadAll(conn)
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, err = conn.Write([]byte(strings.ToUpper(string(input))))
+	assert.NoError(t, err)
+	assert.NoError(t, conn.CloseWrite())
+	io.Copy(io.Discard, conn)
+}
@@ -0,0 +1,88 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	v1 "github.com/substratusai/sandboxai/go/api/v1"
+)
+
+// CreateProcess starts a background process in the sandbox. Unlike
+// RunShellCommand it returns as soon as the process has started.
+func (c *Client) CreateProcess(ctx context.Context, space, name string, request *v1.CreateProcessRequest) (*v1.Process, error) {
+	if (request.Spec.Command == "") == (len(request.Spec.Argv) == 0) {
+		return nil, fmt.Errorf("exactly one of spec.command or spec.argv must be set")
+	}
+	var process v1.Process
+	if err := c.callJSON(ctx, http.MethodPost, c.processURL(space, name, ""), request, http.StatusCreated, &process); err != nil {
+		return nil, err
+	}
+	return &process, nil
+}
+
+func (c *Client) ListProcesses(ctx context.Context, space, name string) (*v1.ProcessList, error) {
+	var list v1.ProcessList
+	if err := c.callJSON(ctx, http.MethodGet, c.processURL(space, name, ""), nil, http.StatusOK, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+func (c *Client) GetProcess(ctx context.Context, space, name, id string) (*v1.Process, error) {
+	var process v1.Process
+	if err := c.callJSON(ctx, http.MethodGet, c.processURL(space, name, "/"+id), nil, http.StatusOK, &process); err != nil {
+		return nil, err
+	}
+	return &process, nil
+}
+
+// GetProcessOutput reads the buffered output of a background process. To
+// follow the output, pass the returned NextOffset as params.Offset until EOF
+// is set.
+func (c *Client) GetProcessOutput(ctx context.Context, space, name, id string, params *v1.GetProcessOutputParams) (*v1.ProcessOutput, error) {
+	query := url.Values{}
+	if params != nil {
+		if params.Stream != "" {
+			query.Set("stream", string(params.Stream))
+		}
+		if params.Offset != 0 {
+			query.Set("offset", strconv.FormatInt(params.Offset, 10))
+		}
+	}
+	url := c.processURL(space, name, "/"+id+"/output")
+	if len(query) > 0 {
+		url += "?" + query.Encode()
+	}
+	var output v1.ProcessOutput
+	if err := c.callJSON(ctx, http.MethodGet, url, nil, http.StatusOK, &output); err != nil {
+		return nil, err
+	}
+	return &output, nil
+}
+
+// SignalProcess sends a signal (for example "SIGTERM") to a background
+// process and any processes it started.
+func (c *Client) SignalProcess(ctx context.Context, space, name, id string, request *v1.SignalProcessRequest) (*v1.Process, error) {
+	var process v1.Process
+	if err := c.callJSON(ctx, http.MethodPost, c.processURL(space, name, "/"+id+":signal"), request, http.StatusOK, &process); err != nil {
+		return nil, err
+	}
+	return &process, nil
+}
+
+// KillProcess kills a background process and any processes it started, and
+// returns it once it has exited.
+func (c *Client) KillProcess(ctx context.Context, space, name, id string) (*v1.Process, error) {
+	var process v1.Process
+	if err := c.callJSON(ctx, http.MethodPost, c.processURL(space, name, "/"+id+":kill"), nil, http.StatusOK, &process); err != nil {
+		return nil, err
+	}
+	return &process, nil
+}
+
+func (c *Client) processURL(space, name, suffix string) string {
+	return fmt.Sprintf("%s/spaces/%s/sandboxes/%s/processes%s", c.BaseURL, space, name, suffix)
+}
@@ -0,0 +1,58 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	v1 "github.com/substratusai/sandboxai/go/api/v1"
+)
+
+// SnapshotVolume stores a copy of the data in a volume. Set Snapshot in
+// CreateVolumeRequest to restore it into a new volume.
+func (c *Client) SnapshotVolume(ctx context.Context, space, volume string, request *v1.CreateSnapshotRequest) (*v1.Snapshot, error) {
+	var snapshot v1.Snapshot
+	url := fmt.Sprintf("%s/spaces/%s/volumes/%s:snapshot", c.BaseURL, space, volume)
+	if err := c.callJSONStatus(ctx, http.MethodPost, url, request, http.StatusCreated, &snapshot, map[int]error{
+		http.StatusNotFound: ErrVolumeNotFound,
+		http.StatusConflict: ErrSnapshotAlreadyExists,
+	}); err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+func (c *Client) GetSnapshot(ctx context.Context, space, name string) (*v1.Snapshot, error) {
+	var snapshot v1.Snapshot
+	url := fmt.Sprintf("%s/spaces/%s/snapshots/%s", c.BaseURL, space, name)
+	if err := c.callJSONStatus(ctx, http.MethodGet, url, nil, http.StatusOK, &snapshot, map[int]error{
+		http.StatusNotFound: ErrSnapshotNotFound,
+	}); err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// ListSnapshots lists the snapshots in a space, oldest first. If volume is
+// not empty only the snapshots of that volume are listed.
+func (c *Client) ListSnapshots(ctx context.Context, space, volume string) (*v1.SnapshotList, error) {
+	var list v1.SnapshotList
+	u := fmt.Sprintf("%s/spaces/%s/snapshots", c.BaseURL, space)
+	if volume != "" {
+		u += "?" + url.Values{"volume": {volume}}.Encode()
+	}
+	if err := c.callJSONStatus(ctx, http.MethodGet, u, nil, http.StatusOK, &list, map[int]error{
+		http.StatusNotFound: ErrSpaceNotFound,
+	}); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+func (c *Client) DeleteSnapshot(ctx context.Context, space, name string) error {
+	url := fmt.Sprintf("%s/spaces/%s/snapshots/%s", c.BaseURL, space, name)
+	return c.callJSONStatus(ctx, http.MethodDelete, url, nil, http.StatusNoContent, nil, map[int]error{
+		http.StatusNotFound: ErrSnapshotNotFound,
+	})
+}
@@ -0,0 +1,55 @@
+package v1
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// sseEvent is a single Server-Sent Event.
+type sseEvent struct {
+	id   string
+	data string
+}
+
+// maxSSELineSize limits the size of a single line in an event stream.
+const maxSSELineSize = 16 * 1024 * 1024
+
+// readSSE calls fn for each event in the stream until fn returns false
+// or the stream ends.
+func readSSE(r io.Reader, fn func(sseEvent) bool) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxSSELineSize)
+
+	var (
+		ev   sseEvent
+		data []string
+	)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			// A blank line dispatches the event.
+			if len(data) > 0 {
+				ev.data = strings.Join(data, "\n")
+				if !fn(ev) {
+					return nil
+				}
+			}
+			ev, data = sseEvent{}, nil
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			// Comment (i.e. keepalive).
+			continue
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "id":
+			ev.id = value
+		case "data":
+			data = append(data, value)
+		}
+	}
+	return scanner.Err()
+}
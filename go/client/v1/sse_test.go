@@ -0,0 +1,43 @@
+package v1
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_readSSE(t *testing.T) {
+	stream := strings.Join([]string{
+		": keepalive",
+		"",
+		"id: 1",
+		"data: {\"a\":1}",
+		"",
+		"data: line1",
+		"data: line2",
+		"",
+		"id: 3",
+		"data:no-space",
+		"",
+		"data: unterminated",
+	}, "\n")
+
+	var got []sseEvent
+	require.NoError(t, readSSE(strings.NewReader(stream), func(ev sseEvent) bool {
+		got = append(got, ev)
+		return true
+	}))
+	require.Equal(t, []sseEvent{
+		{id: "1", data: `{"a":1}`},
+		{data: "line1\nline2"},
+		{id: "3", data: "no-space"},
+	}, got)
+
+	var n int
+	require.NoError(t, readSSE(strings.NewReader(stream), func(ev sseEvent) bool {
+		n++
+		return false
+	}))
+	require.Equal(t, 1, n, "returning false should stop reading")
+}
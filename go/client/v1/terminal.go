@@ -0,0 +1,185 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	v1 "github.com/substratusai/sandboxai/go/api/v1"
+	"golang.org/x/term"
+)
+
+// Terminal is an interactive process running on a pseudo-terminal in a
+// sandbox. Use Read to read its output and Write to send input.
+type Terminal struct {
+	conn *websocket.Conn
+
+	// writeMtx serializes writes to conn (input and resizes).
+	writeMtx sync.Mutex
+
+	// msg is the remainder of the current output message.
+	msg      io.Reader
+	exited   bool
+	exitCode int
+}
+
+// DialTerminal opens a terminal in the sandbox. The caller must close it.
+func (c *Client) DialTerminal(ctx context.Context, space, name string, params *v1.AttachTerminalParams) (*Terminal, error) {
+	query := url.Values{}
+	if params != nil {
+		for _, arg := range params.Command {
+			query.Add("command", arg)
+		}
+		if params.User != "" {
+			query.Set("user", params.User)
+		}
+		if params.Rows != 0 {
+			query.Set("rows", strconv.Itoa(params.Rows))
+		}
+		if params.Cols != 0 {
+			query.Set("cols", strconv.Itoa(params.Cols))
+		}
+	}
+	conn, err := c.dialWebSocket(ctx, fmt.Sprintf("%s/spaces/%s/sandboxes/%s/terminal", c.BaseURL, space, name), query)
+	if err != nil {
+		return nil, err
+	}
+	return &Terminal{conn: conn}, nil
+}
+
+// Read reads terminal output. It returns io.EOF once the process has exited,
+// after which ExitCode reports its exit code.
+func (t *Terminal) Read(p []byte) (int, error) {
+	for {
+		if t.msg != nil {
+			n, err := t.msg.Read(p)
+			if err == io.EOF {
+				t.msg = nil
+				if n == 0 {
+					continue
+				}
+				err = nil
+			}
+			return n, err
+		}
+		if t.exited {
+			return 0, io.EOF
+		}
+
+		typ, r, err := t.conn.NextReader()
+		if err != nil {
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+				return 0, fmt.Errorf("terminal closed before the process exited: %w", io.ErrUnexpectedEOF)
+			}
+			return 0, err
+		}
+		switch typ {
+		case websocket.BinaryMessage:
+			t.msg = r
+		case websocket.TextMessage:
+			var msg v1.TerminalMessage
+			if err := json.NewDecoder(r).Decode(&msg); err != nil {
+				return 0, fmt.Errorf("decoding terminal message: %w", err)
+			}
+			if msg.Type == v1.TerminalMessageExit {
+				t.exited = true
+				t.exitCode = msg.ExitCode
+			}
+		}
+	}
+}
+
+// Write sends input to the terminal.
+func (t *Terminal) Write(p []byte) (int, error) {
+	t.writeMtx.Lock()
+	defer t.writeMtx.Unlock()
+	if err := t.conn.WriteMessage(websocket.BinaryMessage, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Resize changes the size of the terminal.
+func (t *Terminal) Resize(rows, cols int) error {
+	t.writeMtx.Lock()
+	defer t.writeMtx.Unlock()
+	return t.conn.WriteJSON(v1.TerminalMessage{Type: v1.TerminalMessageResize, Rows: rows, Cols: cols})
+}
+
+// ExitCode returns the exit code of the process once Read has returned
+// io.EOF. The second return value is false if the process has not exited.
+func (t *Terminal) ExitCode() (int, bool) {
+	return t.exitCode, t.exited
+}
+
+// Close closes the connection. The process is hung up if it is still
+// running.
+func (t *Terminal) Close() error {
+	t.writeMtx.Lock()
+	t.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	t.writeMtx.Unlock()
+	return t.conn.Close()
+}
+
+// AttachTerminal opens a terminal in the sandbox and connects it to the
+// local terminal (os.Stdin and os.Stdout) until the process exits, and
+// returns its exit code. If stdin is a terminal it is put into raw mode for
+// the duration of the call, and size changes of stdout are forwarded.
+//
+// Input is copied in the background, so one more read from stdin may be
+// consumed after the process exits.
+func (c *Client) AttachTerminal(ctx context.Context, space, name string, params *v1.AttachTerminalParams) (int, error) {
+	inFd, outFd := int(os.Stdin.Fd()), int(os.Stdout.Fd())
+
+	var p v1.AttachTerminalParams
+	if params != nil {
+		p = *params
+	}
+	outIsTerminal := term.IsTerminal(outFd)
+	if outIsTerminal && p.Rows == 0 && p.Cols == 0 {
+		if cols, rows, err := term.GetSize(outFd); err == nil {
+			p.Rows, p.Cols = rows, cols
+		}
+	}
+
+	t, err := c.DialTerminal(ctx, space, name, &p)
+	if err != nil {
+		return 0, err
+	}
+	defer t.Close()
+	stop := context.AfterFunc(ctx, func() { t.conn.Close() })
+	defer stop()
+
+	if term.IsTerminal(inFd) {
+		state, err := term.MakeRaw(inFd)
+		if err != nil {
+			return 0, fmt.Errorf("setting terminal to raw mode: %w", err)
+		}
+		defer term.Restore(inFd, state)
+	}
+	if outIsTerminal {
+		stopResize := notifyResize(func() {
+			if cols, rows, err := term.GetSize(outFd); err == nil {
+				t.Resize(rows, cols)
+			}
+		})
+		defer stopResize()
+	}
+
+	go io.Copy(t, os.Stdin)
+
+	if _, err := io.Copy(os.Stdout, t); err != nil {
+		if ctx.Err() != nil {
+			return 0, ctx.Err()
+		}
+		return 0, err
+	}
+	exitCode, _ := t.ExitCode()
+	return exitCode, nil
+}
@@ -0,0 +1,79 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+	v1 "github.com/substratusai/sandboxai/go/api/v1"
+)
+
+func TestTerminal(t *testing.T) {
+	var upgrader websocket.Upgrader
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/spaces/default/sandboxes/box/terminal" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		require.Equal(t, []string{"sh", "-i"}, r.URL.Query()["command"])
+		require.Equal(t, "24", r.URL.Query().Get("rows"))
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		// Echo input until a resize, then exit with the new size as the code.
+		for {
+			typ, data, err := conn.ReadMessage()
+			require.NoError(t, err)
+			if typ == websocket.BinaryMessage {
+				require.NoError(t, conn.WriteMessage(websocket.BinaryMessage, data))
+				continue
+			}
+			var msg v1.TerminalMessage
+			require.NoError(t, json.Unmarshal(data, &msg))
+			require.Equal(t, v1.TerminalMessageResize, msg.Type)
+			require.NoError(t, conn.WriteJSON(v1.TerminalMessage{Type: v1.TerminalMessageExit, ExitCode: msg.Rows}))
+			conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+			return
+		}
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+	c := NewClient(srv.URL)
+
+	_, err := c.DialTerminal(ctx, "default", "other", nil)
+	require.ErrorIs(t, err, ErrSandboxNotFound)
+
+	term, err := c.DialTerminal(ctx, "default", "box", &v1.AttachTerminalParams{
+		Command: []string{"sh", "-i"},
+		Rows:    24,
+		Cols:    80,
+	})
+	require.NoError(t, err)
+	defer term.Close()
+
+	_, err = term.Write([]byte("hello "))
+	require.NoError(t, err)
+	_, err = term.Write([]byte("world"))
+	require.NoError(t, err)
+	buf := make([]byte, len("hello world"))
+	_, err = io.ReadFull(term, buf)
+	require.NoError(t, err)
+	require.Equal(t, "hello world", string(buf))
+
+	_, exited := term.ExitCode()
+	require.False(t, exited)
+	require.NoError(t, term.Resize(7, 80))
+	rest, err := io.ReadAll(term)
+	require.NoError(t, err)
+	require.Empty(t, rest)
+	exitCode, exited := term.ExitCode()
+	require.True(t, exited)
+	require.Equal(t, 7, exitCode)
+}
@@ -0,0 +1,7 @@
+package v1
+
+// notifyResize is not supported on Windows (there is no SIGWINCH), so the
+// terminal keeps its initial size.
+func notifyResize(fn func()) (stop func()) {
+	return func() {}
+}
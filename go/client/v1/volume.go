@@ -0,0 +1,54 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	v1 "github.com/substratusai/sandboxai/go/api/v1"
+)
+
+// CreateVolume creates a volume that sandboxes in the space can mount.
+func (c *Client) CreateVolume(ctx context.Context, space string, request *v1.CreateVolumeRequest) (*v1.Volume, error) {
+	var vol v1.Volume
+	url := fmt.Sprintf("%s/spaces/%s/volumes", c.BaseURL, space)
+	if err := c.callJSONStatus(ctx, http.MethodPost, url, request, http.StatusCreated, &vol, map[int]error{
+		http.StatusNotFound: ErrSpaceNotFound,
+		http.StatusConflict: ErrVolumeAlreadyExists,
+	}); err != nil {
+		return nil, err
+	}
+	return &vol, nil
+}
+
+func (c *Client) GetVolume(ctx context.Context, space, name string) (*v1.Volume, error) {
+	var vol v1.Volume
+	url := fmt.Sprintf("%s/spaces/%s/volumes/%s", c.BaseURL, space, name)
+	if err := c.callJSONStatus(ctx, http.MethodGet, url, nil, http.StatusOK, &vol, map[int]error{
+		http.StatusNotFound: ErrVolumeNotFound,
+	}); err != nil {
+		return nil, err
+	}
+	return &vol, nil
+}
+
+func (c *Client) ListVolumes(ctx context.Context, space string) (*v1.VolumeList, error) {
+	var list v1.VolumeList
+	url := fmt.Sprintf("%s/spaces/%s/volumes", c.BaseURL, space)
+	if err := c.callJSONStatus(ctx, http.MethodGet, url, nil, http.StatusOK, &list, map[int]error{
+		http.StatusNotFound: ErrSpaceNotFound,
+	}); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// DeleteVolume deletes a volume and its data. ErrVolumeInUse is returned if
+// a sandbox mounts it.
+func (c *Client) DeleteVolume(ctx context.Context, space, name string) error {
+	url := fmt.Sprintf("%s/spaces/%s/volumes/%s", c.BaseURL, space, name)
+	return c.callJSONStatus(ctx, http.MethodDelete, url, nil, http.StatusNoContent, nil, map[int]error{
+		http.StatusNotFound: ErrVolumeNotFound,
+		http.StatusConflict: ErrVolumeInUse,
+	})
+}
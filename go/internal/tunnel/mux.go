@@ -0,0 +1,518 @@
+package tunnel
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// A Session multiplexes streams (each one a TCP connection) over a single
+// WebSocket connection. Every binary message is a frame:
+//
+//	type (1 byte) | stream ID (4 bytes, big endian) | payload
+//
+// The client opens streams with odd IDs and the server with even IDs. The
+// frame types are:
+//
+//   - open: opens a stream.
+//   - data: the payload is data. At most the receiver's window (initially
+//     streamWindow bytes) can be unacknowledged at a time.
+//   - window: the payload is a 4 byte increment of the sender's window,
+//     sent once data has been read.
+//   - closeWrite: marks the end of the data in that direction (a half-close).
+//   - close: the stream is closed. The payload is an error message if the
+//     stream failed, for example because the port could not be dialed.
+//
+// Frames for streams that have been closed are ignored.
+type Session struct {
+	ws *websocket.Conn
+
+	// writeMtx serializes writes (gorilla/websocket supports one concurrent writer).
+	writeMtx sync.Mutex
+
+	mtx     sync.Mutex
+	streams map[uint32]*Stream
+	nextID  uint32
+
+	accepted chan *Stream
+
+	closeOnce sync.Once
+	done      chan struct{}
+	// err is why the session ended. It is set before done is closed.
+	err error
+}
+
+const (
+	frameOpen byte = iota
+	frameData
+	frameWindow
+	frameCloseWrite
+	frameClose
+)
+
+const frameHeaderSize = 5
+
+const (
+	// streamWindow is how much data can be sent on a stream before the
+	// receiver has read it. It stops one slow stream from blocking the rest.
+	streamWindow = 256 << 10
+	// maxFrameData is the most data sent in one frame.
+	maxFrameData = 32 << 10
+)
+
+// ErrSessionClosed is returned by a Session (and its streams) after it was
+// closed with Close.
+var ErrSessionClosed = errors.New("tunnel: session closed")
+
+// NewClientSession starts a session on the dialing side of ws.
+func NewClientSession(ws *websocket.Conn) *Session {
+	return newSession(ws, 1)
+}
+
+// NewServerSession starts a session on the accepting side of ws.
+func NewServerSession(ws *websocket.Conn) *Session {
+	return newSession(ws, 2)
+}
+
+func newSession(ws *websocket.Conn, firstID uint32) *Session {
+	s := &Session{
+		ws:       ws,
+		streams:  make(map[uint32]*Stream),
+		nextID:   firstID,
+		accepted: make(chan *Stream, 16),
+		done:     make(chan struct{}),
+	}
+	go s.readLoop()
+	return s
+}
+
+// Open opens a new stream.
+func (s *Session) Open() (*Stream, error) {
+	s.mtx.Lock()
+	select {
+	case <-s.done:
+		s.mtx.Unlock()
+		return nil, s.err
+	default:
+	}
+	st := newStream(s, s.nextID)
+	s.nextID += 2
+	s.streams[st.id] = st
+	s.mtx.Unlock()
+
+	if err := s.writeFrame(frameOpen, st.id, nil); err != nil {
+		s.remove(st.id)
+		return nil, err
+	}
+	return st, nil
+}
+
+// Accept waits for the peer to open a stream.
+func (s *Session) Accept() (*Stream, error) {
+	select {
+	case st := <-s.accepted:
+		return st, nil
+	case <-s.done:
+		return nil, s.err
+	}
+}
+
+// Done is closed once the session has ended, see Err.
+func (s *Session) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err returns why the session ended, or nil if it has not.
+func (s *Session) Err() error {
+	select {
+	case <-s.done:
+		return s.err
+	default:
+		return nil
+	}
+}
+
+// Close ends the session and all of its streams.
+func (s *Session) Close() error {
+	s.writeMtx.Lock()
+	s.ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+	s.writeMtx.Unlock()
+	s.end(ErrSessionClosed)
+	return nil
+}
+
+func (s *Session) end(err error) {
+	s.closeOnce.Do(func() {
+		s.mtx.Lock()
+		s.err = err
+		close(s.done)
+		streams := s.streams
+		s.streams = map[uint32]*Stream{}
+		s.mtx.Unlock()
+
+		s.ws.Close()
+		for _, st := range streams {
+			st.remoteClose(err)
+		}
+	})
+}
+
+func (s *Session) readLoop() {
+	for {
+		typ, data, err := s.ws.ReadMessage()
+		if err != nil {
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+				err = ErrSessionClosed
+			}
+			s.end(fmt.Errorf("tunnel: session ended: %w", err))
+			return
+		}
+		if typ != websocket.BinaryMessage {
+			continue
+		}
+		if len(data) < frameHeaderSize {
+			s.end(errors.New("tunnel: session ended: short frame"))
+			return
+		}
+		if err := s.handleFrame(data[0], binary.BigEndian.Uint32(data[1:frameHeaderSize]), data[frameHeaderSize:]); err != nil {
+			s.end(fmt.Errorf("tunnel: session ended: %w", err))
+			return
+		}
+	}
+}
+
+func (s *Session) handleFrame(typ byte, id uint32, payload []byte) error {
+	s.mtx.Lock()
+	st, ok := s.streams[id]
+	if typ == frameOpen {
+		if ok || id%2 == s.nextID%2 {
+			s.mtx.Unlock()
+			return fmt.Errorf("stream %d: invalid open", id)
+		}
+		st = newStream(s, id)
+		s.streams[id] = st
+	}
+	s.mtx.Unlock()
+	if typ == frameOpen {
+		select {
+		case s.accepted <- st:
+		case <-s.done:
+		}
+		return nil
+	}
+	if !ok {
+		// Closed on this side.
+		return nil
+	}
+
+	switch typ {
+	case frameData:
+		return st.receive(payload)
+	case frameWindow:
+		if len(payload) != 4 {
+			return fmt.Errorf("stream %d: invalid window update", id)
+		}
+		st.grow(int(binary.BigEndian.Uint32(payload)))
+	case frameCloseWrite:
+		st.remoteCloseWrite()
+	case frameClose:
+		s.remove(id)
+		var err error
+		if len(payload) > 0 {
+			err = &StreamError{Message: string(payload)}
+		}
+		st.remoteClose(err)
+	default:
+		return fmt.Errorf("stream %d: unknown frame type %d", id, typ)
+	}
+	return nil
+}
+
+func (s *Session) writeFrame(typ byte, id uint32, payload []byte) error {
+	msg := make([]byte, frameHeaderSize+len(payload))
+	msg[0] = typ
+	binary.BigEndian.PutUint32(msg[1:frameHeaderSize], id)
+	copy(msg[frameHeaderSize:], payload)
+
+	s.writeMtx.Lock()
+	defer s.writeMtx.Unlock()
+	select {
+	case <-s.done:
+		return s.err
+	default:
+	}
+	return s.ws.WriteMessage(websocket.BinaryMessage, msg)
+}
+
+func (s *Session) remove(id uint32) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	delete(s.streams, id)
+}
+
+// StreamError is returned by reads and writes on a stream that the peer
+// closed because it failed.
+type StreamError struct {
+	Message string
+}
+
+func (e *StreamError) Error() string {
+	return e.Message
+}
+
+// Stream is a connection multiplexed over a Session.
+type Stream struct {
+	id   uint32
+	sess *Session
+
+	mtx  sync.Mutex
+	cond *sync.Cond
+	// buf holds the data received but not read yet.
+	buf bytes.Buffer
+	// unacked is the amount read since the last window update.
+	unacked    int
+	sendWindow int
+	// readEOF is set once the peer has ended its data, and writeClosed once
+	// this side has.
+	readEOF     bool
+	writeClosed bool
+	closed      bool
+	// remoteClosed is set once the peer closed the stream (or the session
+	// ended), with remoteErr if it failed.
+	remoteClosed bool
+	remoteErr    error
+
+	readDeadline, writeDeadline time.Time
+	readTimer, writeTimer       *time.Timer
+}
+
+var _ net.Conn = &Stream{}
+
+func newStream(s *Session, id uint32) *Stream {
+	st := &Stream{id: id, sess: s, sendWindow: streamWindow}
+	st.cond = sync.NewCond(&st.mtx)
+	return st
+}
+
+func (st *Stream) Read(p []byte) (int, error) {
+	st.mtx.Lock()
+	for st.buf.Len() == 0 {
+		switch {
+		case st.closed:
+			st.mtx.Unlock()
+			return 0, net.ErrClosed
+		case st.readEOF:
+			st.mtx.Unlock()
+			return 0, io.EOF
+		case st.remoteClosed:
+			st.mtx.Unlock()
+			if st.remoteErr != nil {
+				return 0, st.remoteErr
+			}
+			return 0, io.EOF
+		case deadlinePassed(st.readDeadline):
+			st.mtx.Unlock()
+			return 0, os.ErrDeadlineExceeded
+		}
+		st.cond.Wait()
+	}
+	n, _ := st.buf.Read(p)
+	st.unacked += n
+	var ack int
+	if st.unacked >= streamWindow/2 {
+		ack, st.unacked = st.unacked, 0
+	}
+	st.mtx.Unlock()
+
+	if ack > 0 {
+		var payload [4]byte
+		binary.BigEndian.PutUint32(payload[:], uint32(ack))
+		// A failure is reported by the next read or write.
+		st.sess.writeFrame(frameWindow, st.id, payload[:])
+	}
+	return n, nil
+}
+
+func (st *Stream) Write(p []byte) (int, error) {
+	var written int
+	for len(p) > 0 {
+		st.mtx.Lock()
+		for st.sendWindow == 0 && !st.closed && !st.writeClosed && !st.remoteClosed && !deadlinePassed(st.writeDeadline) {
+			st.cond.Wait()
+		}
+		switch {
+		case st.closed:
+			st.mtx.Unlock()
+			return written, net.ErrClosed
+		case st.writeClosed:
+			st.mtx.Unlock()
+			return written, errors.New("tunnel: write after CloseWrite")
+		case st.remoteClosed:
+			st.mtx.Unlock()
+			if st.remoteErr != nil {
+				return written, st.remoteErr
+			}
+			return written, errors.New("tunnel: stream closed by peer")
+		case deadlinePassed(st.writeDeadline):
+			st.mtx.Unlock()
+			return written, os.ErrDeadlineExceeded
+		}
+		n := min(len(p), st.sendWindow, maxFrameData)
+		st.sendWindow -= n
+		st.mtx.Unlock()
+
+		if err := st.sess.writeFrame(frameData, st.id, p[:n]); err != nil {
+			return written, err
+		}
+		written += n
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// CloseWrite marks the end of the data sent on the stream.
+func (st *Stream) CloseWrite() error {
+	st.mtx.Lock()
+	if st.writeClosed || st.closed || st.remoteClosed {
+		st.mtx.Unlock()
+		return nil
+	}
+	st.writeClosed = true
+	st.cond.Broadcast()
+	st.mtx.Unlock()
+	return st.sess.writeFrame(frameCloseWrite, st.id, nil)
+}
+
+func (st *Stream) Close() error {
+	return st.close("")
+}
+
+// CloseWithError closes the stream and reports err to the peer, whose
+// reads and writes return a *StreamError with its message.
+func (st *Stream) CloseWithError(err error) error {
+	return st.close(err.Error())
+}
+
+func (st *Stream) close(msg string) error {
+	st.mtx.Lock()
+	if st.closed {
+		st.mtx.Unlock()
+		return nil
+	}
+	st.closed = true
+	notify := !st.remoteClosed
+	st.stopTimers()
+	st.cond.Broadcast()
+	st.mtx.Unlock()
+
+	st.sess.remove(st.id)
+	if notify {
+		if err := st.sess.writeFrame(frameClose, st.id, []byte(msg)); err != nil && st.sess.Err() == nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (st *Stream) receive(p []byte) error {
+	st.mtx.Lock()
+	defer st.mtx.Unlock()
+	if st.closed {
+		return nil
+	}
+	if st.buf.Len()+len(p) > streamWindow {
+		return fmt.Errorf("stream %d: window exceeded", st.id)
+	}
+	st.buf.Write(p)
+	st.cond.Broadcast()
+	return nil
+}
+
+func (st *Stream) grow(n int) {
+	st.mtx.Lock()
+	defer st.mtx.Unlock()
+	st.sendWindow += n
+	st.cond.Broadcast()
+}
+
+func (st *Stream) remoteCloseWrite() {
+	st.mtx.Lock()
+	defer st.mtx.Unlock()
+	st.readEOF = true
+	st.cond.Broadcast()
+}
+
+func (st *Stream) remoteClose(err error) {
+	st.mtx.Lock()
+	defer st.mtx.Unlock()
+	st.remoteClosed = true
+	st.remoteErr = err
+	st.cond.Broadcast()
+}
+
+func (st *Stream) LocalAddr() net.Addr {
+	return st.sess.ws.LocalAddr()
+}
+
+func (st *Stream) RemoteAddr() net.Addr {
+	return st.sess.ws.RemoteAddr()
+}
+
+func (st *Stream) SetDeadline(t time.Time) error {
+	st.SetReadDeadline(t)
+	return st.SetWriteDeadline(t)
+}
+
+func (st *Stream) SetReadDeadline(t time.Time) error {
+	st.mtx.Lock()
+	defer st.mtx.Unlock()
+	st.readDeadline = t
+	st.readTimer = st.wakeAt(st.readTimer, t)
+	return nil
+}
+
+func (st *Stream) SetWriteDeadline(t time.Time) error {
+	st.mtx.Lock()
+	defer st.mtx.Unlock()
+	st.writeDeadline = t
+	st.writeTimer = st.wakeAt(st.writeTimer, t)
+	return nil
+}
+
+// wakeAt replaces timer with one that wakes up blocked reads and writes at
+// t, so that they notice that the deadline passed.
+func (st *Stream) wakeAt(timer *time.Timer, t time.Time) *time.Timer {
+	if timer != nil {
+		timer.Stop()
+	}
+	st.cond.Broadcast()
+	if t.IsZero() {
+		return nil
+	}
+	return time.AfterFunc(time.Until(t), func() {
+		st.mtx.Lock()
+		defer st.mtx.Unlock()
+		st.cond.Broadcast()
+	})
+}
+
+func (st *Stream) stopTimers() {
+	if st.readTimer != nil {
+		st.readTimer.Stop()
+	}
+	if st.writeTimer != nil {
+		st.writeTimer.Stop()
+	}
+}
+
+func deadlinePassed(t time.Time) bool {
+	return !t.IsZero() && !time.Now().Before(t)
+}
@@ -0,0 +1,140 @@
+package tunnel
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sessionPair returns the client and server sessions of a WebSocket
+// connection.
+func sessionPair(t *testing.T) (client, server *Session) {
+	var upgrader websocket.Upgrader
+	servers := make(chan *Session, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if !assert.NoError(t, err) {
+			return
+		}
+		servers <- NewServerSession(ws)
+	}))
+	t.Cleanup(srv.Close)
+
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	require.NoError(t, err)
+	client = NewClientSession(ws)
+	server = <-servers
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+	return client, server
+}
+
+func TestSession(t *testing.T) {
+	client, server := sessionPair(t)
+
+	// The server upper-cases the input of each stream once it has ended.
+	go func() {
+		for {
+			st, err := server.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer st.Close()
+				input, err := io.ReadAll(st)
+				if !assert.NoError(t, err) {
+					return
+				}
+				_, err = st.Write(bytes.ToUpper(input))
+				assert.NoError(t, err)
+				assert.NoError(t, st.CloseWrite())
+			}()
+		}
+	}()
+
+	// Several streams are used concurrently, with more data than fits in
+	// their windows.
+	large := strings.Repeat("abcdefgh", 3*streamWindow/8)
+	results := make(chan error, 3)
+	for i := range 3 {
+		go func() {
+			results <- func() error {
+				st, err := client.Open()
+				if err != nil {
+					return err
+				}
+				defer st.Close()
+				input := fmt.Sprintf("stream %d: %s", i, large)
+				if _, err := st.Write([]byte(input)); err != nil {
+					return err
+				}
+				if err := st.CloseWrite(); err != nil {
+					return err
+				}
+				output, err := io.ReadAll(st)
+				if err != nil {
+					return err
+				}
+				if string(output) != strings.ToUpper(input) {
+					return fmt.Errorf("stream %d: unexpected output", i)
+				}
+				return nil
+			}()
+		}()
+	}
+	for range 3 {
+		require.NoError(t, <-results)
+	}
+}
+
+func TestSessionStreamError(t *testing.T) {
+	client, server := sessionPair(t)
+	go func() {
+		st, err := server.Accept()
+		if assert.NoError(t, err) {
+			st.CloseWithError(errors.New("port 5432: nothing is listening on the port"))
+		}
+	}()
+
+	st, err := client.Open()
+	require.NoError(t, err)
+	_, err = io.ReadAll(st)
+	var streamErr *StreamError
+	require.ErrorAs(t, err, &streamErr)
+	require.Equal(t, "port 5432: nothing is listening on the port", streamErr.Message)
+}
+
+func TestSessionClose(t *testing.T) {
+	client, server := sessionPair(t)
+	accepted := make(chan *Stream, 1)
+	go func() {
+		st, err := server.Accept()
+		if assert.NoError(t, err) {
+			accepted <- st
+		}
+	}()
+
+	st, err := client.Open()
+	require.NoError(t, err)
+	<-accepted
+
+	// Ending the session fails its streams on both sides.
+	require.NoError(t, server.Close())
+	_, err = io.ReadAll(st)
+	require.Error(t, err)
+	<-client.Done()
+	require.Error(t, client.Err())
+	_, err = client.Open()
+	require.Error(t, err)
+}
@@ -0,0 +1,163 @@
+// Package tunnel carries TCP streams over WebSocket connections.
+//
+// A Conn carries a single stream: data is sent in binary messages, and an
+// empty message marks the end of the data in that direction (a half-close).
+// boxd uses the same convention when it relays connections to ports in a
+// sandbox. A Session multiplexes many streams over one WebSocket.
+package tunnel
+
+import (
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// HalfCloser is a connection that can signal the end of the data it sends
+// while still receiving.
+type HalfCloser interface {
+	io.ReadWriteCloser
+	CloseWrite() error
+}
+
+// AsHalfCloser returns conn as a HalfCloser. Connections that do not support
+// half-closes are closed entirely by CloseWrite, once the other side has
+// sent all of its data.
+func AsHalfCloser(conn net.Conn) HalfCloser {
+	if hc, ok := conn.(HalfCloser); ok {
+		return hc
+	}
+	return closeOnCloseWrite{conn}
+}
+
+type closeOnCloseWrite struct {
+	net.Conn
+}
+
+func (c closeOnCloseWrite) CloseWrite() error {
+	return c.Close()
+}
+
+// Conn adapts a tunnelled WebSocket connection to a net.Conn.
+type Conn struct {
+	*websocket.Conn
+
+	// r is the remainder of the current message, and empty reports if
+	// nothing has been read from it yet.
+	r     io.Reader
+	empty bool
+	eof   bool
+
+	// writeMtx serializes writes (gorilla/websocket supports one concurrent writer).
+	writeMtx  sync.Mutex
+	closeOnce sync.Once
+}
+
+var _ net.Conn = &Conn{}
+
+// NewConn returns a Conn that tunnels over ws.
+func NewConn(ws *websocket.Conn) *Conn {
+	return &Conn{Conn: ws}
+}
+
+func (c *Conn) Read(p []byte) (int, error) {
+	for {
+		if c.eof {
+			return 0, io.EOF
+		}
+		if c.r != nil {
+			n, err := c.r.Read(p)
+			if n > 0 {
+				c.empty = false
+			}
+			if err == io.EOF {
+				c.r = nil
+				// An empty message marks the end of the data.
+				c.eof = c.empty
+				err = nil
+			}
+			if n == 0 && err == nil {
+				continue
+			}
+			return n, err
+		}
+
+		typ, r, err := c.NextReader()
+		if err != nil {
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+				c.eof = true
+				continue
+			}
+			return 0, err
+		}
+		if typ == websocket.BinaryMessage {
+			c.r, c.empty = r, true
+		}
+	}
+}
+
+func (c *Conn) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		// An empty message would be mistaken for the end of the data.
+		return 0, nil
+	}
+	c.writeMtx.Lock()
+	defer c.writeMtx.Unlock()
+	if err := c.WriteMessage(websocket.BinaryMessage, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// CloseWrite sends an empty message to mark the end of the data.
+func (c *Conn) CloseWrite() error {
+	c.writeMtx.Lock()
+	defer c.writeMtx.Unlock()
+	return c.WriteMessage(websocket.BinaryMessage, nil)
+}
+
+func (c *Conn) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		c.Conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+		err = c.Conn.Close()
+	})
+	return err
+}
+
+func (c *Conn) SetDeadline(t time.Time) error {
+	if err := c.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return c.SetWriteDeadline(t)
+}
+
+// Relay copies data between a and b in both directions until both sides
+// have ended their data or either connection fails. The end of the data is
+// forwarded with CloseWrite. Both connections are closed before Relay
+// returns.
+func Relay(a, b HalfCloser) error {
+	errc := make(chan error, 2)
+	cp := func(dst, src HalfCloser) {
+		_, err := io.Copy(dst, src)
+		if err == nil {
+			err = dst.CloseWrite()
+		}
+		errc <- err
+	}
+	go cp(a, b)
+	go cp(b, a)
+
+	var err error
+	for range 2 {
+		// Closing the connections unblocks the other copy after a failure.
+		if err = <-errc; err != nil {
+			break
+		}
+	}
+	a.Close()
+	b.Close()
+	return err
+}
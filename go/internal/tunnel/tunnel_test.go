@@ -0,0 +1,95 @@
+package tunnel
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConn(t *testing.T) {
+	// Acts like the boxd relay to a server that upper-cases its input and
+	// closes once its input has ended.
+	var upgrader websocket.Upgrader
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer conn.Close()
+		var input strings.Builder
+		for {
+			_, data, err := conn.ReadMessage()
+			if !assert.NoError(t, err) {
+				return
+			}
+			if len(data) == 0 {
+				break
+			}
+			input.Write(data)
+		}
+		assert.NoError(t, conn.WriteMessage(websocket.BinaryMessage, []byte(strings.ToUpper(input.String()))))
+		assert.NoError(t, conn.WriteMessage(websocket.BinaryMessage, nil))
+	}))
+	defer srv.Close()
+
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	require.NoError(t, err)
+	conn := NewConn(ws)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("hello "))
+	require.NoError(t, err)
+	_, err = conn.Write([]byte("world"))
+	require.NoError(t, err)
+	require.NoError(t, conn.CloseWrite())
+
+	output, err := io.ReadAll(conn)
+	require.NoError(t, err)
+	require.Equal(t, "HELLO WORLD", string(output))
+}
+
+func TestRelay(t *testing.T) {
+	// Relay between two pipes: one side sends a request and half-closes,
+	// the other echoes it back once its input has ended.
+	a, aPeer := halfPipe(t)
+	b, bPeer := halfPipe(t)
+	relayErr := make(chan error, 1)
+	go func() { relayErr <- Relay(aPeer, bPeer) }()
+
+	go func() {
+		input, _ := io.ReadAll(b)
+		b.Write(input)
+		b.CloseWrite()
+	}()
+
+	_, err := a.Write([]byte("ping"))
+	require.NoError(t, err)
+	require.NoError(t, a.CloseWrite())
+	output, err := io.ReadAll(a)
+	require.NoError(t, err)
+	require.Equal(t, "ping", string(output))
+	require.NoError(t, <-relayErr)
+}
+
+// halfPipe returns a connected pair of TCP connections, which support
+// half-closes unlike net.Pipe.
+func halfPipe(t *testing.T) (*net.TCPConn, *net.TCPConn) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	accepted := make(chan net.Conn)
+	go func() {
+		conn, _ := ln.Accept()
+		accepted <- conn
+	}()
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	return conn.(*net.TCPConn), (<-accepted).(*net.TCPConn)
+}
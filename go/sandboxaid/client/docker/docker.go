@@ -2,15 +2,22 @@ package docker
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"maps"
+	"net"
 	"net/http"
 	"os"
+	"sort"
+	"sync"
 	"time"
 
 	sclient "github.com/substratusai/sandboxai/go/sandboxaid/client"
+	"github.com/substratusai/sandboxai/go/sandboxaid/egress"
 
 	stdlog "log"
 
+	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/filters"
 
 	"github.com/docker/docker/api/types/container"
@@ -34,12 +41,52 @@ func SetLogger(logger Logger) {
 var log Logger = stdlog.New(os.Stderr, "", stdlog.LstdFlags)
 
 type DockerClient struct {
-	docker *dclient.Client
-	httpc  *http.Client
-	scope  string
+	docker  *dclient.Client
+	httpc   *http.Client
+	scope   string
+	records *records
+	relays  *relays
+
+	events        *eventBroker
+	eventFeedOnce sync.Once
+
+	networkMtx sync.Mutex
+	// volumeMtx serializes checking for and creating the Docker volumes
+	// that store volumes and spaces. Docker succeeds in creating a volume
+	// that already exists, so the check is the only way to detect a
+	// conflict.
+	volumeMtx sync.Mutex
+
+	// egressProxy is nil if the egress proxy is disabled.
+	egressProxy http.Handler
+	// egressProxyPort is the port that the egress proxy listens on at the
+	// gateways in egressListeners (keyed by gateway address).
+	egressProxyPort int
+	egressListeners map[string]net.Listener
+	// egressPolicies caches the egress policies of sandboxes by token.
+	egressPolicies map[string]*egress.Policy
+	egressMtx      sync.Mutex
+	// cassettes is nil if cassettes are not supported.
+	cassettes *egress.Cassettes
+	// bindMountAllow are the host directories that can be bind mounted.
+	bindMountAllow []string
+	// snapshotDir is empty if snapshots are disabled.
+	snapshotDir string
+	snapshotMtx sync.Mutex
+	helperMtx   sync.Mutex
+
+	// expired keeps deleted expired sandboxes for expiredRetention.
+	expired expiredSandboxes
+
+	// startedAt is when the client was created. Activity before then is
+	// not known (records only live in memory).
+	startedAt time.Time
 }
 
 func NewSandboxClient(docker *dclient.Client, httpc *http.Client, scope string) (*DockerClient, error) {
+	if err := validateScope(scope); err != nil {
+		return nil, err
+	}
 	if docker == nil {
 		if err := setDockerEnvFromContextIfNotSet(); err != nil {
 			log.Printf("Failed to set docker env from context: %v", err)
@@ -51,30 +98,65 @@ func NewSandboxClient(docker *dclient.Client, httpc *http.Client, scope string)
 		}
 	}
 
-	return &DockerClient{
-		docker: docker,
-		httpc:  httpc,
-		scope:  scope,
-	}, nil
+	c := &DockerClient{
+		docker:    docker,
+		httpc:     httpc,
+		scope:     scope,
+		records:   newRecords(),
+		relays:    newRelays(),
+		events:    newEventBroker(),
+		startedAt: time.Now(),
+	}
+	c.startEventFeed()
+	return c, nil
 }
 
 const labelKeyScope = "sandboxai.scope"
 const labelKeySpace = "sandboxai.space"
 const labelKeyName = "sandboxai.name"
 
+// boxPort is the port that boxd listens on in the sandbox.
+const boxPort = "8000"
+
+// labelKeyUserPrefix is prepended to user-specified sandbox labels
+// to keep them separate from the labels used internally.
+const labelKeyUserPrefix = "sandboxai.label."
+
 func (c *DockerClient) CreateSandbox(ctx context.Context, space string, req *v1.CreateSandboxRequest) (*sclient.Sandbox, error) {
 	if space == "" {
 		return nil, fmt.Errorf("space cannot be empty")
 	}
+	sp, err := c.GetSpace(ctx, space)
+	if err != nil {
+		return nil, err
+	}
+	resources, err := sclient.EffectiveResources(req.Spec.Resources, sp.Spec.Resources)
+	if err != nil {
+		return nil, err
+	}
 	if req.Name == "" {
 		req.Name = generateRandomName()
 	}
-	cname := containerName(space, req.Name)
+	cname := containerName(c.scope, space, req.Name)
+
+	mode := v1.NetworkModeBridge
+	if req.Spec.Network != nil {
+		switch {
+		case req.Spec.Network.Mode != "":
+			mode = req.Spec.Network.Mode
+		case len(req.Spec.Network.EgressAllow) > 0 || req.Spec.Network.Cassette != nil:
+			// The egress proxy must be the only way out.
+			mode = v1.NetworkModeInternal
+		}
+	}
+	netName, gateway, err := c.ensureNetwork(ctx, space, mode)
+	if err != nil {
+		return nil, err
+	}
 
-	const boxPortNumber = "8000"
-	boxPort, err := nat.NewPort("tcp", boxPortNumber)
+	mounts, err := c.dockerMounts(ctx, space, req.Spec.Mounts)
 	if err != nil {
-		return nil, fmt.Errorf("create port: %w", err)
+		return nil, err
 	}
 
 	var env []string
@@ -85,99 +167,524 @@ func (c *DockerClient) CreateSandbox(ctx context.Context, space string, req *v1.
 	config := &container.Config{
 		Image: req.Spec.Image,
 		ExposedPorts: nat.PortSet{
-			boxPort: struct{}{},
+			boxPort + "/tcp": struct{}{},
 		},
 		Labels: map[string]string{
-			labelKeyScope: c.scope,
-			labelKeySpace: space,
-			labelKeyName:  req.Name,
+			labelKeyScope:       c.scope,
+			labelKeySpace:       space,
+			labelKeyName:        req.Name,
+			labelKeyNetworkMode: string(mode),
 		},
 		Env: env,
 	}
+	for k, v := range req.Labels {
+		config.Labels[labelKeyUserPrefix+k] = v
+	}
+	lifetimeLabels, err := lifetimeLabels(req.Spec)
+	if err != nil {
+		return nil, err
+	}
+	maps.Copy(config.Labels, lifetimeLabels)
+	var cassette *v1.SandboxCassette
+	if req.Spec.Network != nil {
+		cassette = req.Spec.Network.Cassette
+	}
+	var extraHosts []string
+	if req.Spec.Network != nil && (len(req.Spec.Network.EgressAllow) > 0 || cassette != nil) {
+		labels, env, extraHost, err := c.egressConfig(req.Spec.Network.EgressAllow, gateway)
+		if err != nil {
+			return nil, err
+		}
+		maps.Copy(config.Labels, labels)
+		config.Env = append(config.Env, env...)
+		extraHosts = append(extraHosts, extraHost)
+	}
+	if cassette != nil {
+		labels, env, err := c.cassetteConfig(space, cassette)
+		if err != nil {
+			return nil, err
+		}
+		maps.Copy(config.Labels, labels)
+		config.Env = append(config.Env, env...)
+	}
 
 	hostConfig := &container.HostConfig{
-		PortBindings: nat.PortMap{
-			boxPortNumber: []nat.PortBinding{
+		NetworkMode: container.NetworkMode(netName),
+		Mounts:      mounts,
+		Resources:   resourcesToDocker(resources),
+		ShmSize:     resources.ShmSize,
+		ExtraHosts:  extraHosts,
+	}
+	if mode == v1.NetworkModeBridge {
+		// Ports cannot be published from internal networks, those
+		// sandboxes are reached through a relay instead (see relays.go).
+		hostConfig.PortBindings = nat.PortMap{
+			boxPort + "/tcp": []nat.PortBinding{
 				{
 					HostIP: "127.0.0.1",
 					// Find a free port on the host machine.
 					HostPort: "0",
 				},
 			},
-		},
-		PublishAllPorts: true,
+		}
+		hostConfig.PublishAllPorts = true
 	}
 
 	networkingConfig := &network.NetworkingConfig{}
+	if mode != v1.NetworkModeNone {
+		networkingConfig.EndpointsConfig = map[string]*network.EndpointSettings{netName: {}}
+	}
 	platform := &ocispec.Platform{}
 
+	createStart := time.Now()
 	resp, err := c.docker.ContainerCreate(ctx, config, hostConfig, networkingConfig, platform, cname)
 	if err != nil {
 		return nil, fmt.Errorf("create: %w", err)
 	}
+	if cassette != nil {
+		if err := c.installCACert(ctx, resp.ID); err != nil {
+			c.removeFailed(resp.ID)
+			return nil, err
+		}
+	}
+	startup := v1.SandboxStartupTiming{
+		CreateContainerMs: time.Since(createStart).Milliseconds(),
+	}
+	c.records.add(resp.ID, func(rec *sandboxRecord) {
+		rec.done = make(chan struct{})
+	})
+	c.addEgressPolicy(resp.ID, config.Labels)
+	c.expired.forget(space, req.Name)
 
+	startStart := time.Now()
 	startOpts := container.StartOptions{}
 	if err := c.docker.ContainerStart(ctx, resp.ID, startOpts); err != nil {
+		c.removeFailed(resp.ID)
 		return nil, fmt.Errorf("start: %w", err)
 	}
+	startup.StartContainerMs = time.Since(startStart).Milliseconds()
 
 	log.Printf("Started sandbox: %q", resp.ID)
 
 	dockerContainer, err := c.docker.ContainerInspect(ctx, resp.ID)
 	if err != nil {
+		c.removeFailed(resp.ID)
 		return nil, err
 	}
-	created, err := containerJSONToSandbox(dockerContainer)
+	boxAddr, err := c.getBoxAddr(dockerContainer)
 	if err != nil {
-		return nil, fmt.Errorf("reading container to sandbox: %w", err)
+		c.removeFailed(resp.ID)
+		return nil, fmt.Errorf("container %q: getting box address: %w", dockerContainer.Name, err)
 	}
-	if err := c.waitForHealthcheck(ctx, created.BoxHostPort, 1*time.Second, 60*time.Second); err != nil {
-		return nil, fmt.Errorf("waiting for box healthcheck: %w", err)
+
+	// Waiting for the box to become healthy can take a while (large images,
+	// slow hosts) so it is done in the background. Callers can use
+	// WaitForReady to block until the sandbox is ready.
+	go c.waitForReady(resp.ID, config.Labels, boxAddr, startup, createStart)
+
+	return c.toSandbox(dockerContainer)
+}
+
+// removeFailed removes a container that failed to be set up as a sandbox so
+// that its name is not left taken.
+func (c *DockerClient) removeFailed(id string) {
+	c.records.delete(id)
+	c.closeRelay(id)
+	c.deleteEgressPolicy(id)
+	// The request context may be what failed.
+	if err := c.docker.ContainerRemove(context.Background(), id, container.RemoveOptions{Force: true}); err != nil && !dclient.IsErrNotFound(err) {
+		log.Printf("Failed to remove container %q after failed create: %v", id, err)
 	}
+}
+
+// readyTimeout is how long a sandbox has to pass its healthcheck after
+// starting before it is considered failed.
+const readyTimeout = 60 * time.Second
+
+// waitForReady waits for the box to pass its healthcheck and records the result.
+func (c *DockerClient) waitForReady(id string, labels map[string]string, boxAddr string, startup v1.SandboxStartupTiming, createStart time.Time) {
+	ctx, cancel := context.WithTimeout(context.Background(), readyTimeout)
+	defer cancel()
 
-	log.Printf("Sandbox ready: %q", resp.ID)
+	waitStart := time.Now()
+	err := c.waitForHealthcheck(ctx, id, boxAddr, 1*time.Second, readyTimeout)
+	now := time.Now()
+	startup.WaitForReadyMs = now.Sub(waitStart).Milliseconds()
 
-	return created, nil
+	c.records.resolve(id, func(rec *sandboxRecord) {
+		rec.startup = &startup
+		if err != nil {
+			rec.failReason = "ReadinessCheckFailed"
+			rec.failMessage = fmt.Sprintf("Waiting for box healthcheck: %v", err)
+			return
+		}
+		startup.TotalMs = now.Sub(createStart).Milliseconds()
+		rec.readyAt = now
+	})
+
+	if err != nil {
+		log.Printf("Sandbox failed to become ready: %q: %v", id, err)
+		c.publishEvent(v1.SandboxEventFailed, id, labels, "ReadinessCheckFailed", fmt.Sprintf("Waiting for box healthcheck: %v", err))
+		return
+	}
+	log.Printf("Sandbox ready: %q", id)
+	c.publishEvent(v1.SandboxEventReady, id, labels, "", "")
 }
 
 func (c *DockerClient) GetSandbox(ctx context.Context, space, name string) (*sclient.Sandbox, error) {
 	if space == "" {
 		return nil, fmt.Errorf("space cannot be empty")
 	}
-	cname := containerName(space, name)
+	dockerContainer, err := c.inspectSandbox(ctx, space, name)
+	if err != nil {
+		return c.expiredSandbox(space, name, err)
+	}
+	return c.toSandbox(dockerContainer)
+}
+
+// expiredSandbox returns the sandbox if it was deleted because it expired,
+// otherwise err (from looking the sandbox up). Expired sandboxes have no
+// BoxAddr so they cannot serve tool calls.
+func (c *DockerClient) expiredSandbox(space, name string, err error) (*sclient.Sandbox, error) {
+	if !errors.Is(err, sclient.ErrSandboxNotFound) {
+		return nil, err
+	}
+	sbx := c.expired.get(space, name, time.Now())
+	if sbx == nil {
+		return nil, err
+	}
+	return &sclient.Sandbox{Sandbox: sbx}, nil
+}
+
+func (c *DockerClient) WaitForReady(ctx context.Context, space, name string, timeout time.Duration) (*sclient.Sandbox, error) {
+	if space == "" {
+		return nil, fmt.Errorf("space cannot be empty")
+	}
+	dockerContainer, err := c.inspectSandbox(ctx, space, name)
+	if err != nil {
+		return c.expiredSandbox(space, name, err)
+	}
+
+	rec := c.records.get(dockerContainer.ID)
+	if rec == nil || rec.done == nil {
+		// Readiness already resolved.
+		return c.toSandbox(dockerContainer)
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-rec.done:
+	case <-timer.C:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	return c.GetSandbox(ctx, space, name)
+}
+
+// TrackActivity marks the sandbox as serving a tool call until done is called.
+// If the sandbox is being stopped for being idle, it waits until it has
+// stopped so that the caller sees the sandbox as stopped and starts it.
+func (c *DockerClient) TrackActivity(sbx *sclient.Sandbox) func() {
+	for {
+		var stopping chan struct{}
+		// The record might not have been added yet after a restart.
+		c.records.add(sbx.UID, func(rec *sandboxRecord) {
+			if rec.stopping != nil {
+				stopping = rec.stopping
+				return
+			}
+			rec.lastActivityAt = time.Now()
+			rec.activeCalls++
+		})
+		if stopping == nil {
+			break
+		}
+		<-stopping
+	}
+	return func() {
+		now := time.Now()
+		c.records.update(sbx.UID, func(rec *sandboxRecord) {
+			rec.lastActivityAt = now
+			rec.activeCalls--
+		})
+	}
+}
+
+// toSandbox combines the container with the in-memory record for it.
+func (c *DockerClient) toSandbox(dockerContainer types.ContainerJSON) (*sclient.Sandbox, error) {
+	rec := c.records.get(dockerContainer.ID)
+	// Ports are only bound while the container is running.
+	var boxAddr string
+	if dockerContainer.State != nil && dockerContainer.State.Running {
+		var err error
+		boxAddr, err = c.getBoxAddr(dockerContainer)
+		if err != nil {
+			return nil, fmt.Errorf("container %q: getting box address: %w", dockerContainer.Name, err)
+		}
+	}
+	sbx, err := containerJSONToSandbox(dockerContainer, rec, boxAddr)
+	if err != nil {
+		return nil, err
+	}
+	if sbx.Status.CreatedAt != nil {
+		var idleSince time.Time
+		if !sbx.Paused() {
+			idleSince = c.idleSince(*sbx.Status.CreatedAt, rec)
+		}
+		if exp, _ := sandboxExpiry(dockerContainer.Config.Labels, *sbx.Status.CreatedAt, idleSince); !exp.IsZero() {
+			sbx.Status.ExpiresAt = &exp
+		}
+	}
+	return sbx, nil
+}
+
+// inspectSandbox looks up the container for a sandbox. Containers that are
+// outside of the client's scope are treated as not found.
+func (c *DockerClient) inspectSandbox(ctx context.Context, space, name string) (types.ContainerJSON, error) {
+	cname := containerName(c.scope, space, name)
 	dockerContainer, err := c.docker.ContainerInspect(ctx, cname)
 	if err != nil {
 		if dclient.IsErrNotFound(err) {
-			return nil, fmt.Errorf("getting container %q: %w", cname, sclient.ErrSandboxNotFound)
+			return types.ContainerJSON{}, fmt.Errorf("getting container %q: %w", cname, sclient.ErrSandboxNotFound)
 		}
-		return nil, fmt.Errorf("getting container %q: %w", cname, err)
+		return types.ContainerJSON{}, fmt.Errorf("getting container %q: %w", cname, err)
+	}
+	if !c.inScope(dockerContainer.Config.Labels, space, name) {
+		return types.ContainerJSON{}, fmt.Errorf("container %q is not managed in scope %q: %w", cname, c.scope, sclient.ErrSandboxNotFound)
 	}
-	return containerJSONToSandbox(dockerContainer)
+	return dockerContainer, nil
+}
+
+// inScope returns true if the labels belong to the given sandbox
+// in the client's scope.
+func (c *DockerClient) inScope(labels map[string]string, space, name string) bool {
+	return labels[labelKeyScope] == c.scope &&
+		labels[labelKeySpace] == space &&
+		labels[labelKeyName] == name
+}
+
+func (c *DockerClient) ListSandboxes(ctx context.Context, space string, opts sclient.ListOptions) (*sclient.SandboxList, error) {
+	if space == "" {
+		return nil, fmt.Errorf("space cannot be empty")
+	}
+	if _, err := c.GetSpace(ctx, space); err != nil {
+		return nil, err
+	}
+
+	args := filters.NewArgs(
+		filters.Arg("label", fmt.Sprintf("%s=%s", labelKeyScope, c.scope)),
+		filters.Arg("label", fmt.Sprintf("%s=%s", labelKeySpace, space)),
+	)
+	// Let Docker do the filtering that it supports, the rest of the
+	// selector is applied below.
+	for _, req := range opts.Selector {
+		switch req.Operator {
+		case sclient.OpEquals:
+			args.Add("label", fmt.Sprintf("%s%s=%s", labelKeyUserPrefix, req.Key, req.Value))
+		case sclient.OpExists:
+			args.Add("label", labelKeyUserPrefix+req.Key)
+		}
+	}
+
+	containers, err := c.docker.ContainerList(ctx, container.ListOptions{
+		All:     true,
+		Filters: args,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing containers: %w", err)
+	}
+
+	var matched []types.Container
+	for _, ctr := range containers {
+		name := ctr.Labels[labelKeyName]
+		if name == "" || name <= opts.After {
+			continue
+		}
+		if !opts.Selector.Matches(userLabels(ctr.Labels)) {
+			continue
+		}
+		matched = append(matched, ctr)
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		return matched[i].Labels[labelKeyName] < matched[j].Labels[labelKeyName]
+	})
+
+	list := &sclient.SandboxList{}
+	if opts.Limit > 0 && len(matched) > opts.Limit {
+		matched = matched[:opts.Limit]
+		list.Continue = matched[len(matched)-1].Labels[labelKeyName]
+	}
+
+	for _, ctr := range matched {
+		dockerContainer, err := c.docker.ContainerInspect(ctx, ctr.ID)
+		if err != nil {
+			if dclient.IsErrNotFound(err) {
+				// Deleted since listing.
+				continue
+			}
+			return nil, fmt.Errorf("getting container %q: %w", ctr.ID, err)
+		}
+		sbx, err := c.toSandbox(dockerContainer)
+		if err != nil {
+			return nil, fmt.Errorf("reading container to sandbox: %w", err)
+		}
+		list.Items = append(list.Items, sbx)
+	}
+
+	return list, nil
 }
 
 func (c *DockerClient) DeleteSandbox(ctx context.Context, space, name string) error {
 	if space == "" {
 		return fmt.Errorf("space cannot be empty")
 	}
-	cname := containerName(space, name)
-	if err := c.docker.ContainerStop(ctx, cname, container.StopOptions{
+	dockerContainer, err := c.inspectSandbox(ctx, space, name)
+	if err != nil {
+		return err
+	}
+	// Operate on the ID to guarantee that the in-scope container is the one deleted.
+	id := dockerContainer.ID
+	if dockerContainer.State != nil && dockerContainer.State.Paused {
+		// Frozen processes cannot handle the stop signal, so stopping
+		// would wait for the full timeout before killing them.
+		if err := c.docker.ContainerUnpause(ctx, id); err != nil {
+			return fmt.Errorf("resuming container %q: %w", id, err)
+		}
+	}
+	if err := c.docker.ContainerStop(ctx, id, container.StopOptions{
 		// TODO: Configurable timeout.
 		// Timeout:
 	}); err != nil {
 		if dclient.IsErrNotFound(err) {
-			return fmt.Errorf("getting container %q: %w", cname, sclient.ErrSandboxNotFound)
+			return fmt.Errorf("stopping container %q: %w", id, sclient.ErrSandboxNotFound)
 		}
-		return fmt.Errorf("stoping container %q: %w", cname, err)
+		return fmt.Errorf("stoping container %q: %w", id, err)
 	}
-	if err := c.docker.ContainerRemove(ctx, containerName(space, name), container.RemoveOptions{}); err != nil {
+	if err := c.docker.ContainerRemove(ctx, id, container.RemoveOptions{}); err != nil {
 		if dclient.IsErrNotFound(err) {
-			return fmt.Errorf("removing container %q: %w", cname, sclient.ErrSandboxNotFound)
+			return fmt.Errorf("removing container %q: %w", id, sclient.ErrSandboxNotFound)
 		}
-		return fmt.Errorf("removing container %q: %w", cname, err)
+		return fmt.Errorf("removing container %q: %w", id, err)
+	}
+	c.records.delete(id)
+	c.closeRelay(id)
+	c.deleteEgressPolicy(id)
+	if c.cassettes != nil {
+		c.cassettes.Release(id)
 	}
 	return nil
 }
 
+func (c *DockerClient) PauseSandbox(ctx context.Context, space, name string) (*sclient.Sandbox, error) {
+	dockerContainer, err := c.inspectSandbox(ctx, space, name)
+	if err != nil {
+		return nil, err
+	}
+	id := dockerContainer.ID
+	switch {
+	case dockerContainer.State == nil || !dockerContainer.State.Running:
+		return nil, fmt.Errorf("sandbox %q: %w", name, sclient.ErrSandboxNotRunning)
+	case !dockerContainer.State.Paused:
+		if err := c.docker.ContainerPause(ctx, id); err != nil {
+			return nil, fmt.Errorf("pausing container %q: %w", id, err)
+		}
+		log.Printf("Paused sandbox: %q", id)
+	}
+	return c.GetSandbox(ctx, space, name)
+}
+
+func (c *DockerClient) ResumeSandbox(ctx context.Context, space, name string) (*sclient.Sandbox, error) {
+	dockerContainer, err := c.inspectSandbox(ctx, space, name)
+	if err != nil {
+		return nil, err
+	}
+	id := dockerContainer.ID
+	switch {
+	case dockerContainer.State == nil || !dockerContainer.State.Running:
+		return nil, fmt.Errorf("sandbox %q: %w", name, sclient.ErrSandboxNotRunning)
+	case dockerContainer.State.Paused:
+		if err := c.docker.ContainerUnpause(ctx, id); err != nil {
+			return nil, fmt.Errorf("resuming container %q: %w", id, err)
+		}
+		// Time spent paused does not count towards the idle timeout.
+		now := time.Now()
+		c.records.update(id, func(rec *sandboxRecord) {
+			rec.resumedAt = now
+		})
+		log.Printf("Resumed sandbox: %q", id)
+	}
+	return c.GetSandbox(ctx, space, name)
+}
+
+// StartSandbox starts a sandbox that was stopped by its idle_policy and waits
+// for it to become ready.
+func (c *DockerClient) StartSandbox(ctx context.Context, space, name string) (*sclient.Sandbox, error) {
+	dockerContainer, err := c.inspectSandbox(ctx, space, name)
+	if err != nil {
+		return nil, err
+	}
+	id := dockerContainer.ID
+	if dockerContainer.State != nil && dockerContainer.State.Running {
+		// It might have just been started by another call.
+		return c.WaitForReady(ctx, space, name, readyTimeout)
+	}
+
+	// Only one call starts the container, the others wait for it to
+	// become ready.
+	var starting bool
+	c.records.update(id, func(rec *sandboxRecord) {
+		if rec.done != nil {
+			return
+		}
+		starting = true
+		rec.done = make(chan struct{})
+		rec.readyAt = time.Time{}
+		rec.failReason = ""
+		rec.failMessage = ""
+		rec.stopReason = ""
+		rec.stopMessage = ""
+	})
+	if !starting {
+		return c.WaitForReady(ctx, space, name, readyTimeout)
+	}
+
+	fail := func(err error) {
+		c.records.resolve(id, func(rec *sandboxRecord) {
+			rec.failReason = "StartFailed"
+			rec.failMessage = fmt.Sprintf("Starting container: %v", err)
+		})
+	}
+
+	start := time.Now()
+	if err := c.docker.ContainerStart(ctx, id, container.StartOptions{}); err != nil {
+		fail(err)
+		return nil, fmt.Errorf("start: %w", err)
+	}
+	startup := v1.SandboxStartupTiming{
+		StartContainerMs: time.Since(start).Milliseconds(),
+	}
+	log.Printf("Started stopped sandbox: %q", id)
+
+	// The box is published on a new host port.
+	dockerContainer, err = c.docker.ContainerInspect(ctx, id)
+	if err != nil {
+		fail(err)
+		return nil, err
+	}
+	boxAddr, err := c.getBoxAddr(dockerContainer)
+	if err != nil {
+		fail(err)
+		return nil, fmt.Errorf("container %q: getting box address: %w", dockerContainer.Name, err)
+	}
+	// Waiting continues in the background if ctx is cancelled so that
+	// other calls see the result.
+	go c.waitForReady(id, dockerContainer.Config.Labels, boxAddr, startup, start)
+
+	return c.WaitForReady(ctx, space, name, readyTimeout)
+}
+
 type SandboxSpacedName struct {
 	Space string
 	Name  string
@@ -185,6 +692,7 @@ type SandboxSpacedName struct {
 
 func (c *DockerClient) ListAllSandboxes(ctx context.Context) ([]SandboxSpacedName, error) {
 	containers, err := c.docker.ContainerList(ctx, container.ListOptions{
+		All: true,
 		Filters: filters.NewArgs(
 			filters.Arg("label", fmt.Sprintf("%s=%s", labelKeyScope, c.scope)),
 		),
@@ -197,13 +705,13 @@ func (c *DockerClient) ListAllSandboxes(ctx context.Context) ([]SandboxSpacedNam
 	for i, container := range containers {
 		items[i] = SandboxSpacedName{
 			Space: container.Labels[labelKeySpace],
-			Name:  container.Names[0],
+			Name:  container.Labels[labelKeyName],
 		}
 	}
 	return items, nil
 }
 
-func (c *DockerClient) waitForHealthcheck(ctx context.Context, port int, interval, timeout time.Duration) error {
+func (c *DockerClient) waitForHealthcheck(ctx context.Context, id string, addr string, interval, timeout time.Duration) error {
 	start := time.Now()
 
 	ticker := time.NewTicker(interval)
@@ -218,14 +726,22 @@ func (c *DockerClient) waitForHealthcheck(ctx context.Context, port int, interva
 		if time.Since(start) > timeout {
 			return fmt.Errorf("healthcheck timeout")
 		}
-		if err := c.sendHealthcheck(ctx, port); err == nil {
+		if err := c.sendHealthcheck(ctx, addr); err == nil {
 			return nil
 		}
+		// Stop waiting early if the container is not going to become healthy.
+		dockerContainer, err := c.docker.ContainerInspect(ctx, id)
+		if err != nil {
+			return fmt.Errorf("inspecting container: %w", err)
+		}
+		if dockerContainer.State != nil && !dockerContainer.State.Running {
+			return fmt.Errorf("container exited with code %d", dockerContainer.State.ExitCode)
+		}
 	}
 }
 
-func (c *DockerClient) sendHealthcheck(ctx context.Context, port int) error {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("http://127.0.0.1:%d/healthz", port), nil)
+func (c *DockerClient) sendHealthcheck(ctx context.Context, addr string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("http://%s/healthz", addr), nil)
 	if err != nil {
 		return fmt.Errorf("create request: %w", err)
 	}
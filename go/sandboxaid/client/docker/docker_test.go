@@ -0,0 +1,23 @@
+package docker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDockerClient_inScope(t *testing.T) {
+	c := &DockerClient{scope: "a"}
+	labels := map[string]string{
+		labelKeyScope: "a",
+		labelKeySpace: "default",
+		labelKeyName:  "box",
+	}
+	require.True(t, c.inScope(labels, "default", "box"))
+	require.False(t, c.inScope(labels, "other", "box"), "different space")
+	require.False(t, c.inScope(labels, "default", "other"), "different name")
+
+	other := &DockerClient{scope: "b"}
+	require.False(t, other.inScope(labels, "default", "box"), "different scope")
+	require.False(t, c.inScope(map[string]string{}, "default", "box"), "unlabelled container")
+}
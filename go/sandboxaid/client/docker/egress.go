@@ -0,0 +1,346 @@
+package docker
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/network"
+	v1 "github.com/substratusai/sandboxai/go/api/v1"
+	sclient "github.com/substratusai/sandboxai/go/sandboxaid/client"
+	"github.com/substratusai/sandboxai/go/sandboxaid/egress"
+)
+
+// Sandboxes with an egress_allow list or a cassette are configured to use
+// the egress proxy of sandboxaid. They authenticate with a token that is
+// stored in a label along with their policy so that the proxy can look them
+// up after restarts.
+
+const labelKeyEgressToken = "sandboxai.egress-token"
+const labelKeyEgressAllow = "sandboxai.egress-allow"
+const labelKeyCassette = "sandboxai.cassette"
+
+// caCertPath is where the certificate of the cassette CA is installed in
+// sandboxes with a cassette.
+const caCertPath = "/etc/sandboxai/egress-ca.pem"
+
+// maxEgressConnections is the number of connections kept per sandbox.
+const maxEgressConnections = 1000
+
+var _ egress.Store = &DockerClient{}
+
+// SetEgressProxy enables the egress proxy. It listens on port at the gateway
+// of each network that has sandboxes which use it, so that it cannot be
+// reached from outside of the host. A port of 0 picks a free port when the
+// first listener is started. Listeners are started for the existing networks
+// so that their sandboxes keep working after sandboxaid restarts. Sandboxes
+// with an egress_allow list cannot be created until it is set.
+func (c *DockerClient) SetEgressProxy(ctx context.Context, proxy http.Handler, port int) error {
+	c.egressMtx.Lock()
+	c.egressProxy = proxy
+	c.egressProxyPort = port
+	c.egressMtx.Unlock()
+
+	networks, err := c.docker.NetworkList(ctx, network.ListOptions{
+		Filters: filters.NewArgs(
+			filters.Arg("label", fmt.Sprintf("%s=%s", labelKeyScope, c.scope)),
+			filters.Arg("label", fmt.Sprintf("%s=%s", labelKeyKind, kindNetwork)),
+		),
+	})
+	if err != nil {
+		return fmt.Errorf("listing networks: %w", err)
+	}
+	for _, n := range networks {
+		for _, cfg := range n.IPAM.Config {
+			// Networks of a Docker VM are skipped, sandboxes on
+			// them cannot use the egress proxy.
+			if local, _ := isLocalAddr(cfg.Gateway); !local {
+				continue
+			}
+			if _, err := c.listenEgress(cfg.Gateway); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// listenEgress starts the egress proxy on the gateway, if it is not already
+// listening there, and returns its port.
+func (c *DockerClient) listenEgress(gateway string) (int, error) {
+	c.egressMtx.Lock()
+	defer c.egressMtx.Unlock()
+	if _, ok := c.egressListeners[gateway]; ok {
+		return c.egressProxyPort, nil
+	}
+	ln, err := net.Listen("tcp", net.JoinHostPort(gateway, strconv.Itoa(c.egressProxyPort)))
+	if err != nil {
+		return 0, fmt.Errorf("listening for the egress proxy: %w", err)
+	}
+	if c.egressProxyPort == 0 {
+		c.egressProxyPort = ln.Addr().(*net.TCPAddr).Port
+	}
+	if c.egressListeners == nil {
+		c.egressListeners = make(map[string]net.Listener)
+	}
+	c.egressListeners[gateway] = ln
+	log.Printf("Egress proxy listening on address %s", ln.Addr())
+	go func() {
+		if err := http.Serve(ln, c.egressProxy); err != nil && !errors.Is(err, net.ErrClosed) {
+			log.Printf("Failed to serve egress proxy on address %s: %v", ln.Addr(), err)
+		}
+	}()
+	return c.egressProxyPort, nil
+}
+
+// closeEgress stops the egress proxy on the gateway of a removed network.
+func (c *DockerClient) closeEgress(gateway string) {
+	c.egressMtx.Lock()
+	ln, ok := c.egressListeners[gateway]
+	delete(c.egressListeners, gateway)
+	c.egressMtx.Unlock()
+	if ok {
+		ln.Close()
+	}
+}
+
+// SetCassettes sets the cassettes that the egress proxy records and
+// replays. Sandboxes with a cassette cannot be created until it is set.
+func (c *DockerClient) SetCassettes(cassettes *egress.Cassettes) {
+	c.cassettes = cassettes
+}
+
+// egressProxyHost is the name that sandboxes reach the egress proxy at. It is
+// mapped to the gateway of their network.
+const egressProxyHost = "host.docker.internal"
+
+// egressConfig returns the labels and environment variables that make a
+// sandbox use the egress proxy, and the extra hosts entry that points
+// egressProxyHost at the gateway of its network.
+func (c *DockerClient) egressConfig(allow []string, gateway string) (labels map[string]string, env []string, extraHost string, err error) {
+	if c.egressProxy == nil {
+		return nil, nil, "", sclient.ErrEgressProxyDisabled
+	}
+	// The gateway is only this host if Docker runs on it. When Docker runs
+	// in a VM the gateway is the VM, and internal networks have no route
+	// to the host of the VM.
+	local, err := isLocalAddr(gateway)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	if !local {
+		return nil, nil, "", fmt.Errorf("gateway %s is not an address of this host: %w", gateway, sclient.ErrEgressProxyUnreachable)
+	}
+	port, err := c.listenEgress(gateway)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	allowJSON, err := json.Marshal(allow)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("marshalling egress_allow: %w", err)
+	}
+	tokenBytes := make([]byte, 16)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return nil, nil, "", fmt.Errorf("generating proxy token: %w", err)
+	}
+	token := hex.EncodeToString(tokenBytes)
+
+	proxyURL := (&url.URL{
+		Scheme: "http",
+		User:   url.UserPassword(egress.ProxyUser, token),
+		Host:   net.JoinHostPort(egressProxyHost, strconv.Itoa(port)),
+	}).String()
+	labels = map[string]string{
+		labelKeyEgressToken: token,
+		labelKeyEgressAllow: string(allowJSON),
+	}
+	// Tools differ in which case they read.
+	for _, key := range []string{"HTTP_PROXY", "HTTPS_PROXY", "http_proxy", "https_proxy"} {
+		env = append(env, key+"="+proxyURL)
+	}
+	for _, key := range []string{"NO_PROXY", "no_proxy"} {
+		env = append(env, key+"=localhost,127.0.0.1,::1")
+	}
+	return labels, env, egressProxyHost + ":" + gateway, nil
+}
+
+// isLocalAddr returns true if the IP address belongs to an interface of this host.
+func isLocalAddr(addr string) (bool, error) {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false, fmt.Errorf("invalid IP address %q", addr)
+	}
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return false, fmt.Errorf("listing interface addresses: %w", err)
+	}
+	for _, a := range addrs {
+		if ipNet, ok := a.(*net.IPNet); ok && ipNet.IP.Equal(ip) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// cassetteConfig prepares the cassette of a new sandbox and returns the
+// labels and environment variables that make the sandbox trust the CA that
+// its HTTPS requests are intercepted with. The mode of the cassette is
+// resolved to record or replay.
+func (c *DockerClient) cassetteConfig(space string, cassette *v1.SandboxCassette) (labels map[string]string, env []string, err error) {
+	if c.cassettes == nil || c.egressProxy == nil {
+		return nil, nil, sclient.ErrEgressProxyDisabled
+	}
+	mode, err := c.cassettes.Prepare(space, *cassette)
+	if err != nil {
+		return nil, nil, fmt.Errorf("preparing cassette: %w", err)
+	}
+	cassetteJSON, err := json.Marshal(v1.SandboxCassette{Name: cassette.Name, Mode: mode})
+	if err != nil {
+		return nil, nil, fmt.Errorf("marshalling cassette: %w", err)
+	}
+	labels = map[string]string{labelKeyCassette: string(cassetteJSON)}
+	// Tools differ in which variable they read.
+	for _, key := range []string{"SSL_CERT_FILE", "REQUESTS_CA_BUNDLE", "CURL_CA_BUNDLE", "NODE_EXTRA_CA_CERTS"} {
+		env = append(env, key+"="+caCertPath)
+	}
+	return labels, env, nil
+}
+
+// installCACert copies the certificate of the cassette CA into a container
+// before it is started.
+func (c *DockerClient) installCACert(ctx context.Context, id string) error {
+	cert := c.cassettes.CACert()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if err := tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     strings.TrimPrefix(caCertPath, "/"),
+		Mode:     0o644,
+		Size:     int64(len(cert)),
+		ModTime:  time.Now(),
+	}); err != nil {
+		return fmt.Errorf("writing tar header: %w", err)
+	}
+	if _, err := tw.Write(cert); err != nil {
+		return fmt.Errorf("writing tar content: %w", err)
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("closing tar: %w", err)
+	}
+	if err := c.docker.CopyToContainer(ctx, id, "/", &buf, container.CopyToContainerOptions{}); err != nil {
+		return fmt.Errorf("copying CA certificate to container: %w", err)
+	}
+	return nil
+}
+
+// LookupEgress returns the policy of the sandbox that the proxy token
+// belongs to.
+func (c *DockerClient) LookupEgress(ctx context.Context, token string) (*egress.Policy, error) {
+	c.egressMtx.Lock()
+	defer c.egressMtx.Unlock()
+	policy, ok := c.egressPolicies[token]
+	if !ok {
+		return nil, egress.ErrUnknownToken
+	}
+	return policy, nil
+}
+
+// addEgressPolicy caches the egress policy stored in the labels of a
+// container, if it has one, for LookupEgress. Policies are added when
+// sandboxes are created and from the event feed.
+func (c *DockerClient) addEgressPolicy(id string, labels map[string]string) {
+	token := labels[labelKeyEgressToken]
+	if token == "" {
+		return
+	}
+	allow, err := egressAllow(labels)
+	if err != nil {
+		log.Printf("Failed to read egress policy of container %q: %v", id, err)
+		return
+	}
+	cassette, err := egressCassette(labels)
+	if err != nil {
+		log.Printf("Failed to read egress policy of container %q: %v", id, err)
+		return
+	}
+	c.egressMtx.Lock()
+	defer c.egressMtx.Unlock()
+	if c.egressPolicies == nil {
+		c.egressPolicies = make(map[string]*egress.Policy)
+	}
+	c.egressPolicies[token] = &egress.Policy{
+		ID:       id,
+		Space:    labels[labelKeySpace],
+		Allow:    allow,
+		Cassette: cassette,
+	}
+}
+
+// deleteEgressPolicy removes the cached egress policy of a removed container.
+func (c *DockerClient) deleteEgressPolicy(id string) {
+	c.egressMtx.Lock()
+	defer c.egressMtx.Unlock()
+	for token, policy := range c.egressPolicies {
+		if policy.ID == id {
+			delete(c.egressPolicies, token)
+		}
+	}
+}
+
+// RecordEgress records a connection made through the egress proxy.
+func (c *DockerClient) RecordEgress(id string, conn v1.EgressConnection) {
+	c.records.update(id, func(rec *sandboxRecord) {
+		if len(rec.egress) >= maxEgressConnections {
+			rec.egress = rec.egress[1:]
+		}
+		rec.egress = append(rec.egress, conn)
+	})
+}
+
+func (c *DockerClient) ListEgressConnections(ctx context.Context, sbx *sclient.Sandbox) ([]v1.EgressConnection, error) {
+	rec := c.records.get(sbx.UID)
+	if rec == nil {
+		return nil, nil
+	}
+	return slices.Clone(rec.egress), nil
+}
+
+// egressAllow returns the egress_allow list stored in the labels of a container.
+func egressAllow(labels map[string]string) ([]string, error) {
+	allowJSON := labels[labelKeyEgressAllow]
+	if allowJSON == "" {
+		return nil, nil
+	}
+	var allow []string
+	if err := json.Unmarshal([]byte(allowJSON), &allow); err != nil {
+		return nil, fmt.Errorf("unmarshalling egress_allow: %w", err)
+	}
+	return allow, nil
+}
+
+// egressCassette returns the cassette stored in the labels of a container.
+func egressCassette(labels map[string]string) (*v1.SandboxCassette, error) {
+	cassetteJSON := labels[labelKeyCassette]
+	if cassetteJSON == "" {
+		return nil, nil
+	}
+	var cassette v1.SandboxCassette
+	if err := json.Unmarshal([]byte(cassetteJSON), &cassette); err != nil {
+		return nil, fmt.Errorf("unmarshalling cassette: %w", err)
+	}
+	return &cassette, nil
+}
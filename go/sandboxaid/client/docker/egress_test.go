@@ -0,0 +1,51 @@
+package docker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	v1 "github.com/substratusai/sandboxai/go/api/v1"
+	"github.com/substratusai/sandboxai/go/sandboxaid/egress"
+)
+
+func TestDockerClient_egressPolicies(t *testing.T) {
+	c := &DockerClient{}
+	ctx := context.Background()
+
+	c.addEgressPolicy("without-token", map[string]string{labelKeySpace: "default"})
+	c.addEgressPolicy("abc", map[string]string{
+		labelKeySpace:       "default",
+		labelKeyEgressToken: "token",
+		labelKeyEgressAllow: `["pypi.org"]`,
+		labelKeyCassette:    `{"name":"evals","mode":"replay"}`,
+	})
+
+	policy, err := c.LookupEgress(ctx, "token")
+	require.NoError(t, err)
+	require.Equal(t, &egress.Policy{
+		ID:       "abc",
+		Space:    "default",
+		Allow:    []string{"pypi.org"},
+		Cassette: &v1.SandboxCassette{Name: "evals", Mode: v1.CassetteModeReplay},
+	}, policy)
+	_, err = c.LookupEgress(ctx, "")
+	require.ErrorIs(t, err, egress.ErrUnknownToken)
+
+	c.deleteEgressPolicy("abc")
+	_, err = c.LookupEgress(ctx, "token")
+	require.ErrorIs(t, err, egress.ErrUnknownToken)
+}
+
+func Test_isLocalAddr(t *testing.T) {
+	local, err := isLocalAddr("127.0.0.1")
+	require.NoError(t, err)
+	require.True(t, local)
+
+	local, err = isLocalAddr("192.0.2.1")
+	require.NoError(t, err)
+	require.False(t, local, "documentation address")
+
+	_, err = isLocalAddr("gateway")
+	require.Error(t, err)
+}
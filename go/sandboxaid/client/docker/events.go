@@ -0,0 +1,284 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	v1 "github.com/substratusai/sandboxai/go/api/v1"
+	sclient "github.com/substratusai/sandboxai/go/sandboxaid/client"
+)
+
+// maxBufferedEvents is the number of past events that watches can be
+// resumed from.
+const maxBufferedEvents = 1024
+
+// eventBroker keeps a buffer of recent sandbox events and notifies
+// watchers when new events are published.
+//
+// Resume tokens have the form "<epoch>-<seq>". The epoch is unique to the
+// broker so that tokens from a previous sandboxaid process are rejected
+// rather than silently skipping events.
+type eventBroker struct {
+	mtx     sync.Mutex
+	epoch   string
+	seq     uint64
+	buf     []sclient.Event
+	changed chan struct{}
+}
+
+func newEventBroker() *eventBroker {
+	return &eventBroker{
+		epoch:   generateRandomName()[:8],
+		changed: make(chan struct{}),
+	}
+}
+
+func (b *eventBroker) publish(ev sclient.Event) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	b.seq++
+	ev.ResumeToken = fmt.Sprintf("%s-%d", b.epoch, b.seq)
+	b.buf = append(b.buf, ev)
+	if len(b.buf) > maxBufferedEvents {
+		b.buf = append(b.buf[:0:0], b.buf[len(b.buf)-maxBufferedEvents:]...)
+	}
+
+	// Wake up all watchers.
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
+
+// cursor returns the sequence number that a watch should start after.
+func (b *eventBroker) cursor(token string) (uint64, error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if token == "" {
+		return b.seq, nil
+	}
+	epoch, seqStr, ok := strings.Cut(token, "-")
+	if !ok || epoch != b.epoch {
+		return 0, sclient.ErrResumeTokenExpired
+	}
+	seq, err := strconv.ParseUint(seqStr, 10, 64)
+	if err != nil || seq > b.seq {
+		return 0, sclient.ErrResumeTokenExpired
+	}
+	// Make sure that no events were dropped from the buffer after seq.
+	if oldest := b.seq - uint64(len(b.buf)); seq < oldest {
+		return 0, sclient.ErrResumeTokenExpired
+	}
+	return seq, nil
+}
+
+// since returns the events after the given sequence number, the sequence
+// number of the last event, and a channel that is closed on the next publish.
+func (b *eventBroker) since(seq uint64) ([]sclient.Event, uint64, <-chan struct{}) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	n := b.seq - seq
+	if n > uint64(len(b.buf)) {
+		n = uint64(len(b.buf))
+	}
+	evs := make([]sclient.Event, n)
+	copy(evs, b.buf[uint64(len(b.buf))-n:])
+	return evs, b.seq, b.changed
+}
+
+func (c *DockerClient) WatchSandboxes(ctx context.Context, space string, opts sclient.WatchOptions) (<-chan sclient.Event, error) {
+	if space == "" {
+		return nil, fmt.Errorf("space cannot be empty")
+	}
+	c.startEventFeed()
+
+	seq, err := c.events.cursor(opts.ResumeToken)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan sclient.Event)
+	go func() {
+		defer close(ch)
+		for {
+			evs, last, changed := c.events.since(seq)
+			seq = last
+			for _, ev := range evs {
+				if ev.Space != space || !opts.Selector.Matches(ev.Labels) {
+					continue
+				}
+				select {
+				case ch <- ev:
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case <-changed:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// publishEvent publishes an event that does not originate from Docker.
+func (c *DockerClient) publishEvent(typ v1.SandboxEventType, id string, labels map[string]string, reason, message string) {
+	if c.events == nil {
+		return
+	}
+	c.events.publish(sclient.Event{
+		SandboxEvent: v1.SandboxEvent{
+			Type:    typ,
+			Space:   labels[labelKeySpace],
+			Name:    labels[labelKeyName],
+			UID:     id,
+			Time:    time.Now(),
+			Reason:  reason,
+			Message: message,
+		},
+		Labels: userLabels(labels),
+	})
+}
+
+// startEventFeed starts publishing events from Docker (once).
+// The feed runs for the lifetime of the process.
+func (c *DockerClient) startEventFeed() {
+	c.eventFeedOnce.Do(func() {
+		go c.runEventFeed(context.Background())
+	})
+}
+
+func (c *DockerClient) runEventFeed(ctx context.Context) {
+	since := time.Now()
+	// Containers created before the feed started (by a previous sandboxaid)
+	// have no records yet, later ones are added by their create events.
+	if err := c.addRecords(ctx); err != nil {
+		log.Printf("Failed to add records for existing sandboxes: %v", err)
+	}
+	for {
+		msgs, errs := c.docker.Events(ctx, events.ListOptions{
+			Since: strconv.FormatInt(since.Unix(), 10),
+			Filters: filters.NewArgs(
+				filters.Arg("type", string(events.ContainerEventType)),
+				filters.Arg("label", fmt.Sprintf("%s=%s", labelKeyScope, c.scope)),
+				filters.Arg("event", string(events.ActionCreate)),
+				filters.Arg("event", string(events.ActionDie)),
+				filters.Arg("event", string(events.ActionOOM)),
+				filters.Arg("event", string(events.ActionDestroy)),
+				filters.Arg("event", string(events.ActionPause)),
+				filters.Arg("event", string(events.ActionUnPause)),
+			),
+		})
+	recv:
+		for {
+			select {
+			case msg := <-msgs:
+				msgTime := time.Unix(0, msg.TimeNano)
+				// Events can be replayed after reconnecting.
+				if !msgTime.After(since) {
+					continue
+				}
+				since = msgTime
+				c.updateRecords(msg)
+				if ev, ok := dockerEventToSandboxEvent(msg); ok {
+					c.events.publish(ev)
+				}
+			case err := <-errs:
+				log.Printf("Docker events stream failed, reconnecting: %v", err)
+				break recv
+			case <-ctx.Done():
+				return
+			}
+		}
+		select {
+		case <-time.After(time.Second):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// addRecords adds records (and egress policies) for all of the containers in
+// the client's scope.
+func (c *DockerClient) addRecords(ctx context.Context) error {
+	containers, err := c.docker.ContainerList(ctx, container.ListOptions{
+		All: true,
+		Filters: filters.NewArgs(
+			filters.Arg("label", fmt.Sprintf("%s=%s", labelKeyScope, c.scope)),
+		),
+	})
+	if err != nil {
+		return fmt.Errorf("listing containers: %w", err)
+	}
+	for _, ctr := range containers {
+		c.records.add(ctr.ID, func(*sandboxRecord) {})
+		c.addEgressPolicy(ctr.ID, ctr.Labels)
+	}
+	return nil
+}
+
+// updateRecords keeps the records in sync with the containers that exist,
+// including containers that are created or removed outside of the client.
+func (c *DockerClient) updateRecords(msg events.Message) {
+	switch msg.Action {
+	case events.ActionCreate:
+		c.records.add(msg.Actor.ID, func(*sandboxRecord) {})
+		// The attributes of events include the labels of the container.
+		c.addEgressPolicy(msg.Actor.ID, msg.Actor.Attributes)
+	case events.ActionDestroy:
+		c.records.delete(msg.Actor.ID)
+		c.closeRelay(msg.Actor.ID)
+		c.deleteEgressPolicy(msg.Actor.ID)
+	}
+}
+
+// dockerEventToSandboxEvent converts a Docker container event into a sandbox event.
+// False is returned for events that are not relevant to sandboxes.
+func dockerEventToSandboxEvent(msg events.Message) (sclient.Event, bool) {
+	attrs := msg.Actor.Attributes
+	ev := sclient.Event{
+		SandboxEvent: v1.SandboxEvent{
+			Space: attrs[labelKeySpace],
+			Name:  attrs[labelKeyName],
+			UID:   msg.Actor.ID,
+			Time:  time.Unix(0, msg.TimeNano),
+		},
+		Labels: userLabels(attrs),
+	}
+	if ev.Space == "" || ev.Name == "" {
+		return sclient.Event{}, false
+	}
+
+	switch msg.Action {
+	case events.ActionCreate:
+		ev.Type = v1.SandboxEventCreated
+	case events.ActionDie:
+		ev.Type = v1.SandboxEventStopped
+		if code, err := strconv.Atoi(attrs["exitCode"]); err == nil {
+			ev.ExitCode = &code
+			ev.Message = fmt.Sprintf("Container exited with code %d", code)
+		}
+	case events.ActionOOM:
+		ev.Type = v1.SandboxEventOOMKilled
+		ev.Message = "Container ran out of memory"
+	case events.ActionDestroy:
+		ev.Type = v1.SandboxEventDeleted
+	case events.ActionPause:
+		ev.Type = v1.SandboxEventPaused
+	case events.ActionUnPause:
+		ev.Type = v1.SandboxEventResumed
+	default:
+		return sclient.Event{}, false
+	}
+	return ev, true
+}
@@ -0,0 +1,114 @@
+package docker
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/events"
+	"github.com/stretchr/testify/require"
+	v1 "github.com/substratusai/sandboxai/go/api/v1"
+	sclient "github.com/substratusai/sandboxai/go/sandboxaid/client"
+)
+
+func TestEventBroker(t *testing.T) {
+	b := newEventBroker()
+
+	start, err := b.cursor("")
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		b.publish(sclient.Event{SandboxEvent: v1.SandboxEvent{Name: fmt.Sprint(i)}})
+	}
+
+	evs, last, _ := b.since(start)
+	require.Len(t, evs, 3)
+	require.Equal(t, uint64(3), last)
+	require.Equal(t, "0", evs[0].Name)
+
+	// Resume after the first event.
+	seq, err := b.cursor(evs[0].ResumeToken)
+	require.NoError(t, err)
+	evs, _, changed := b.since(seq)
+	require.Len(t, evs, 2)
+	require.Equal(t, "1", evs[0].Name)
+
+	b.publish(sclient.Event{})
+	select {
+	case <-changed:
+	default:
+		t.Fatal("publish should notify watchers")
+	}
+
+	_, err = b.cursor("other-1")
+	require.ErrorIs(t, err, sclient.ErrResumeTokenExpired, "token from another epoch")
+	_, err = b.cursor(b.epoch + "-100")
+	require.ErrorIs(t, err, sclient.ErrResumeTokenExpired, "token from the future")
+	_, err = b.cursor("garbage")
+	require.ErrorIs(t, err, sclient.ErrResumeTokenExpired)
+}
+
+func TestEventBrokerExpiry(t *testing.T) {
+	b := newEventBroker()
+	b.publish(sclient.Event{})
+	first, _, _ := b.since(0)
+	for i := 0; i < maxBufferedEvents; i++ {
+		b.publish(sclient.Event{})
+	}
+	_, err := b.cursor(first[0].ResumeToken)
+	require.NoError(t, err, "the event after the token is still buffered")
+
+	b.publish(sclient.Event{})
+	_, err = b.cursor(first[0].ResumeToken)
+	require.ErrorIs(t, err, sclient.ErrResumeTokenExpired, "events after the token were dropped")
+}
+
+func Test_dockerEventToSandboxEvent(t *testing.T) {
+	attrs := map[string]string{
+		labelKeyScope:               "default",
+		labelKeySpace:               "default",
+		labelKeyName:                "box",
+		labelKeyUserPrefix + "team": "ml",
+		"exitCode":                  "137",
+	}
+	now := time.Now()
+
+	cases := []struct {
+		action  events.Action
+		attrs   map[string]string
+		expOK   bool
+		expType v1.SandboxEventType
+	}{
+		{action: events.ActionCreate, attrs: attrs, expOK: true, expType: v1.SandboxEventCreated},
+		{action: events.ActionDie, attrs: attrs, expOK: true, expType: v1.SandboxEventStopped},
+		{action: events.ActionOOM, attrs: attrs, expOK: true, expType: v1.SandboxEventOOMKilled},
+		{action: events.ActionDestroy, attrs: attrs, expOK: true, expType: v1.SandboxEventDeleted},
+		{action: events.ActionPause, attrs: attrs, expOK: true, expType: v1.SandboxEventPaused},
+		{action: events.ActionUnPause, attrs: attrs, expOK: true, expType: v1.SandboxEventResumed},
+		{action: events.ActionStart, attrs: attrs, expOK: false},
+		{action: events.ActionCreate, attrs: map[string]string{}, expOK: false},
+	}
+
+	for _, c := range cases {
+		t.Run(string(c.action), func(t *testing.T) {
+			ev, ok := dockerEventToSandboxEvent(events.Message{
+				Action:   c.action,
+				Actor:    events.Actor{ID: "abc", Attributes: c.attrs},
+				TimeNano: now.UnixNano(),
+			})
+			require.Equal(t, c.expOK, ok)
+			if !ok {
+				return
+			}
+			require.Equal(t, c.expType, ev.Type)
+			require.Equal(t, "default", ev.Space)
+			require.Equal(t, "box", ev.Name)
+			require.Equal(t, "abc", ev.UID)
+			require.Equal(t, map[string]string{"team": "ml"}, ev.Labels)
+			require.True(t, now.Equal(ev.Time))
+			if c.expType == v1.SandboxEventStopped {
+				require.Equal(t, 137, *ev.ExitCode)
+			}
+		})
+	}
+}
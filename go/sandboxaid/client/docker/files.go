@@ -0,0 +1,343 @@
+package docker
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	dclient "github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/docker/pkg/stdcopy"
+	v1 "github.com/substratusai/sandboxai/go/api/v1"
+	sclient "github.com/substratusai/sandboxai/go/sandboxaid/client"
+)
+
+// Files are transferred with the Docker archive API (tar streams) so that
+// they do not depend on anything being installed in the sandbox image.
+// Directories are listed and paths are deleted by boxd instead: the archive
+// API can only list a directory by archiving its entire subtree, and it
+// cannot delete.
+
+const defaultFileMode = 0o644
+
+func (c *DockerClient) WriteFile(ctx context.Context, sbx *sclient.Sandbox, p string, content []byte, mode fs.FileMode) (*v1.FileInfo, error) {
+	if len(content) > sclient.MaxFileSize {
+		return nil, fmt.Errorf("writing %q: %d bytes: %w", p, len(content), sclient.ErrFileTooLarge)
+	}
+	if mode == 0 {
+		mode = defaultFileMode
+	}
+
+	if info, err := c.StatPath(ctx, sbx, p); err == nil && info.Type == v1.FileTypeDirectory {
+		return nil, fmt.Errorf("writing %q: %w", p, sclient.ErrIsDirectory)
+	} else if err != nil && !errors.Is(err, sclient.ErrPathNotFound) {
+		return nil, err
+	}
+
+	// The archive is extracted at the root so that Docker creates any
+	// missing parent directories.
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if err := tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     strings.TrimPrefix(p, "/"),
+		Mode:     int64(mode.Perm()),
+		Size:     int64(len(content)),
+		ModTime:  time.Now(),
+	}); err != nil {
+		return nil, fmt.Errorf("writing tar header: %w", err)
+	}
+	if _, err := tw.Write(content); err != nil {
+		return nil, fmt.Errorf("writing tar content: %w", err)
+	}
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("closing tar: %w", err)
+	}
+
+	if err := c.docker.CopyToContainer(ctx, sbx.UID, "/", &buf, container.CopyToContainerOptions{}); err != nil {
+		return nil, fmt.Errorf("copying %q to container: %w", p, err)
+	}
+
+	return c.StatPath(ctx, sbx, p)
+}
+
+func (c *DockerClient) ReadFile(ctx context.Context, sbx *sclient.Sandbox, p string) ([]byte, *v1.FileInfo, error) {
+	info, src, err := c.statFollow(ctx, sbx, p)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch info.Type {
+	case v1.FileTypeDirectory:
+		return nil, nil, fmt.Errorf("reading %q: %w", p, sclient.ErrIsDirectory)
+	case v1.FileTypeFile:
+	default:
+		return nil, nil, fmt.Errorf("reading %q: not a regular file", p)
+	}
+	if info.Size > sclient.MaxFileSize {
+		return nil, nil, fmt.Errorf("reading %q: %d bytes: %w", p, info.Size, sclient.ErrFileTooLarge)
+	}
+
+	rc, _, err := c.docker.CopyFromContainer(ctx, sbx.UID, src)
+	if err != nil {
+		return nil, nil, wrapPathError(fmt.Sprintf("copying %q from container", p), err)
+	}
+	defer rc.Close()
+
+	tr := tar.NewReader(rc)
+	hdr, err := tr.Next()
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading tar header: %w", err)
+	}
+	// The file could have grown since it was stat'd.
+	if hdr.Size > sclient.MaxFileSize {
+		return nil, nil, fmt.Errorf("reading %q: %d bytes: %w", p, hdr.Size, sclient.ErrFileTooLarge)
+	}
+	content, err := io.ReadAll(tr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading tar content: %w", err)
+	}
+	info.Size = int64(len(content))
+	info.ModifiedAt = hdr.ModTime
+
+	return content, info, nil
+}
+
+func (c *DockerClient) ListDir(ctx context.Context, sbx *sclient.Sandbox, p string) ([]v1.FileInfo, bool, error) {
+	var result v1.ListDirResult
+	if err := c.callBox(ctx, sbx, "/tools:list_dir", v1.ListDirRequest{Path: p}, &result); err != nil {
+		var boxErr *boxError
+		if errors.As(err, &boxErr) && boxErr.status == http.StatusBadRequest {
+			return nil, false, fmt.Errorf("listing %q: %w", p, sclient.ErrNotDirectory)
+		}
+		return nil, false, fmt.Errorf("listing %q: %w", p, err)
+	}
+	return result.Entries, result.Truncated, nil
+}
+
+func (c *DockerClient) StatPath(ctx context.Context, sbx *sclient.Sandbox, p string) (*v1.FileInfo, error) {
+	stat, err := c.docker.ContainerStatPath(ctx, sbx.UID, p)
+	if err != nil {
+		return nil, wrapPathError(fmt.Sprintf("stat %q", p), err)
+	}
+	return pathStatToFileInfo(stat, p), nil
+}
+
+func (c *DockerClient) DeletePath(ctx context.Context, sbx *sclient.Sandbox, p string, recursive bool) error {
+	if p == "/" {
+		return fmt.Errorf("deleting %q: the root directory cannot be deleted", p)
+	}
+	if err := c.callBox(ctx, sbx, "/tools:delete_path", v1.DeletePathRequest{Path: p, Recursive: recursive}, nil); err != nil {
+		var boxErr *boxError
+		if errors.As(err, &boxErr) && boxErr.status == http.StatusConflict {
+			return fmt.Errorf("deleting %q: %w", p, sclient.ErrDirectoryNotEmpty)
+		}
+		return fmt.Errorf("deleting %q: %w", p, err)
+	}
+	return nil
+}
+
+// boxError is an error response from boxd.
+type boxError struct {
+	status  int
+	message string
+}
+
+func (e *boxError) Error() string {
+	return fmt.Sprintf("boxd responded with %d: %s", e.status, e.message)
+}
+
+// callBox sends a JSON request to boxd and decodes the response into result
+// (if not nil). Error responses are returned as a *boxError, or wrap
+// ErrPathNotFound if the path was not found.
+func (c *DockerClient) callBox(ctx context.Context, sbx *sclient.Sandbox, p string, req, result any) error {
+	if sbx.BoxAddr == "" {
+		return sclient.ErrSandboxNotRunning
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("marshalling request: %w", err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://"+sbx.BoxAddr+p, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp, err := c.httpc.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("calling boxd: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		// boxd sends v1.Error bodies for errors that it handles and
+		// FastAPI sends {"detail": ...} for the rest.
+		var errBody struct {
+			v1.Error
+			Detail any `json:"detail"`
+		}
+		message := strings.TrimSpace(string(respBody))
+		if json.Unmarshal(respBody, &errBody) == nil {
+			switch {
+			case errBody.Reason == "PathNotFound":
+				return fmt.Errorf("%s: %w", errBody.Message, sclient.ErrPathNotFound)
+			case errBody.Message != "":
+				message = errBody.Message
+			case errBody.Detail != nil:
+				message = fmt.Sprint(errBody.Detail)
+			}
+		}
+		return &boxError{status: resp.StatusCode, message: message}
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+	return nil
+}
+
+// statFollow stats a path, following a symlink if there is one. The path
+// that the symlink resolved to is returned as src.
+func (c *DockerClient) statFollow(ctx context.Context, sbx *sclient.Sandbox, p string) (info *v1.FileInfo, src string, err error) {
+	stat, err := c.docker.ContainerStatPath(ctx, sbx.UID, p)
+	if err != nil {
+		return nil, "", wrapPathError(fmt.Sprintf("stat %q", p), err)
+	}
+	src = p
+	if stat.Mode&fs.ModeSymlink != 0 {
+		src = stat.LinkTarget
+		stat, err = c.docker.ContainerStatPath(ctx, sbx.UID, src)
+		if err != nil {
+			return nil, "", wrapPathError(fmt.Sprintf("stat %q (target of %q)", src, p), err)
+		}
+	}
+	info = pathStatToFileInfo(stat, p)
+	return info, src, nil
+}
+
+// exec runs a command in the container and returns its exit code and
+// combined output.
+func (c *DockerClient) exec(ctx context.Context, id string, cmd []string) (int, string, error) {
+	created, err := c.docker.ContainerExecCreate(ctx, id, container.ExecOptions{
+		Cmd:          cmd,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return 0, "", fmt.Errorf("creating exec: %w", err)
+	}
+	attached, err := c.docker.ContainerExecAttach(ctx, created.ID, container.ExecAttachOptions{})
+	if err != nil {
+		return 0, "", fmt.Errorf("attaching to exec: %w", err)
+	}
+	defer attached.Close()
+
+	var output bytes.Buffer
+	if _, err := stdcopy.StdCopy(&output, &output, attached.Reader); err != nil {
+		return 0, "", fmt.Errorf("reading exec output: %w", err)
+	}
+	inspect, err := c.docker.ContainerExecInspect(ctx, created.ID)
+	if err != nil {
+		return 0, "", fmt.Errorf("inspecting exec: %w", err)
+	}
+	return inspect.ExitCode, output.String(), nil
+}
+
+func wrapPathError(msg string, err error) error {
+	if dclient.IsErrNotFound(err) {
+		return fmt.Errorf("%s: %w", msg, sclient.ErrPathNotFound)
+	}
+	return fmt.Errorf("%s: %w", msg, err)
+}
+
+func fileModeToType(mode fs.FileMode) v1.FileType {
+	switch {
+	case mode.IsRegular():
+		return v1.FileTypeFile
+	case mode.IsDir():
+		return v1.FileTypeDirectory
+	case mode&fs.ModeSymlink != 0:
+		return v1.FileTypeSymlink
+	default:
+		return v1.FileTypeOther
+	}
+}
+
+func pathStatToFileInfo(stat container.PathStat, p string) *v1.FileInfo {
+	info := &v1.FileInfo{
+		Name:       path.Base(p),
+		Path:       p,
+		Type:       fileModeToType(stat.Mode),
+		Size:       stat.Size,
+		Mode:       uint32(stat.Mode.Perm()),
+		ModifiedAt: stat.Mtime,
+	}
+	if info.Type == v1.FileTypeSymlink {
+		info.LinkTarget = stat.LinkTarget
+	}
+	return info
+}
+
+func (c *DockerClient) PutArchive(ctx context.Context, sbx *sclient.Sandbox, p string, archive io.Reader) error {
+	info, err := c.StatPath(ctx, sbx, p)
+	switch {
+	case errors.Is(err, sclient.ErrPathNotFound):
+		if err := c.mkdirAll(ctx, sbx, p); err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	case info.Type != v1.FileTypeDirectory:
+		return fmt.Errorf("extracting archive to %q: %w", p, sclient.ErrNotDirectory)
+	}
+
+	if err := c.docker.CopyToContainer(ctx, sbx.UID, p, archive, container.CopyToContainerOptions{}); err != nil {
+		if errdefs.IsInvalidParameter(err) {
+			return fmt.Errorf("copying archive to %q: %w: %v", p, sclient.ErrInvalidArchive, err)
+		}
+		return fmt.Errorf("copying archive to %q: %w", p, err)
+	}
+	return nil
+}
+
+func (c *DockerClient) GetArchive(ctx context.Context, sbx *sclient.Sandbox, p string) (io.ReadCloser, error) {
+	rc, _, err := c.docker.CopyFromContainer(ctx, sbx.UID, p)
+	if err != nil {
+		return nil, wrapPathError(fmt.Sprintf("copying %q from container", p), err)
+	}
+	return rc, nil
+}
+
+// mkdirAll creates a directory and any missing parents by extracting an
+// archive that only contains the directory.
+func (c *DockerClient) mkdirAll(ctx context.Context, sbx *sclient.Sandbox, p string) error {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if err := tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     strings.TrimPrefix(p, "/") + "/",
+		Mode:     0o755,
+		ModTime:  time.Now(),
+	}); err != nil {
+		return fmt.Errorf("writing tar header: %w", err)
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("closing tar: %w", err)
+	}
+	if err := c.docker.CopyToContainer(ctx, sbx.UID, "/", &buf, container.CopyToContainerOptions{}); err != nil {
+		return fmt.Errorf("creating directory %q: %w", p, err)
+	}
+	return nil
+}
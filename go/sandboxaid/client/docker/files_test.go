@@ -0,0 +1,74 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	v1 "github.com/substratusai/sandboxai/go/api/v1"
+	sclient "github.com/substratusai/sandboxai/go/sandboxaid/client"
+)
+
+func Test_fileModeToType(t *testing.T) {
+	require.Equal(t, v1.FileTypeFile, fileModeToType(0o644))
+	require.Equal(t, v1.FileTypeDirectory, fileModeToType(fs.ModeDir|0o755))
+	require.Equal(t, v1.FileTypeSymlink, fileModeToType(fs.ModeSymlink|0o777))
+	require.Equal(t, v1.FileTypeOther, fileModeToType(fs.ModeNamedPipe|0o600))
+}
+
+func TestDockerClient_boxFileTools(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Path string `json:"path"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		switch {
+		case req.Path == "/missing":
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message":"path not found: /missing","reason":"PathNotFound"}`)
+		case r.URL.Path == "/tools:list_dir" && req.Path == "/file":
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"detail":"not a directory: /file"}`)
+		case r.URL.Path == "/tools:list_dir":
+			fmt.Fprint(w, `{"entries":[{"name":"a","path":"/work/a","type":"File","size":1,"mode":420,"modified_at":"2025-01-01T00:00:00Z"}]}`)
+		case r.URL.Path == "/tools:delete_path" && req.Path == "/full":
+			w.WriteHeader(http.StatusConflict)
+			fmt.Fprint(w, `{"detail":"directory not empty: /full"}`)
+		case r.URL.Path == "/tools:delete_path":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	c := &DockerClient{httpc: http.DefaultClient}
+	sbx := &sclient.Sandbox{BoxAddr: srv.Listener.Addr().String()}
+	ctx := context.Background()
+
+	entries, truncated, err := c.ListDir(ctx, sbx, "/work")
+	require.NoError(t, err)
+	require.False(t, truncated)
+	require.Len(t, entries, 1)
+	require.Equal(t, "/work/a", entries[0].Path)
+
+	_, _, err = c.ListDir(ctx, sbx, "/missing")
+	require.ErrorIs(t, err, sclient.ErrPathNotFound)
+	_, _, err = c.ListDir(ctx, sbx, "/file")
+	require.ErrorIs(t, err, sclient.ErrNotDirectory)
+
+	require.NoError(t, c.DeletePath(ctx, sbx, "/work/a", false))
+	require.ErrorIs(t, c.DeletePath(ctx, sbx, "/missing", false), sclient.ErrPathNotFound)
+	require.ErrorIs(t, c.DeletePath(ctx, sbx, "/full", false), sclient.ErrDirectoryNotEmpty)
+
+	_, _, err = c.ListDir(ctx, &sclient.Sandbox{}, "/work")
+	require.ErrorIs(t, err, sclient.ErrSandboxNotRunning)
+}
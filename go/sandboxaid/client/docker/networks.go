@@ -0,0 +1,113 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/network"
+	dclient "github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+	v1 "github.com/substratusai/sandboxai/go/api/v1"
+)
+
+// Sandboxes are attached to a dedicated Docker network per space and mode
+// rather than Docker's default bridge. The networks are created when the
+// first sandbox needs them and removed along with the space. Sandboxes with
+// the none mode are not attached to any network (see ensureNetwork).
+
+// labelKeyNetworkMode is set on containers to the network mode of the sandbox.
+const labelKeyNetworkMode = "sandboxai.network-mode"
+
+const kindNetwork = "network"
+
+func (c *DockerClient) networkName(space string, mode v1.SandboxNetworkMode) string {
+	return fmt.Sprintf("sandboxai-network.%s.%s.%s", c.scope, space, mode)
+}
+
+// ensureNetwork returns the name of the network for the mode in the space,
+// creating it if needed, and the address of the host on the network. For the
+// none mode it returns Docker's "none" network, which only has a loopback
+// interface, and no gateway.
+func (c *DockerClient) ensureNetwork(ctx context.Context, space string, mode v1.SandboxNetworkMode) (name, gateway string, err error) {
+	if mode == v1.NetworkModeNone {
+		return network.NetworkNone, "", nil
+	}
+	name = c.networkName(space, mode)
+
+	// Serialize creation so that concurrent sandboxes do not race to create
+	// the same network.
+	c.networkMtx.Lock()
+	defer c.networkMtx.Unlock()
+
+	if gateway, err := c.networkGateway(ctx, name); err == nil {
+		return name, gateway, nil
+	} else if !dclient.IsErrNotFound(err) {
+		return "", "", err
+	}
+
+	opts := network.CreateOptions{
+		Driver: "bridge",
+		Labels: map[string]string{
+			labelKeyScope:       c.scope,
+			labelKeySpace:       space,
+			labelKeyKind:        kindNetwork,
+			labelKeyNetworkMode: string(mode),
+		},
+	}
+	if mode == v1.NetworkModeInternal {
+		opts.Internal = true
+	}
+	if _, err := c.docker.NetworkCreate(ctx, name, opts); err != nil {
+		// A conflict means that another sandboxaid in the same scope
+		// created it first.
+		if !errdefs.IsConflict(err) {
+			return "", "", fmt.Errorf("creating network %q: %w", name, err)
+		}
+	} else {
+		log.Printf("Created network: %q", name)
+	}
+	gateway, err = c.networkGateway(ctx, name)
+	if err != nil {
+		return "", "", err
+	}
+	return name, gateway, nil
+}
+
+// networkGateway returns the address of the host on the network.
+func (c *DockerClient) networkGateway(ctx context.Context, name string) (string, error) {
+	n, err := c.docker.NetworkInspect(ctx, name, network.InspectOptions{})
+	if err != nil {
+		return "", fmt.Errorf("getting network %q: %w", name, err)
+	}
+	for _, cfg := range n.IPAM.Config {
+		if cfg.Gateway != "" {
+			return cfg.Gateway, nil
+		}
+	}
+	return "", fmt.Errorf("network %q has no gateway", name)
+}
+
+// deleteNetworks removes the networks of a space. The sandboxes in the space
+// must have been deleted first.
+func (c *DockerClient) deleteNetworks(ctx context.Context, space string) error {
+	networks, err := c.docker.NetworkList(ctx, network.ListOptions{
+		Filters: filters.NewArgs(
+			filters.Arg("label", fmt.Sprintf("%s=%s", labelKeyScope, c.scope)),
+			filters.Arg("label", fmt.Sprintf("%s=%s", labelKeySpace, space)),
+			filters.Arg("label", fmt.Sprintf("%s=%s", labelKeyKind, kindNetwork)),
+		),
+	})
+	if err != nil {
+		return fmt.Errorf("listing networks: %w", err)
+	}
+	for _, n := range networks {
+		if err := c.docker.NetworkRemove(ctx, n.ID); err != nil && !dclient.IsErrNotFound(err) {
+			return fmt.Errorf("removing network %q: %w", n.Name, err)
+		}
+		for _, cfg := range n.IPAM.Config {
+			c.closeEgress(cfg.Gateway)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/websocket"
+	"github.com/substratusai/sandboxai/go/internal/tunnel"
+	sclient "github.com/substratusai/sandboxai/go/sandboxaid/client"
+)
+
+// Connections to ports are relayed by boxd over a WebSocket so that servers
+// that only listen on the loopback interface of the sandbox can be reached
+// without publishing their ports.
+
+func (c *DockerClient) DialPort(ctx context.Context, sbx *sclient.Sandbox, port int) (sclient.PortConn, error) {
+	url := fmt.Sprintf("ws://%s/ports/%d:connect", sbx.BoxAddr, port)
+	conn, resp, err := websocket.DefaultDialer.DialContext(ctx, url, nil)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusForbidden {
+			// boxd rejects the handshake if the connection was refused.
+			return nil, fmt.Errorf("port %d: %w", port, sclient.ErrPortNotListening)
+		}
+		return nil, fmt.Errorf("dialing port %d: %w", port, err)
+	}
+	return tunnel.NewConn(conn), nil
+}
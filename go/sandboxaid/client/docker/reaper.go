@@ -0,0 +1,294 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	v1 "github.com/substratusai/sandboxai/go/api/v1"
+	sclient "github.com/substratusai/sandboxai/go/sandboxaid/client"
+)
+
+// The ttl, idle_timeout and idle_policy of sandboxes are stored in labels so
+// that they are still enforced after sandboxaid restarts.
+const labelKeyTTL = "sandboxai.ttl"
+const labelKeyIdleTimeout = "sandboxai.idle-timeout"
+const labelKeyIdlePolicy = "sandboxai.idle-policy"
+
+const (
+	expireReasonTTL  = "TTLExpired"
+	expireReasonIdle = "IdleTimeout"
+	stopReasonIdle   = "IdleStopped"
+)
+
+// expiredRetention is how long expired sandboxes can still be gotten after
+// they are deleted.
+const expiredRetention = 10 * time.Minute
+
+// expiredSandboxes keeps the last state of deleted expired sandboxes, keyed
+// by space and name, so that clients can see why they are gone.
+type expiredSandboxes struct {
+	mtx sync.Mutex
+	m   map[SandboxSpacedName]expiredSandbox
+}
+
+type expiredSandbox struct {
+	sbx       *v1.Sandbox
+	deletedAt time.Time
+}
+
+// add keeps an expired sandbox and forgets the ones past their retention.
+func (e *expiredSandboxes) add(sbx *v1.Sandbox, space string, deletedAt time.Time) {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	if e.m == nil {
+		e.m = make(map[SandboxSpacedName]expiredSandbox)
+	}
+	for key, exp := range e.m {
+		if deletedAt.Sub(exp.deletedAt) >= expiredRetention {
+			delete(e.m, key)
+		}
+	}
+	e.m[SandboxSpacedName{Space: space, Name: sbx.Name}] = expiredSandbox{sbx: sbx, deletedAt: deletedAt}
+}
+
+// get returns a copy of an expired sandbox (nil if not found).
+func (e *expiredSandboxes) get(space, name string, now time.Time) *v1.Sandbox {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	exp, ok := e.m[SandboxSpacedName{Space: space, Name: name}]
+	if !ok || now.Sub(exp.deletedAt) >= expiredRetention {
+		return nil
+	}
+	cp := *exp.sbx
+	return &cp
+}
+
+// forget removes an expired sandbox, for example because a sandbox with the
+// same name was created.
+func (e *expiredSandboxes) forget(space, name string) {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	delete(e.m, SandboxSpacedName{Space: space, Name: name})
+}
+
+// forgetSpace removes the expired sandboxes of a deleted space.
+func (e *expiredSandboxes) forgetSpace(space string) {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	for key := range e.m {
+		if key.Space == space {
+			delete(e.m, key)
+		}
+	}
+}
+
+// lifetimeLabels returns the labels for the ttl, idle_timeout and
+// idle_policy of a sandbox.
+func lifetimeLabels(spec v1.SandboxSpec) (map[string]string, error) {
+	labels := map[string]string{}
+	ttl, err := sclient.ParseLifetime(spec.TTL)
+	if err != nil {
+		return nil, fmt.Errorf("ttl: %w", err)
+	}
+	if ttl > 0 {
+		labels[labelKeyTTL] = ttl.String()
+	}
+	idle, err := sclient.ParseLifetime(spec.IdleTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("idle_timeout: %w", err)
+	}
+	if idle > 0 {
+		labels[labelKeyIdleTimeout] = idle.String()
+	}
+	if spec.IdlePolicy == v1.IdlePolicyStop {
+		labels[labelKeyIdlePolicy] = string(spec.IdlePolicy)
+	}
+	return labels, nil
+}
+
+// sandboxExpiry returns when a sandbox expires and why, or the zero time if
+// it does not have a ttl or idle_timeout. Whichever limit is reached first
+// wins. The idle_timeout is ignored if idleSince is zero (i.e. the sandbox is
+// paused) or if idle sandboxes are stopped rather than deleted.
+func sandboxExpiry(labels map[string]string, created, idleSince time.Time) (time.Time, string) {
+	var expiresAt time.Time
+	var reason string
+	if ttl, err := sclient.ParseLifetime(labels[labelKeyTTL]); err == nil && ttl > 0 {
+		expiresAt = created.Add(ttl)
+		reason = expireReasonTTL
+	}
+	if labels[labelKeyIdlePolicy] == string(v1.IdlePolicyStop) {
+		return expiresAt, reason
+	}
+	if idle, err := sclient.ParseLifetime(labels[labelKeyIdleTimeout]); err == nil && idle > 0 && !idleSince.IsZero() {
+		if t := idleSince.Add(idle); expiresAt.IsZero() || t.Before(expiresAt) {
+			expiresAt = t
+			reason = expireReasonIdle
+		}
+	}
+	return expiresAt, reason
+}
+
+// idleStopAt returns when a sandbox is stopped for being idle, or the zero
+// time if its idle_policy is not stop or idleSince is zero.
+func idleStopAt(labels map[string]string, idleSince time.Time) time.Time {
+	if labels[labelKeyIdlePolicy] != string(v1.IdlePolicyStop) || idleSince.IsZero() {
+		return time.Time{}
+	}
+	idle, err := sclient.ParseLifetime(labels[labelKeyIdleTimeout])
+	if err != nil || idle == 0 {
+		return time.Time{}
+	}
+	return idleSince.Add(idle)
+}
+
+// idleSince returns the time that a sandbox has been idle since: its last
+// tool call, when it was last resumed, when it became ready, or when it was
+// created, whichever is latest. Sandboxes are never considered idle since
+// before the client started because their activity was not tracked.
+func (c *DockerClient) idleSince(created time.Time, rec *sandboxRecord) time.Time {
+	since := created
+	if c.startedAt.After(since) {
+		since = c.startedAt
+	}
+	if rec != nil {
+		if rec.activeCalls > 0 || rec.done != nil {
+			// Serving a tool call or starting.
+			return time.Now()
+		}
+		if rec.readyAt.After(since) {
+			since = rec.readyAt
+		}
+		if rec.resumedAt.After(since) {
+			since = rec.resumedAt
+		}
+		if rec.lastActivityAt.After(since) {
+			since = rec.lastActivityAt
+		}
+	}
+	return since
+}
+
+// RunReaper deletes sandboxes once their ttl or idle_timeout passes, or stops
+// them if their idle_policy is stop. It checks every interval until ctx is
+// done.
+func (c *DockerClient) RunReaper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.reap(ctx); err != nil {
+				log.Printf("Reaper: %v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// reap deletes the sandboxes that have expired and stops the sandboxes that
+// are idle.
+func (c *DockerClient) reap(ctx context.Context) error {
+	containers, err := c.docker.ContainerList(ctx, container.ListOptions{
+		All: true,
+		Filters: filters.NewArgs(
+			filters.Arg("label", fmt.Sprintf("%s=%s", labelKeyScope, c.scope)),
+		),
+	})
+	if err != nil {
+		return fmt.Errorf("listing containers: %w", err)
+	}
+	now := time.Now()
+	for _, ctr := range containers {
+		labels := ctr.Labels
+		if labels[labelKeyTTL] == "" && labels[labelKeyIdleTimeout] == "" {
+			continue
+		}
+		space, name := labels[labelKeySpace], labels[labelKeyName]
+		if space == "" || name == "" {
+			continue
+		}
+		created := time.Unix(ctr.Created, 0)
+		var idleSince time.Time
+		if ctr.State != "paused" {
+			idleSince = c.idleSince(created, c.records.get(ctr.ID))
+		}
+		if stopAt := idleStopAt(labels, idleSince); ctr.State == "running" && !stopAt.IsZero() && !now.Before(stopAt) {
+			c.stopIdle(ctx, ctr.ID, space, name, labels, created)
+			continue
+		}
+		expiresAt, reason := sandboxExpiry(labels, created, idleSince)
+		if expiresAt.IsZero() || now.Before(expiresAt) {
+			continue
+		}
+
+		var message string
+		switch reason {
+		case expireReasonTTL:
+			message = fmt.Sprintf("The ttl of %s passed", labels[labelKeyTTL])
+		case expireReasonIdle:
+			message = fmt.Sprintf("No tool calls were made for %s", labels[labelKeyIdleTimeout])
+		}
+		log.Printf("Reaper: deleting sandbox %q in space %q: %s", name, space, message)
+		c.records.update(ctr.ID, func(rec *sandboxRecord) {
+			rec.expireReason = reason
+			rec.expireMessage = message
+		})
+		c.publishEvent(v1.SandboxEventExpired, ctr.ID, labels, reason, message)
+		// The state is read before deleting, the record goes with it.
+		dockerContainer, err := c.docker.ContainerInspect(ctx, ctr.ID)
+		if err != nil {
+			log.Printf("Reaper: failed to get sandbox %q in space %q: %v", name, space, err)
+			continue
+		}
+		sbx, err := containerJSONToSandbox(dockerContainer, c.records.get(ctr.ID), "")
+		if err != nil {
+			log.Printf("Reaper: failed to get sandbox %q in space %q: %v", name, space, err)
+			continue
+		}
+		sbx.Status.ExpiresAt = &expiresAt
+		if err := c.DeleteSandbox(ctx, space, name); err != nil {
+			log.Printf("Reaper: failed to delete sandbox %q in space %q: %v", name, space, err)
+			continue
+		}
+		c.expired.add(sbx.Sandbox, space, time.Now())
+	}
+	return nil
+}
+
+// stopIdle stops the container of a sandbox with the stop idle_policy. It is
+// started again by StartSandbox. Whether the sandbox is idle is checked again
+// under the lock of the records, and tool calls wait in TrackActivity until
+// the container has stopped, so that no call is cut off by the stop.
+func (c *DockerClient) stopIdle(ctx context.Context, id, space, name string, labels map[string]string, created time.Time) {
+	var stopping chan struct{}
+	c.records.add(id, func(rec *sandboxRecord) {
+		stopAt := idleStopAt(labels, c.idleSince(created, rec))
+		if rec.stopping != nil || stopAt.IsZero() || time.Now().Before(stopAt) {
+			return
+		}
+		stopping = make(chan struct{})
+		rec.stopping = stopping
+		rec.stopReason = stopReasonIdle
+		rec.stopMessage = fmt.Sprintf("No tool calls were made for %s, the next tool call starts the sandbox again", labels[labelKeyIdleTimeout])
+	})
+	if stopping == nil {
+		return
+	}
+	defer func() {
+		c.records.update(id, func(rec *sandboxRecord) {
+			rec.stopping = nil
+		})
+		close(stopping)
+	}()
+
+	log.Printf("Reaper: stopping idle sandbox %q in space %q", name, space)
+	if err := c.docker.ContainerStop(ctx, id, container.StopOptions{}); err != nil {
+		log.Printf("Reaper: failed to stop sandbox %q in space %q: %v", name, space, err)
+	}
+}
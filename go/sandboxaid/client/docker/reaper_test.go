@@ -0,0 +1,221 @@
+package docker
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	dclient "github.com/docker/docker/client"
+	"github.com/stretchr/testify/require"
+	v1 "github.com/substratusai/sandboxai/go/api/v1"
+	sclient "github.com/substratusai/sandboxai/go/sandboxaid/client"
+)
+
+func Test_lifetimeLabels(t *testing.T) {
+	labels, err := lifetimeLabels(v1.SandboxSpec{})
+	require.NoError(t, err)
+	require.Empty(t, labels)
+
+	labels, err = lifetimeLabels(v1.SandboxSpec{TTL: "90m", IdleTimeout: "600s", IdlePolicy: v1.IdlePolicyDelete})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{labelKeyTTL: "1h30m0s", labelKeyIdleTimeout: "10m0s"}, labels)
+
+	labels, err = lifetimeLabels(v1.SandboxSpec{IdleTimeout: "10m", IdlePolicy: v1.IdlePolicyStop})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{labelKeyIdleTimeout: "10m0s", labelKeyIdlePolicy: "stop"}, labels)
+
+	_, err = lifetimeLabels(v1.SandboxSpec{TTL: "forever"})
+	require.Error(t, err)
+}
+
+func Test_sandboxExpiry(t *testing.T) {
+	created := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name      string
+		labels    map[string]string
+		idleSince time.Time
+		exp       time.Time
+		expReason string
+	}{
+		{
+			name:      "no limits",
+			idleSince: created,
+		},
+		{
+			name:      "ttl",
+			labels:    map[string]string{labelKeyTTL: "1h0m0s"},
+			idleSince: created,
+			exp:       created.Add(time.Hour),
+			expReason: expireReasonTTL,
+		},
+		{
+			name:      "idle",
+			labels:    map[string]string{labelKeyIdleTimeout: "10m0s"},
+			idleSince: created.Add(time.Hour),
+			exp:       created.Add(70 * time.Minute),
+			expReason: expireReasonIdle,
+		},
+		{
+			name:      "idle before ttl",
+			labels:    map[string]string{labelKeyTTL: "1h0m0s", labelKeyIdleTimeout: "10m0s"},
+			idleSince: created.Add(5 * time.Minute),
+			exp:       created.Add(15 * time.Minute),
+			expReason: expireReasonIdle,
+		},
+		{
+			name:      "ttl before idle",
+			labels:    map[string]string{labelKeyTTL: "1h0m0s", labelKeyIdleTimeout: "10m0s"},
+			idleSince: created.Add(55 * time.Minute),
+			exp:       created.Add(time.Hour),
+			expReason: expireReasonTTL,
+		},
+		{
+			name:      "idle sandboxes are stopped",
+			labels:    map[string]string{labelKeyTTL: "1h0m0s", labelKeyIdleTimeout: "10m0s", labelKeyIdlePolicy: "stop"},
+			idleSince: created,
+			exp:       created.Add(time.Hour),
+			expReason: expireReasonTTL,
+		},
+		{
+			name:      "paused sandboxes are not idle",
+			labels:    map[string]string{labelKeyTTL: "1h0m0s", labelKeyIdleTimeout: "10m0s"},
+			exp:       created.Add(time.Hour),
+			expReason: expireReasonTTL,
+		},
+		{
+			name:      "invalid labels are ignored",
+			labels:    map[string]string{labelKeyTTL: "x"},
+			idleSince: created,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			exp, reason := sandboxExpiry(c.labels, created, c.idleSince)
+			require.Equal(t, c.exp, exp)
+			require.Equal(t, c.expReason, reason)
+		})
+	}
+}
+
+func Test_idleStopAt(t *testing.T) {
+	idleSince := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.True(t, idleStopAt(map[string]string{labelKeyIdleTimeout: "10m0s"}, idleSince).IsZero())
+	require.True(t, idleStopAt(map[string]string{labelKeyIdleTimeout: "10m0s", labelKeyIdlePolicy: "stop"}, time.Time{}).IsZero())
+	require.Equal(t, idleSince.Add(10*time.Minute), idleStopAt(map[string]string{labelKeyIdleTimeout: "10m0s", labelKeyIdlePolicy: "stop"}, idleSince))
+}
+
+func Test_idleSince(t *testing.T) {
+	created := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := &DockerClient{startedAt: created.Add(-time.Hour)}
+	require.Equal(t, created, c.idleSince(created, nil))
+	require.Equal(t, created.Add(time.Minute), c.idleSince(created, &sandboxRecord{readyAt: created.Add(time.Minute)}))
+	require.Equal(t, created.Add(time.Hour), c.idleSince(created, &sandboxRecord{
+		readyAt:        created.Add(time.Minute),
+		lastActivityAt: created.Add(time.Hour),
+	}))
+
+	require.Equal(t, created.Add(2*time.Hour), c.idleSince(created, &sandboxRecord{
+		lastActivityAt: created.Add(time.Hour),
+		resumedAt:      created.Add(2 * time.Hour),
+	}))
+
+	before := time.Now()
+	require.False(t, c.idleSince(created, &sandboxRecord{activeCalls: 1}).Before(before), "sandboxes serving calls are not idle")
+
+	// Activity from before the client started is not known.
+	c.startedAt = created.Add(2 * time.Hour)
+	require.Equal(t, c.startedAt, c.idleSince(created, &sandboxRecord{lastActivityAt: created.Add(time.Hour)}))
+}
+
+func TestDockerClient_stopIdleWithActiveCalls(t *testing.T) {
+	created := time.Now().Add(-time.Hour)
+	c := &DockerClient{records: newRecords(), startedAt: created}
+	labels := map[string]string{labelKeyIdleTimeout: "10m0s", labelKeyIdlePolicy: "stop"}
+	c.records.add("id", func(rec *sandboxRecord) {})
+	sbx := &sclient.Sandbox{Sandbox: &v1.Sandbox{UID: "id"}}
+
+	// Calls that arrived after the reaper listed the containers are not cut
+	// off (the Docker client is nil, so stopping would panic).
+	done := c.TrackActivity(sbx)
+	c.stopIdle(context.Background(), "id", "default", "a", labels, created)
+	require.Nil(t, c.records.get("id").stopping)
+	require.Empty(t, c.records.get("id").stopReason)
+	done()
+
+	// Calls wait while the sandbox is being stopped.
+	stopping := make(chan struct{})
+	c.records.update("id", func(rec *sandboxRecord) { rec.stopping = stopping })
+	tracked := make(chan func())
+	go func() { tracked <- c.TrackActivity(sbx) }()
+	select {
+	case <-tracked:
+		t.Fatal("tracked while stopping")
+	case <-time.After(50 * time.Millisecond):
+	}
+	c.records.update("id", func(rec *sandboxRecord) { rec.stopping = nil })
+	close(stopping)
+	(<-tracked)()
+	require.Zero(t, c.records.get("id").activeCalls)
+}
+
+func TestDockerClient_activityWithoutRecord(t *testing.T) {
+	var stopped atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/containers/idle/stop") {
+			stopped.Store(true)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+	docker, err := dclient.NewClientWithOpts(dclient.WithHost("tcp://"+srv.Listener.Addr().String()), dclient.WithVersion("1.47"))
+	require.NoError(t, err)
+
+	created := time.Now().Add(-time.Hour)
+	c := &DockerClient{docker: docker, records: newRecords(), startedAt: created}
+	labels := map[string]string{labelKeyIdleTimeout: "10m0s", labelKeyIdlePolicy: "stop"}
+
+	// Records of existing containers are added asynchronously on startup,
+	// calls are tracked before that.
+	done := c.TrackActivity(&sclient.Sandbox{Sandbox: &v1.Sandbox{UID: "busy"}})
+	require.Equal(t, 1, c.records.get("busy").activeCalls)
+	require.False(t, c.idleSince(created, c.records.get("busy")).Before(created.Add(time.Hour)), "sandboxes serving calls are not idle")
+	c.stopIdle(context.Background(), "busy", "default", "busy", labels, created)
+	require.Empty(t, c.records.get("busy").stopReason)
+	done()
+	require.Zero(t, c.records.get("busy").activeCalls)
+
+	// Idle sandboxes are stopped.
+	c.stopIdle(context.Background(), "idle", "default", "idle", labels, created)
+	require.True(t, stopped.Load())
+	rec := c.records.get("idle")
+	require.Equal(t, stopReasonIdle, rec.stopReason)
+	require.Nil(t, rec.stopping)
+}
+
+func Test_expiredSandboxes(t *testing.T) {
+	var e expiredSandboxes
+	deletedAt := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	phase := v1.SandboxPhaseExpired
+	e.add(&v1.Sandbox{Name: "a", Status: &v1.SandboxStatus{Phase: &phase, Reason: expireReasonTTL}}, "default", deletedAt)
+
+	sbx := e.get("default", "a", deletedAt.Add(time.Minute))
+	require.NotNil(t, sbx)
+	require.Equal(t, expireReasonTTL, sbx.Status.Reason)
+	require.Nil(t, e.get("other", "a", deletedAt), "other space")
+	require.Nil(t, e.get("default", "a", deletedAt.Add(expiredRetention)), "past the retention")
+
+	e.forget("default", "a")
+	require.Nil(t, e.get("default", "a", deletedAt))
+
+	e.add(&v1.Sandbox{Name: "b"}, "default", deletedAt)
+	e.add(&v1.Sandbox{Name: "c"}, "default", deletedAt.Add(expiredRetention))
+	require.Len(t, e.m, 1, "sandboxes past the retention are forgotten")
+	e.forgetSpace("default")
+	require.Empty(t, e.m)
+}
@@ -0,0 +1,115 @@
+package docker
+
+import (
+	"sync"
+	"time"
+
+	v1 "github.com/substratusai/sandboxai/go/api/v1"
+)
+
+// sandboxRecord holds sandbox state that Docker does not track.
+// Records only live in memory: they are lost when sandboxaid restarts.
+type sandboxRecord struct {
+	readyAt        time.Time
+	lastActivityAt time.Time
+	resumedAt      time.Time
+	startup        *v1.SandboxStartupTiming
+	// activeCalls is the number of tool calls being served.
+	activeCalls int
+	// failReason and failMessage are set if the sandbox never became ready.
+	failReason  string
+	failMessage string
+	// expireReason and expireMessage are set once the sandbox has expired
+	// and is being deleted.
+	expireReason  string
+	expireMessage string
+	// stopReason and stopMessage are set while the sandbox is stopped
+	// because of its idle_policy.
+	stopReason  string
+	stopMessage string
+	// egress holds the most recent connections through the egress proxy.
+	egress []v1.EgressConnection
+	// done is non-nil while the sandbox is waiting to become ready.
+	// It is closed once readiness is resolved (ready or failed).
+	done chan struct{}
+	// stopping is non-nil while the sandbox is being stopped because of its
+	// idle_policy. It is closed once the container has stopped.
+	stopping chan struct{}
+}
+
+// records is a concurrency-safe set of sandboxRecords keyed by container ID.
+type records struct {
+	mtx sync.Mutex
+	m   map[string]*sandboxRecord
+}
+
+func newRecords() *records {
+	return &records{m: make(map[string]*sandboxRecord)}
+}
+
+// get returns a copy of the record for the container (nil if not found).
+func (r *records) get(id string) *sandboxRecord {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	rec, ok := r.m[id]
+	if !ok {
+		return nil
+	}
+	cp := *rec
+	return &cp
+}
+
+// add applies fn to the record for the container, creating it if needed.
+// It is only used when the container is known to exist (including when
+// activity is tracked or an idle sandbox is stopped, as the records of
+// existing containers are added asynchronously on startup), anything that can
+// run after the sandbox is deleted uses update so that records of deleted
+// containers are not recreated.
+func (r *records) add(id string, fn func(*sandboxRecord)) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	rec, ok := r.m[id]
+	if !ok {
+		rec = &sandboxRecord{}
+		r.m[id] = rec
+	}
+	fn(rec)
+}
+
+// update applies fn to an existing record. False is returned if there is no
+// record for the container.
+func (r *records) update(id string, fn func(*sandboxRecord)) bool {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	rec, ok := r.m[id]
+	if !ok {
+		return false
+	}
+	fn(rec)
+	return true
+}
+
+// resolve applies fn to an existing record and wakes up anything waiting
+// on the readiness of the sandbox.
+func (r *records) resolve(id string, fn func(*sandboxRecord)) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	rec, ok := r.m[id]
+	if !ok {
+		return
+	}
+	fn(rec)
+	if rec.done != nil {
+		close(rec.done)
+		rec.done = nil
+	}
+}
+
+func (r *records) delete(id string) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if rec, ok := r.m[id]; ok && rec.done != nil {
+		close(rec.done)
+	}
+	delete(r.m, id)
+}
@@ -0,0 +1,53 @@
+package docker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecords(t *testing.T) {
+	r := newRecords()
+	require.Nil(t, r.get("a"))
+
+	require.False(t, r.update("a", func(rec *sandboxRecord) {}), "update should not create records")
+	require.Nil(t, r.get("a"))
+
+	r.add("a", func(rec *sandboxRecord) {
+		rec.done = make(chan struct{})
+	})
+	pending := r.get("a")
+	require.NotNil(t, pending.done, "should be waiting for readiness")
+
+	readyAt := time.Now()
+	r.resolve("a", func(rec *sandboxRecord) {
+		rec.readyAt = readyAt
+	})
+	select {
+	case <-pending.done:
+	default:
+		t.Fatal("resolve should wake up waiters")
+	}
+	resolved := r.get("a")
+	require.Nil(t, resolved.done)
+	require.Equal(t, readyAt, resolved.readyAt)
+
+	// Resolving or updating a deleted record should not recreate it.
+	r.delete("a")
+	r.resolve("a", func(rec *sandboxRecord) {})
+	require.False(t, r.update("a", func(rec *sandboxRecord) {}))
+	require.Nil(t, r.get("a"))
+
+	// Deleting a pending record should wake up waiters.
+	r.add("b", func(rec *sandboxRecord) {
+		rec.done = make(chan struct{})
+	})
+	pending = r.get("b")
+	r.delete("b")
+	select {
+	case <-pending.done:
+	default:
+		t.Fatal("delete should wake up waiters")
+	}
+}
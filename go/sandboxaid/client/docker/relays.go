@@ -0,0 +1,119 @@
+package docker
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// Sandboxes on internal networks cannot publish ports, and their address on
+// the network is only routable from the Docker host (not from the host of a
+// Docker Desktop or colima VM). Their boxd is reached through a relay
+// instead: a local listener that runs relayProgram in the sandbox for each
+// connection and pipes the connection through the stdio of the exec. Docker
+// exec works wherever the Docker API does.
+
+// relayProgram connects to boxd from inside the sandbox and copies stdin to
+// the connection and the connection to stdout. boxd needs python so it is
+// available in all sandboxes.
+const relayProgram = `
+import socket, sys, threading
+s = socket.create_connection(("127.0.0.1", ` + boxPort + `))
+def up():
+    while b := sys.stdin.buffer.read1(65536):
+        s.sendall(b)
+    s.shutdown(socket.SHUT_WR)
+threading.Thread(target=up, daemon=True).start()
+while b := s.recv(65536):
+    sys.stdout.buffer.write(b)
+    sys.stdout.buffer.flush()
+`
+
+// relays holds the relay listeners keyed by container ID. A relay outlives
+// restarts of its container so its address stays the same.
+type relays struct {
+	mtx sync.Mutex
+	m   map[string]net.Listener
+}
+
+func newRelays() *relays {
+	return &relays{m: make(map[string]net.Listener)}
+}
+
+// relayAddr returns the local address of the relay to boxd in the container,
+// starting the relay if needed.
+func (c *DockerClient) relayAddr(id string) (string, error) {
+	c.relays.mtx.Lock()
+	defer c.relays.mtx.Unlock()
+	if ln, ok := c.relays.m[id]; ok {
+		return ln.Addr().String(), nil
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	c.relays.m[id] = ln
+	go c.serveRelay(id, ln)
+	return ln.Addr().String(), nil
+}
+
+// closeRelay stops the relay to the container, if any.
+func (c *DockerClient) closeRelay(id string) {
+	c.relays.mtx.Lock()
+	ln, ok := c.relays.m[id]
+	delete(c.relays.m, id)
+	c.relays.mtx.Unlock()
+	if ok {
+		ln.Close()
+	}
+}
+
+func (c *DockerClient) serveRelay(id string, ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			// Closed.
+			return
+		}
+		go c.relayConn(id, conn)
+	}
+}
+
+// relayConn pipes a local connection to boxd through an exec in the container.
+// Failures (such as boxd not listening yet) close the connection.
+func (c *DockerClient) relayConn(id string, local net.Conn) {
+	defer local.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	created, err := c.docker.ContainerExecCreate(ctx, id, container.ExecOptions{
+		Cmd:          []string{"python3", "-c", relayProgram},
+		AttachStdin:  true,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		log.Printf("Failed to relay to box of container %q: creating exec: %v", id, err)
+		return
+	}
+	attached, err := c.docker.ContainerExecAttach(ctx, created.ID, container.ExecAttachOptions{})
+	if err != nil {
+		log.Printf("Failed to relay to box of container %q: attaching to exec: %v", id, err)
+		return
+	}
+	defer attached.Close()
+
+	go func() {
+		if _, err := io.Copy(attached.Conn, local); err == nil {
+			// Pass on the end of the request.
+			attached.CloseWrite()
+		}
+	}()
+	// The connection is done once boxd (or the relay) closes it. Errors
+	// of the relay, such as boxd not listening yet, are discarded.
+	stdcopy.StdCopy(local, io.Discard, attached.Reader)
+}
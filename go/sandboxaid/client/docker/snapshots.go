@@ -0,0 +1,356 @@
+package docker
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/mount"
+	dclient "github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/jsonmessage"
+	v1 "github.com/substratusai/sandboxai/go/api/v1"
+	sclient "github.com/substratusai/sandboxai/go/sandboxaid/client"
+)
+
+// Snapshots are gzipped tarballs of the data in volumes. They are stored in
+// a directory per space next to a JSON file with their metadata:
+//
+//	<dir>/<space>/<name>.tar.gz
+//	<dir>/<space>/<name>.json
+//
+// The metadata is written last so that snapshots are only found once they
+// are complete.
+//
+// Docker has no API to export volumes, so volumes are accessed through
+// helper containers that mount them and are never started.
+
+// helperImage is an empty image that helper containers are created from so
+// that no image has to be pulled.
+const helperImage = "sandboxai-helper:1"
+
+// helperMountPath is where helper containers mount volumes. The entries in
+// snapshots are prefixed with its base name.
+const helperMountPath = "/volume"
+
+// labelKeyHelperScope marks helper containers. They do not have the scope
+// label so that they are never mistaken for sandboxes.
+const labelKeyHelperScope = "sandboxai.helper-scope"
+
+// SetSnapshotDir sets the directory that snapshots are stored in. Snapshots
+// cannot be taken until it is set.
+func (c *DockerClient) SetSnapshotDir(dir string) {
+	c.snapshotDir = dir
+}
+
+func (c *DockerClient) SnapshotVolume(ctx context.Context, space, volName string, req *v1.CreateSnapshotRequest) (*v1.Snapshot, error) {
+	vol, err := c.inspectVolume(ctx, space, volName)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	name := req.Name
+	if name == "" {
+		name = snapshotName(volName, now)
+	}
+	tarPath, metaPath, err := c.snapshotPaths(space, name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(metaPath); err == nil {
+		return nil, fmt.Errorf("snapshot %q: %w", name, sclient.ErrSnapshotAlreadyExists)
+	}
+	if err := os.MkdirAll(filepath.Dir(tarPath), 0o755); err != nil {
+		return nil, fmt.Errorf("creating snapshot directory: %w", err)
+	}
+
+	helper, err := c.createHelper(ctx, vol.Name, true)
+	if err != nil {
+		return nil, err
+	}
+	defer c.removeHelper(helper)
+
+	rc, _, err := c.docker.CopyFromContainer(ctx, helper, helperMountPath)
+	if err != nil {
+		return nil, fmt.Errorf("copying volume from helper container: %w", err)
+	}
+	defer rc.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(tarPath), ".snapshot-*")
+	if err != nil {
+		return nil, fmt.Errorf("creating snapshot: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	gz := gzip.NewWriter(tmp)
+	if _, err := io.Copy(gz, rc); err != nil {
+		return nil, fmt.Errorf("writing snapshot: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("writing snapshot: %w", err)
+	}
+	info, err := tmp.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("writing snapshot: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, fmt.Errorf("writing snapshot: %w", err)
+	}
+
+	snapshot := &v1.Snapshot{
+		Name:      name,
+		Volume:    volName,
+		Labels:    req.Labels,
+		CreatedAt: now,
+		SizeBytes: info.Size(),
+	}
+	metaJSON, err := json.Marshal(snapshot)
+	if err != nil {
+		return nil, fmt.Errorf("marshalling snapshot: %w", err)
+	}
+
+	// Check again in case the same name was taken while copying.
+	c.snapshotMtx.Lock()
+	defer c.snapshotMtx.Unlock()
+	if _, err := os.Stat(metaPath); err == nil {
+		return nil, fmt.Errorf("snapshot %q: %w", name, sclient.ErrSnapshotAlreadyExists)
+	}
+	if err := os.Rename(tmp.Name(), tarPath); err != nil {
+		return nil, fmt.Errorf("writing snapshot: %w", err)
+	}
+	if err := os.WriteFile(metaPath, metaJSON, 0o644); err != nil {
+		return nil, fmt.Errorf("writing snapshot metadata: %w", err)
+	}
+
+	log.Printf("Created snapshot: %q of volume %q in space %q (%d bytes)", name, volName, space, snapshot.SizeBytes)
+
+	return snapshot, nil
+}
+
+func (c *DockerClient) GetSnapshot(ctx context.Context, space, name string) (*v1.Snapshot, error) {
+	_, metaPath, err := c.snapshotPaths(space, name)
+	if err != nil {
+		return nil, err
+	}
+	return readSnapshot(metaPath)
+}
+
+func (c *DockerClient) ListSnapshots(ctx context.Context, space, volName string) ([]v1.Snapshot, error) {
+	if _, err := c.GetSpace(ctx, space); err != nil {
+		return nil, err
+	}
+	if c.snapshotDir == "" {
+		return nil, nil
+	}
+	matches, err := filepath.Glob(filepath.Join(c.snapshotDir, space, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("listing snapshots: %w", err)
+	}
+	var snapshots []v1.Snapshot
+	for _, metaPath := range matches {
+		snapshot, err := readSnapshot(metaPath)
+		if errors.Is(err, sclient.ErrSnapshotNotFound) {
+			// Deleted since listing.
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if volName != "" && snapshot.Volume != volName {
+			continue
+		}
+		snapshots = append(snapshots, *snapshot)
+	}
+	slices.SortFunc(snapshots, func(a, b v1.Snapshot) int {
+		if n := a.CreatedAt.Compare(b.CreatedAt); n != 0 {
+			return n
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+	return snapshots, nil
+}
+
+func (c *DockerClient) DeleteSnapshot(ctx context.Context, space, name string) error {
+	tarPath, metaPath, err := c.snapshotPaths(space, name)
+	if err != nil {
+		return err
+	}
+	c.snapshotMtx.Lock()
+	defer c.snapshotMtx.Unlock()
+	// The metadata is removed first so that the snapshot is not found while
+	// it is being deleted.
+	if err := os.Remove(metaPath); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("snapshot %q: %w", name, sclient.ErrSnapshotNotFound)
+		}
+		return fmt.Errorf("removing snapshot metadata: %w", err)
+	}
+	if err := os.Remove(tarPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("removing snapshot: %w", err)
+	}
+
+	log.Printf("Deleted snapshot: %q in space %q", name, space)
+
+	return nil
+}
+
+// deleteSnapshots deletes the snapshots in a space.
+func (c *DockerClient) deleteSnapshots(space string) error {
+	if c.snapshotDir == "" {
+		return nil
+	}
+	if err := sclient.ValidateSpaceName(space); err != nil {
+		return err
+	}
+	c.snapshotMtx.Lock()
+	defer c.snapshotMtx.Unlock()
+	if err := os.RemoveAll(filepath.Join(c.snapshotDir, space)); err != nil {
+		return fmt.Errorf("removing snapshots: %w", err)
+	}
+	return nil
+}
+
+// restoreSnapshot extracts a snapshot into a Docker volume.
+func (c *DockerClient) restoreSnapshot(ctx context.Context, vname, tarPath string) error {
+	f, err := os.Open(tarPath)
+	if err != nil {
+		return fmt.Errorf("opening snapshot: %w", err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("reading snapshot: %w", err)
+	}
+
+	helper, err := c.createHelper(ctx, vname, false)
+	if err != nil {
+		return err
+	}
+	defer c.removeHelper(helper)
+
+	// The entries are prefixed with the base name of the mount path.
+	if err := c.docker.CopyToContainer(ctx, helper, "/", gz, container.CopyToContainerOptions{CopyUIDGID: true}); err != nil {
+		return fmt.Errorf("copying snapshot to helper container: %w", err)
+	}
+	return nil
+}
+
+// snapshotName generates the name of a snapshot from the name of the volume
+// and the time it was taken. The result is a valid volume name.
+func snapshotName(volName string, t time.Time) string {
+	prefix := strings.TrimRight(volName[:min(len(volName), 47)], "-")
+	return fmt.Sprintf("%s-%s", prefix, t.UTC().Format("20060102-150405"))
+}
+
+// snapshotPaths returns the paths of the tarball and metadata of a snapshot.
+// The names are validated because they are used in paths.
+func (c *DockerClient) snapshotPaths(space, name string) (tarPath, metaPath string, err error) {
+	if c.snapshotDir == "" {
+		return "", "", sclient.ErrSnapshotsDisabled
+	}
+	if sclient.ValidateSpaceName(space) != nil || sclient.ValidateSnapshotName(name) != nil {
+		return "", "", fmt.Errorf("snapshot %q: %w", name, sclient.ErrSnapshotNotFound)
+	}
+	base := filepath.Join(c.snapshotDir, space, name)
+	return base + ".tar.gz", base + ".json", nil
+}
+
+func readSnapshot(metaPath string) (*v1.Snapshot, error) {
+	data, err := os.ReadFile(metaPath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("snapshot %q: %w", strings.TrimSuffix(filepath.Base(metaPath), ".json"), sclient.ErrSnapshotNotFound)
+		}
+		return nil, fmt.Errorf("reading snapshot metadata: %w", err)
+	}
+	var snapshot v1.Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("%s: unmarshalling snapshot metadata: %w", metaPath, err)
+	}
+	return &snapshot, nil
+}
+
+// createHelper creates a helper container that mounts a Docker volume at
+// helperMountPath.
+func (c *DockerClient) createHelper(ctx context.Context, vname string, readOnly bool) (string, error) {
+	if err := c.ensureHelperImage(ctx); err != nil {
+		return "", err
+	}
+	resp, err := c.docker.ContainerCreate(ctx,
+		&container.Config{
+			Image: helperImage,
+			// The container is never started.
+			Cmd:             []string{"/none"},
+			Labels:          map[string]string{labelKeyHelperScope: c.scope},
+			NetworkDisabled: true,
+		},
+		&container.HostConfig{
+			NetworkMode: "none",
+			Mounts: []mount.Mount{{
+				Type:     mount.TypeVolume,
+				Source:   vname,
+				Target:   helperMountPath,
+				ReadOnly: readOnly,
+			}},
+		},
+		nil, nil, "")
+	if err != nil {
+		return "", fmt.Errorf("creating helper container: %w", err)
+	}
+	return resp.ID, nil
+}
+
+func (c *DockerClient) removeHelper(id string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := c.docker.ContainerRemove(ctx, id, container.RemoveOptions{Force: true}); err != nil {
+		log.Printf("Failed to remove helper container %q: %v", id, err)
+	}
+}
+
+// ensureHelperImage imports the helper image if it does not exist.
+func (c *DockerClient) ensureHelperImage(ctx context.Context) error {
+	c.helperMtx.Lock()
+	defer c.helperMtx.Unlock()
+	if _, _, err := c.docker.ImageInspectWithRaw(ctx, helperImage); err == nil {
+		return nil
+	} else if !dclient.IsErrNotFound(err) {
+		return fmt.Errorf("inspecting helper image: %w", err)
+	}
+
+	// The root filesystem only contains the mount path.
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if err := tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     strings.TrimPrefix(helperMountPath, "/") + "/",
+		Mode:     0o755,
+		ModTime:  time.Now(),
+	}); err != nil {
+		return fmt.Errorf("writing tar header: %w", err)
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("closing tar: %w", err)
+	}
+	rc, err := c.docker.ImageImport(ctx, image.ImportSource{Source: &buf, SourceName: "-"}, helperImage, image.ImportOptions{})
+	if err != nil {
+		return fmt.Errorf("importing helper image: %w", err)
+	}
+	defer rc.Close()
+	if err := jsonmessage.DisplayJSONMessagesStream(rc, io.Discard, 0, false, nil); err != nil {
+		return fmt.Errorf("importing helper image: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	v1 "github.com/substratusai/sandboxai/go/api/v1"
+	sclient "github.com/substratusai/sandboxai/go/sandboxaid/client"
+)
+
+func Test_snapshotName(t *testing.T) {
+	ts := time.Date(2025, 3, 4, 5, 6, 7, 0, time.UTC)
+	require.Equal(t, "data-20250304-050607", snapshotName("data", ts))
+
+	name := snapshotName(strings.Repeat("a", 46)+"-b", ts)
+	require.Equal(t, strings.Repeat("a", 46)+"-20250304-050607", name)
+	require.NoError(t, sclient.ValidateVolumeName(name))
+	require.NoError(t, sclient.ValidateVolumeName(snapshotName(strings.Repeat("a", 63), ts)))
+}
+
+func TestSnapshotMetadata(t *testing.T) {
+	ctx := context.Background()
+
+	c := &DockerClient{}
+	_, err := c.GetSnapshot(ctx, "default", "snap")
+	require.ErrorIs(t, err, sclient.ErrSnapshotsDisabled)
+
+	c.SetSnapshotDir(t.TempDir())
+	_, err = c.GetSnapshot(ctx, "default", "snap")
+	require.ErrorIs(t, err, sclient.ErrSnapshotNotFound)
+	_, err = c.GetSnapshot(ctx, "default", "../snap")
+	require.ErrorIs(t, err, sclient.ErrSnapshotNotFound)
+	_, err = c.GetSnapshot(ctx, "..", "snap")
+	require.ErrorIs(t, err, sclient.ErrSnapshotNotFound)
+
+	tarPath, metaPath, err := c.snapshotPaths("default", "snap")
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(filepath.Dir(tarPath), 0o755))
+	require.NoError(t, os.WriteFile(tarPath, nil, 0o644))
+	snapshot := v1.Snapshot{
+		Name:      "snap",
+		Volume:    "data",
+		Labels:    map[string]string{"a": "b"},
+		CreatedAt: time.Date(2025, 3, 4, 5, 6, 7, 0, time.UTC),
+		SizeBytes: 10,
+	}
+	data, err := json.Marshal(snapshot)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(metaPath, data, 0o644))
+
+	got, err := c.GetSnapshot(ctx, "default", "snap")
+	require.NoError(t, err)
+	require.Equal(t, snapshot, *got)
+
+	require.NoError(t, c.DeleteSnapshot(ctx, "default", "snap"))
+	require.NoFileExists(t, tarPath)
+	require.NoFileExists(t, metaPath)
+	require.ErrorIs(t, c.DeleteSnapshot(ctx, "default", "snap"), sclient.ErrSnapshotNotFound)
+}
@@ -0,0 +1,187 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/volume"
+	dclient "github.com/docker/docker/client"
+	v1 "github.com/substratusai/sandboxai/go/api/v1"
+	sclient "github.com/substratusai/sandboxai/go/sandboxaid/client"
+)
+
+// Spaces are stored as labelled Docker volumes. Docker has no namespace
+// primitive and volumes are cheap, persistent, and support labels.
+// The volume itself is never mounted.
+
+const labelKeyKind = "sandboxai.kind"
+const labelKeySpec = "sandboxai.spec"
+
+const kindSpace = "space"
+
+func (c *DockerClient) CreateSpace(ctx context.Context, req *v1.CreateSpaceRequest) (*v1.Space, error) {
+	if err := sclient.ValidateSpaceName(req.Name); err != nil {
+		return nil, err
+	}
+
+	c.volumeMtx.Lock()
+	defer c.volumeMtx.Unlock()
+
+	if _, err := c.GetSpace(ctx, req.Name); err == nil {
+		return nil, fmt.Errorf("space %q: %w", req.Name, sclient.ErrSpaceAlreadyExists)
+	} else if !errors.Is(err, sclient.ErrSpaceNotFound) {
+		return nil, err
+	}
+
+	var spec v1.SpaceSpec
+	if req.Spec != nil {
+		spec = *req.Spec
+	}
+	specJSON, err := json.Marshal(spec)
+	if err != nil {
+		return nil, fmt.Errorf("marshalling spec: %w", err)
+	}
+
+	vol, err := c.docker.VolumeCreate(ctx, volume.CreateOptions{
+		Name: c.spaceVolumeName(req.Name),
+		Labels: map[string]string{
+			labelKeyScope: c.scope,
+			labelKeySpace: req.Name,
+			labelKeyKind:  kindSpace,
+			labelKeySpec:  string(specJSON),
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating volume: %w", err)
+	}
+
+	log.Printf("Created space: %q", req.Name)
+
+	return volumeToSpace(vol)
+}
+
+func (c *DockerClient) GetSpace(ctx context.Context, space string) (*v1.Space, error) {
+	vname := c.spaceVolumeName(space)
+	vol, err := c.docker.VolumeInspect(ctx, vname)
+	if err != nil {
+		if dclient.IsErrNotFound(err) {
+			return nil, fmt.Errorf("getting volume %q: %w", vname, sclient.ErrSpaceNotFound)
+		}
+		return nil, fmt.Errorf("getting volume %q: %w", vname, err)
+	}
+	if vol.Labels[labelKeyKind] != kindSpace || vol.Labels[labelKeyScope] != c.scope {
+		return nil, fmt.Errorf("volume %q is not a space in scope %q: %w", vname, c.scope, sclient.ErrSpaceNotFound)
+	}
+	return volumeToSpace(vol)
+}
+
+func (c *DockerClient) DeleteSpace(ctx context.Context, space string) error {
+	if _, err := c.GetSpace(ctx, space); err != nil {
+		return err
+	}
+
+	deleted, err := c.deleteSandboxes(ctx, space)
+	if err != nil {
+		return err
+	}
+	if err := c.deleteVolumes(ctx, space); err != nil {
+		return err
+	}
+	if err := c.deleteSnapshots(space); err != nil {
+		return err
+	}
+
+	vname := c.spaceVolumeName(space)
+	if err := c.docker.VolumeRemove(ctx, vname, false); err != nil {
+		if dclient.IsErrNotFound(err) {
+			return fmt.Errorf("removing volume %q: %w", vname, sclient.ErrSpaceNotFound)
+		}
+		return fmt.Errorf("removing volume %q: %w", vname, err)
+	}
+	c.expired.forgetSpace(space)
+
+	log.Printf("Deleted space: %q (sandboxes deleted = %d)", space, deleted)
+
+	return nil
+}
+
+// CleanupSpace deletes the sandboxes and networks of a space. Unlike
+// DeleteSpace, the space is kept along with its volumes and snapshots, which
+// is what cleaning up on shutdown needs.
+func (c *DockerClient) CleanupSpace(ctx context.Context, space string) error {
+	deleted, err := c.deleteSandboxes(ctx, space)
+	if err != nil {
+		return err
+	}
+	log.Printf("Cleaned up space: %q (sandboxes deleted = %d)", space, deleted)
+	return nil
+}
+
+// deleteSandboxes deletes the sandboxes of a space and then its networks. The
+// number of deleted sandboxes is returned.
+func (c *DockerClient) deleteSandboxes(ctx context.Context, space string) (int, error) {
+	list, err := c.ListSandboxes(ctx, space, sclient.ListOptions{})
+	if err != nil {
+		return 0, fmt.Errorf("listing sandboxes: %w", err)
+	}
+	for _, sbx := range list.Items {
+		if err := c.DeleteSandbox(ctx, space, sbx.Name); err != nil && !errors.Is(err, sclient.ErrSandboxNotFound) {
+			return 0, fmt.Errorf("deleting sandbox %q: %w", sbx.Name, err)
+		}
+	}
+	if err := c.deleteNetworks(ctx, space); err != nil {
+		return 0, err
+	}
+	return len(list.Items), nil
+}
+
+// ListAllSpaces returns the names of all spaces in the client's scope.
+func (c *DockerClient) ListAllSpaces(ctx context.Context) ([]string, error) {
+	resp, err := c.docker.VolumeList(ctx, volume.ListOptions{
+		Filters: filters.NewArgs(
+			filters.Arg("label", fmt.Sprintf("%s=%s", labelKeyScope, c.scope)),
+			filters.Arg("label", fmt.Sprintf("%s=%s", labelKeyKind, kindSpace)),
+		),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, len(resp.Volumes))
+	for i, vol := range resp.Volumes {
+		names[i] = vol.Labels[labelKeySpace]
+	}
+	return names, nil
+}
+
+func (c *DockerClient) spaceVolumeName(space string) string {
+	return fmt.Sprintf("sandboxai-space.%s.%s", c.scope, space)
+}
+
+func volumeToSpace(vol volume.Volume) (*v1.Space, error) {
+	var spec v1.SpaceSpec
+	if specJSON := vol.Labels[labelKeySpec]; specJSON != "" {
+		if err := json.Unmarshal([]byte(specJSON), &spec); err != nil {
+			return nil, fmt.Errorf("volume %q: unmarshalling spec: %w", vol.Name, err)
+		}
+	}
+
+	status := &v1.SpaceStatus{}
+	if vol.CreatedAt != "" {
+		createdAt, err := time.Parse(time.RFC3339, vol.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("volume %q: parsing creation time: %w", vol.Name, err)
+		}
+		status.CreatedAt = createdAt
+	}
+
+	return &v1.Space{
+		Name:   vol.Labels[labelKeySpace],
+		Spec:   spec,
+		Status: status,
+	}, nil
+}
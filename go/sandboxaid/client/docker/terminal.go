@@ -0,0 +1,136 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/errdefs"
+	sclient "github.com/substratusai/sandboxai/go/sandboxaid/client"
+)
+
+// Docker does not stop an exec when the client detaches, so the process
+// reports its PID (in the sandbox) before it starts. That PID is hung up
+// when the terminal is closed.
+const (
+	terminalPIDPrefix = `echo $$; `
+	terminalShell     = `if command -v bash >/dev/null 2>&1; then exec bash -l; else exec sh -l; fi`
+)
+
+// terminalExitPollInterval is how often the exec is inspected while waiting
+// for the exit code after the output has ended.
+const terminalExitPollInterval = 50 * time.Millisecond
+
+func (c *DockerClient) OpenTerminal(ctx context.Context, sbx *sclient.Sandbox, opts sclient.TerminalOptions) (sclient.Terminal, error) {
+	cmd := []string{"/bin/sh", "-c", terminalPIDPrefix + terminalShell}
+	if len(opts.Command) > 0 {
+		cmd = append([]string{"/bin/sh", "-c", terminalPIDPrefix + `exec "$@"`, "sh"}, opts.Command...)
+	}
+	var consoleSize *[2]uint
+	if opts.Rows > 0 && opts.Cols > 0 {
+		consoleSize = &[2]uint{opts.Rows, opts.Cols}
+	}
+
+	created, err := c.docker.ContainerExecCreate(ctx, sbx.UID, container.ExecOptions{
+		Cmd:          cmd,
+		User:         opts.User,
+		Env:          []string{"TERM=xterm-256color"},
+		Tty:          true,
+		ConsoleSize:  consoleSize,
+		AttachStdin:  true,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		if errdefs.IsConflict(err) {
+			return nil, fmt.Errorf("creating exec: %w: %v", sclient.ErrSandboxNotRunning, err)
+		}
+		return nil, fmt.Errorf("creating exec: %w", err)
+	}
+	attached, err := c.docker.ContainerExecAttach(ctx, created.ID, container.ExecAttachOptions{
+		Tty:         true,
+		ConsoleSize: consoleSize,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("attaching to exec: %w", err)
+	}
+
+	t := &terminal{
+		c:        c,
+		sbx:      sbx,
+		execID:   created.ID,
+		attached: attached,
+	}
+	// The terminal echoes "<pid>\r\n" before the command starts.
+	line, err := attached.Reader.ReadString('\n')
+	if err != nil {
+		attached.Close()
+		return nil, fmt.Errorf("reading terminal pid: %w", err)
+	}
+	if t.pid, err = strconv.Atoi(strings.TrimSpace(line)); err != nil {
+		attached.Close()
+		return nil, fmt.Errorf("reading terminal pid: unexpected output %q", line)
+	}
+	return t, nil
+}
+
+type terminal struct {
+	c        *DockerClient
+	sbx      *sclient.Sandbox
+	execID   string
+	attached types.HijackedResponse
+	pid      int
+
+	closeOnce sync.Once
+}
+
+func (t *terminal) Read(p []byte) (int, error) {
+	return t.attached.Reader.Read(p)
+}
+
+func (t *terminal) Write(p []byte) (int, error) {
+	return t.attached.Conn.Write(p)
+}
+
+func (t *terminal) Resize(ctx context.Context, rows, cols uint) error {
+	return t.c.docker.ContainerExecResize(ctx, t.execID, container.ResizeOptions{
+		Height: rows,
+		Width:  cols,
+	})
+}
+
+func (t *terminal) Wait(ctx context.Context) (int, error) {
+	for {
+		inspect, err := t.c.docker.ContainerExecInspect(ctx, t.execID)
+		if err != nil {
+			return 0, fmt.Errorf("inspecting exec: %w", err)
+		}
+		if !inspect.Running {
+			return inspect.ExitCode, nil
+		}
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(terminalExitPollInterval):
+		}
+	}
+}
+
+func (t *terminal) Close() error {
+	t.closeOnce.Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if inspect, err := t.c.docker.ContainerExecInspect(ctx, t.execID); err == nil && inspect.Running {
+			if _, _, err := t.c.exec(ctx, t.sbx.UID, []string{"kill", "-HUP", strconv.Itoa(t.pid)}); err != nil {
+				log.Printf("Failed to hang up terminal process %d in sandbox %q: %v", t.pid, t.sbx.Name, err)
+			}
+		}
+		t.attached.Close()
+	})
+	return nil
+}
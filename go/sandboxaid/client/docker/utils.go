@@ -7,13 +7,16 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
-	"strconv"
+	"reflect"
+	"regexp"
 	"strings"
 	"time"
 
 	"math/rand"
+	"net"
 
 	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
 	v1 "github.com/substratusai/sandboxai/go/api/v1"
 	sclient "github.com/substratusai/sandboxai/go/sandboxaid/client"
 )
@@ -152,11 +155,26 @@ func setDockerAPIVersionFromEngineIfNotSet() error {
 	return nil
 }
 
-func containerName(space, name string) string {
-	return fmt.Sprintf("%s.%s", space, name)
+// containerName namespaces the container name by scope so that sandboxes
+// managed by different sandboxaid instances never collide. Scopes and spaces
+// cannot contain '.' so the name is unambiguous.
+func containerName(scope, space, name string) string {
+	return fmt.Sprintf("%s.%s.%s", scope, space, name)
 }
 
-func containerJSONToSandbox(c types.ContainerJSON) (*sclient.Sandbox, error) {
+var scopeRegexp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_-]*$`)
+
+// validateScope ensures the scope can be used as a part of Docker object names.
+func validateScope(scope string) error {
+	if !scopeRegexp.MatchString(scope) {
+		return fmt.Errorf("invalid scope %q: must consist of alphanumeric characters, '_' or '-', and must start with an alphanumeric character", scope)
+	}
+	return nil
+}
+
+// containerJSONToSandbox converts a container to a sandbox that boxd is
+// reached at boxAddr.
+func containerJSONToSandbox(c types.ContainerJSON, rec *sandboxRecord, boxAddr string) (*sclient.Sandbox, error) {
 	var env map[string]string
 	if len(c.Config.Env) > 0 {
 		for _, kv := range c.Config.Env {
@@ -170,34 +188,227 @@ func containerJSONToSandbox(c types.ContainerJSON) (*sclient.Sandbox, error) {
 		}
 	}
 
-	boxHostPort, err := getBoxHostPort(c)
-	if err != nil {
-		return nil, fmt.Errorf("container %q: getting box host port: %w", c.Name, err)
+	var netSpec *v1.SandboxNetwork
+	if mode := c.Config.Labels[labelKeyNetworkMode]; mode != "" {
+		allow, err := egressAllow(c.Config.Labels)
+		if err != nil {
+			return nil, fmt.Errorf("container %q: %w", c.Name, err)
+		}
+		cassette, err := egressCassette(c.Config.Labels)
+		if err != nil {
+			return nil, fmt.Errorf("container %q: %w", c.Name, err)
+		}
+		netSpec = &v1.SandboxNetwork{
+			Mode:        v1.SandboxNetworkMode(mode),
+			EgressAllow: allow,
+			Cassette:    cassette,
+		}
 	}
 
 	name := c.Config.Labels[labelKeyName]
 
 	return &sclient.Sandbox{
 		Sandbox: &v1.Sandbox{
-			Name: name,
-			UID:  c.ID,
+			Name:   name,
+			Labels: userLabels(c.Config.Labels),
+			UID:    c.ID,
 			Spec: v1.SandboxSpec{
-				Image: c.Config.Image,
-				Env:   env,
+				Image:       c.Config.Image,
+				Env:         env,
+				Resources:   dockerToResources(c.HostConfig),
+				Network:     netSpec,
+				Mounts:      dockerToMounts(c.HostConfig.Mounts, c.Config.Labels[labelKeyScope], c.Config.Labels[labelKeySpace]),
+				TTL:         c.Config.Labels[labelKeyTTL],
+				IdleTimeout: c.Config.Labels[labelKeyIdleTimeout],
+				IdlePolicy:  v1.SandboxSpecIdlePolicy(c.Config.Labels[labelKeyIdlePolicy]),
 			},
+			Status: containerStatus(c, rec),
 		},
-		BoxHostPort: boxHostPort,
+		BoxAddr: boxAddr,
 	}, nil
 }
 
-func getBoxHostPort(dockerContainer types.ContainerJSON) (int, error) {
-	boxHostPortStr := dockerContainer.NetworkSettings.Ports["8000/tcp"][0].HostPort
-	boxHostPort, err := strconv.Atoi(boxHostPortStr)
-	if err != nil {
-		return 0, fmt.Errorf("converting docker host port string %q to int: %w", boxHostPortStr, err)
+// containerStatus derives the sandbox status from the container state and
+// the in-memory record for the sandbox (which may be nil).
+func containerStatus(c types.ContainerJSON, rec *sandboxRecord) *v1.SandboxStatus {
+	status := &v1.SandboxStatus{
+		CreatedAt: parseDockerTime(c.Created),
+	}
+	if rec != nil {
+		status.Startup = rec.startup
+		if !rec.readyAt.IsZero() {
+			status.ReadyAt = &rec.readyAt
+		}
+		if !rec.lastActivityAt.IsZero() {
+			status.LastActivityAt = &rec.lastActivityAt
+		}
+	}
+
+	phase := v1.SandboxPhasePending
+	status.Phase = &phase
+
+	state := c.State
+	if state == nil {
+		return status
+	}
+
+	status.StartedAt = parseDockerTime(state.StartedAt)
+	status.OomKilled = state.OOMKilled
+
+	switch {
+	case state.Paused:
+		phase = v1.SandboxPhasePaused
+	case state.Running:
+		switch {
+		case rec == nil:
+			// The record was lost (sandboxaid restarted) after the
+			// sandbox was created, it would have been ready by now.
+			phase = v1.SandboxPhaseReady
+		case rec.failReason != "":
+			phase = v1.SandboxPhaseFailed
+			status.Reason = rec.failReason
+			status.Message = rec.failMessage
+		case !rec.readyAt.IsZero() || rec.done == nil:
+			// Records that were added without waiting for readiness
+			// (after sandboxaid restarted) are ready too.
+			phase = v1.SandboxPhaseReady
+		}
+	case state.Status == "created" || state.Restarting:
+		phase = v1.SandboxPhasePending
+	default:
+		// Exited, dead, or being removed.
+		exitCode := state.ExitCode
+		status.ExitCode = &exitCode
+		status.FinishedAt = parseDockerTime(state.FinishedAt)
+		switch {
+		case rec != nil && rec.stopReason != "":
+			// Stopping sends a signal so the exit code is not meaningful.
+			phase = v1.SandboxPhaseStopped
+			status.Reason = rec.stopReason
+			status.Message = rec.stopMessage
+		case state.OOMKilled:
+			phase = v1.SandboxPhaseFailed
+			status.Reason = "OOMKilled"
+			status.Message = fmt.Sprintf("Container was killed because it ran out of memory (exit code %d)", exitCode)
+		case exitCode != 0 || state.Error != "":
+			phase = v1.SandboxPhaseFailed
+			status.Reason = "Error"
+			status.Message = fmt.Sprintf("Container exited with code %d", exitCode)
+			if state.Error != "" {
+				status.Message += ": " + state.Error
+			}
+		default:
+			phase = v1.SandboxPhaseStopped
+			status.Reason = "Completed"
+			status.Message = "Container exited with code 0"
+		}
+	}
+
+	if rec != nil && rec.expireReason != "" {
+		// Expired sandboxes are being deleted, which is more relevant
+		// than the state of the container.
+		phase = v1.SandboxPhaseExpired
+		status.Reason = rec.expireReason
+		status.Message = rec.expireMessage
+	}
+
+	return status
+}
+
+// resourcesToDocker converts resource limits to their Docker equivalent.
+func resourcesToDocker(r v1.SandboxResources) container.Resources {
+	out := container.Resources{
+		NanoCPUs: int64(r.CPUs * 1e9),
+		Memory:   r.Memory,
+	}
+	if r.Memory > 0 {
+		// Docker allows as much swap as memory by default.
+		out.MemorySwap = r.Memory + r.Swap
 	}
-	return boxHostPort, nil
+	if r.PIDs > 0 {
+		out.PidsLimit = &r.PIDs
+	}
+	for _, u := range r.Ulimits {
+		out.Ulimits = append(out.Ulimits, &container.Ulimit{Name: u.Name, Soft: u.Soft, Hard: u.Hard})
+	}
+	return out
 }
+
+// dockerToResources returns the resource limits of a container, or nil if
+// it has none.
+func dockerToResources(hc *container.HostConfig) *v1.SandboxResources {
+	if hc == nil {
+		return nil
+	}
+	r := v1.SandboxResources{
+		CPUs:    float64(hc.NanoCPUs) / 1e9,
+		Memory:  hc.Memory,
+		ShmSize: hc.ShmSize,
+	}
+	if hc.Memory > 0 && hc.MemorySwap > hc.Memory {
+		r.Swap = hc.MemorySwap - hc.Memory
+	}
+	if hc.PidsLimit != nil && *hc.PidsLimit > 0 {
+		r.PIDs = *hc.PidsLimit
+	}
+	for _, u := range hc.Ulimits {
+		r.Ulimits = append(r.Ulimits, v1.Ulimit{Name: u.Name, Soft: u.Soft, Hard: u.Hard})
+	}
+	if reflect.ValueOf(r).IsZero() {
+		return nil
+	}
+	return &r
+}
+
+// parseDockerTime parses a timestamp returned by the Docker API.
+// Docker uses the zero time for events that have not happened.
+func parseDockerTime(s string) *time.Time {
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil || t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// getBoxAddr returns the address that boxd can be reached at from the host:
+// its published port, or a relay for sandboxes on internal networks.
+func (c *DockerClient) getBoxAddr(dockerContainer types.ContainerJSON) (string, error) {
+	if addr, ok := publishedBoxAddr(dockerContainer); ok {
+		return addr, nil
+	}
+	return c.relayAddr(dockerContainer.ID)
+}
+
+// publishedBoxAddr returns the host address that the port of boxd is
+// published on. False is returned if it is not published.
+func publishedBoxAddr(dockerContainer types.ContainerJSON) (string, bool) {
+	settings := dockerContainer.NetworkSettings
+	if settings == nil {
+		return "", false
+	}
+	bindings := settings.Ports[boxPort+"/tcp"]
+	if len(bindings) == 0 || bindings[0].HostPort == "" {
+		return "", false
+	}
+	return net.JoinHostPort("127.0.0.1", bindings[0].HostPort), true
+}
+
+// userLabels extracts the user-specified labels from a set of container labels.
+func userLabels(labels map[string]string) map[string]string {
+	var out map[string]string
+	for k, v := range labels {
+		key, ok := strings.CutPrefix(k, labelKeyUserPrefix)
+		if !ok {
+			continue
+		}
+		if out == nil {
+			out = make(map[string]string)
+		}
+		out[key] = v
+	}
+	return out
+}
+
 func parseEnvKeyVal(s string) (string, string) {
 	key, val, ok := strings.Cut(s, "=")
 	if ok {
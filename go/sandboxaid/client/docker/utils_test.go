@@ -1,9 +1,16 @@
 package docker
 
 import (
+	"net"
 	"testing"
+	"time"
 
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/go-connections/nat"
 	"github.com/stretchr/testify/require"
+	v1 "github.com/substratusai/sandboxai/go/api/v1"
 )
 
 func Test_parseEnvKeyVal(t *testing.T) {
@@ -37,3 +44,215 @@ func Test_parseEnvKeyVal(t *testing.T) {
 		})
 	}
 }
+
+func Test_userLabels(t *testing.T) {
+	require.Nil(t, userLabels(map[string]string{
+		labelKeyScope: "default",
+		labelKeyName:  "abc",
+	}))
+	require.Equal(t, map[string]string{"team": "ml"}, userLabels(map[string]string{
+		labelKeyScope:               "default",
+		labelKeyUserPrefix + "team": "ml",
+	}))
+}
+
+func Test_validateScope(t *testing.T) {
+	require.NoError(t, validateScope("default"))
+	require.NoError(t, validateScope("5f0c9a8e-0b8e-4c8e-9a4e-2f1d0c6b7a11"))
+	require.Error(t, validateScope(""))
+	require.Error(t, validateScope("a.b"), "dots would make container names ambiguous")
+	require.Error(t, validateScope("-a"))
+}
+
+func Test_containerName(t *testing.T) {
+	require.Equal(t, "scope-a.default.my-box", containerName("scope-a", "default", "my-box"))
+	require.NotEqual(t,
+		containerName("scope-a", "default", "my-box"),
+		containerName("scope-b", "default", "my-box"),
+		"container names should be unique across scopes",
+	)
+}
+
+func Test_containerStatus(t *testing.T) {
+	readyAt := time.Date(2025, 1, 1, 0, 0, 5, 0, time.UTC)
+
+	cases := []struct {
+		name       string
+		state      *types.ContainerState
+		rec        *sandboxRecord
+		expPhase   v1.SandboxPhase
+		expReason  string
+		expExit    *int
+		expReadyAt *time.Time
+	}{
+		{
+			name:     "created",
+			state:    &types.ContainerState{Status: "created"},
+			rec:      &sandboxRecord{},
+			expPhase: v1.SandboxPhasePending,
+		},
+		{
+			name:     "running not ready",
+			state:    &types.ContainerState{Status: "running", Running: true},
+			rec:      &sandboxRecord{done: make(chan struct{})},
+			expPhase: v1.SandboxPhasePending,
+		},
+		{
+			name:     "running with record added after restart",
+			state:    &types.ContainerState{Status: "running", Running: true},
+			rec:      &sandboxRecord{},
+			expPhase: v1.SandboxPhaseReady,
+		},
+		{
+			name:       "running ready",
+			state:      &types.ContainerState{Status: "running", Running: true},
+			rec:        &sandboxRecord{readyAt: readyAt},
+			expPhase:   v1.SandboxPhaseReady,
+			expReadyAt: &readyAt,
+		},
+		{
+			name:     "running without record",
+			state:    &types.ContainerState{Status: "running", Running: true},
+			expPhase: v1.SandboxPhaseReady,
+		},
+		{
+			name:      "running never ready",
+			state:     &types.ContainerState{Status: "running", Running: true},
+			rec:       &sandboxRecord{failReason: "ReadinessCheckFailed"},
+			expPhase:  v1.SandboxPhaseFailed,
+			expReason: "ReadinessCheckFailed",
+		},
+		{
+			name:      "exited cleanly",
+			state:     &types.ContainerState{Status: "exited", ExitCode: 0},
+			expPhase:  v1.SandboxPhaseStopped,
+			expReason: "Completed",
+			expExit:   ptr(0),
+		},
+		{
+			name:      "exited with error",
+			state:     &types.ContainerState{Status: "exited", ExitCode: 1},
+			expPhase:  v1.SandboxPhaseFailed,
+			expReason: "Error",
+			expExit:   ptr(1),
+		},
+		{
+			name:      "oom killed",
+			state:     &types.ContainerState{Status: "exited", ExitCode: 137, OOMKilled: true},
+			expPhase:  v1.SandboxPhaseFailed,
+			expReason: "OOMKilled",
+			expExit:   ptr(137),
+		},
+		{
+			name:      "stopped when idle",
+			state:     &types.ContainerState{Status: "exited", ExitCode: 143},
+			rec:       &sandboxRecord{stopReason: "IdleStopped"},
+			expPhase:  v1.SandboxPhaseStopped,
+			expReason: "IdleStopped",
+			expExit:   ptr(143),
+		},
+		{
+			name:       "paused",
+			state:      &types.ContainerState{Status: "paused", Running: true, Paused: true},
+			rec:        &sandboxRecord{readyAt: readyAt},
+			expPhase:   v1.SandboxPhasePaused,
+			expReadyAt: &readyAt,
+		},
+		{
+			name:       "expired",
+			state:      &types.ContainerState{Status: "running", Running: true},
+			rec:        &sandboxRecord{readyAt: readyAt, expireReason: "IdleTimeout"},
+			expPhase:   v1.SandboxPhaseExpired,
+			expReason:  "IdleTimeout",
+			expReadyAt: &readyAt,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			status := containerStatus(types.ContainerJSON{
+				ContainerJSONBase: &types.ContainerJSONBase{
+					Created: "2025-01-01T00:00:00.123456789Z",
+					State:   c.state,
+				},
+			}, c.rec)
+			require.NotNil(t, status.Phase)
+			require.Equal(t, c.expPhase, *status.Phase, "phase")
+			require.Equal(t, c.expReason, status.Reason, "reason")
+			require.Equal(t, c.expExit, status.ExitCode, "exit code")
+			require.Equal(t, c.expReadyAt, status.ReadyAt, "ready at")
+			require.NotNil(t, status.CreatedAt, "created at")
+		})
+	}
+}
+
+func Test_parseDockerTime(t *testing.T) {
+	require.Nil(t, parseDockerTime(""))
+	require.Nil(t, parseDockerTime("0001-01-01T00:00:00Z"))
+	require.Equal(t,
+		time.Date(2025, 1, 1, 0, 0, 0, 123456789, time.UTC),
+		*parseDockerTime("2025-01-01T00:00:00.123456789Z"),
+	)
+}
+
+func Test_resourcesToDocker(t *testing.T) {
+	r := v1.SandboxResources{
+		CPUs:    1.5,
+		Memory:  1 << 30,
+		Swap:    512 << 20,
+		PIDs:    256,
+		Ulimits: []v1.Ulimit{{Name: "nofile", Soft: 1024, Hard: 4096}},
+	}
+	res := resourcesToDocker(r)
+	require.EqualValues(t, 1_500_000_000, res.NanoCPUs)
+	require.EqualValues(t, (1<<30)+(512<<20), res.MemorySwap)
+	require.EqualValues(t, 256, *res.PidsLimit)
+
+	r.ShmSize = 64 << 20
+	got := dockerToResources(&container.HostConfig{Resources: res, ShmSize: r.ShmSize})
+	require.Equal(t, &r, got, "round trip")
+
+	require.EqualValues(t, 1<<20, resourcesToDocker(v1.SandboxResources{Memory: 1 << 20}).MemorySwap, "no swap by default")
+	require.Nil(t, dockerToResources(&container.HostConfig{}))
+}
+
+func Test_getBoxAddr(t *testing.T) {
+	c := &DockerClient{relays: newRelays()}
+	withSettings := func(settings *types.NetworkSettings) types.ContainerJSON {
+		return types.ContainerJSON{
+			ContainerJSONBase: &types.ContainerJSONBase{ID: "abc"},
+			NetworkSettings:   settings,
+		}
+	}
+
+	addr, err := c.getBoxAddr(withSettings(&types.NetworkSettings{
+		NetworkSettingsBase: types.NetworkSettingsBase{Ports: nat.PortMap{
+			"8000/tcp": []nat.PortBinding{{HostIP: "127.0.0.1", HostPort: "32768"}},
+		}},
+		Networks: map[string]*network.EndpointSettings{"bridged": {IPAddress: "172.18.0.2"}},
+	}))
+	require.NoError(t, err)
+	require.Equal(t, "127.0.0.1:32768", addr, "published port")
+
+	addr, err = c.getBoxAddr(withSettings(&types.NetworkSettings{
+		NetworkSettingsBase: types.NetworkSettingsBase{Ports: nat.PortMap{"8000/tcp": nil}},
+		Networks:            map[string]*network.EndpointSettings{"internal": {IPAddress: "172.19.0.2"}},
+	}))
+	require.NoError(t, err)
+	t.Cleanup(func() { c.closeRelay("abc") })
+	host, _, err := net.SplitHostPort(addr)
+	require.NoError(t, err)
+	require.Equal(t, "127.0.0.1", host, "internal network is relayed")
+
+	again, err := c.getBoxAddr(withSettings(&types.NetworkSettings{}))
+	require.NoError(t, err)
+	require.Equal(t, addr, again, "relay is reused")
+
+	c.closeRelay("abc")
+	_, err = net.Dial("tcp", addr)
+	require.Error(t, err, "relay is closed")
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,383 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/volume"
+	dclient "github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+	v1 "github.com/substratusai/sandboxai/go/api/v1"
+	sclient "github.com/substratusai/sandboxai/go/sandboxaid/client"
+)
+
+// Volumes are labelled Docker volumes like spaces, but of a different kind
+// so that the volumes that store spaces are never listed as volumes.
+
+const kindVolume = "volume"
+
+func volumeName(scope, space, name string) string {
+	return fmt.Sprintf("sandboxai-volume.%s.%s.%s", scope, space, name)
+}
+
+// SetBindMountAllow sets the host directories that sandboxes can bind mount
+// (including their subdirectories). The directories must exist.
+func (c *DockerClient) SetBindMountAllow(dirs []string) error {
+	var resolved []string
+	for _, dir := range dirs {
+		if !filepath.IsAbs(dir) {
+			return fmt.Errorf("bind mount directory %q: must be absolute", dir)
+		}
+		r, err := filepath.EvalSymlinks(dir)
+		if err != nil {
+			return fmt.Errorf("bind mount directory %q: %w", dir, err)
+		}
+		resolved = append(resolved, r)
+	}
+	c.bindMountAllow = resolved
+	return nil
+}
+
+func (c *DockerClient) CreateVolume(ctx context.Context, space string, req *v1.CreateVolumeRequest) (*v1.Volume, error) {
+	if err := sclient.ValidateVolumeName(req.Name); err != nil {
+		return nil, err
+	}
+	if _, err := c.GetSpace(ctx, space); err != nil {
+		return nil, err
+	}
+	var snapshotPath string
+	if req.Snapshot != "" {
+		tarPath, metaPath, err := c.snapshotPaths(space, req.Snapshot)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := readSnapshot(metaPath); err != nil {
+			return nil, err
+		}
+		snapshotPath = tarPath
+	}
+
+	labels := map[string]string{
+		labelKeyScope: c.scope,
+		labelKeySpace: space,
+		labelKeyKind:  kindVolume,
+		labelKeyName:  req.Name,
+	}
+	for k, v := range req.Labels {
+		labels[labelKeyUserPrefix+k] = v
+	}
+	vol, err := c.createVolume(ctx, space, req.Name, labels)
+	if err != nil {
+		return nil, err
+	}
+	if snapshotPath != "" {
+		if err := c.restoreSnapshot(ctx, vol.Name, snapshotPath); err != nil {
+			if rmErr := c.docker.VolumeRemove(context.Background(), vol.Name, true); rmErr != nil {
+				log.Printf("Failed to remove volume %q after failed restore: %v", vol.Name, rmErr)
+			}
+			return nil, fmt.Errorf("restoring snapshot %q: %w", req.Snapshot, err)
+		}
+		log.Printf("Created volume: %q in space %q from snapshot %q", req.Name, space, req.Snapshot)
+		return dockerToVolume(vol, nil)
+	}
+
+	log.Printf("Created volume: %q in space %q", req.Name, space)
+
+	return dockerToVolume(vol, nil)
+}
+
+// createVolume creates the Docker volume for a volume in the space, unless
+// it already exists.
+func (c *DockerClient) createVolume(ctx context.Context, space, name string, labels map[string]string) (volume.Volume, error) {
+	c.volumeMtx.Lock()
+	defer c.volumeMtx.Unlock()
+
+	if _, err := c.inspectVolume(ctx, space, name); err == nil {
+		return volume.Volume{}, fmt.Errorf("volume %q: %w", name, sclient.ErrVolumeAlreadyExists)
+	} else if !errors.Is(err, sclient.ErrVolumeNotFound) {
+		return volume.Volume{}, err
+	}
+	vol, err := c.docker.VolumeCreate(ctx, volume.CreateOptions{
+		Name:   volumeName(c.scope, space, name),
+		Labels: labels,
+	})
+	if err != nil {
+		return volume.Volume{}, fmt.Errorf("creating volume: %w", err)
+	}
+	return vol, nil
+}
+
+func (c *DockerClient) GetVolume(ctx context.Context, space, name string) (*v1.Volume, error) {
+	vol, err := c.inspectVolume(ctx, space, name)
+	if err != nil {
+		return nil, err
+	}
+	mountedBy, err := c.volumeMountedBy(ctx, vol.Name)
+	if err != nil {
+		return nil, err
+	}
+	return dockerToVolume(vol, mountedBy)
+}
+
+func (c *DockerClient) ListVolumes(ctx context.Context, space string) ([]v1.Volume, error) {
+	if _, err := c.GetSpace(ctx, space); err != nil {
+		return nil, err
+	}
+	resp, err := c.docker.VolumeList(ctx, volume.ListOptions{
+		Filters: filters.NewArgs(
+			filters.Arg("label", fmt.Sprintf("%s=%s", labelKeyScope, c.scope)),
+			filters.Arg("label", fmt.Sprintf("%s=%s", labelKeySpace, space)),
+			filters.Arg("label", fmt.Sprintf("%s=%s", labelKeyKind, kindVolume)),
+		),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing volumes: %w", err)
+	}
+
+	// Find the sandboxes that mount each volume with a single list.
+	containers, err := c.docker.ContainerList(ctx, container.ListOptions{
+		All: true,
+		Filters: filters.NewArgs(
+			filters.Arg("label", fmt.Sprintf("%s=%s", labelKeyScope, c.scope)),
+			filters.Arg("label", fmt.Sprintf("%s=%s", labelKeySpace, space)),
+		),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing containers: %w", err)
+	}
+	mountedBy := make(map[string][]string)
+	for _, ctr := range containers {
+		for _, m := range ctr.Mounts {
+			if m.Type == mount.TypeVolume {
+				mountedBy[m.Name] = append(mountedBy[m.Name], ctr.Labels[labelKeyName])
+			}
+		}
+	}
+
+	volumes := make([]v1.Volume, 0, len(resp.Volumes))
+	for _, vol := range resp.Volumes {
+		names := mountedBy[vol.Name]
+		slices.Sort(names)
+		v, err := dockerToVolume(*vol, names)
+		if err != nil {
+			return nil, err
+		}
+		volumes = append(volumes, *v)
+	}
+	slices.SortFunc(volumes, func(a, b v1.Volume) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return volumes, nil
+}
+
+func (c *DockerClient) DeleteVolume(ctx context.Context, space, name string) error {
+	vol, err := c.inspectVolume(ctx, space, name)
+	if err != nil {
+		return err
+	}
+	mountedBy, err := c.volumeMountedBy(ctx, vol.Name)
+	if err != nil {
+		return err
+	}
+	if len(mountedBy) > 0 {
+		return fmt.Errorf("volume %q is mounted by sandboxes %s: %w", name, strings.Join(mountedBy, ", "), sclient.ErrVolumeInUse)
+	}
+	if err := c.removeVolume(ctx, vol.Name); err != nil {
+		return err
+	}
+
+	log.Printf("Deleted volume: %q in space %q", name, space)
+
+	return nil
+}
+
+// deleteVolumes deletes the volumes in a space. The sandboxes in the space
+// must have been deleted.
+func (c *DockerClient) deleteVolumes(ctx context.Context, space string) error {
+	resp, err := c.docker.VolumeList(ctx, volume.ListOptions{
+		Filters: filters.NewArgs(
+			filters.Arg("label", fmt.Sprintf("%s=%s", labelKeyScope, c.scope)),
+			filters.Arg("label", fmt.Sprintf("%s=%s", labelKeySpace, space)),
+			filters.Arg("label", fmt.Sprintf("%s=%s", labelKeyKind, kindVolume)),
+		),
+	})
+	if err != nil {
+		return fmt.Errorf("listing volumes: %w", err)
+	}
+	for _, vol := range resp.Volumes {
+		if err := c.removeVolume(ctx, vol.Name); err != nil && !errors.Is(err, sclient.ErrVolumeNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *DockerClient) removeVolume(ctx context.Context, vname string) error {
+	// Docker refuses to remove volumes that containers use, which covers
+	// sandboxes that were created since checking.
+	if err := c.docker.VolumeRemove(ctx, vname, false); err != nil {
+		if dclient.IsErrNotFound(err) {
+			return fmt.Errorf("removing volume %q: %w", vname, sclient.ErrVolumeNotFound)
+		}
+		if errdefs.IsConflict(err) {
+			return fmt.Errorf("removing volume %q: %w", vname, sclient.ErrVolumeInUse)
+		}
+		return fmt.Errorf("removing volume %q: %w", vname, err)
+	}
+	return nil
+}
+
+// inspectVolume returns the Docker volume of a volume in the client's scope.
+func (c *DockerClient) inspectVolume(ctx context.Context, space, name string) (volume.Volume, error) {
+	vname := volumeName(c.scope, space, name)
+	vol, err := c.docker.VolumeInspect(ctx, vname)
+	if err != nil {
+		if dclient.IsErrNotFound(err) {
+			return volume.Volume{}, fmt.Errorf("getting volume %q: %w", vname, sclient.ErrVolumeNotFound)
+		}
+		return volume.Volume{}, fmt.Errorf("getting volume %q: %w", vname, err)
+	}
+	if vol.Labels[labelKeyKind] != kindVolume ||
+		vol.Labels[labelKeyScope] != c.scope ||
+		vol.Labels[labelKeySpace] != space ||
+		vol.Labels[labelKeyName] != name {
+		return volume.Volume{}, fmt.Errorf("docker volume %q is not a volume in scope %q: %w", vname, c.scope, sclient.ErrVolumeNotFound)
+	}
+	return vol, nil
+}
+
+// volumeMountedBy returns the names of the sandboxes (running or not) that
+// mount the Docker volume.
+func (c *DockerClient) volumeMountedBy(ctx context.Context, vname string) ([]string, error) {
+	containers, err := c.docker.ContainerList(ctx, container.ListOptions{
+		All: true,
+		Filters: filters.NewArgs(
+			filters.Arg("label", fmt.Sprintf("%s=%s", labelKeyScope, c.scope)),
+			filters.Arg("volume", vname),
+		),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing containers: %w", err)
+	}
+	names := make([]string, 0, len(containers))
+	for _, ctr := range containers {
+		names = append(names, ctr.Labels[labelKeyName])
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+// dockerMounts returns the Docker mounts for the mounts of a new sandbox.
+// ErrVolumeNotFound and ErrBindMountNotAllowed are returned for sources that
+// cannot be mounted.
+func (c *DockerClient) dockerMounts(ctx context.Context, space string, mounts []v1.Mount) ([]mount.Mount, error) {
+	var result []mount.Mount
+	for _, m := range mounts {
+		switch m.Type {
+		case v1.MountTypeVolume:
+			vol, err := c.inspectVolume(ctx, space, m.Source)
+			if err != nil {
+				return nil, fmt.Errorf("mount %q: %w", m.Target, err)
+			}
+			result = append(result, mount.Mount{
+				Type:     mount.TypeVolume,
+				Source:   vol.Name,
+				Target:   m.Target,
+				ReadOnly: m.ReadOnly,
+			})
+		case v1.MountTypeBind:
+			source, err := c.resolveBindSource(m.Source)
+			if err != nil {
+				return nil, fmt.Errorf("mount %q: %w", m.Target, err)
+			}
+			result = append(result, mount.Mount{
+				Type:     mount.TypeBind,
+				Source:   source,
+				Target:   m.Target,
+				ReadOnly: m.ReadOnly,
+			})
+		default:
+			return nil, fmt.Errorf("mount %q: unsupported type %q", m.Target, m.Type)
+		}
+	}
+	return result, nil
+}
+
+// resolveBindSource resolves the symlinks in a host path and checks that it
+// is in an allowed directory. Symlinks are resolved so that a sandbox cannot
+// create a link in an allowed directory that a later sandbox mounts.
+func (c *DockerClient) resolveBindSource(source string) (string, error) {
+	if len(c.bindMountAllow) == 0 {
+		return "", fmt.Errorf("host path %q: no host directories are allowed: %w", source, sclient.ErrBindMountNotAllowed)
+	}
+	resolved, err := filepath.EvalSymlinks(source)
+	if err != nil {
+		return "", fmt.Errorf("host path %q: %w: %v", source, sclient.ErrBindMountNotAllowed, err)
+	}
+	if !pathInDirs(resolved, c.bindMountAllow) {
+		return "", fmt.Errorf("host path %q: not in an allowed directory: %w", source, sclient.ErrBindMountNotAllowed)
+	}
+	return resolved, nil
+}
+
+// pathInDirs reports if the path is one of the directories or inside one.
+func pathInDirs(p string, dirs []string) bool {
+	for _, dir := range dirs {
+		if p == dir || strings.HasPrefix(p, strings.TrimSuffix(dir, string(filepath.Separator))+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// dockerToMounts returns the mounts of a sandbox from its Docker mounts.
+func dockerToMounts(mounts []mount.Mount, scope, space string) []v1.Mount {
+	var result []v1.Mount
+	prefix := volumeName(scope, space, "")
+	for _, m := range mounts {
+		switch m.Type {
+		case mount.TypeVolume:
+			result = append(result, v1.Mount{
+				Type:     v1.MountTypeVolume,
+				Source:   strings.TrimPrefix(m.Source, prefix),
+				Target:   m.Target,
+				ReadOnly: m.ReadOnly,
+			})
+		case mount.TypeBind:
+			result = append(result, v1.Mount{
+				Type:     v1.MountTypeBind,
+				Source:   m.Source,
+				Target:   m.Target,
+				ReadOnly: m.ReadOnly,
+			})
+		}
+	}
+	return result
+}
+
+func dockerToVolume(vol volume.Volume, mountedBy []string) (*v1.Volume, error) {
+	status := v1.VolumeStatus{MountedBy: mountedBy}
+	if status.MountedBy == nil {
+		status.MountedBy = []string{}
+	}
+	if vol.CreatedAt != "" {
+		createdAt, err := time.Parse(time.RFC3339, vol.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("volume %q: parsing creation time: %w", vol.Name, err)
+		}
+		status.CreatedAt = createdAt
+	}
+	return &v1.Volume{
+		Name:   vol.Labels[labelKeyName],
+		Labels: userLabels(vol.Labels),
+		Status: status,
+	}, nil
+}
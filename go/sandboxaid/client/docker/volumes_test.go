@@ -0,0 +1,80 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/docker/docker/api/types/mount"
+	dclient "github.com/docker/docker/client"
+	"github.com/stretchr/testify/require"
+	v1 "github.com/substratusai/sandboxai/go/api/v1"
+)
+
+func Test_pathInDirs(t *testing.T) {
+	dirs := []string{"/srv/data", "/models/"}
+	require.True(t, pathInDirs("/srv/data", dirs))
+	require.True(t, pathInDirs("/srv/data/a/b", dirs))
+	require.True(t, pathInDirs("/models/llama", dirs))
+	require.False(t, pathInDirs("/srv/database", dirs))
+	require.False(t, pathInDirs("/srv", dirs))
+	require.False(t, pathInDirs("/etc", nil))
+}
+
+func Test_dockerToMounts(t *testing.T) {
+	require.Nil(t, dockerToMounts(nil, "default", "myspace"))
+	require.Equal(t, []v1.Mount{
+		{Type: v1.MountTypeVolume, Source: "data", Target: "/data"},
+		{Type: v1.MountTypeBind, Source: "/srv/models", Target: "/models", ReadOnly: true},
+	}, dockerToMounts([]mount.Mount{
+		{Type: mount.TypeVolume, Source: "sandboxai-volume.default.myspace.data", Target: "/data"},
+		{Type: mount.TypeBind, Source: "/srv/models", Target: "/models", ReadOnly: true},
+		{Type: mount.TypeTmpfs, Target: "/tmp"},
+	}, "default", "myspace"))
+}
+
+func TestDockerClient_CleanupSpaceKeepsVolumes(t *testing.T) {
+	var mtx sync.Mutex
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path[strings.Index(r.URL.Path[1:], "/")+1:]
+		mtx.Lock()
+		requests = append(requests, r.Method+" "+path)
+		mtx.Unlock()
+		switch {
+		case r.Method == http.MethodGet && path == "/volumes/sandboxai-space.test.default":
+			fmt.Fprint(w, `{"Name":"sandboxai-space.test.default","Labels":{"sandboxai.scope":"test","sandboxai.space":"default","sandboxai.kind":"space"}}`)
+		case r.Method == http.MethodGet && path == "/containers/json":
+			fmt.Fprint(w, `[]`)
+		case r.Method == http.MethodGet && path == "/networks":
+			fmt.Fprint(w, `[{"Id":"net1","Name":"sandboxai-network.test.default.internal","IPAM":{"Config":[]}}]`)
+		case r.Method == http.MethodDelete && path == "/networks/net1":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message":"not found"}`)
+		}
+	}))
+	defer srv.Close()
+
+	docker, err := dclient.NewClientWithOpts(dclient.WithHost("tcp://"+srv.Listener.Addr().String()), dclient.WithVersion("1.47"))
+	require.NoError(t, err)
+	snapshot := filepath.Join(t.TempDir(), "default", "snap.tar")
+	require.NoError(t, os.MkdirAll(filepath.Dir(snapshot), 0o755))
+	require.NoError(t, os.WriteFile(snapshot, nil, 0o644))
+	c := &DockerClient{docker: docker, scope: "test", snapshotDir: filepath.Dir(filepath.Dir(snapshot))}
+
+	require.NoError(t, c.CleanupSpace(context.Background(), "default"))
+
+	require.Contains(t, requests, "DELETE /networks/net1")
+	for _, req := range requests {
+		require.False(t, strings.HasPrefix(req, "DELETE /volumes/"), "volume removed: %s", req)
+	}
+	require.FileExists(t, snapshot)
+}
@@ -3,19 +3,215 @@ package client
 import (
 	"context"
 	"errors"
+	"io"
+	"io/fs"
+	"net"
+	"time"
 
 	v1 "github.com/substratusai/sandboxai/go/api/v1"
+	"github.com/substratusai/sandboxai/go/sandboxaid/egress"
 )
 
 var ErrSandboxNotFound = errors.New("sandbox not found")
+var ErrSpaceNotFound = errors.New("space not found")
+var ErrSpaceAlreadyExists = errors.New("space already exists")
+var ErrResumeTokenExpired = errors.New("resume token expired")
+var ErrPathNotFound = errors.New("path not found")
+var ErrFileTooLarge = errors.New("file too large")
+var ErrIsDirectory = errors.New("is a directory")
+var ErrNotDirectory = errors.New("not a directory")
+var ErrDirectoryNotEmpty = errors.New("directory not empty")
+var ErrInvalidArchive = errors.New("invalid archive")
+var ErrSandboxNotRunning = errors.New("sandbox is not running")
+var ErrSandboxPaused = errors.New("sandbox is paused")
+var ErrPortNotListening = errors.New("nothing is listening on the port")
+var ErrEgressProxyDisabled = errors.New("the egress proxy is disabled")
+var ErrEgressProxyUnreachable = errors.New("the egress proxy cannot be reached from sandbox networks (Docker runs in a VM, for example with Docker Desktop or colima)")
+var ErrCassetteNotFound = egress.ErrCassetteNotFound
+var ErrVolumeNotFound = errors.New("volume not found")
+var ErrVolumeAlreadyExists = errors.New("volume already exists")
+var ErrVolumeInUse = errors.New("volume is mounted by a sandbox")
+var ErrBindMountNotAllowed = errors.New("bind mount not allowed")
+var ErrSnapshotNotFound = errors.New("snapshot not found")
+var ErrSnapshotAlreadyExists = errors.New("snapshot already exists")
+var ErrSnapshotsDisabled = errors.New("snapshots are disabled")
+
+// MaxFileSize is the maximum size of a file that can be read or written
+// through the file API.
+const MaxFileSize = 32 << 20
+
+// MaxListDirEntries is the maximum number of entries returned when listing
+// a directory.
+const MaxListDirEntries = 10000
+
+// DefaultSpace is the space that always exists and cannot be deleted.
+const DefaultSpace = "default"
 
 type Sandbox struct {
 	*v1.Sandbox
-	BoxHostPort int
+	// BoxAddr is the host:port that boxd can be reached at. Empty if the
+	// sandbox is not running.
+	BoxAddr string
+}
+
+// Paused returns true if the sandbox is paused.
+func (s *Sandbox) Paused() bool {
+	return s.Status != nil && s.Status.Phase != nil && *s.Status.Phase == v1.SandboxPhasePaused
+}
+
+// ListOptions control which sandboxes are returned from a list call.
+type ListOptions struct {
+	// Selector filters sandboxes by their labels.
+	Selector Selector
+	// Limit is the maximum number of sandboxes to return (0 means no limit).
+	Limit int
+	// After is the name of the sandbox that results should start after.
+	// Sandboxes are listed in order of name.
+	After string
+}
+
+type SandboxList struct {
+	Items []*Sandbox
+	// Continue is the value to pass as ListOptions.After to retrieve
+	// the next page. Empty if there are no more sandboxes.
+	Continue string
+}
+
+// Event is a sandbox lifecycle event.
+type Event struct {
+	v1.SandboxEvent
+	// Labels are the user-specified labels of the sandbox.
+	Labels map[string]string
+}
+
+// WatchOptions control which events are returned from a watch.
+type WatchOptions struct {
+	// Selector filters events by the labels of the sandbox.
+	Selector Selector
+	// ResumeToken is the token of the last event that was received.
+	// If empty, only new events are returned.
+	ResumeToken string
+}
+
+// TerminalOptions configure the process started by OpenTerminal.
+type TerminalOptions struct {
+	// Command is the program and arguments to run. If empty, a login shell
+	// is started.
+	Command []string
+	// User to run the process as. If empty, the sandbox's user is used.
+	User string
+	// Rows and Cols are the initial size of the terminal (0 for the default).
+	Rows, Cols uint
+}
+
+// Terminal is a process attached to a pseudo-terminal in a sandbox.
+type Terminal interface {
+	// Read reads terminal output. io.EOF is returned once the process has
+	// exited and all output has been read.
+	Read(p []byte) (int, error)
+	// Write writes terminal input.
+	Write(p []byte) (int, error)
+	Resize(ctx context.Context, rows, cols uint) error
+	// Wait waits for the process to exit (after Read returned io.EOF) and
+	// returns its exit code.
+	Wait(ctx context.Context) (int, error)
+	// Close hangs up the process if it is still running and releases the
+	// terminal.
+	Close() error
 }
 
 type Client interface {
+	CreateSpace(ctx context.Context, req *v1.CreateSpaceRequest) (*v1.Space, error)
+	GetSpace(ctx context.Context, space string) (*v1.Space, error)
+	// DeleteSpace deletes a space and all of the sandboxes, volumes and
+	// snapshots in it.
+	DeleteSpace(ctx context.Context, space string) error
+
+	CreateVolume(ctx context.Context, space string, req *v1.CreateVolumeRequest) (*v1.Volume, error)
+	GetVolume(ctx context.Context, space, name string) (*v1.Volume, error)
+	ListVolumes(ctx context.Context, space string) ([]v1.Volume, error)
+	// DeleteVolume deletes a volume and its data. ErrVolumeInUse is returned
+	// if a sandbox mounts it.
+	DeleteVolume(ctx context.Context, space, name string) error
+
+	// SnapshotVolume stores a copy of the data in a volume. It can be
+	// restored with CreateVolume.
+	SnapshotVolume(ctx context.Context, space, volume string, req *v1.CreateSnapshotRequest) (*v1.Snapshot, error)
+	GetSnapshot(ctx context.Context, space, name string) (*v1.Snapshot, error)
+	// ListSnapshots lists the snapshots in a space, oldest first. If volume
+	// is set only the snapshots of that volume are listed.
+	ListSnapshots(ctx context.Context, space, volume string) ([]v1.Snapshot, error)
+	DeleteSnapshot(ctx context.Context, space, name string) error
+
 	CreateSandbox(ctx context.Context, space string, req *v1.CreateSandboxRequest) (*Sandbox, error)
+	// GetSandbox returns a sandbox. Sandboxes that were deleted because
+	// their ttl or idle_timeout passed are returned in the Expired phase for
+	// a while after they are deleted.
 	GetSandbox(ctx context.Context, space, name string) (*Sandbox, error)
+	// WaitForReady blocks until the sandbox is no longer Pending or the
+	// timeout passes, and then returns the latest state of the sandbox.
+	WaitForReady(ctx context.Context, space, name string, timeout time.Duration) (*Sandbox, error)
+	ListSandboxes(ctx context.Context, space string, opts ListOptions) (*SandboxList, error)
 	DeleteSandbox(ctx context.Context, space, name string) error
+	// PauseSandbox freezes the processes in a sandbox until it is resumed.
+	// ErrSandboxNotRunning is returned if the sandbox is not running.
+	PauseSandbox(ctx context.Context, space, name string) (*Sandbox, error)
+	// ResumeSandbox unfreezes the processes in a paused sandbox.
+	// ErrSandboxNotRunning is returned if the sandbox is not running.
+	ResumeSandbox(ctx context.Context, space, name string) (*Sandbox, error)
+	// StartSandbox starts the container of a sandbox that was stopped by its
+	// idle_policy and waits for it to become ready (or for the ready timeout
+	// to pass). Running sandboxes are returned as they are.
+	StartSandbox(ctx context.Context, space, name string) (*Sandbox, error)
+	// WatchSandboxes streams events until ctx is done. ErrResumeTokenExpired
+	// is returned if the watch cannot be resumed from opts.ResumeToken.
+	WatchSandboxes(ctx context.Context, space string, opts WatchOptions) (<-chan Event, error)
+	// TrackActivity marks the sandbox as serving a tool call (or an open
+	// connection) until the returned function is called. Sandboxes are never
+	// idle while serving tool calls, and if the sandbox is being stopped for
+	// being idle, TrackActivity waits until it has stopped.
+	TrackActivity(sbx *Sandbox) (done func())
+
+	// File operations. Paths must be absolute and clean (see ValidatePath).
+	// ErrPathNotFound is returned for paths that do not exist.
+
+	// WriteFile creates or replaces a file, creating parent directories as needed.
+	WriteFile(ctx context.Context, sbx *Sandbox, path string, content []byte, mode fs.FileMode) (*v1.FileInfo, error)
+	// ReadFile reads a file, following symlinks. ErrFileTooLarge is returned
+	// for files larger than MaxFileSize.
+	ReadFile(ctx context.Context, sbx *Sandbox, path string) ([]byte, *v1.FileInfo, error)
+	// ListDir lists the entries in a directory, following symlinks. At most
+	// MaxListDirEntries entries are returned and truncated reports if there were more.
+	ListDir(ctx context.Context, sbx *Sandbox, path string) (entries []v1.FileInfo, truncated bool, err error)
+	// StatPath returns information about a path without following symlinks.
+	StatPath(ctx context.Context, sbx *Sandbox, path string) (*v1.FileInfo, error)
+	// DeletePath deletes a file or directory. Non-empty directories are only
+	// deleted if recursive is set, otherwise ErrDirectoryNotEmpty is returned.
+	DeletePath(ctx context.Context, sbx *Sandbox, path string, recursive bool) error
+	// PutArchive extracts a tar archive into a directory, creating it if needed.
+	// ErrNotDirectory is returned if the path exists and is not a directory.
+	PutArchive(ctx context.Context, sbx *Sandbox, path string, archive io.Reader) error
+	// GetArchive returns a tar archive of a file or directory. The entries
+	// are prefixed with the base name of the path.
+	GetArchive(ctx context.Context, sbx *Sandbox, path string) (io.ReadCloser, error)
+
+	// OpenTerminal starts a process attached to a pseudo-terminal.
+	// ErrSandboxNotRunning is returned if the sandbox is not running.
+	OpenTerminal(ctx context.Context, sbx *Sandbox, opts TerminalOptions) (Terminal, error)
+
+	// DialPort opens a TCP connection to a port on the loopback interface of
+	// the sandbox. ErrPortNotListening is returned if the connection is
+	// refused. The connection supports CloseWrite (half-close).
+	DialPort(ctx context.Context, sbx *Sandbox, port int) (PortConn, error)
+
+	// ListEgressConnections returns the most recent connections that the
+	// sandbox made through the egress proxy, oldest first.
+	ListEgressConnections(ctx context.Context, sbx *Sandbox) ([]v1.EgressConnection, error)
+}
+
+// PortConn is a connection to a port in a sandbox.
+type PortConn interface {
+	net.Conn
+	// CloseWrite signals the end of the data sent to the port.
+	CloseWrite() error
 }
@@ -0,0 +1,155 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	v1 "github.com/substratusai/sandboxai/go/api/v1"
+)
+
+var ErrResourcesExceedMax = errors.New("resources exceed the maximum of the space")
+
+// ulimitNames are the limits supported by Docker.
+var ulimitNames = []string{
+	"core", "cpu", "data", "fsize", "locks", "memlock", "msgqueue", "nice",
+	"nofile", "nproc", "rss", "rtprio", "rttime", "sigpending", "stack",
+}
+
+// ValidateResources returns an error if any resource limit is invalid.
+// A nil value is valid.
+func ValidateResources(r *v1.SandboxResources) error {
+	if r == nil {
+		return nil
+	}
+	if r.CPUs < 0 {
+		return fmt.Errorf("cpus: must not be negative")
+	}
+	for _, f := range []struct {
+		name string
+		val  int64
+	}{
+		{"memory", r.Memory},
+		{"swap", r.Swap},
+		{"pids", r.PIDs},
+		{"shm_size", r.ShmSize},
+	} {
+		if f.val < 0 {
+			return fmt.Errorf("%s: must not be negative", f.name)
+		}
+	}
+	seen := map[string]bool{}
+	for _, u := range r.Ulimits {
+		if !slices.Contains(ulimitNames, u.Name) {
+			return fmt.Errorf("ulimit %q: unknown name", u.Name)
+		}
+		if seen[u.Name] {
+			return fmt.Errorf("ulimit %q: specified more than once", u.Name)
+		}
+		seen[u.Name] = true
+		if u.Soft < 0 || u.Hard < 0 {
+			return fmt.Errorf("ulimit %q: must not be negative", u.Name)
+		}
+		if u.Soft > u.Hard {
+			return fmt.Errorf("ulimit %q: soft limit must not exceed the hard limit", u.Name)
+		}
+	}
+	return nil
+}
+
+// ValidateSpaceResources returns an error if the resource policy of a space
+// is invalid, including defaults that exceed the maximum.
+func ValidateSpaceResources(r *v1.SpaceResources) error {
+	if r == nil {
+		return nil
+	}
+	if err := ValidateResources(r.Default); err != nil {
+		return fmt.Errorf("default: %w", err)
+	}
+	if err := ValidateResources(r.Max); err != nil {
+		return fmt.Errorf("max: %w", err)
+	}
+	if r.Default != nil && r.Max != nil {
+		if err := checkMax(*r.Default, *r.Max); err != nil {
+			return fmt.Errorf("default: %w", err)
+		}
+	}
+	return nil
+}
+
+// EffectiveResources returns the limits of a sandbox that requests req
+// (which may be nil) in a space with the given policy (which may be nil).
+// Unset limits are taken from the default and then the maximum of the
+// space. ErrResourcesExceedMax is returned if a limit exceeds the maximum.
+func EffectiveResources(req *v1.SandboxResources, policy *v1.SpaceResources) (v1.SandboxResources, error) {
+	var out v1.SandboxResources
+	if req != nil {
+		out = *req
+		out.Ulimits = slices.Clone(req.Ulimits)
+	}
+	var max v1.SandboxResources
+	if policy != nil {
+		if policy.Default != nil {
+			fillUnset(&out, *policy.Default)
+		}
+		if policy.Max != nil {
+			max = *policy.Max
+			fillUnset(&out, max)
+		}
+	}
+	if err := checkMax(out, max); err != nil {
+		return v1.SandboxResources{}, err
+	}
+	return out, nil
+}
+
+// fillUnset sets the limits that are unset in r to the ones in from.
+func fillUnset(r *v1.SandboxResources, from v1.SandboxResources) {
+	if r.CPUs == 0 {
+		r.CPUs = from.CPUs
+	}
+	for _, f := range []struct{ dst, src *int64 }{
+		{&r.Memory, &from.Memory},
+		{&r.Swap, &from.Swap},
+		{&r.PIDs, &from.PIDs},
+		{&r.ShmSize, &from.ShmSize},
+	} {
+		if *f.dst == 0 {
+			*f.dst = *f.src
+		}
+	}
+	for _, u := range from.Ulimits {
+		if !slices.ContainsFunc(r.Ulimits, func(o v1.Ulimit) bool { return o.Name == u.Name }) {
+			r.Ulimits = append(r.Ulimits, u)
+		}
+	}
+}
+
+// checkMax returns an error wrapping ErrResourcesExceedMax if any limit in
+// r exceeds the one in max. Unset limits in max are unlimited.
+func checkMax(r, max v1.SandboxResources) error {
+	if max.CPUs > 0 && r.CPUs > max.CPUs {
+		return fmt.Errorf("%w: cpus %g is more than %g", ErrResourcesExceedMax, r.CPUs, max.CPUs)
+	}
+	for _, f := range []struct {
+		name     string
+		val, max int64
+	}{
+		{"memory", r.Memory, max.Memory},
+		{"swap", r.Swap, max.Swap},
+		{"pids", r.PIDs, max.PIDs},
+		{"shm_size", r.ShmSize, max.ShmSize},
+	} {
+		if f.max > 0 && f.val > f.max {
+			return fmt.Errorf("%w: %s %d is more than %d", ErrResourcesExceedMax, f.name, f.val, f.max)
+		}
+	}
+	for _, m := range max.Ulimits {
+		for _, u := range r.Ulimits {
+			if u.Name == m.Name && u.Hard > m.Hard {
+				return fmt.Errorf("%w: ulimit %q hard limit %d is more than %d", ErrResourcesExceedMax, u.Name, u.Hard, m.Hard)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	v1 "github.com/substratusai/sandboxai/go/api/v1"
+)
+
+func TestValidateResources(t *testing.T) {
+	require.NoError(t, ValidateResources(nil))
+	require.NoError(t, ValidateResources(&v1.SandboxResources{
+		CPUs:    0.5,
+		Memory:  1 << 30,
+		Ulimits: []v1.Ulimit{{Name: "nofile", Soft: 1024, Hard: 4096}},
+	}))
+	require.Error(t, ValidateResources(&v1.SandboxResources{CPUs: -1}))
+	require.Error(t, ValidateResources(&v1.SandboxResources{PIDs: -1}))
+	require.Error(t, ValidateResources(&v1.SandboxResources{Ulimits: []v1.Ulimit{{Name: "files", Soft: 1, Hard: 1}}}), "unknown name")
+	require.Error(t, ValidateResources(&v1.SandboxResources{Ulimits: []v1.Ulimit{{Name: "nofile", Soft: 2, Hard: 1}}}), "soft above hard")
+	require.Error(t, ValidateResources(&v1.SandboxResources{Ulimits: []v1.Ulimit{
+		{Name: "nofile", Soft: 1, Hard: 1},
+		{Name: "nofile", Soft: 2, Hard: 2},
+	}}), "duplicate")
+
+	require.NoError(t, ValidateSpaceResources(&v1.SpaceResources{
+		Default: &v1.SandboxResources{Memory: 1 << 30},
+		Max:     &v1.SandboxResources{Memory: 2 << 30},
+	}))
+	require.ErrorIs(t, ValidateSpaceResources(&v1.SpaceResources{
+		Default: &v1.SandboxResources{Memory: 4 << 30},
+		Max:     &v1.SandboxResources{Memory: 2 << 30},
+	}), ErrResourcesExceedMax)
+}
+
+func TestEffectiveResources(t *testing.T) {
+	policy := &v1.SpaceResources{
+		Default: &v1.SandboxResources{
+			CPUs:    1,
+			Memory:  1 << 30,
+			Ulimits: []v1.Ulimit{{Name: "nofile", Soft: 1024, Hard: 1024}},
+		},
+		Max: &v1.SandboxResources{
+			CPUs:    4,
+			Memory:  8 << 30,
+			PIDs:    512,
+			Ulimits: []v1.Ulimit{{Name: "nofile", Soft: 65536, Hard: 65536}},
+		},
+	}
+
+	cases := []struct {
+		name   string
+		req    *v1.SandboxResources
+		policy *v1.SpaceResources
+		exp    v1.SandboxResources
+		expErr error
+	}{
+		{
+			name: "no policy",
+			req:  &v1.SandboxResources{Memory: 1 << 20},
+			exp:  v1.SandboxResources{Memory: 1 << 20},
+		},
+		{
+			name:   "defaults then max",
+			policy: policy,
+			exp: v1.SandboxResources{
+				CPUs:    1,
+				Memory:  1 << 30,
+				PIDs:    512,
+				Ulimits: []v1.Ulimit{{Name: "nofile", Soft: 1024, Hard: 1024}},
+			},
+		},
+		{
+			name: "requested",
+			req: &v1.SandboxResources{
+				CPUs:    2,
+				PIDs:    100,
+				Ulimits: []v1.Ulimit{{Name: "nofile", Soft: 4096, Hard: 8192}},
+			},
+			policy: policy,
+			exp: v1.SandboxResources{
+				CPUs:    2,
+				Memory:  1 << 30,
+				PIDs:    100,
+				Ulimits: []v1.Ulimit{{Name: "nofile", Soft: 4096, Hard: 8192}},
+			},
+		},
+		{
+			name:   "cpus above max",
+			req:    &v1.SandboxResources{CPUs: 8},
+			policy: policy,
+			expErr: ErrResourcesExceedMax,
+		},
+		{
+			name:   "ulimit above max",
+			req:    &v1.SandboxResources{Ulimits: []v1.Ulimit{{Name: "nofile", Soft: 1, Hard: 1 << 20}}},
+			policy: policy,
+			expErr: ErrResourcesExceedMax,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := EffectiveResources(c.req, c.policy)
+			if c.expErr != nil {
+				require.ErrorIs(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.exp, got)
+		})
+	}
+}
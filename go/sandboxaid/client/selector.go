@@ -0,0 +1,98 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Operator is a comparison used in a label selector requirement.
+type Operator string
+
+const (
+	OpEquals       Operator = "="
+	OpNotEquals    Operator = "!="
+	OpExists       Operator = "exists"
+	OpDoesNotExist Operator = "!"
+)
+
+// Requirement is a single term of a label selector.
+type Requirement struct {
+	Key      string
+	Operator Operator
+	Value    string
+}
+
+// Selector is a set of requirements that must all match.
+// A nil/empty Selector matches everything.
+type Selector []Requirement
+
+// ParseSelector parses a comma-separated list of requirements.
+// Supported forms:
+//
+// - "key=value" (or "key==value")
+// - "key!=value"
+// - "key" (key exists)
+// - "!key" (key does not exist)
+func ParseSelector(s string) (Selector, error) {
+	var sel Selector
+	for _, term := range strings.Split(s, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+
+		var req Requirement
+		switch {
+		case strings.Contains(term, "!="):
+			key, val, _ := strings.Cut(term, "!=")
+			req = Requirement{Key: key, Operator: OpNotEquals, Value: val}
+		case strings.Contains(term, "=="):
+			key, val, _ := strings.Cut(term, "==")
+			req = Requirement{Key: key, Operator: OpEquals, Value: val}
+		case strings.Contains(term, "="):
+			key, val, _ := strings.Cut(term, "=")
+			req = Requirement{Key: key, Operator: OpEquals, Value: val}
+		case strings.HasPrefix(term, "!"):
+			req = Requirement{Key: strings.TrimPrefix(term, "!"), Operator: OpDoesNotExist}
+		default:
+			req = Requirement{Key: term, Operator: OpExists}
+		}
+
+		req.Key = strings.TrimSpace(req.Key)
+		req.Value = strings.TrimSpace(req.Value)
+		if err := validateLabelKey(req.Key); err != nil {
+			return nil, fmt.Errorf("invalid requirement %q: %w", term, err)
+		}
+		if strings.ContainsAny(req.Value, "=!") {
+			return nil, fmt.Errorf("invalid requirement %q: value contains an operator", term)
+		}
+		sel = append(sel, req)
+	}
+	return sel, nil
+}
+
+// Matches returns true if the labels satisfy every requirement.
+func (s Selector) Matches(labels map[string]string) bool {
+	for _, req := range s {
+		val, ok := labels[req.Key]
+		switch req.Operator {
+		case OpEquals:
+			if !ok || val != req.Value {
+				return false
+			}
+		case OpNotEquals:
+			if ok && val == req.Value {
+				return false
+			}
+		case OpExists:
+			if !ok {
+				return false
+			}
+		case OpDoesNotExist:
+			if ok {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,95 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSelector(t *testing.T) {
+	cases := []struct {
+		input  string
+		exp    Selector
+		expErr bool
+	}{
+		{
+			input: "",
+			exp:   nil,
+		},
+		{
+			input: "a=b",
+			exp:   Selector{{Key: "a", Operator: OpEquals, Value: "b"}},
+		},
+		{
+			input: "a==b, c!=d",
+			exp: Selector{
+				{Key: "a", Operator: OpEquals, Value: "b"},
+				{Key: "c", Operator: OpNotEquals, Value: "d"},
+			},
+		},
+		{
+			input: "a,!b",
+			exp: Selector{
+				{Key: "a", Operator: OpExists},
+				{Key: "b", Operator: OpDoesNotExist},
+			},
+		},
+		{
+			input: "a=",
+			exp:   Selector{{Key: "a", Operator: OpEquals, Value: ""}},
+		},
+		{
+			input:  "=b",
+			expErr: true,
+		},
+		{
+			input:  "a=b=c",
+			expErr: true,
+		},
+		{
+			input:  "a b",
+			expErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.input, func(t *testing.T) {
+			sel, err := ParseSelector(c.input)
+			if c.expErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.exp, sel)
+		})
+	}
+}
+
+func TestSelectorMatches(t *testing.T) {
+	labels := map[string]string{"team": "ml", "env": "dev"}
+
+	cases := []struct {
+		selector string
+		exp      bool
+	}{
+		{selector: "", exp: true},
+		{selector: "team=ml", exp: true},
+		{selector: "team=ml,env=dev", exp: true},
+		{selector: "team=ml,env=prod", exp: false},
+		{selector: "env!=prod", exp: true},
+		{selector: "env!=dev", exp: false},
+		{selector: "owner!=me", exp: true},
+		{selector: "team", exp: true},
+		{selector: "owner", exp: false},
+		{selector: "!owner", exp: true},
+		{selector: "!team", exp: false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.selector, func(t *testing.T) {
+			sel, err := ParseSelector(c.selector)
+			require.NoError(t, err)
+			require.Equal(t, c.exp, sel.Matches(labels))
+		})
+	}
+}
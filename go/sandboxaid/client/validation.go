@@ -0,0 +1,187 @@
+package client
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	v1 "github.com/substratusai/sandboxai/go/api/v1"
+	"github.com/substratusai/sandboxai/go/sandboxaid/egress"
+)
+
+var nameRegexp = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+const maxNameLength = 63
+
+// validateName returns an error if the name is not a valid name for an
+// object of the kind (space, volume or snapshot). Names are used as a part
+// of Docker object names and file names so they are restricted to lower case
+// alphanumeric characters and '-'.
+func validateName(kind, name string) error {
+	if len(name) > maxNameLength {
+		return fmt.Errorf("%s name %q: must be no more than %d characters", kind, name, maxNameLength)
+	}
+	if !nameRegexp.MatchString(name) {
+		return fmt.Errorf("%s name %q: must consist of lower case alphanumeric characters or '-', and must start and end with an alphanumeric character", kind, name)
+	}
+	return nil
+}
+
+// ValidateSpaceName returns an error if the name is not a valid space name.
+func ValidateSpaceName(name string) error {
+	return validateName("space", name)
+}
+
+// ValidateVolumeName returns an error if the name is not a valid volume name.
+func ValidateVolumeName(name string) error {
+	return validateName("volume", name)
+}
+
+// ValidateSnapshotName returns an error if the name is not a valid snapshot
+// name.
+func ValidateSnapshotName(name string) error {
+	return validateName("snapshot", name)
+}
+
+// ValidateMounts returns an error if any mount is invalid or if two mounts
+// have the same target.
+func ValidateMounts(mounts []v1.Mount) error {
+	targets := make(map[string]bool)
+	for i, m := range mounts {
+		switch m.Type {
+		case v1.MountTypeVolume:
+			if err := ValidateVolumeName(m.Source); err != nil {
+				return fmt.Errorf("mount %d: source: %w", i, err)
+			}
+		case v1.MountTypeBind:
+			if err := ValidatePath(m.Source); err != nil {
+				return fmt.Errorf("mount %d: source: %w", i, err)
+			}
+		default:
+			return fmt.Errorf("mount %d: type: unsupported value %q", i, m.Type)
+		}
+		if err := ValidatePath(m.Target); err != nil {
+			return fmt.Errorf("mount %d: target: %w", i, err)
+		}
+		if m.Target == "/" {
+			return fmt.Errorf("mount %d: target: cannot be /", i)
+		}
+		if targets[m.Target] {
+			return fmt.Errorf("mount %d: target: %q is already mounted", i, m.Target)
+		}
+		targets[m.Target] = true
+	}
+	return nil
+}
+
+// ValidateLabels returns an error if any label key is not valid.
+func ValidateLabels(labels map[string]string) error {
+	for k := range labels {
+		if err := validateLabelKey(k); err != nil {
+			return fmt.Errorf("label %q: %w", k, err)
+		}
+	}
+	return nil
+}
+
+func validateLabelKey(key string) error {
+	if key == "" {
+		return fmt.Errorf("key cannot be empty")
+	}
+	if strings.ContainsAny(key, "=!, \t\n") {
+		return fmt.Errorf("key cannot contain '=', '!', ',' or whitespace")
+	}
+	return nil
+}
+
+// ValidateNetwork returns an error if the network configuration of a
+// sandbox is invalid. A nil value is valid.
+func ValidateNetwork(n *v1.SandboxNetwork) error {
+	if n == nil {
+		return nil
+	}
+	switch n.Mode {
+	case "", v1.NetworkModeNone, v1.NetworkModeInternal, v1.NetworkModeBridge:
+	default:
+		return fmt.Errorf("mode: unsupported value %q", n.Mode)
+	}
+	if _, err := egress.ParseAllowlist(n.EgressAllow); err != nil {
+		return fmt.Errorf("egress_allow: %w", err)
+	}
+	// Bridged sandboxes could ignore the proxy and connect directly, and
+	// sandboxes without a network cannot reach it.
+	switch {
+	case n.Mode == v1.NetworkModeBridge && len(n.EgressAllow) > 0:
+		return fmt.Errorf("egress_allow: cannot be used with mode %q, the allowlist is only enforced on internal networks", n.Mode)
+	case n.Mode == v1.NetworkModeBridge && n.Cassette != nil:
+		return fmt.Errorf("cassette: cannot be used with mode %q, replaying is only hermetic on internal networks", n.Mode)
+	case n.Mode == v1.NetworkModeNone && len(n.EgressAllow) > 0:
+		return fmt.Errorf("egress_allow: cannot be used with mode %q, the sandbox cannot reach the egress proxy", n.Mode)
+	case n.Mode == v1.NetworkModeNone && n.Cassette != nil:
+		return fmt.Errorf("cassette: cannot be used with mode %q, the sandbox cannot reach the egress proxy", n.Mode)
+	}
+	if n.Cassette != nil {
+		if err := egress.ValidateCassetteName(n.Cassette.Name); err != nil {
+			return fmt.Errorf("cassette: %w", err)
+		}
+		switch n.Cassette.Mode {
+		case "", v1.CassetteModeAuto, v1.CassetteModeRecord, v1.CassetteModeReplay:
+		default:
+			return fmt.Errorf("cassette: mode: unsupported value %q", n.Cassette.Mode)
+		}
+	}
+	return nil
+}
+
+// ValidateLifetime returns an error if the ttl or idle_timeout of a sandbox
+// is set and is not a positive duration, or if the idle_policy is invalid.
+func ValidateLifetime(spec v1.SandboxSpec) error {
+	if _, err := ParseLifetime(spec.TTL); err != nil {
+		return fmt.Errorf("ttl: %w", err)
+	}
+	if _, err := ParseLifetime(spec.IdleTimeout); err != nil {
+		return fmt.Errorf("idle_timeout: %w", err)
+	}
+	switch spec.IdlePolicy {
+	case "", v1.IdlePolicyDelete, v1.IdlePolicyStop:
+	default:
+		return fmt.Errorf("idle_policy: unsupported value %q", spec.IdlePolicy)
+	}
+	if spec.IdlePolicy != "" && spec.IdleTimeout == "" {
+		return fmt.Errorf("idle_policy: requires an idle_timeout")
+	}
+	return nil
+}
+
+// ParseLifetime parses a ttl or idle_timeout. An empty value is parsed as 0
+// (no limit).
+func ParseLifetime(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d < time.Second {
+		return 0, fmt.Errorf("must be at least 1s, got %q", s)
+	}
+	return d, nil
+}
+
+// ValidatePath returns an error if p is not an absolute, clean path
+// (i.e. it must not contain "." or ".." elements or a trailing slash).
+func ValidatePath(p string) error {
+	if !path.IsAbs(p) {
+		return fmt.Errorf("path %q: must be absolute", p)
+	}
+	if path.Clean(p) != p {
+		return fmt.Errorf("path %q: must be clean (expected %q)", p, path.Clean(p))
+	}
+	if strings.ContainsRune(p, 0) {
+		return fmt.Errorf("path %q: cannot contain NUL", p)
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package client
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	v1 "github.com/substratusai/sandboxai/go/api/v1"
+)
+
+func TestValidateSpaceName(t *testing.T) {
+	cases := []struct {
+		name   string
+		expErr bool
+	}{
+		{name: "default"},
+		{name: "team-1"},
+		{name: "a"},
+		{name: "", expErr: true},
+		{name: "Team", expErr: true},
+		{name: "team.a", expErr: true},
+		{name: "-team", expErr: true},
+		{name: "team-", expErr: true},
+		{name: "team_a", expErr: true},
+		{name: strings.Repeat("a", 64), expErr: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := ValidateSpaceName(c.name)
+			if c.expErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateNameKinds(t *testing.T) {
+	require.ErrorContains(t, ValidateSpaceName("Data"), `space name "Data"`)
+	require.ErrorContains(t, ValidateVolumeName("Data"), `volume name "Data"`)
+	require.ErrorContains(t, ValidateSnapshotName("Data"), `snapshot name "Data"`)
+	require.NoError(t, ValidateSnapshotName("data-2025-01-01"))
+}
+
+func TestValidatePath(t *testing.T) {
+	cases := []struct {
+		path   string
+		expErr bool
+	}{
+		{path: "/"},
+		{path: "/work/main.py"},
+		{path: "/work/dir name"},
+		{path: "", expErr: true},
+		{path: "work/main.py", expErr: true},
+		{path: "/work/", expErr: true},
+		{path: "/work/../etc/passwd", expErr: true},
+		{path: "/work/./main.py", expErr: true},
+		{path: "//work", expErr: true},
+		{path: "/work/a\x00b", expErr: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.path, func(t *testing.T) {
+			err := ValidatePath(c.path)
+			if c.expErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateNetwork(t *testing.T) {
+	require.NoError(t, ValidateNetwork(nil))
+	require.NoError(t, ValidateNetwork(&v1.SandboxNetwork{}))
+	require.NoError(t, ValidateNetwork(&v1.SandboxNetwork{Mode: v1.NetworkModeInternal}))
+	require.Error(t, ValidateNetwork(&v1.SandboxNetwork{Mode: "host"}))
+	require.NoError(t, ValidateNetwork(&v1.SandboxNetwork{EgressAllow: []string{"pypi.org", "10.0.0.0/8"}}))
+	require.Error(t, ValidateNetwork(&v1.SandboxNetwork{EgressAllow: []string{"https://pypi.org"}}))
+	require.Error(t, ValidateNetwork(&v1.SandboxNetwork{Mode: v1.NetworkModeNone, EgressAllow: []string{"pypi.org"}}))
+	require.Error(t, ValidateNetwork(&v1.SandboxNetwork{Mode: v1.NetworkModeBridge, EgressAllow: []string{"pypi.org"}}))
+	require.NoError(t, ValidateNetwork(&v1.SandboxNetwork{Cassette: &v1.SandboxCassette{Name: "evals"}}))
+	require.NoError(t, ValidateNetwork(&v1.SandboxNetwork{Cassette: &v1.SandboxCassette{Name: "evals", Mode: v1.CassetteModeReplay}}))
+	require.Error(t, ValidateNetwork(&v1.SandboxNetwork{Cassette: &v1.SandboxCassette{Name: "../evals"}}))
+	require.Error(t, ValidateNetwork(&v1.SandboxNetwork{Cassette: &v1.SandboxCassette{Name: "evals", Mode: "rewind"}}))
+	require.Error(t, ValidateNetwork(&v1.SandboxNetwork{Mode: v1.NetworkModeBridge, Cassette: &v1.SandboxCassette{Name: "evals"}}))
+	require.Error(t, ValidateNetwork(&v1.SandboxNetwork{Mode: v1.NetworkModeNone, Cassette: &v1.SandboxCassette{Name: "evals"}}))
+}
+
+func TestValidateMounts(t *testing.T) {
+	require.NoError(t, ValidateMounts(nil))
+	require.NoError(t, ValidateMounts([]v1.Mount{
+		{Type: v1.MountTypeVolume, Source: "data", Target: "/data"},
+		{Type: v1.MountTypeBind, Source: "/srv/models", Target: "/models", ReadOnly: true},
+	}))
+	require.Error(t, ValidateMounts([]v1.Mount{{Type: "tmpfs", Source: "x", Target: "/x"}}))
+	require.Error(t, ValidateMounts([]v1.Mount{{Type: v1.MountTypeVolume, Source: "Data", Target: "/data"}}))
+	require.Error(t, ValidateMounts([]v1.Mount{{Type: v1.MountTypeBind, Source: "srv", Target: "/data"}}))
+	require.Error(t, ValidateMounts([]v1.Mount{{Type: v1.MountTypeVolume, Source: "data", Target: "data"}}))
+	require.Error(t, ValidateMounts([]v1.Mount{{Type: v1.MountTypeVolume, Source: "data", Target: "/"}}))
+	require.Error(t, ValidateMounts([]v1.Mount{
+		{Type: v1.MountTypeVolume, Source: "a", Target: "/data"},
+		{Type: v1.MountTypeVolume, Source: "b", Target: "/data"},
+	}))
+}
+
+func TestValidateLifetime(t *testing.T) {
+	require.NoError(t, ValidateLifetime(v1.SandboxSpec{}))
+	require.NoError(t, ValidateLifetime(v1.SandboxSpec{TTL: "1h", IdleTimeout: "10m30s"}))
+	require.Error(t, ValidateLifetime(v1.SandboxSpec{TTL: "1"}))
+	require.Error(t, ValidateLifetime(v1.SandboxSpec{TTL: "-1h"}))
+	require.Error(t, ValidateLifetime(v1.SandboxSpec{IdleTimeout: "100ms"}))
+	require.NoError(t, ValidateLifetime(v1.SandboxSpec{IdleTimeout: "1h", IdlePolicy: v1.IdlePolicyStop}))
+	require.Error(t, ValidateLifetime(v1.SandboxSpec{IdleTimeout: "1h", IdlePolicy: "pause"}))
+	require.Error(t, ValidateLifetime(v1.SandboxSpec{IdlePolicy: v1.IdlePolicyStop}))
+}
@@ -0,0 +1,98 @@
+package egress
+
+import (
+	"fmt"
+	"net/netip"
+	"regexp"
+	"strings"
+)
+
+// Allowlist is a parsed list of the destinations that a sandbox can connect to.
+type Allowlist struct {
+	domains []string
+	// suffixes match subdomains, for example ".example.com" from "*.example.com".
+	suffixes []string
+	prefixes []netip.Prefix
+	// anyPublic allows every host and public address. It is used while
+	// recording a cassette without an egress_allow list.
+	anyPublic bool
+}
+
+var domainRegexp = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`)
+
+// ParseAllowlist parses entries that are domains ("pypi.org"), wildcards
+// that match subdomains ("*.pythonhosted.org"), IP addresses, or CIDR ranges.
+func ParseAllowlist(entries []string) (*Allowlist, error) {
+	a := &Allowlist{}
+	for _, entry := range entries {
+		if strings.Contains(entry, "/") {
+			prefix, err := netip.ParsePrefix(entry)
+			if err != nil {
+				return nil, fmt.Errorf("%q: invalid CIDR range", entry)
+			}
+			a.prefixes = append(a.prefixes, prefix.Masked())
+			continue
+		}
+		if addr, err := netip.ParseAddr(entry); err == nil {
+			addr = addr.Unmap()
+			a.prefixes = append(a.prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		domain := strings.TrimSuffix(strings.ToLower(entry), ".")
+		if parent, ok := strings.CutPrefix(domain, "*."); ok {
+			if !domainRegexp.MatchString(parent) {
+				return nil, fmt.Errorf("%q: invalid wildcard domain", entry)
+			}
+			a.suffixes = append(a.suffixes, "."+parent)
+			continue
+		}
+		if !domainRegexp.MatchString(domain) {
+			return nil, fmt.Errorf("%q: must be a domain, IP address or CIDR range", entry)
+		}
+		a.domains = append(a.domains, domain)
+	}
+	return a, nil
+}
+
+// AllowsHost reports if the host name matches a domain or wildcard.
+func (a *Allowlist) AllowsHost(host string) bool {
+	if a.anyPublic {
+		return true
+	}
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	for _, d := range a.domains {
+		if host == d {
+			return true
+		}
+	}
+	for _, s := range a.suffixes {
+		if strings.HasSuffix(host, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsAddr reports if the address is in an allowed range.
+func (a *Allowlist) AllowsAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, p := range a.prefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasAddrs reports if any addresses are allowed.
+func (a *Allowlist) hasAddrs() bool {
+	return len(a.prefixes) > 0
+}
+
+// isPublic reports if the address is routed on the internet. Connections to
+// other addresses (including the host that sandboxaid runs on) must be
+// allowed by address rather than by domain, so that allowing a domain does
+// not allow whatever it resolves to.
+func isPublic(addr netip.Addr) bool {
+	return addr.IsGlobalUnicast() && !addr.IsPrivate()
+}
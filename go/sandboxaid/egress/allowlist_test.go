@@ -0,0 +1,38 @@
+package egress
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAllowlist(t *testing.T) {
+	a, err := ParseAllowlist([]string{"PyPI.org", "*.pythonhosted.org", "10.0.0.0/8", "192.168.1.10", "2001:db8::/32"})
+	require.NoError(t, err)
+
+	require.True(t, a.AllowsHost("pypi.org"))
+	require.True(t, a.AllowsHost("pypi.org."))
+	require.False(t, a.AllowsHost("test.pypi.org"), "domains do not match subdomains")
+	require.True(t, a.AllowsHost("files.pythonhosted.org"))
+	require.False(t, a.AllowsHost("pythonhosted.org"), "wildcards only match subdomains")
+	require.False(t, a.AllowsHost("evilpythonhosted.org"))
+
+	require.True(t, a.AllowsAddr(netip.MustParseAddr("10.1.2.3")))
+	require.True(t, a.AllowsAddr(netip.MustParseAddr("::ffff:10.1.2.3")))
+	require.True(t, a.AllowsAddr(netip.MustParseAddr("192.168.1.10")))
+	require.False(t, a.AllowsAddr(netip.MustParseAddr("192.168.1.11")))
+	require.True(t, a.AllowsAddr(netip.MustParseAddr("2001:db8::1")))
+
+	for _, invalid := range []string{"", "10.0.0.0/33", "exa mple.com", "*.", "http://pypi.org"} {
+		_, err := ParseAllowlist([]string{invalid})
+		require.Error(t, err, invalid)
+	}
+}
+
+func Test_isPublic(t *testing.T) {
+	require.True(t, isPublic(netip.MustParseAddr("203.0.113.10")))
+	for _, addr := range []string{"127.0.0.1", "10.0.0.1", "172.17.0.1", "169.254.169.254", "0.0.0.0", "::1", "fd00::1"} {
+		require.False(t, isPublic(netip.MustParseAddr(addr)), addr)
+	}
+}
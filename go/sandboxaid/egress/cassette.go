@@ -0,0 +1,294 @@
+package egress
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"unicode/utf8"
+
+	v1 "github.com/substratusai/sandboxai/go/api/v1"
+)
+
+// ErrCassetteNotFound is returned when replaying a cassette that has not
+// been recorded.
+var ErrCassetteNotFound = errors.New("cassette not found")
+
+// errNoMatch is returned when replaying a request that was not recorded.
+var errNoMatch = errors.New("no matching request in cassette")
+
+var cassetteNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9]([-_.a-zA-Z0-9]*[a-zA-Z0-9])?$`)
+
+const maxCassetteNameLength = 128
+
+// maxCassetteBodySize is the maximum size of a request or response body
+// that can be recorded.
+const maxCassetteBodySize = 32 << 20
+
+// ValidateCassetteName returns an error if the name is not a valid cassette
+// name. Names are used as file names so they are restricted to
+// alphanumeric characters, '-', '_' and '.'.
+func ValidateCassetteName(name string) error {
+	if len(name) > maxCassetteNameLength {
+		return fmt.Errorf("cassette name %q: must be no more than %d characters", name, maxCassetteNameLength)
+	}
+	if !cassetteNameRegexp.MatchString(name) {
+		return fmt.Errorf("cassette name %q: must consist of alphanumeric characters, '-', '_' or '.', and must start and end with an alphanumeric character", name)
+	}
+	return nil
+}
+
+// Cassettes stores the cassettes that sandboxes record and replay, and the
+// certificate authority that their HTTPS requests are intercepted with.
+type Cassettes struct {
+	dir string
+	ca  *certAuthority
+
+	// mtx serializes writes to cassettes and guards replays.
+	mtx sync.Mutex
+	// replays are the cassettes being replayed by sandbox ID.
+	replays map[string]*replay
+}
+
+// NewCassettes stores cassettes in dir (in a directory per space) and
+// loads the certificate authority from caDir, creating it if needed.
+func NewCassettes(dir, caDir string) (*Cassettes, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating cassette directory: %w", err)
+	}
+	ca, err := loadOrCreateCA(caDir)
+	if err != nil {
+		return nil, err
+	}
+	return &Cassettes{
+		dir:     dir,
+		ca:      ca,
+		replays: make(map[string]*replay),
+	}, nil
+}
+
+// CACert returns the PEM encoded certificate that sandboxes must trust.
+func (c *Cassettes) CACert() []byte {
+	return c.ca.certPEM
+}
+
+// Prepare readies a cassette for a new sandbox and returns whether it will
+// be recorded or replayed. Recording starts from an empty cassette.
+// ErrCassetteNotFound is returned if the cassette must be replayed but does
+// not exist.
+func (c *Cassettes) Prepare(space string, cassette v1.SandboxCassette) (v1.SandboxCassetteMode, error) {
+	if err := ValidateCassetteName(cassette.Name); err != nil {
+		return "", err
+	}
+	path := c.path(space, cassette.Name)
+	_, err := os.Stat(path)
+	exists := err == nil
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("checking cassette: %w", err)
+	}
+
+	mode := cassette.Mode
+	if mode == "" || mode == v1.CassetteModeAuto {
+		mode = v1.CassetteModeRecord
+		if exists {
+			mode = v1.CassetteModeReplay
+		}
+	}
+	switch mode {
+	case v1.CassetteModeReplay:
+		if !exists {
+			return "", fmt.Errorf("%q: %w", cassette.Name, ErrCassetteNotFound)
+		}
+	case v1.CassetteModeRecord:
+		c.mtx.Lock()
+		defer c.mtx.Unlock()
+		if err := resetCassette(path); err != nil {
+			return "", err
+		}
+	default:
+		return "", fmt.Errorf("unsupported cassette mode %q", mode)
+	}
+	return mode, nil
+}
+
+// Release forgets the replay state of a sandbox that was deleted.
+func (c *Cassettes) Release(id string) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	delete(c.replays, id)
+}
+
+func (c *Cassettes) path(space, name string) string {
+	return filepath.Join(c.dir, space, name+".jsonl")
+}
+
+// record appends an exchange to a cassette.
+func (c *Cassettes) record(space, name string, i interaction) error {
+	line, err := json.Marshal(i)
+	if err != nil {
+		return fmt.Errorf("marshalling interaction: %w", err)
+	}
+	line = append(line, '\n')
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	path := c.path(space, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("creating cassette directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("opening cassette: %w", err)
+	}
+	if _, err := f.Write(line); err != nil {
+		f.Close()
+		return fmt.Errorf("writing cassette: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("writing cassette: %w", err)
+	}
+	return nil
+}
+
+// replay returns the response to the first unused exchange in the cassette
+// that matches the request. Each sandbox replays the cassette from the start.
+func (c *Cassettes) replay(id, space, name string, req recordedRequest) (*recordedResponse, error) {
+	c.mtx.Lock()
+	rp, ok := c.replays[id]
+	if !ok {
+		interactions, err := readCassette(c.path(space, name))
+		if err != nil {
+			c.mtx.Unlock()
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil, fmt.Errorf("%q: %w", name, ErrCassetteNotFound)
+			}
+			return nil, err
+		}
+		rp = &replay{interactions: interactions, used: make([]bool, len(interactions))}
+		c.replays[id] = rp
+	}
+	c.mtx.Unlock()
+
+	rp.mtx.Lock()
+	defer rp.mtx.Unlock()
+	for i, in := range rp.interactions {
+		if rp.used[i] || !in.Request.matches(req) {
+			continue
+		}
+		rp.used[i] = true
+		return &in.Response, nil
+	}
+	return nil, fmt.Errorf("%s %s: %w %q", req.Method, req.URL, errNoMatch, name)
+}
+
+type replay struct {
+	mtx          sync.Mutex
+	interactions []interaction
+	used         []bool
+}
+
+// interaction is an exchange in a cassette. Cassettes are stored as JSON
+// lines with an interaction per line, so that recording only appends.
+type interaction struct {
+	Request  recordedRequest  `json:"request"`
+	Response recordedResponse `json:"response"`
+}
+
+type recordedRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   body        `json:"body,omitempty"`
+}
+
+// matches reports if the request is a replay of r. Headers are not
+// compared because they often contain timestamps or credentials.
+func (r *recordedRequest) matches(other recordedRequest) bool {
+	return r.Method == other.Method && r.URL == other.URL && bytes.Equal(r.Body, other.Body)
+}
+
+type recordedResponse struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Body   body        `json:"body,omitempty"`
+}
+
+// body is stored as a string if it is valid UTF-8 (so that cassettes can be
+// reviewed) and as {"base64": "..."} otherwise.
+type body []byte
+
+type base64Body struct {
+	Base64 string `json:"base64"`
+}
+
+func (b body) MarshalJSON() ([]byte, error) {
+	if utf8.Valid(b) {
+		return json.Marshal(string(b))
+	}
+	return json.Marshal(base64Body{Base64: base64.StdEncoding.EncodeToString(b)})
+}
+
+func (b *body) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*b = body(s)
+		return nil
+	}
+	var encoded base64Body
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return fmt.Errorf("body must be a string or {\"base64\": ...}: %w", err)
+	}
+	decoded, err := base64.StdEncoding.DecodeString(encoded.Base64)
+	if err != nil {
+		return fmt.Errorf("decoding base64 body: %w", err)
+	}
+	*b = decoded
+	return nil
+}
+
+func readCassette(path string) ([]interaction, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	interactions := []interaction{}
+	dec := json.NewDecoder(f)
+	for {
+		var i interaction
+		if err := dec.Decode(&i); err == io.EOF {
+			return interactions, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("parsing cassette %s: %w", path, err)
+		}
+		interactions = append(interactions, i)
+	}
+}
+
+// resetCassette replaces the cassette with an empty one atomically so that
+// it is never read half written.
+func resetCassette(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("creating cassette directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".cassette-*")
+	if err != nil {
+		return fmt.Errorf("creating cassette: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("creating cassette: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("creating cassette: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package egress
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_body(t *testing.T) {
+	cases := []struct {
+		body body
+		json string
+	}{
+		{body: body("hello"), json: `"hello"`},
+		{body: body{0xff, 0x00}, json: `{"base64":"/wA="}`},
+	}
+	for _, c := range cases {
+		data, err := json.Marshal(c.body)
+		require.NoError(t, err)
+		require.JSONEq(t, c.json, string(data))
+
+		var decoded body
+		require.NoError(t, json.Unmarshal(data, &decoded))
+		require.Equal(t, c.body, decoded)
+	}
+
+	var decoded body
+	require.Error(t, json.Unmarshal([]byte(`{"base64":"!"}`), &decoded))
+}
+
+func TestValidateCassetteName(t *testing.T) {
+	for _, name := range []string{"evals", "run-1.v2", "A_b"} {
+		require.NoError(t, ValidateCassetteName(name), name)
+	}
+	for _, name := range []string{"", "../x", "a/b", ".hidden", "x."} {
+		require.Error(t, ValidateCassetteName(name), name)
+	}
+}
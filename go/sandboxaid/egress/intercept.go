@@ -0,0 +1,252 @@
+package egress
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	v1 "github.com/substratusai/sandboxai/go/api/v1"
+)
+
+// hopHeaders only apply to a single connection so they are neither
+// forwarded nor recorded.
+var hopHeaders = []string{
+	"Connection",
+	"Proxy-Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// secretHeaders are forwarded but not recorded in cassettes.
+var secretHeaders = []string{
+	"Authorization",
+	"Cookie",
+}
+
+// intercept terminates the TLS connection inside a CONNECT tunnel with a
+// certificate of the cassette CA so that the HTTP requests in it can be
+// recorded or replayed. target is the address that is connected to while
+// recording.
+func (p *Proxy) intercept(w http.ResponseWriter, r *http.Request, pol *Policy, conn v1.EgressConnection, target string) {
+	p.record(pol.ID, conn)
+
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "hijacking not supported", http.StatusInternalServerError)
+		return
+	}
+	client, rw, err := hj.Hijack()
+	if err != nil {
+		log.Printf("Egress: hijacking connection: %v", err)
+		return
+	}
+	client.SetDeadline(time.Time{})
+	if _, err := client.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n")); err != nil {
+		client.Close()
+		return
+	}
+	// Anything that the client sent early is read before the connection.
+	var inner net.Conn = client
+	if n := rw.Reader.Buffered(); n > 0 {
+		buffered, _ := rw.Reader.Peek(n)
+		inner = &prefixConn{Conn: client, r: io.MultiReader(bytes.NewReader(bytes.Clone(buffered)), client)}
+	}
+	tlsConn := tls.Server(inner, &tls.Config{
+		GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			host := hello.ServerName
+			if host == "" {
+				host = conn.Host
+			}
+			return p.cassettes.ca.certificate(host)
+		},
+		NextProtos: []string{"http/1.1"},
+	})
+
+	authority := net.JoinHostPort(conn.Host, strconv.Itoa(conn.Port))
+	if conn.Port == 443 {
+		authority = strings.TrimSuffix(authority, ":443")
+	}
+	ln := newConnListener(tlsConn)
+	srv := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			req.URL.Scheme = "https"
+			req.URL.Host = authority
+			p.serveCassette(w, req, pol, conn, target)
+		}),
+		ReadHeaderTimeout: 30 * time.Second,
+		IdleTimeout:       2 * time.Minute,
+		ConnState: func(_ net.Conn, state http.ConnState) {
+			if state == http.StateClosed || state == http.StateHijacked {
+				ln.Close()
+			}
+		},
+	}
+	srv.Serve(ln)
+}
+
+// serveCassette answers a request from the cassette of the sandbox while
+// replaying, or forwards it to target and records the exchange.
+func (p *Proxy) serveCassette(w http.ResponseWriter, r *http.Request, pol *Policy, conn v1.EgressConnection, target string) {
+	name := pol.Cassette.Name
+	conn.Time = time.Now()
+	conn.Method = r.Method
+	conn.URL = r.URL.String()
+
+	reqBody, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxCassetteBodySize))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("reading request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	req := recordedRequest{
+		Method: r.Method,
+		URL:    conn.URL,
+		Header: cleanHeader(r.Header, hopHeaders, secretHeaders),
+		Body:   reqBody,
+	}
+
+	if pol.Cassette.Mode == v1.CassetteModeReplay {
+		resp, err := p.cassettes.replay(pol.ID, pol.Space, name, req)
+		if err != nil {
+			conn.Allowed = false
+			conn.Message = err.Error()
+			p.record(pol.ID, conn)
+			http.Error(w, fmt.Sprintf("replaying cassette: %v", err), http.StatusBadGateway)
+			return
+		}
+		p.record(pol.ID, conn)
+		writeRecordedResponse(w, resp)
+		return
+	}
+
+	out, err := http.NewRequestWithContext(r.Context(), r.Method, conn.URL, bytes.NewReader(reqBody))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+	out.Header = cleanHeader(r.Header, hopHeaders)
+	out.Host = r.Host
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return p.dialContext(ctx, network, target)
+		},
+		TLSClientConfig:   p.upstreamTLS,
+		DisableKeepAlives: true,
+		// Bodies are recorded and replayed as they were sent.
+		DisableCompression: true,
+	}
+	resp, err := transport.RoundTrip(out)
+	if err != nil {
+		conn.Message = err.Error()
+		p.record(pol.ID, conn)
+		http.Error(w, fmt.Sprintf("connecting to %s: %v", conn.Host, err), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxCassetteBodySize+1))
+	if err == nil && len(respBody) > maxCassetteBodySize {
+		err = fmt.Errorf("response body is larger than %d bytes", maxCassetteBodySize)
+	}
+	if err != nil {
+		conn.Message = fmt.Sprintf("reading response: %v", err)
+		p.record(pol.ID, conn)
+		http.Error(w, conn.Message, http.StatusBadGateway)
+		return
+	}
+
+	rec := recordedResponse{
+		Status: resp.StatusCode,
+		Header: cleanHeader(resp.Header, hopHeaders),
+		Body:   respBody,
+	}
+	if err := p.cassettes.record(pol.Space, name, interaction{Request: req, Response: rec}); err != nil {
+		log.Printf("Egress: sandbox %q: recording cassette %q: %v", pol.ID, name, err)
+		conn.Message = fmt.Sprintf("recording cassette: %v", err)
+		p.record(pol.ID, conn)
+		http.Error(w, conn.Message, http.StatusInternalServerError)
+		return
+	}
+	p.record(pol.ID, conn)
+	writeRecordedResponse(w, &rec)
+}
+
+func writeRecordedResponse(w http.ResponseWriter, resp *recordedResponse) {
+	for k, vs := range resp.Header {
+		w.Header()[k] = vs
+	}
+	if len(resp.Body) > 0 {
+		w.Header().Set("Content-Length", strconv.Itoa(len(resp.Body)))
+	}
+	w.WriteHeader(resp.Status)
+	w.Write(resp.Body)
+}
+
+// cleanHeader returns a copy of the header without the listed keys.
+func cleanHeader(h http.Header, remove ...[]string) http.Header {
+	h = h.Clone()
+	for _, keys := range remove {
+		for _, k := range keys {
+			h.Del(k)
+		}
+	}
+	if len(h) == 0 {
+		return nil
+	}
+	return h
+}
+
+// prefixConn is a connection that first returns data that was already read
+// from it.
+type prefixConn struct {
+	net.Conn
+	r io.Reader
+}
+
+func (c *prefixConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+// connListener is a net.Listener that accepts a single connection. Accept
+// returns net.ErrClosed after it is closed.
+type connListener struct {
+	conns     chan net.Conn
+	addr      net.Addr
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newConnListener(conn net.Conn) *connListener {
+	conns := make(chan net.Conn, 1)
+	conns <- conn
+	return &connListener{conns: conns, addr: conn.LocalAddr(), done: make(chan struct{})}
+}
+
+func (l *connListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *connListener) Close() error {
+	l.closeOnce.Do(func() { close(l.done) })
+	return nil
+}
+
+func (l *connListener) Addr() net.Addr {
+	return l.addr
+}
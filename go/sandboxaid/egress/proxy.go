@@ -0,0 +1,306 @@
+// Package egress implements the HTTP proxy that sandboxes connect to the
+// internet through. Every connection is checked against the egress_allow
+// list of the sandbox and recorded. Sandboxes with a cassette have their
+// HTTP exchanges recorded to it or replayed from it.
+package egress
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	stdlog "log"
+
+	v1 "github.com/substratusai/sandboxai/go/api/v1"
+	"github.com/substratusai/sandboxai/go/internal/tunnel"
+)
+
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+func SetLogger(logger Logger) {
+	log = logger
+}
+
+var log Logger = stdlog.New(os.Stderr, "", stdlog.LstdFlags)
+
+// ProxyUser is the user name in the proxy credentials of sandboxes. The
+// password identifies the sandbox.
+const ProxyUser = "sandbox"
+
+// ErrUnknownToken is returned by Store.LookupEgress for credentials that do
+// not belong to a sandbox.
+var ErrUnknownToken = errors.New("unknown proxy token")
+
+// Policy is the egress configuration of a sandbox.
+type Policy struct {
+	// ID identifies the sandbox when recording connections.
+	ID    string
+	Space string
+	Allow []string
+	// Cassette is set if the sandbox records or replays a cassette. Its
+	// mode is either record or replay.
+	Cassette *v1.SandboxCassette
+}
+
+// Store looks up sandboxes by their proxy credentials and records their
+// connections.
+type Store interface {
+	// LookupEgress returns the policy of the sandbox with the token.
+	LookupEgress(ctx context.Context, token string) (*Policy, error)
+	// RecordEgress records a connection made by the sandbox.
+	RecordEgress(id string, conn v1.EgressConnection)
+}
+
+// Proxy is an HTTP proxy that supports CONNECT tunnels (used for HTTPS) and
+// plain HTTP requests.
+type Proxy struct {
+	store     Store
+	cassettes *Cassettes
+
+	// lookupNetIP, dialContext and upstreamTLS can be replaced in tests.
+	lookupNetIP func(ctx context.Context, network, host string) ([]netip.Addr, error)
+	dialContext func(ctx context.Context, network, addr string) (net.Conn, error)
+	upstreamTLS *tls.Config
+}
+
+// NewProxy returns a proxy for the sandboxes in the store. Cassettes can be
+// nil if no sandboxes have a cassette.
+func NewProxy(store Store, cassettes *Cassettes) *Proxy {
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	return &Proxy{
+		store:       store,
+		cassettes:   cassettes,
+		lookupNetIP: net.DefaultResolver.LookupNetIP,
+		dialContext: dialer.DialContext,
+	}
+}
+
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	pol, allow, ok := p.authenticate(w, r)
+	if !ok {
+		return
+	}
+	id := pol.ID
+
+	conn := v1.EgressConnection{
+		Time:   time.Now(),
+		Method: r.Method,
+	}
+	var hostport string
+	if r.Method == http.MethodConnect {
+		hostport = r.Host
+	} else {
+		if !r.URL.IsAbs() || r.URL.Scheme != "http" {
+			http.Error(w, "only absolute http:// URLs can be proxied, use CONNECT for https", http.StatusBadRequest)
+			return
+		}
+		conn.URL = r.URL.String()
+		hostport = r.URL.Host
+		if r.URL.Port() == "" {
+			hostport = net.JoinHostPort(r.URL.Hostname(), "80")
+		}
+	}
+	host, portStr, err := net.SplitHostPort(hostport)
+	port, perr := strconv.Atoi(portStr)
+	if err != nil || perr != nil || port < 1 || port > 65535 {
+		http.Error(w, fmt.Sprintf("invalid host %q", hostport), http.StatusBadRequest)
+		return
+	}
+	conn.Host, conn.Port = host, port
+
+	if pol.Cassette != nil && pol.Cassette.Mode == v1.CassetteModeReplay {
+		// Nothing is connected to while replaying.
+		conn.Allowed = true
+		if r.Method == http.MethodConnect {
+			p.intercept(w, r, pol, conn, "")
+		} else {
+			p.serveCassette(w, r, pol, conn, "")
+		}
+		return
+	}
+
+	addr, err := p.resolve(r.Context(), allow, host)
+	if err != nil {
+		conn.Message = err.Error()
+		p.record(id, conn)
+		http.Error(w, fmt.Sprintf("egress to %s denied: %v", hostport, err), http.StatusForbidden)
+		return
+	}
+	conn.Allowed = true
+	conn.Address = addr.String()
+	target := netip.AddrPortFrom(addr, uint16(port)).String()
+
+	if pol.Cassette != nil {
+		if r.Method == http.MethodConnect {
+			p.intercept(w, r, pol, conn, target)
+		} else {
+			p.serveCassette(w, r, pol, conn, target)
+		}
+		return
+	}
+	if r.Method == http.MethodConnect {
+		p.tunnel(w, r, id, conn, target)
+		return
+	}
+	p.record(id, conn)
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.Out.Host = pr.In.Host
+		},
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return p.dialContext(ctx, network, target)
+			},
+			DisableKeepAlives: true,
+		},
+		FlushInterval: -1,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			if r.Context().Err() == nil {
+				http.Error(w, fmt.Sprintf("connecting to %s: %v", hostport, err), http.StatusBadGateway)
+			}
+		},
+	}
+	proxy.ServeHTTP(w, r)
+}
+
+// authenticate identifies the sandbox from the Proxy-Authorization header.
+// An error response has been sent if ok is false.
+func (p *Proxy) authenticate(w http.ResponseWriter, r *http.Request) (pol *Policy, allow *Allowlist, ok bool) {
+	user, token, ok := parseProxyAuthorization(r.Header.Get("Proxy-Authorization"))
+	if !ok || user != ProxyUser {
+		w.Header().Set("Proxy-Authenticate", `Basic realm="sandboxai"`)
+		http.Error(w, "proxy credentials required", http.StatusProxyAuthRequired)
+		return nil, nil, false
+	}
+	pol, err := p.store.LookupEgress(r.Context(), token)
+	if err != nil {
+		if errors.Is(err, ErrUnknownToken) {
+			w.Header().Set("Proxy-Authenticate", `Basic realm="sandboxai"`)
+			http.Error(w, "invalid proxy credentials", http.StatusProxyAuthRequired)
+			return nil, nil, false
+		}
+		log.Printf("Egress: looking up sandbox: %v", err)
+		http.Error(w, "looking up sandbox", http.StatusInternalServerError)
+		return nil, nil, false
+	}
+	if pol.Cassette != nil && p.cassettes == nil {
+		log.Printf("Egress: sandbox %q: cassettes are not supported", pol.ID)
+		http.Error(w, "cassettes are not supported", http.StatusInternalServerError)
+		return nil, nil, false
+	}
+	allow, err = ParseAllowlist(pol.Allow)
+	if err != nil {
+		log.Printf("Egress: sandbox %q: parsing egress_allow: %v", pol.ID, err)
+		http.Error(w, "invalid egress_allow", http.StatusInternalServerError)
+		return nil, nil, false
+	}
+	if pol.Cassette != nil && len(pol.Allow) == 0 {
+		allow.anyPublic = true
+	}
+	return pol, allow, true
+}
+
+func parseProxyAuthorization(header string) (user, password string, ok bool) {
+	const prefix = "Basic "
+	if len(header) < len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return "", "", false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(header[len(prefix):])
+	if err != nil {
+		return "", "", false
+	}
+	return strings.Cut(string(decoded), ":")
+}
+
+// resolve returns the address to connect to for the host, or an error
+// explaining why the host is not allowed.
+func (p *Proxy) resolve(ctx context.Context, allow *Allowlist, host string) (netip.Addr, error) {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if allow.AllowsAddr(addr) || (allow.anyPublic && isPublic(addr.Unmap())) {
+			return addr.Unmap(), nil
+		}
+		return netip.Addr{}, fmt.Errorf("address %s is not in egress_allow", host)
+	}
+
+	hostAllowed := allow.AllowsHost(host)
+	if !hostAllowed && !allow.hasAddrs() {
+		return netip.Addr{}, fmt.Errorf("host %s is not in egress_allow", host)
+	}
+	addrs, err := p.lookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("resolving %s: %w", host, err)
+	}
+	for _, addr := range addrs {
+		addr = addr.Unmap()
+		if allow.AllowsAddr(addr) || (hostAllowed && isPublic(addr)) {
+			return addr, nil
+		}
+	}
+	if hostAllowed {
+		return netip.Addr{}, fmt.Errorf("host %s does not resolve to a public address, its addresses must be in egress_allow", host)
+	}
+	return netip.Addr{}, fmt.Errorf("host %s is not in egress_allow", host)
+}
+
+// tunnel connects to the target and relays the hijacked connection to it.
+func (p *Proxy) tunnel(w http.ResponseWriter, r *http.Request, id string, conn v1.EgressConnection, target string) {
+	upstream, err := p.dialContext(r.Context(), "tcp", target)
+	if err != nil {
+		conn.Message = err.Error()
+		p.record(id, conn)
+		http.Error(w, fmt.Sprintf("connecting to %s: %v", r.Host, err), http.StatusBadGateway)
+		return
+	}
+	p.record(id, conn)
+
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		upstream.Close()
+		http.Error(w, "hijacking not supported", http.StatusInternalServerError)
+		return
+	}
+	client, rw, err := hj.Hijack()
+	if err != nil {
+		upstream.Close()
+		log.Printf("Egress: hijacking connection: %v", err)
+		return
+	}
+	// The connection is long-lived from here on.
+	client.SetDeadline(time.Time{})
+	if _, err := client.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n")); err != nil {
+		client.Close()
+		upstream.Close()
+		return
+	}
+	// Forward anything that the client sent early.
+	if n := rw.Reader.Buffered(); n > 0 {
+		buffered, _ := rw.Reader.Peek(n)
+		if _, err := upstream.Write(buffered); err != nil {
+			client.Close()
+			upstream.Close()
+			return
+		}
+	}
+	tunnel.Relay(tunnel.AsHalfCloser(client), tunnel.AsHalfCloser(upstream))
+}
+
+func (p *Proxy) record(id string, conn v1.EgressConnection) {
+	if conn.Allowed {
+		log.Printf("Egress allowed: sandbox %q: %s %s:%d", id, conn.Method, conn.Host, conn.Port)
+	} else {
+		log.Printf("Egress denied: sandbox %q: %s %s:%d: %s", id, conn.Method, conn.Host, conn.Port, conn.Message)
+	}
+	p.store.RecordEgress(id, conn)
+}
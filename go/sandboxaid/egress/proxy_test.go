@@ -0,0 +1,292 @@
+package egress
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	v1 "github.com/substratusai/sandboxai/go/api/v1"
+)
+
+type fakeStore struct {
+	id       string
+	allow    []string
+	cassette *v1.SandboxCassette
+
+	mtx   sync.Mutex
+	conns []v1.EgressConnection
+}
+
+func (s *fakeStore) LookupEgress(ctx context.Context, token string) (*Policy, error) {
+	if token != "secret" {
+		return nil, ErrUnknownToken
+	}
+	return &Policy{ID: s.id, Space: "default", Allow: s.allow, Cassette: s.cassette}, nil
+}
+
+func (s *fakeStore) RecordEgress(id string, conn v1.EgressConnection) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.conns = append(s.conns, conn)
+}
+
+func (s *fakeStore) last() v1.EgressConnection {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.conns[len(s.conns)-1]
+}
+
+// publicAddr is what the test hosts on the internet resolve to in
+// tests. It is dialed as the local stand-in.
+const publicAddr = "203.0.113.10"
+
+// newTestProxy starts the proxy with a resolver for the test hosts.
+// upstream.test resolves to a public address which is dialed as the local
+// stand-in, local.test resolves to loopback.
+func newTestProxy(t *testing.T, store Store, cassettes *Cassettes) (*Proxy, *httptest.Server) {
+	proxy := NewProxy(store, cassettes)
+	proxy.lookupNetIP = func(ctx context.Context, network, host string) ([]netip.Addr, error) {
+		switch host {
+		case "upstream.test", "api.example.test", "www.example.com":
+			return []netip.Addr{netip.MustParseAddr(publicAddr)}, nil
+		case "local.test":
+			return []netip.Addr{netip.MustParseAddr("127.0.0.1")}, nil
+		}
+		return nil, fmt.Errorf("no such host %q", host)
+	}
+	proxy.dialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		addr = strings.Replace(addr, publicAddr, "127.0.0.1", 1)
+		var d net.Dialer
+		return d.DialContext(ctx, network, addr)
+	}
+	proxySrv := httptest.NewServer(proxy)
+	t.Cleanup(proxySrv.Close)
+	return proxy, proxySrv
+}
+
+func newProxyClient(t *testing.T, proxySrv *httptest.Server, user *url.Userinfo, tlsConfig *tls.Config) *http.Client {
+	proxyURL, err := url.Parse(proxySrv.URL)
+	require.NoError(t, err)
+	proxyURL.User = user
+	return &http.Client{Transport: &http.Transport{
+		Proxy:           http.ProxyURL(proxyURL),
+		TLSClientConfig: tlsConfig,
+	}}
+}
+
+func port(srv *httptest.Server) string {
+	u, _ := url.Parse(srv.URL)
+	return u.Port()
+}
+
+func do(t *testing.T, c *http.Client, method, u, body string) (int, string) {
+	req, err := http.NewRequest(method, u, strings.NewReader(body))
+	require.NoError(t, err)
+	resp, err := c.Do(req)
+	if err != nil {
+		// CONNECT failures are returned as errors.
+		return 0, err.Error()
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(respBody)
+}
+
+func TestProxy(t *testing.T) {
+	// Stand-ins for upstream servers on the internet.
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Empty(t, r.Header.Get("Proxy-Authorization"), "credentials must not leak upstream")
+		fmt.Fprintf(w, "hello from %s%s", r.Host, r.URL.Path)
+	})
+	upstream := httptest.NewServer(handler)
+	defer upstream.Close()
+	upstreamTLS := httptest.NewTLSServer(handler)
+	defer upstreamTLS.Close()
+
+	store := &fakeStore{id: "box", allow: []string{"upstream.test", "local.test", "*.example.test"}}
+	_, proxySrv := newTestProxy(t, store, nil)
+	newClient := func(user *url.Userinfo) *http.Client {
+		return newProxyClient(t, proxySrv, user, &tls.Config{InsecureSkipVerify: true})
+	}
+	c := newClient(url.UserPassword(ProxyUser, "secret"))
+	get := func(c *http.Client, u string) (int, string) {
+		return do(t, c, http.MethodGet, u, "")
+	}
+
+	t.Run("credentials required", func(t *testing.T) {
+		status, _ := get(newClient(nil), "http://upstream.test:"+port(upstream)+"/")
+		require.Equal(t, http.StatusProxyAuthRequired, status)
+		status, _ = get(newClient(url.UserPassword(ProxyUser, "wrong")), "http://upstream.test:"+port(upstream)+"/")
+		require.Equal(t, http.StatusProxyAuthRequired, status)
+	})
+
+	t.Run("http allowed", func(t *testing.T) {
+		status, body := get(c, "http://upstream.test:"+port(upstream)+"/simple")
+		require.Equal(t, http.StatusOK, status)
+		require.Equal(t, "hello from upstream.test:"+port(upstream)+"/simple", body)
+		conn := store.last()
+		require.True(t, conn.Allowed)
+		require.Equal(t, http.MethodGet, conn.Method)
+		require.Equal(t, "upstream.test", conn.Host)
+		require.Equal(t, publicAddr, conn.Address)
+	})
+
+	t.Run("https allowed", func(t *testing.T) {
+		status, body := get(c, "https://api.example.test:"+port(upstreamTLS)+"/simple")
+		require.Equal(t, http.StatusOK, status, body)
+		require.Equal(t, "hello from api.example.test:"+port(upstreamTLS)+"/simple", body)
+		conn := store.last()
+		require.True(t, conn.Allowed)
+		require.Equal(t, http.MethodConnect, conn.Method)
+	})
+
+	t.Run("denied", func(t *testing.T) {
+		status, _ := get(c, "http://other.test/")
+		require.Equal(t, http.StatusForbidden, status)
+		conn := store.last()
+		require.False(t, conn.Allowed)
+		require.Equal(t, "other.test", conn.Host)
+		require.Contains(t, conn.Message, "not in egress_allow")
+
+		_, errMsg := get(c, "https://other.test/")
+		require.Contains(t, errMsg, "Forbidden")
+		require.Equal(t, http.MethodConnect, store.last().Method)
+	})
+
+	t.Run("domain resolving to loopback", func(t *testing.T) {
+		status, _ := get(c, "http://local.test:"+port(upstream)+"/")
+		require.Equal(t, http.StatusForbidden, status)
+		require.Contains(t, store.last().Message, "public address")
+	})
+
+	t.Run("address allowed", func(t *testing.T) {
+		status, _ := get(c, upstream.URL+"/")
+		require.Equal(t, http.StatusForbidden, status)
+
+		store.allow = append(store.allow, "127.0.0.0/8")
+		status, _ = get(c, upstream.URL+"/")
+		require.Equal(t, http.StatusOK, status)
+		status, _ = get(c, "http://local.test:"+port(upstream)+"/")
+		require.Equal(t, http.StatusOK, status)
+	})
+}
+
+func TestProxyCassette(t *testing.T) {
+	// Stand-ins for upstream servers on the internet.
+	var hits atomic.Int32
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Upstream", "yes")
+		fmt.Fprintf(w, "%s %s%s %s", r.Method, r.Host, r.URL.Path, body)
+	})
+	upstream := httptest.NewServer(handler)
+	defer upstream.Close()
+	upstreamTLS := httptest.NewTLSServer(handler)
+	defer upstreamTLS.Close()
+
+	cassetteDir := t.TempDir()
+	cassettes, err := NewCassettes(cassetteDir, t.TempDir())
+	require.NoError(t, err)
+
+	store := &fakeStore{id: "recorder"}
+	proxy, proxySrv := newTestProxy(t, store, cassettes)
+	upstreamRoots := x509.NewCertPool()
+	upstreamRoots.AddCert(upstreamTLS.Certificate())
+	proxy.upstreamTLS = &tls.Config{RootCAs: upstreamRoots}
+
+	// Sandboxes trust the CA of the cassettes.
+	roots := x509.NewCertPool()
+	require.True(t, roots.AppendCertsFromPEM(cassettes.CACert()))
+	c := newProxyClient(t, proxySrv, url.UserPassword(ProxyUser, "secret"), &tls.Config{RootCAs: roots})
+
+	httpURL := "http://upstream.test:" + port(upstream) + "/http"
+	// The certificate of the TLS stand-in is valid for *.example.com.
+	httpsURL := "https://www.example.com:" + port(upstreamTLS) + "/https"
+
+	t.Run("replay requires a recording", func(t *testing.T) {
+		_, err := cassettes.Prepare("default", v1.SandboxCassette{Name: "evals", Mode: v1.CassetteModeReplay})
+		require.ErrorIs(t, err, ErrCassetteNotFound)
+	})
+
+	t.Run("record", func(t *testing.T) {
+		mode, err := cassettes.Prepare("default", v1.SandboxCassette{Name: "evals"})
+		require.NoError(t, err)
+		require.Equal(t, v1.CassetteModeRecord, mode)
+		store.cassette = &v1.SandboxCassette{Name: "evals", Mode: mode}
+
+		status, body := do(t, c, http.MethodPost, httpURL, "one")
+		require.Equal(t, http.StatusOK, status, body)
+		require.Equal(t, "POST upstream.test:"+port(upstream)+"/http one", body)
+		status, body = do(t, c, http.MethodGet, httpsURL, "")
+		require.Equal(t, http.StatusOK, status, body)
+		require.Equal(t, "GET www.example.com:"+port(upstreamTLS)+"/https ", body)
+		status, body = do(t, c, http.MethodGet, httpsURL, "")
+		require.Equal(t, http.StatusOK, status, body)
+		require.Equal(t, int32(3), hits.Load())
+
+		conn := store.last()
+		require.True(t, conn.Allowed)
+		require.Equal(t, http.MethodGet, conn.Method)
+		require.Equal(t, httpsURL, conn.URL)
+
+		// Destinations must be public while recording without egress_allow.
+		status, _ = do(t, c, http.MethodGet, upstream.URL, "")
+		require.Equal(t, http.StatusForbidden, status)
+
+		data, err := os.ReadFile(filepath.Join(cassetteDir, "default", "evals.jsonl"))
+		require.NoError(t, err)
+		require.Equal(t, 3, strings.Count(string(data), "\n"), "an interaction per line")
+		require.NotContains(t, string(data), "Proxy-Authorization")
+		require.Contains(t, string(data), `"body":"one"`)
+	})
+
+	t.Run("replay", func(t *testing.T) {
+		mode, err := cassettes.Prepare("default", v1.SandboxCassette{Name: "evals"})
+		require.NoError(t, err)
+		require.Equal(t, v1.CassetteModeReplay, mode)
+		// Intercepted connections keep the policy they were opened with.
+		c.CloseIdleConnections()
+		store.id = "replayer"
+		store.cassette = &v1.SandboxCassette{Name: "evals", Mode: mode}
+
+		for range 2 {
+			status, body := do(t, c, http.MethodGet, httpsURL, "")
+			require.Equal(t, http.StatusOK, status, body)
+			require.Equal(t, "GET www.example.com:"+port(upstreamTLS)+"/https ", body)
+		}
+		resp, err := c.Post(httpURL, "text/plain", strings.NewReader("one"))
+		require.NoError(t, err)
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "yes", resp.Header.Get("X-Upstream"))
+		require.Equal(t, "POST upstream.test:"+port(upstream)+"/http one", string(body))
+		require.Equal(t, int32(3), hits.Load(), "nothing is connected to while replaying")
+
+		// Each recorded exchange is replayed once.
+		status, _ := do(t, c, http.MethodGet, httpsURL, "")
+		require.Equal(t, http.StatusBadGateway, status)
+		conn := store.last()
+		require.False(t, conn.Allowed)
+		require.Contains(t, conn.Message, "no matching request")
+
+		status, _ = do(t, c, http.MethodPost, httpURL, "two")
+		require.Equal(t, http.StatusBadGateway, status)
+		require.Equal(t, int32(3), hits.Load())
+	})
+}
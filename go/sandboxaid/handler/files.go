@@ -0,0 +1,234 @@
+package handler
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	v1 "github.com/substratusai/sandboxai/go/api/v1"
+	"github.com/substratusai/sandboxai/go/sandboxaid/client"
+)
+
+// errorReasonPathNotFound distinguishes a missing path from a missing sandbox
+// (both are 404s).
+const errorReasonPathNotFound = "PathNotFound"
+
+// maxFileRequestSize limits the size of file API request bodies. File
+// content is base64 encoded so allow for that plus the rest of the request.
+var maxFileRequestSize = int64(base64.StdEncoding.EncodedLen(client.MaxFileSize) + 64<<10)
+
+func (h *Handler) v1WriteFile(w http.ResponseWriter, r *http.Request) {
+	var req v1.WriteFileRequest
+	sbx, done, ok := h.fileRequest(w, r, &req, &req.Path)
+	if !ok {
+		return
+	}
+	defer done()
+
+	info, err := h.client.WriteFile(r.Context(), sbx, req.Path, req.Content, fs.FileMode(req.Mode))
+	if err != nil {
+		sendFileError(w, r, err)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(v1.WriteFileResult{Info: *info}); err != nil {
+		sendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *Handler) v1ReadFile(w http.ResponseWriter, r *http.Request) {
+	var req v1.ReadFileRequest
+	sbx, done, ok := h.fileRequest(w, r, &req, &req.Path)
+	if !ok {
+		return
+	}
+	defer done()
+
+	content, info, err := h.client.ReadFile(r.Context(), sbx, req.Path)
+	if err != nil {
+		sendFileError(w, r, err)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(v1.ReadFileResult{Info: *info, Content: content}); err != nil {
+		sendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *Handler) v1ListDir(w http.ResponseWriter, r *http.Request) {
+	var req v1.ListDirRequest
+	sbx, done, ok := h.fileRequest(w, r, &req, &req.Path)
+	if !ok {
+		return
+	}
+	defer done()
+
+	entries, truncated, err := h.client.ListDir(r.Context(), sbx, req.Path)
+	if err != nil {
+		sendFileError(w, r, err)
+		return
+	}
+	if entries == nil {
+		entries = []v1.FileInfo{}
+	}
+	if err := json.NewEncoder(w).Encode(v1.ListDirResult{Entries: entries, Truncated: truncated}); err != nil {
+		sendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *Handler) v1Stat(w http.ResponseWriter, r *http.Request) {
+	var req v1.StatRequest
+	sbx, done, ok := h.fileRequest(w, r, &req, &req.Path)
+	if !ok {
+		return
+	}
+	defer done()
+
+	info, err := h.client.StatPath(r.Context(), sbx, req.Path)
+	if err != nil {
+		sendFileError(w, r, err)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(v1.StatResult{Info: *info}); err != nil {
+		sendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *Handler) v1DeletePath(w http.ResponseWriter, r *http.Request) {
+	var req v1.DeletePathRequest
+	sbx, done, ok := h.fileRequest(w, r, &req, &req.Path)
+	if !ok {
+		return
+	}
+	defer done()
+	if req.Path == "/" {
+		sendError(w, r, errors.New("the root directory cannot be deleted"), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.client.DeletePath(r.Context(), sbx, req.Path, req.Recursive); err != nil {
+		sendFileError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// fileRequest decodes and validates a file API request and looks up the
+// sandbox. done must be called once the request has been served. If false is
+// returned, an error has been sent.
+func (h *Handler) fileRequest(w http.ResponseWriter, r *http.Request, req any, path *string) (_ *client.Sandbox, done func(), ok bool) {
+	space := chi.URLParam(r, "space")
+	name := chi.URLParam(r, "name")
+
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxFileRequestSize)).Decode(req); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			sendError(w, r, client.ErrFileTooLarge, http.StatusRequestEntityTooLarge)
+			return nil, nil, false
+		}
+		sendError(w, r, err, http.StatusBadRequest)
+		return nil, nil, false
+	}
+	if err := client.ValidatePath(*path); err != nil {
+		sendError(w, r, err, http.StatusBadRequest)
+		return nil, nil, false
+	}
+
+	sbx, err := h.client.GetSandbox(r.Context(), space, name)
+	if err != nil {
+		if errors.Is(err, client.ErrSandboxNotFound) {
+			sendError(w, r, err, http.StatusNotFound)
+			return nil, nil, false
+		}
+		sendError(w, r, err, http.StatusInternalServerError)
+		return nil, nil, false
+	}
+	return h.activeSandbox(w, r, space, sbx)
+}
+
+func (h *Handler) v1PutArchive(w http.ResponseWriter, r *http.Request) {
+	sbx, path, done, ok := h.archiveRequest(w, r)
+	if !ok {
+		return
+	}
+	defer done()
+
+	if err := h.client.PutArchive(r.Context(), sbx, path, r.Body); err != nil {
+		sendFileError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) v1GetArchive(w http.ResponseWriter, r *http.Request) {
+	sbx, path, done, ok := h.archiveRequest(w, r)
+	if !ok {
+		return
+	}
+	defer done()
+
+	archive, err := h.client.GetArchive(r.Context(), sbx, path)
+	if err != nil {
+		sendFileError(w, r, err)
+		return
+	}
+	defer archive.Close()
+
+	w.Header().Set("Content-Type", "application/x-tar")
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, archive); err != nil {
+		// The status has already been sent.
+		log.Printf("error serving request: %s: copying archive: %v", r.URL.Path, err)
+	}
+}
+
+// archiveRequest validates an archive request and looks up the sandbox.
+// done must be called once the request has been served. If false is
+// returned, an error has been sent.
+func (h *Handler) archiveRequest(w http.ResponseWriter, r *http.Request) (_ *client.Sandbox, path string, done func(), ok bool) {
+	space := chi.URLParam(r, "space")
+	name := chi.URLParam(r, "name")
+
+	path = r.URL.Query().Get("path")
+	if err := client.ValidatePath(path); err != nil {
+		sendError(w, r, err, http.StatusBadRequest)
+		return nil, "", nil, false
+	}
+
+	sbx, err := h.client.GetSandbox(r.Context(), space, name)
+	if err != nil {
+		if errors.Is(err, client.ErrSandboxNotFound) {
+			sendError(w, r, err, http.StatusNotFound)
+			return nil, "", nil, false
+		}
+		sendError(w, r, err, http.StatusInternalServerError)
+		return nil, "", nil, false
+	}
+	sbx, done, ok = h.activeSandbox(w, r, space, sbx)
+	if !ok {
+		return nil, "", nil, false
+	}
+	return sbx, path, done, true
+}
+
+func sendFileError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, client.ErrPathNotFound):
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(v1.Error{Message: err.Error(), Reason: errorReasonPathNotFound})
+	case errors.Is(err, client.ErrFileTooLarge):
+		sendError(w, r, err, http.StatusRequestEntityTooLarge)
+	case errors.Is(err, client.ErrIsDirectory), errors.Is(err, client.ErrNotDirectory), errors.Is(err, client.ErrInvalidArchive):
+		sendError(w, r, err, http.StatusBadRequest)
+	case errors.Is(err, client.ErrDirectoryNotEmpty):
+		sendError(w, r, err, http.StatusConflict)
+	default:
+		sendError(w, r, err, http.StatusInternalServerError)
+	}
+}
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -8,7 +9,10 @@ import (
 	"net/http/httputil"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -32,58 +36,225 @@ var log Logger = stdlog.New(os.Stderr, "", stdlog.LstdFlags)
 type Handler struct {
 	http.Handler
 	client client.Client
+
+	// portDomain enables routing requests for "{port}-{name}.{space}.{portDomain}"
+	// to sandbox ports (if not empty).
+	portDomain string
+
+	// shutdown is closed to end long-lived streams (i.e. watches).
+	shutdown     chan struct{}
+	shutdownOnce sync.Once
 }
 
-func NewHandler(client client.Client) *Handler {
+type Option func(*Handler)
+
+// WithPortDomain routes requests with a host of the form
+// "{port}-{name}.{space}.{domain}" to the port of the sandbox. For example,
+// with the domain "localhost", http://3000-mybox.default.localhost:5266/
+// reaches port 3000 in the "mybox" sandbox.
+func WithPortDomain(domain string) Option {
+	return func(h *Handler) {
+		h.portDomain = domain
+	}
+}
+
+func NewHandler(client client.Client, opts ...Option) *Handler {
 	r := chi.NewRouter()
 
 	h := &Handler{
-		Handler: r,
-		client:  client,
+		Handler:  r,
+		client:   client,
+		shutdown: make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(h)
 	}
 
 	// Log to stderr.
 	r.Use(middleware.RequestLogger(&middleware.DefaultLogFormatter{
 		Logger: log,
 	}))
+	if h.portDomain != "" {
+		r.Use(h.routePortHosts)
+	}
 
 	r.Route("/v1", func(r chi.Router) {
 		r.Get("/healthz", func(w http.ResponseWriter, r *http.Request) {
 			w.WriteHeader(http.StatusOK)
 		})
+		r.Post("/spaces", h.v1PostSpace)
+		r.Get("/spaces/{space}", h.v1GetSpace)
+		r.Delete("/spaces/{space}", h.v1DeleteSpace)
+		r.Route("/spaces/{space}/volumes", func(r chi.Router) {
+			r.Get("/", h.v1ListVolumes)
+			r.Post("/", h.v1PostVolume)
+			r.Get("/{name}", h.v1GetVolume)
+			r.Delete("/{name}", h.v1DeleteVolume)
+			r.Post("/{name}:snapshot", h.v1PostSnapshot)
+		})
+		r.Route("/spaces/{space}/snapshots", func(r chi.Router) {
+			r.Get("/", h.v1ListSnapshots)
+			r.Get("/{name}", h.v1GetSnapshot)
+			r.Delete("/{name}", h.v1DeleteSnapshot)
+		})
+		r.Get("/spaces/{space}/sandboxes:watch", h.v1WatchSandboxes)
+		r.Post("/spaces/{space}/sandboxes/{name}:pause", h.v1PauseSandbox)
+		r.Post("/spaces/{space}/sandboxes/{name}:resume", h.v1ResumeSandbox)
 		r.Route("/spaces/{space}/sandboxes", func(r chi.Router) {
+			r.Get("/", h.v1ListSandboxes)
 			r.Post("/", h.v1PostSandbox)
 		})
 		r.Route("/spaces/{space}/sandboxes/{name}", func(r chi.Router) {
 			r.Get("/", h.v1GetSandbox)
 			r.Delete("/", h.v1DeleteSandbox)
+			r.Put("/archive", h.v1PutArchive)
+			r.Get("/archive", h.v1GetArchive)
+			r.Get("/terminal", h.v1AttachTerminal)
+			r.Get("/egress", h.v1ListEgressConnections)
+			r.Get("/ports/{port}:connect", h.v1ConnectPort)
+			r.Get("/ports/{port}:forward", h.v1ForwardPort)
+			r.HandleFunc("/ports/{port}", h.v1ProxyToPort)
+			r.HandleFunc("/ports/{port}/*", h.v1ProxyToPort)
+			r.Post("/tools:write_file", h.v1WriteFile)
+			r.Post("/tools:read_file", h.v1ReadFile)
+			r.Post("/tools:list_dir", h.v1ListDir)
+			r.Post("/tools:stat", h.v1Stat)
+			r.Post("/tools:delete_path", h.v1DeletePath)
 			r.Post("/tools:*", h.v1ProxyToSandbox)
+			r.Get("/processes", h.v1ProxyToSandbox)
+			r.Post("/processes", h.v1ProxyToSandbox)
+			r.Get("/processes/*", h.v1ProxyToSandbox)
+			r.Post("/processes/*", h.v1ProxyToSandbox)
 		})
 	})
 	return h
 }
 
-func (h *Handler) v1PostSandbox(w http.ResponseWriter, r *http.Request) {
+// CloseStreams ends all long-lived streams so that the server can shutdown
+// gracefully. It should be registered with http.Server.RegisterOnShutdown.
+func (h *Handler) CloseStreams() {
+	h.shutdownOnce.Do(func() {
+		close(h.shutdown)
+	})
+}
+
+func (h *Handler) v1PostSpace(w http.ResponseWriter, r *http.Request) {
+	var s v1.CreateSpaceRequest
+	if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+		sendError(w, r, err, http.StatusBadRequest)
+		return
+	}
+	if err := client.ValidateSpaceName(s.Name); err != nil {
+		sendError(w, r, err, http.StatusBadRequest)
+		return
+	}
+	if s.Spec != nil {
+		if err := client.ValidateSpaceResources(s.Spec.Resources); err != nil {
+			sendError(w, r, fmt.Errorf("resources: %w", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	created, err := h.client.CreateSpace(r.Context(), &s)
+	if err != nil {
+		if errors.Is(err, client.ErrSpaceAlreadyExists) {
+			sendError(w, r, err, http.StatusConflict)
+			return
+		}
+		sendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(created); err != nil {
+		sendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *Handler) v1GetSpace(w http.ResponseWriter, r *http.Request) {
 	space := chi.URLParam(r, "space")
 
-	if space != "default" {
-		sendUnimplementedSpaceError(w, r, space)
+	s, err := h.client.GetSpace(r.Context(), space)
+	if err != nil {
+		if errors.Is(err, client.ErrSpaceNotFound) {
+			sendError(w, r, err, http.StatusNotFound)
+			return
+		}
+		sendError(w, r, err, http.StatusInternalServerError)
 		return
 	}
+	if err := json.NewEncoder(w).Encode(s); err != nil {
+		sendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *Handler) v1DeleteSpace(w http.ResponseWriter, r *http.Request) {
+	space := chi.URLParam(r, "space")
+
+	if space == client.DefaultSpace {
+		sendError(w, r, fmt.Errorf("the %q space cannot be deleted", client.DefaultSpace), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.client.DeleteSpace(r.Context(), space); err != nil {
+		if errors.Is(err, client.ErrSpaceNotFound) {
+			sendError(w, r, err, http.StatusNotFound)
+			return
+		}
+		sendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) v1PostSandbox(w http.ResponseWriter, r *http.Request) {
+	space := chi.URLParam(r, "space")
 
 	var s v1.CreateSandboxRequest
 	if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
 		sendError(w, r, err, http.StatusBadRequest)
 		return
 	}
+	if err := client.ValidateLabels(s.Labels); err != nil {
+		sendError(w, r, err, http.StatusBadRequest)
+		return
+	}
+	if err := client.ValidateResources(s.Spec.Resources); err != nil {
+		sendError(w, r, fmt.Errorf("resources: %w", err), http.StatusBadRequest)
+		return
+	}
+	if err := client.ValidateNetwork(s.Spec.Network); err != nil {
+		sendError(w, r, fmt.Errorf("network: %w", err), http.StatusBadRequest)
+		return
+	}
+	if err := client.ValidateMounts(s.Spec.Mounts); err != nil {
+		sendError(w, r, fmt.Errorf("mounts: %w", err), http.StatusBadRequest)
+		return
+	}
+	if err := client.ValidateLifetime(s.Spec); err != nil {
+		sendError(w, r, err, http.StatusBadRequest)
+		return
+	}
 
 	created, err := h.client.CreateSandbox(r.Context(), space, &s)
 	if err != nil {
+		if errors.Is(err, client.ErrSpaceNotFound) {
+			sendError(w, r, err, http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, client.ErrResourcesExceedMax) || errors.Is(err, client.ErrEgressProxyDisabled) || errors.Is(err, client.ErrEgressProxyUnreachable) ||
+			errors.Is(err, client.ErrCassetteNotFound) || errors.Is(err, client.ErrVolumeNotFound) || errors.Is(err, client.ErrBindMountNotAllowed) {
+			sendError(w, r, err, http.StatusBadRequest)
+			return
+		}
 		sendError(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
+	// The sandbox is still starting up, clients can wait for it with GET ?wait=Ready.
+	w.WriteHeader(http.StatusAccepted)
 	if err := json.NewEncoder(w).Encode(&created.Sandbox); err != nil {
 		sendError(w, r, err, http.StatusInternalServerError)
 		return
@@ -94,12 +265,26 @@ func (h *Handler) v1GetSandbox(w http.ResponseWriter, r *http.Request) {
 	space := chi.URLParam(r, "space")
 	name := chi.URLParam(r, "name")
 
-	if space != "default" {
-		sendUnimplementedSpaceError(w, r, space)
+	query := r.URL.Query()
+
+	var (
+		s   *client.Sandbox
+		err error
+	)
+	switch wait := v1.GetSandboxParamsWait(query.Get("wait")); wait {
+	case "":
+		s, err = h.client.GetSandbox(r.Context(), space, name)
+	case v1.GetSandboxParamsWaitReady:
+		timeout, terr := parseWaitTimeout(query.Get("timeout"))
+		if terr != nil {
+			sendError(w, r, fmt.Errorf("timeout: %w", terr), http.StatusBadRequest)
+			return
+		}
+		s, err = h.client.WaitForReady(r.Context(), space, name, timeout)
+	default:
+		sendError(w, r, fmt.Errorf("wait: unsupported value %q", wait), http.StatusBadRequest)
 		return
 	}
-
-	s, err := h.client.GetSandbox(r.Context(), space, name)
 	if err != nil {
 		if errors.Is(err, client.ErrSandboxNotFound) {
 			sendError(w, r, err, http.StatusNotFound)
@@ -114,15 +299,142 @@ func (h *Handler) v1GetSandbox(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (h *Handler) v1DeleteSandbox(w http.ResponseWriter, r *http.Request) {
+const (
+	defaultListLimit = 100
+	maxListLimit     = 1000
+)
+
+func (h *Handler) v1ListSandboxes(w http.ResponseWriter, r *http.Request) {
 	space := chi.URLParam(r, "space")
-	name := chi.URLParam(r, "name")
 
-	if space != "default" {
-		sendUnimplementedSpaceError(w, r, space)
+	query := r.URL.Query()
+
+	selector, err := client.ParseSelector(query.Get("label_selector"))
+	if err != nil {
+		sendError(w, r, fmt.Errorf("label_selector: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	limit := defaultListLimit
+	if val := query.Get("limit"); val != "" {
+		limit, err = strconv.Atoi(val)
+		if err != nil || limit < 1 || limit > maxListLimit {
+			sendError(w, r, fmt.Errorf("limit: must be an integer between 1 and %d", maxListLimit), http.StatusBadRequest)
+			return
+		}
+	}
+
+	after, err := decodePageToken(query.Get("page_token"))
+	if err != nil {
+		sendError(w, r, fmt.Errorf("page_token: %w", err), http.StatusBadRequest)
 		return
 	}
 
+	list, err := h.client.ListSandboxes(r.Context(), space, client.ListOptions{
+		Selector: selector,
+		Limit:    limit,
+		After:    after,
+	})
+	if err != nil {
+		if errors.Is(err, client.ErrSpaceNotFound) {
+			sendError(w, r, err, http.StatusNotFound)
+			return
+		}
+		sendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	resp := v1.SandboxList{
+		Items: make([]v1.Sandbox, 0, len(list.Items)),
+	}
+	for _, s := range list.Items {
+		resp.Items = append(resp.Items, *s.Sandbox)
+	}
+	if list.Continue != "" {
+		resp.NextPageToken = encodePageToken(list.Continue)
+	}
+
+	if err := json.NewEncoder(w).Encode(&resp); err != nil {
+		sendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+}
+
+// sseKeepaliveInterval is how often keepalives are sent on idle event streams.
+const sseKeepaliveInterval = 15 * time.Second
+
+func (h *Handler) v1WatchSandboxes(w http.ResponseWriter, r *http.Request) {
+	space := chi.URLParam(r, "space")
+
+	query := r.URL.Query()
+
+	selector, err := client.ParseSelector(query.Get("label_selector"))
+	if err != nil {
+		sendError(w, r, fmt.Errorf("label_selector: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	// Support automatic reconnects from EventSource clients.
+	resumeToken := query.Get("resume_token")
+	if resumeToken == "" {
+		resumeToken = r.Header.Get("Last-Event-ID")
+	}
+
+	if _, err := h.client.GetSpace(r.Context(), space); err != nil {
+		if errors.Is(err, client.ErrSpaceNotFound) {
+			sendError(w, r, err, http.StatusNotFound)
+			return
+		}
+		sendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	events, err := h.client.WatchSandboxes(r.Context(), space, client.WatchOptions{
+		Selector:    selector,
+		ResumeToken: resumeToken,
+	})
+	if err != nil {
+		if errors.Is(err, client.ErrResumeTokenExpired) {
+			sendError(w, r, err, http.StatusGone)
+			return
+		}
+		sendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	sse, err := startSSE(w)
+	if err != nil {
+		log.Printf("error serving request: %s: %v", r.URL.Path, err)
+		return
+	}
+
+	keepalive := time.NewTicker(sseKeepaliveInterval)
+	defer keepalive.Stop()
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				return
+			}
+			if err := sse.event(ev.ResumeToken, &ev.SandboxEvent); err != nil {
+				return
+			}
+		case <-keepalive.C:
+			if err := sse.keepalive(); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		case <-h.shutdown:
+			return
+		}
+	}
+}
+
+func (h *Handler) v1DeleteSandbox(w http.ResponseWriter, r *http.Request) {
+	space := chi.URLParam(r, "space")
+	name := chi.URLParam(r, "name")
+
 	if err := h.client.DeleteSandbox(r.Context(), space, name); err != nil {
 		if errors.Is(err, client.ErrSandboxNotFound) {
 			sendError(w, r, err, http.StatusNotFound)
@@ -134,33 +446,246 @@ func (h *Handler) v1DeleteSandbox(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
-func (h *Handler) v1ProxyToSandbox(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) v1PauseSandbox(w http.ResponseWriter, r *http.Request) {
+	space := chi.URLParam(r, "space")
+	name := chi.URLParam(r, "name")
+
+	sbx, err := h.client.PauseSandbox(r.Context(), space, name)
+	if err != nil {
+		if errors.Is(err, client.ErrSandboxNotFound) {
+			sendError(w, r, err, http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, client.ErrSandboxNotRunning) {
+			sendError(w, r, err, http.StatusConflict)
+			return
+		}
+		sendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(sbx.Sandbox); err != nil {
+		sendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *Handler) v1ResumeSandbox(w http.ResponseWriter, r *http.Request) {
 	space := chi.URLParam(r, "space")
 	name := chi.URLParam(r, "name")
 
-	if space != "default" {
-		sendUnimplementedSpaceError(w, r, space)
+	sbx, err := h.client.ResumeSandbox(r.Context(), space, name)
+	if err != nil {
+		if errors.Is(err, client.ErrSandboxNotFound) {
+			sendError(w, r, err, http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, client.ErrSandboxNotRunning) {
+			sendError(w, r, err, http.StatusConflict)
+			return
+		}
+		sendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(sbx.Sandbox); err != nil {
+		sendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *Handler) v1ListEgressConnections(w http.ResponseWriter, r *http.Request) {
+	space := chi.URLParam(r, "space")
+	name := chi.URLParam(r, "name")
+
+	sbx, err := h.client.GetSandbox(r.Context(), space, name)
+	if err != nil {
+		if errors.Is(err, client.ErrSandboxNotFound) {
+			sendError(w, r, err, http.StatusNotFound)
+			return
+		}
+		sendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	conns, err := h.client.ListEgressConnections(r.Context(), sbx)
+	if err != nil {
+		sendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	list := v1.EgressConnectionList{Items: conns}
+	if list.Items == nil {
+		list.Items = []v1.EgressConnection{}
+	}
+	if err := json.NewEncoder(w).Encode(list); err != nil {
+		sendError(w, r, err, http.StatusInternalServerError)
 		return
 	}
+}
+
+func (h *Handler) v1ProxyToSandbox(w http.ResponseWriter, r *http.Request) {
+	space := chi.URLParam(r, "space")
+	name := chi.URLParam(r, "name")
 
 	s, err := h.client.GetSandbox(r.Context(), space, name)
 	if err != nil {
 		sendError(w, r, err, http.StatusNotFound)
 		return
 	}
+	s, done, ok := h.activeSandbox(w, r, space, s)
+	if !ok {
+		return
+	}
+	// Long running calls are not mistaken for idleness.
+	defer done()
 
-	containerURL, err := url.Parse(fmt.Sprintf("http://127.0.0.1:%d", s.BoxHostPort))
+	containerURL, err := url.Parse("http://" + s.BoxAddr)
 	if err != nil {
 		sendError(w, r, err, http.StatusInternalServerError)
 		return
 	}
 	r.URL.Path = strings.TrimPrefix(r.URL.Path, fmt.Sprintf("/v1/spaces/%s/sandboxes/%s", space, name))
 	proxy := httputil.NewSingleHostReverseProxy(containerURL)
+	// Flush after every write so that streamed tool output reaches the
+	// client as soon as it is produced.
+	proxy.FlushInterval = -1
+	// The proxied request is bound to the client's context, so a client
+	// disconnect closes the connection to boxd, which then kills the command
+	// or interrupts the kernel. That is not a proxy error.
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		if r.Context().Err() != nil {
+			log.Printf("client disconnected: %s: %v", r.URL.Path, err)
+			return
+		}
+		sendError(w, r, err, http.StatusBadGateway)
+	}
+
 	proxy.ServeHTTP(w, r)
 }
 
-func sendUnimplementedSpaceError(w http.ResponseWriter, r *http.Request, space string) {
-	sendError(w, r, fmt.Errorf("space %q not found: current only the %q is supported", space, "default"), http.StatusNotFound)
+const (
+	defaultWaitTimeout = 30 * time.Second
+	maxWaitTimeout     = 5 * time.Minute
+)
+
+// parseWaitTimeout parses the timeout used for long-polling requests.
+func parseWaitTimeout(val string) (time.Duration, error) {
+	if val == "" {
+		return defaultWaitTimeout, nil
+	}
+	timeout, err := time.ParseDuration(val)
+	if err != nil {
+		return 0, err
+	}
+	if timeout < 0 || timeout > maxWaitTimeout {
+		return 0, fmt.Errorf("must be between 0s and %s", maxWaitTimeout)
+	}
+	return timeout, nil
+}
+
+// encodePageToken returns an opaque token that marks the position after
+// the given sandbox name.
+func encodePageToken(after string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(after))
+}
+
+func decodePageToken(token string) (string, error) {
+	if token == "" {
+		return "", nil
+	}
+	after, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return "", fmt.Errorf("invalid token")
+	}
+	return string(after), nil
+}
+
+// errorReasonSandboxPaused is sent for tool calls made to paused sandboxes,
+// which would otherwise hang until the sandbox is resumed.
+const errorReasonSandboxPaused = "SandboxPaused"
+
+// errorReasonSandboxNotRunning is sent for tool calls made to sandboxes that
+// are not running and are not started by the call, such as Pending sandboxes.
+const errorReasonSandboxNotRunning = "SandboxNotRunning"
+
+// activeSandbox prepares a sandbox for a tool call. Paused sandboxes are
+// rejected and sandboxes that were stopped by their idle_policy are started
+// (which blocks until they are ready). The sandbox is tracked as serving a
+// call, so that it is not stopped for being idle, until done is called. An
+// error response has been sent if ok is false.
+func (h *Handler) activeSandbox(w http.ResponseWriter, r *http.Request, space string, sbx *client.Sandbox) (_ *client.Sandbox, done func(), ok bool) {
+	if sbx.Status != nil && sbx.Status.Phase != nil && *sbx.Status.Phase == v1.SandboxPhaseExpired {
+		sendError(w, r, fmt.Errorf("sandbox %q expired: %w", sbx.Name, client.ErrSandboxNotFound), http.StatusNotFound)
+		return nil, nil, false
+	}
+	if sbx.Paused() {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(v1.Error{Message: client.ErrSandboxPaused.Error(), Reason: errorReasonSandboxPaused})
+		return nil, nil, false
+	}
+	done = h.client.TrackActivity(sbx)
+	if sbx.Spec.IdlePolicy != v1.IdlePolicyStop {
+		if sbx.BoxAddr == "" {
+			done()
+			sendNotRunning(w, sbx)
+			return nil, nil, false
+		}
+		return sbx, done, true
+	}
+
+	// The sandbox might have been stopped since it was gotten, but it is
+	// not stopped while tracked.
+	current, err := h.client.GetSandbox(r.Context(), space, sbx.Name)
+	if err != nil {
+		done()
+		if errors.Is(err, client.ErrSandboxNotFound) {
+			sendError(w, r, err, http.StatusNotFound)
+			return nil, nil, false
+		}
+		sendError(w, r, err, http.StatusInternalServerError)
+		return nil, nil, false
+	}
+	if current.BoxAddr != "" {
+		return current, done, true
+	}
+
+	started, err := h.client.StartSandbox(r.Context(), space, sbx.Name)
+	if err != nil {
+		done()
+		if errors.Is(err, client.ErrSandboxNotFound) {
+			sendError(w, r, err, http.StatusNotFound)
+			return nil, nil, false
+		}
+		sendError(w, r, err, http.StatusInternalServerError)
+		return nil, nil, false
+	}
+	if started.BoxAddr == "" || started.Status.Phase == nil || *started.Status.Phase != v1.SandboxPhaseReady {
+		done()
+		msg := "sandbox did not become ready after starting"
+		if started.Status.Message != "" {
+			msg += ": " + started.Status.Message
+		}
+		// Not sent with sendError, which hides the messages of 5xx errors.
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(v1.Error{Message: msg})
+		return nil, nil, false
+	}
+	return started, done, true
+}
+
+// sendNotRunning sends a 409 with the phase and reason of a sandbox that is not
+// running.
+func sendNotRunning(w http.ResponseWriter, sbx *client.Sandbox) {
+	msg := fmt.Sprintf("sandbox %q is not running", sbx.Name)
+	if sbx.Status != nil && sbx.Status.Phase != nil {
+		msg = fmt.Sprintf("sandbox %q is %s", sbx.Name, *sbx.Status.Phase)
+		if sbx.Status.Reason != "" {
+			msg += ": " + sbx.Status.Reason
+		}
+		if sbx.Status.Message != "" {
+			msg += ": " + sbx.Status.Message
+		}
+	}
+	w.WriteHeader(http.StatusConflict)
+	json.NewEncoder(w).Encode(v1.Error{Message: msg, Reason: errorReasonSandboxNotRunning})
 }
 
 func sendError(w http.ResponseWriter, r *http.Request, err error, status int) {
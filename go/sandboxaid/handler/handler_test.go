@@ -0,0 +1,26 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	v1 "github.com/substratusai/sandboxai/go/api/v1"
+	"github.com/substratusai/sandboxai/go/sandboxaid/client"
+)
+
+func Test_sendNotRunning(t *testing.T) {
+	phase := v1.SandboxPhaseStopped
+	w := httptest.NewRecorder()
+	sendNotRunning(w, &client.Sandbox{Sandbox: &v1.Sandbox{
+		Name:   "box",
+		Status: &v1.SandboxStatus{Phase: &phase, Reason: "ContainerExited", Message: "Exit code 1"},
+	}})
+	require.Equal(t, http.StatusConflict, w.Code)
+	var apiErr v1.Error
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&apiErr))
+	require.Equal(t, errorReasonSandboxNotRunning, apiErr.Reason)
+	require.Equal(t, `sandbox "box" is Stopped: ContainerExited: Exit code 1`, apiErr.Message)
+}
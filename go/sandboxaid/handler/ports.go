@@ -0,0 +1,240 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	v1 "github.com/substratusai/sandboxai/go/api/v1"
+	"github.com/substratusai/sandboxai/go/internal/tunnel"
+	"github.com/substratusai/sandboxai/go/sandboxaid/client"
+)
+
+// errorReasonPortNotListening is sent when nothing in the sandbox is
+// listening on a proxied port.
+const errorReasonPortNotListening = "PortNotListening"
+
+func (h *Handler) v1ProxyToPort(w http.ResponseWriter, r *http.Request) {
+	space := chi.URLParam(r, "space")
+	name := chi.URLParam(r, "name")
+	port := chi.URLParam(r, "port")
+
+	prefix := fmt.Sprintf("/v1/spaces/%s/sandboxes/%s/ports/%s", space, name, port)
+	if r.URL.Path == prefix {
+		// Redirect so that relative links in the app resolve under the prefix.
+		u := *r.URL
+		u.Path += "/"
+		u.RawPath = ""
+		http.Redirect(w, r, u.String(), http.StatusPermanentRedirect)
+		return
+	}
+	r.URL.Path = strings.TrimPrefix(r.URL.Path, prefix)
+	r.URL.RawPath = strings.TrimPrefix(r.URL.RawPath, prefix)
+	r.Header.Set("X-Forwarded-Prefix", prefix)
+
+	h.proxyToPort(w, r, space, name, port)
+}
+
+// routePortHosts is middleware that routes requests with a host like
+// "{port}-{name}.{space}.{portDomain}" to the port of the sandbox, so that
+// apps that use absolute paths work. Other requests are passed to next.
+func (h *Handler) routePortHosts(next http.Handler) http.Handler {
+	suffix := "." + strings.ToLower(h.portDomain)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		space, name, port, ok := parsePortHost(r.Host, suffix)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		r.Header.Del("X-Forwarded-Prefix")
+		h.proxyToPort(w, r, space, name, port)
+	})
+}
+
+// parsePortHost parses a host of the form "{port}-{name}.{space}{suffix}"
+// with an optional ":port".
+func parsePortHost(host, suffix string) (space, name, port string, ok bool) {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host, ok = strings.CutSuffix(strings.ToLower(host), suffix)
+	if !ok {
+		return "", "", "", false
+	}
+	portName, space, ok := strings.Cut(host, ".")
+	if !ok || space == "" || strings.Contains(space, ".") {
+		return "", "", "", false
+	}
+	port, name, ok = strings.Cut(portName, "-")
+	if !ok || name == "" || port == "" {
+		return "", "", "", false
+	}
+	return space, name, port, true
+}
+
+func (h *Handler) proxyToPort(w http.ResponseWriter, r *http.Request, space, name, portStr string) {
+	sbx, port, done, ok := h.getPortSandbox(w, r, space, name, portStr)
+	if !ok {
+		return
+	}
+	// The sandbox is not idle while the request (or the upgraded
+	// connection) is served.
+	defer done()
+
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(&url.URL{Scheme: "http", Host: fmt.Sprintf("localhost:%d", port)})
+			// Keep the original host so that apps can build absolute URLs.
+			pr.Out.Host = pr.In.Host
+			pr.SetXForwarded()
+		},
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return h.client.DialPort(ctx, sbx, port)
+			},
+			// Every request gets its own relayed connection.
+			DisableKeepAlives: true,
+		},
+		// Flush immediately so that streamed responses (e.g. server-sent
+		// events) are not delayed.
+		FlushInterval: -1,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			switch {
+			case r.Context().Err() != nil:
+				log.Printf("client disconnected: %s: %v", r.URL.Path, err)
+			case errors.Is(err, client.ErrPortNotListening):
+				w.WriteHeader(http.StatusBadGateway)
+				json.NewEncoder(w).Encode(v1.Error{Message: err.Error(), Reason: errorReasonPortNotListening})
+			default:
+				sendError(w, r, err, http.StatusBadGateway)
+			}
+		},
+	}
+	proxy.ServeHTTP(w, r)
+}
+
+// getPortSandbox validates the port and looks up the sandbox. done must be
+// called once the request or connection has ended. An error response has
+// been sent if ok is false.
+func (h *Handler) getPortSandbox(w http.ResponseWriter, r *http.Request, space, name, portStr string) (sbx *client.Sandbox, port int, done func(), ok bool) {
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 1 || port > 65535 {
+		sendError(w, r, fmt.Errorf("port: must be an integer between 1 and 65535"), http.StatusBadRequest)
+		return nil, 0, nil, false
+	}
+
+	sbx, err = h.client.GetSandbox(r.Context(), space, name)
+	if err != nil {
+		if errors.Is(err, client.ErrSandboxNotFound) {
+			sendError(w, r, err, http.StatusNotFound)
+			return nil, 0, nil, false
+		}
+		sendError(w, r, err, http.StatusInternalServerError)
+		return nil, 0, nil, false
+	}
+	sbx, done, ok = h.activeSandbox(w, r, space, sbx)
+	if !ok {
+		return nil, 0, nil, false
+	}
+	return sbx, port, done, true
+}
+
+// v1ConnectPort tunnels a TCP connection to a port of the sandbox over a
+// WebSocket (see the tunnel package for the framing).
+func (h *Handler) v1ConnectPort(w http.ResponseWriter, r *http.Request) {
+	sbx, port, done, ok := h.getPortSandbox(w, r, chi.URLParam(r, "space"), chi.URLParam(r, "name"), chi.URLParam(r, "port"))
+	if !ok {
+		return
+	}
+	// The sandbox is not idle while the connection is open.
+	defer done()
+
+	// Connect before upgrading so that errors can be reported with a status.
+	portConn, err := h.client.DialPort(r.Context(), sbx, port)
+	if err != nil {
+		if errors.Is(err, client.ErrPortNotListening) {
+			w.WriteHeader(http.StatusBadGateway)
+			json.NewEncoder(w).Encode(v1.Error{Message: err.Error(), Reason: errorReasonPortNotListening})
+			return
+		}
+		sendError(w, r, err, http.StatusBadGateway)
+		return
+	}
+
+	ws, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader has already sent an error response.
+		portConn.Close()
+		return
+	}
+	conn := tunnel.NewConn(ws)
+
+	relayed := make(chan struct{})
+	defer close(relayed)
+	go func() {
+		select {
+		case <-h.shutdown:
+			conn.Close()
+			portConn.Close()
+		case <-relayed:
+		}
+	}()
+	if err := tunnel.Relay(conn, portConn); err != nil {
+		log.Printf("port connection ended: %s: %v", r.URL.Path, err)
+	}
+}
+
+// v1ForwardPort multiplexes TCP connections to a port of the sandbox over a
+// WebSocket (see tunnel.Session for the framing). The client opens a stream
+// for each connection, and the stream is closed with an error message if the
+// port cannot be dialed.
+func (h *Handler) v1ForwardPort(w http.ResponseWriter, r *http.Request) {
+	sbx, port, done, ok := h.getPortSandbox(w, r, chi.URLParam(r, "space"), chi.URLParam(r, "name"), chi.URLParam(r, "port"))
+	if !ok {
+		return
+	}
+	// The sandbox is not idle while the connection is open.
+	defer done()
+
+	ws, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader has already sent an error response.
+		return
+	}
+	sess := tunnel.NewServerSession(ws)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-h.shutdown:
+			sess.Close()
+		case <-sess.Done():
+		}
+		cancel()
+	}()
+
+	for {
+		stream, err := sess.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			portConn, err := h.client.DialPort(ctx, sbx, port)
+			if err != nil {
+				stream.CloseWithError(err)
+				return
+			}
+			if err := tunnel.Relay(stream, portConn); err != nil {
+				log.Printf("forwarded port connection ended: %s: %v", r.URL.Path, err)
+			}
+		}()
+	}
+}
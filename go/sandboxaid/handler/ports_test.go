@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parsePortHost(t *testing.T) {
+	cases := []struct {
+		host                   string
+		expSpace, expName, exp string
+		expOK                  bool
+	}{
+		{host: "3000-mybox.default.localhost", expSpace: "default", expName: "mybox", exp: "3000", expOK: true},
+		{host: "3000-my-box.team-a.localhost:5266", expSpace: "team-a", expName: "my-box", exp: "3000", expOK: true},
+		{host: "3000-MyBox.Default.LOCALHOST", expSpace: "default", expName: "mybox", exp: "3000", expOK: true},
+		{host: "localhost:5266"},
+		{host: "127.0.0.1:5266"},
+		{host: "mybox.default.localhost"},
+		{host: "3000-.default.localhost"},
+		{host: "3000-mybox.localhost"},
+		{host: "3000-mybox.a.b.localhost"},
+		{host: "3000-mybox.default.example.com"},
+	}
+	for _, c := range cases {
+		t.Run(c.host, func(t *testing.T) {
+			space, name, port, ok := parsePortHost(c.host, ".localhost")
+			require.Equal(t, c.expOK, ok)
+			require.Equal(t, c.expSpace, space)
+			require.Equal(t, c.expName, name)
+			require.Equal(t, c.exp, port)
+		})
+	}
+}
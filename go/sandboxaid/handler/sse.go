@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// sseWriter writes Server-Sent Events, flushing after each one so that
+// events are not buffered by the server.
+type sseWriter struct {
+	w  io.Writer
+	rc *http.ResponseController
+}
+
+// startSSE writes the headers for an event stream.
+func startSSE(w http.ResponseWriter) (*sseWriter, error) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// Disable buffering in proxies such as nginx.
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	sse := &sseWriter{w: w, rc: http.NewResponseController(w)}
+	if err := sse.rc.Flush(); err != nil {
+		return nil, fmt.Errorf("flushing headers: %w", err)
+	}
+	return sse, nil
+}
+
+// event writes a JSON encoded event. The id is omitted if empty.
+func (s *sseWriter) event(id string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	var b strings.Builder
+	if id != "" {
+		fmt.Fprintf(&b, "id: %s\n", id)
+	}
+	fmt.Fprintf(&b, "data: %s\n\n", data)
+	if _, err := io.WriteString(s.w, b.String()); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
+
+// keepalive writes a comment that is ignored by clients. It keeps idle
+// connections from being closed by load balancers.
+func (s *sseWriter) keepalive() error {
+	if _, err := io.WriteString(s.w, ": keepalive\n\n"); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
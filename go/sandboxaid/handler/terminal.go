@@ -0,0 +1,172 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gorilla/websocket"
+	v1 "github.com/substratusai/sandboxai/go/api/v1"
+	"github.com/substratusai/sandboxai/go/sandboxaid/client"
+)
+
+// The default CheckOrigin rejects cross-origin requests so that web pages
+// cannot open terminals or port tunnels on behalf of a user's browser.
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  4096,
+	WriteBufferSize: 4096,
+}
+
+const (
+	terminalPingInterval = 15 * time.Second
+	terminalWriteTimeout = 10 * time.Second
+	// maxTerminalMessageSize limits the size of messages from the client.
+	maxTerminalMessageSize = 64 << 10
+)
+
+func (h *Handler) v1AttachTerminal(w http.ResponseWriter, r *http.Request) {
+	space := chi.URLParam(r, "space")
+	name := chi.URLParam(r, "name")
+
+	query := r.URL.Query()
+	opts := client.TerminalOptions{
+		Command: query["command"],
+		User:    query.Get("user"),
+	}
+	for param, dst := range map[string]*uint{"rows": &opts.Rows, "cols": &opts.Cols} {
+		if val := query.Get(param); val != "" {
+			n, err := strconv.ParseUint(val, 10, 16)
+			if err != nil || n == 0 {
+				sendError(w, r, fmt.Errorf("%s: must be a positive integer", param), http.StatusBadRequest)
+				return
+			}
+			*dst = uint(n)
+		}
+	}
+
+	sbx, err := h.client.GetSandbox(r.Context(), space, name)
+	if err != nil {
+		if errors.Is(err, client.ErrSandboxNotFound) {
+			sendError(w, r, err, http.StatusNotFound)
+			return
+		}
+		sendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	sbx, done, ok := h.activeSandbox(w, r, space, sbx)
+	if !ok {
+		return
+	}
+	// The sandbox is not idle while the terminal is attached.
+	defer done()
+
+	// The terminal is closed explicitly below rather than by cancelling the
+	// request context.
+	ctx := context.WithoutCancel(r.Context())
+	term, err := h.client.OpenTerminal(ctx, sbx, opts)
+	if err != nil {
+		if errors.Is(err, client.ErrSandboxNotRunning) {
+			sendError(w, r, err, http.StatusConflict)
+			return
+		}
+		sendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	defer term.Close()
+
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader has already sent an error response.
+		return
+	}
+	defer conn.Close()
+	conn.SetReadLimit(maxTerminalMessageSize)
+
+	// Forward output until the process exits, then report the exit code.
+	outputDone := make(chan struct{})
+	go func() {
+		defer close(outputDone)
+		buf := make([]byte, 32<<10)
+		for {
+			n, err := term.Read(buf)
+			if n > 0 {
+				conn.SetWriteDeadline(time.Now().Add(terminalWriteTimeout))
+				if werr := conn.WriteMessage(websocket.BinaryMessage, buf[:n]); werr != nil {
+					return
+				}
+			}
+			if err != nil {
+				break
+			}
+		}
+
+		waitCtx, cancel := context.WithTimeout(ctx, terminalWriteTimeout)
+		defer cancel()
+		exitCode, err := term.Wait(waitCtx)
+		if err != nil {
+			log.Printf("error serving request: %s: waiting for terminal: %v", r.URL.Path, err)
+			conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseInternalServerErr, ""), time.Now().Add(terminalWriteTimeout))
+			return
+		}
+		conn.SetWriteDeadline(time.Now().Add(terminalWriteTimeout))
+		if err := conn.WriteJSON(v1.TerminalMessage{Type: v1.TerminalMessageExit, ExitCode: exitCode}); err != nil {
+			return
+		}
+		conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(terminalWriteTimeout))
+	}()
+
+	// Keep the connection alive and close it once the output has ended,
+	// which unblocks ReadMessage below.
+	go func() {
+		ping := time.NewTicker(terminalPingInterval)
+		defer ping.Stop()
+		for {
+			select {
+			case <-ping.C:
+				if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(terminalWriteTimeout)); err != nil {
+					conn.Close()
+					return
+				}
+			case <-outputDone:
+				// Give the client a moment to acknowledge the close message.
+				time.Sleep(time.Second)
+				conn.Close()
+				return
+			case <-h.shutdown:
+				conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"), time.Now().Add(terminalWriteTimeout))
+				conn.Close()
+				return
+			}
+		}
+	}()
+
+	// Forward input and resizes until the connection is closed.
+	for {
+		typ, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		switch typ {
+		case websocket.BinaryMessage:
+			if _, err := term.Write(data); err != nil {
+				return
+			}
+		case websocket.TextMessage:
+			var msg v1.TerminalMessage
+			if err := json.Unmarshal(data, &msg); err != nil {
+				log.Printf("error serving request: %s: invalid terminal message: %v", r.URL.Path, err)
+				continue
+			}
+			if msg.Type == v1.TerminalMessageResize && msg.Rows > 0 && msg.Cols > 0 {
+				if err := term.Resize(ctx, uint(msg.Rows), uint(msg.Cols)); err != nil {
+					log.Printf("error serving request: %s: resizing terminal: %v", r.URL.Path, err)
+				}
+			}
+		}
+	}
+}
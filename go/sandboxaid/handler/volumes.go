@@ -0,0 +1,214 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	v1 "github.com/substratusai/sandboxai/go/api/v1"
+	"github.com/substratusai/sandboxai/go/sandboxaid/client"
+)
+
+func (h *Handler) v1PostVolume(w http.ResponseWriter, r *http.Request) {
+	space := chi.URLParam(r, "space")
+
+	var req v1.CreateVolumeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, r, err, http.StatusBadRequest)
+		return
+	}
+	if err := client.ValidateVolumeName(req.Name); err != nil {
+		sendError(w, r, err, http.StatusBadRequest)
+		return
+	}
+	if err := client.ValidateLabels(req.Labels); err != nil {
+		sendError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	created, err := h.client.CreateVolume(r.Context(), space, &req)
+	if err != nil {
+		if errors.Is(err, client.ErrSpaceNotFound) {
+			sendError(w, r, err, http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, client.ErrVolumeAlreadyExists) {
+			sendError(w, r, err, http.StatusConflict)
+			return
+		}
+		if errors.Is(err, client.ErrSnapshotNotFound) || errors.Is(err, client.ErrSnapshotsDisabled) {
+			sendError(w, r, err, http.StatusBadRequest)
+			return
+		}
+		sendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(created); err != nil {
+		sendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *Handler) v1ListVolumes(w http.ResponseWriter, r *http.Request) {
+	space := chi.URLParam(r, "space")
+
+	volumes, err := h.client.ListVolumes(r.Context(), space)
+	if err != nil {
+		if errors.Is(err, client.ErrSpaceNotFound) {
+			sendError(w, r, err, http.StatusNotFound)
+			return
+		}
+		sendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	list := v1.VolumeList{Items: volumes}
+	if list.Items == nil {
+		list.Items = []v1.Volume{}
+	}
+	if err := json.NewEncoder(w).Encode(list); err != nil {
+		sendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *Handler) v1GetVolume(w http.ResponseWriter, r *http.Request) {
+	space := chi.URLParam(r, "space")
+	name := chi.URLParam(r, "name")
+
+	vol, err := h.client.GetVolume(r.Context(), space, name)
+	if err != nil {
+		if errors.Is(err, client.ErrVolumeNotFound) {
+			sendError(w, r, err, http.StatusNotFound)
+			return
+		}
+		sendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(vol); err != nil {
+		sendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *Handler) v1DeleteVolume(w http.ResponseWriter, r *http.Request) {
+	space := chi.URLParam(r, "space")
+	name := chi.URLParam(r, "name")
+
+	if err := h.client.DeleteVolume(r.Context(), space, name); err != nil {
+		if errors.Is(err, client.ErrVolumeNotFound) {
+			sendError(w, r, err, http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, client.ErrVolumeInUse) {
+			sendError(w, r, err, http.StatusConflict)
+			return
+		}
+		sendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) v1PostSnapshot(w http.ResponseWriter, r *http.Request) {
+	space := chi.URLParam(r, "space")
+	name := chi.URLParam(r, "name")
+
+	var req v1.CreateSnapshotRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, r, err, http.StatusBadRequest)
+		return
+	}
+	if req.Name != "" {
+		if err := client.ValidateSnapshotName(req.Name); err != nil {
+			sendError(w, r, err, http.StatusBadRequest)
+			return
+		}
+	}
+	if err := client.ValidateLabels(req.Labels); err != nil {
+		sendError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	created, err := h.client.SnapshotVolume(r.Context(), space, name, &req)
+	if err != nil {
+		if errors.Is(err, client.ErrVolumeNotFound) {
+			sendError(w, r, err, http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, client.ErrSnapshotAlreadyExists) {
+			sendError(w, r, err, http.StatusConflict)
+			return
+		}
+		if errors.Is(err, client.ErrSnapshotsDisabled) {
+			sendError(w, r, err, http.StatusBadRequest)
+			return
+		}
+		sendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(created); err != nil {
+		sendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *Handler) v1ListSnapshots(w http.ResponseWriter, r *http.Request) {
+	space := chi.URLParam(r, "space")
+
+	snapshots, err := h.client.ListSnapshots(r.Context(), space, r.URL.Query().Get("volume"))
+	if err != nil {
+		if errors.Is(err, client.ErrSpaceNotFound) {
+			sendError(w, r, err, http.StatusNotFound)
+			return
+		}
+		sendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	list := v1.SnapshotList{Items: snapshots}
+	if list.Items == nil {
+		list.Items = []v1.Snapshot{}
+	}
+	if err := json.NewEncoder(w).Encode(list); err != nil {
+		sendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *Handler) v1GetSnapshot(w http.ResponseWriter, r *http.Request) {
+	space := chi.URLParam(r, "space")
+	name := chi.URLParam(r, "name")
+
+	snapshot, err := h.client.GetSnapshot(r.Context(), space, name)
+	if err != nil {
+		if errors.Is(err, client.ErrSnapshotNotFound) || errors.Is(err, client.ErrSnapshotsDisabled) {
+			sendError(w, r, err, http.StatusNotFound)
+			return
+		}
+		sendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(snapshot); err != nil {
+		sendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *Handler) v1DeleteSnapshot(w http.ResponseWriter, r *http.Request) {
+	space := chi.URLParam(r, "space")
+	name := chi.URLParam(r, "name")
+
+	if err := h.client.DeleteSnapshot(r.Context(), space, name); err != nil {
+		if errors.Is(err, client.ErrSnapshotNotFound) || errors.Is(err, client.ErrSnapshotsDisabled) {
+			sendError(w, r, err, http.StatusNotFound)
+			return
+		}
+		sendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -10,11 +10,16 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
+	v1 "github.com/substratusai/sandboxai/go/api/v1"
+	sclient "github.com/substratusai/sandboxai/go/sandboxaid/client"
 	"github.com/substratusai/sandboxai/go/sandboxaid/client/docker"
+	"github.com/substratusai/sandboxai/go/sandboxaid/egress"
 	"github.com/substratusai/sandboxai/go/sandboxaid/handler"
 )
 
@@ -28,14 +33,55 @@ func main() {
 		port = "5266"
 	}
 	// SCOPE limits the containers that this server will manage.
-	// It does this by labelling containers that it creates with the
-	// scope value.
-	// TODO: This is currently only implemented for the cleanup flow...
-	//       Consider implementing for all operations (i.e. GET/DELETE /sandboxes/{id}).
+	// It does this by labelling (and prefixing the names of) containers
+	// that it creates with the scope value. Containers outside of the
+	// scope are treated as not found by all operations.
 	scope, ok := os.LookupEnv("SANDBOXAID_SCOPE")
 	if !ok {
 		scope = "default"
 	}
+	// PORT_DOMAIN enables host based routing to sandbox ports, for example
+	// "localhost" routes "3000-mybox.default.localhost" to port 3000 of the
+	// sandbox "mybox" in the space "default". Anyone who can reach sandboxaid
+	// can then reach the ports with a Host header, so it is disabled (empty)
+	// by default.
+	portDomain := os.Getenv("SANDBOXAID_PORT_DOMAIN")
+	// EGRESS_PROXY_PORT is the port of the egress proxy for sandboxes with
+	// an egress_allow list or a cassette. It listens on the gateways of the
+	// networks of those sandboxes, which only they can reach. Sandboxes keep
+	// the port through restarts of sandboxaid, so "0" (any free port) only
+	// suits sandboxes that do not outlive it. Set it to an empty string to
+	// disable.
+	egressProxyPort, ok := os.LookupEnv("SANDBOXAID_EGRESS_PROXY_PORT")
+	if !ok {
+		egressProxyPort = "5267"
+	}
+	// DATA_DIR is where sandboxaid keeps data that outlives it, such as
+	// cassettes and volume snapshots. It is shared by all scopes.
+	dataDir, ok := os.LookupEnv("SANDBOXAID_DATA_DIR")
+	if !ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			dataDir = filepath.Join(home, ".sandboxai")
+		}
+	}
+	// BIND_MOUNT_ALLOW is a comma separated list of the host directories
+	// that sandboxes can bind mount. Nothing can be bind mounted by default.
+	var bindMountAllow []string
+	for _, dir := range strings.Split(os.Getenv("SANDBOXAID_BIND_MOUNT_ALLOW"), ",") {
+		if dir = strings.TrimSpace(dir); dir != "" {
+			bindMountAllow = append(bindMountAllow, dir)
+		}
+	}
+	// REAP_INTERVAL is how often sandboxes are checked for having passed
+	// their ttl or idle_timeout.
+	reapInterval := 10 * time.Second
+	if val, ok := os.LookupEnv("SANDBOXAID_REAP_INTERVAL"); ok {
+		d, err := time.ParseDuration(val)
+		if err != nil || d <= 0 {
+			log.Fatalf("Invalid SANDBOXAID_REAP_INTERVAL %q", val)
+		}
+		reapInterval = d
+	}
 	var deleteOnShutdown bool
 	if val, ok := os.LookupEnv("SANDBOXAID_DELETE_ON_SHUTDOWN"); ok {
 		deleteOnShutdown = strings.ToLower(strings.TrimSpace(val)) == "true"
@@ -44,12 +90,50 @@ func main() {
 	log := log.New(os.Stderr, "", log.LstdFlags)
 	handler.SetLogger(log)
 	docker.SetLogger(log)
+	egress.SetLogger(log)
 
 	client, err := docker.NewSandboxClient(nil, &http.Client{}, scope)
 	if err != nil {
 		log.Fatalf("Failed to create sandbox client: %v", err)
 	}
 
+	if err := client.SetBindMountAllow(bindMountAllow); err != nil {
+		log.Fatalf("Failed to set allowed bind mount directories: %v", err)
+	}
+	if dataDir != "" {
+		client.SetSnapshotDir(filepath.Join(dataDir, "snapshots", scope))
+	} else {
+		log.Print("No data directory, snapshots are disabled")
+	}
+
+	if egressProxyPort != "" {
+		port, err := strconv.Atoi(egressProxyPort)
+		if err != nil || port < 0 || port > 65535 {
+			log.Fatalf("Invalid SANDBOXAID_EGRESS_PROXY_PORT %q", egressProxyPort)
+		}
+		var cassettes *egress.Cassettes
+		if dataDir != "" {
+			cassettes, err = egress.NewCassettes(filepath.Join(dataDir, "cassettes"), filepath.Join(dataDir, "egress-ca"))
+			if err != nil {
+				log.Fatalf("Failed to set up cassettes: %v", err)
+			}
+			client.SetCassettes(cassettes)
+		} else {
+			log.Print("No data directory, cassettes are disabled")
+		}
+		if err := client.SetEgressProxy(context.Background(), egress.NewProxy(client, cassettes), port); err != nil {
+			log.Fatalf("Failed to start egress proxy: %v", err)
+		}
+	}
+
+	// Ensure the default space exists so that clients can create sandboxes
+	// without first creating a space.
+	if _, err := client.CreateSpace(context.Background(), &v1.CreateSpaceRequest{Name: sclient.DefaultSpace}); err != nil && !errors.Is(err, sclient.ErrSpaceAlreadyExists) {
+		log.Fatalf("Failed to create %q space: %v", sclient.DefaultSpace, err)
+	}
+
+	go client.RunReaper(context.Background(), reapInterval)
+
 	// Cleanup on shutdown if specified (useful for embedded mode).
 	// This is important for handling sandboxes that were created but not yet deleted.
 	// The most likely scenario for this to happen would be when a client launches a
@@ -60,6 +144,24 @@ func main() {
 	// # Ctrl-C
 	// ```
 	if deleteOnShutdown {
+		// Spaces are cleaned up after sandboxes (defers run in reverse order).
+		// Only their sandboxes and networks are deleted, the spaces are kept
+		// along with their volumes and snapshots.
+		defer func() {
+			cleanupCtx, cancelCleanup := context.WithTimeout(context.Background(), 1*time.Minute)
+			defer cancelCleanup()
+			spaces, err := client.ListAllSpaces(cleanupCtx)
+			if err != nil {
+				log.Printf("Cleanup: failed to list spaces: %v", err)
+				return
+			}
+			for _, space := range spaces {
+				if err := client.CleanupSpace(cleanupCtx, space); err != nil {
+					log.Printf("Cleanup: failed to clean up space %q: %v", space, err)
+				}
+			}
+			log.Printf("Cleanup: done cleaning up spaces (total = %d)", len(spaces))
+		}()
 		defer func() {
 			log.Print("Cleanup: ensuring all sandboxes at deleted")
 			cleanupCtx, cancelCleanup := context.WithTimeout(context.Background(), 1*time.Minute)
@@ -84,10 +186,12 @@ func main() {
 		}()
 	}
 
+	h := handler.NewHandler(client, handler.WithPortDomain(portDomain))
 	server := &http.Server{
 		Addr:    fmt.Sprintf("%s:%s", host, port),
-		Handler: handler.NewHandler(client),
+		Handler: h,
 	}
+	server.RegisterOnShutdown(h.CloseStreams)
 
 	go func() {
 		ln, err := net.Listen("tcp", server.Addr)
@@ -3,8 +3,15 @@ package e2e_test
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
@@ -28,10 +35,13 @@ func TestClientV1(t *testing.T) {
 
 	const space = "default"
 	createdSbx, err := c.CreateSandbox(ctx, space, &v1.CreateSandboxRequest{
-		Spec: spec,
+		Labels: map[string]string{"test": t.Name()},
+		Spec:   spec,
 	})
 	require.NoError(t, err, "Creating sandbox")
 	require.NotEmpty(t, createdSbx.Name, "Sandbox name returned from create should not be empty")
+	require.NotNil(t, createdSbx.Status, "Sandbox status returned from create should be set")
+	require.Equal(t, v1.SandboxPhasePending, *createdSbx.Status.Phase, "Sandbox should be pending after create")
 
 	t.Cleanup(func() {
 		t.Logf("Cleanup(): Deleting sandbox (space = %q, name = %q)", space, createdSbx.Name)
@@ -39,9 +49,30 @@ func TestClientV1(t *testing.T) {
 		require.NoError(t, err, "Failed to delete sandbox")
 	})
 
+	readySbx, err := c.WaitForReady(ctx, space, createdSbx.Name)
+	require.NoError(t, err, "Waiting for sandbox to become ready")
+	require.Equal(t, v1.SandboxPhaseReady, *readySbx.Status.Phase)
+	require.NotNil(t, readySbx.Status.ReadyAt, "Sandbox ready time should be set")
+
 	gottenSbx, err := c.GetSandbox(ctx, space, createdSbx.Name)
 	require.NoError(t, err, "Getting sandbox")
-	require.EqualValues(t, createdSbx, gottenSbx, "Sandbox returned from GetSandbox should match that returned from CreateSandbox")
+	require.EqualValues(t, readySbx, gottenSbx, "Sandbox returned from GetSandbox should match that returned from WaitForReady")
+
+	listedSbxs, err := c.ListSandboxes(ctx, space, &v1.ListSandboxesParams{
+		LabelSelector: "test=" + t.Name(),
+	})
+	require.NoError(t, err, "Listing sandboxes")
+	require.Len(t, listedSbxs.Items, 1, "Listing sandboxes by label should return the created sandbox")
+	require.Equal(t, createdSbx.Name, listedSbxs.Items[0].Name)
+	require.Empty(t, listedSbxs.NextPageToken)
+
+	listedSbxs, err = c.ListSandboxes(ctx, space, &v1.ListSandboxesParams{
+		LabelSelector: "!test",
+	})
+	require.NoError(t, err, "Listing sandboxes")
+	for _, sbx := range listedSbxs.Items {
+		require.NotEqual(t, createdSbx.Name, sbx.Name, "Sandbox should not match selector")
+	}
 
 	// IPython Tool //
 
@@ -53,6 +84,7 @@ func TestClientV1(t *testing.T) {
 		ExpectedOutputContains string `json:"expected_output_contains"`
 		ExpectedStdout         string `json:"expected_stdout"`
 		ExpectedStderr         string `json:"expected_stderr"`
+		ExpectedErrorName      string `json:"expected_error_name"`
 	}
 	ipyCasesJSON, err := os.ReadFile(os.Getenv("TEST_IPYTHON_CASES_PATH"))
 	require.NoError(t, err, "reading ipython cases")
@@ -75,18 +107,28 @@ func TestClientV1(t *testing.T) {
 			}
 			require.Equal(t, tc.ExpectedStdout, resp.Stdout, "stdout")
 			require.Equal(t, tc.ExpectedStderr, resp.Stderr, "stderr")
+			require.Equal(t, tc.ExpectedErrorName == "", resp.Success, "success")
+			require.Equal(t, tc.ExpectedErrorName, resp.ErrorName, "error name")
 		})
 	}
 
 	// Shell Command Tool //
 
 	var shellCases []struct {
-		Name           string `json:"name"`
-		Command        string `json:"command"`
-		Split          bool   `json:"split"`
-		ExpectedOutput string `json:"expected_output"`
-		ExpectedStdout string `json:"expected_stdout"`
-		ExpectedStderr string `json:"expected_stderr"`
+		Name             string            `json:"name"`
+		Command          string            `json:"command"`
+		Argv             []string          `json:"argv"`
+		Cwd              string            `json:"cwd"`
+		Env              map[string]string `json:"env"`
+		Stdin            string            `json:"stdin"`
+		User             string            `json:"user"`
+		Split            bool              `json:"split"`
+		ExpectedOutput   string            `json:"expected_output"`
+		ExpectedStdout   string            `json:"expected_stdout"`
+		ExpectedStderr   string            `json:"expected_stderr"`
+		ExpectedExitCode int               `json:"expected_exit_code"`
+		TimeoutSeconds   int               `json:"timeout_seconds"`
+		ExpectedTimedOut bool              `json:"expected_timed_out"`
 	}
 	shellCasesJSON, err := os.ReadFile(os.Getenv("TEST_SHELL_CASES_PATH"))
 	require.NoError(t, err, "reading shell cases")
@@ -96,13 +138,52 @@ func TestClientV1(t *testing.T) {
 	for _, tc := range shellCases {
 		t.Run(tc.Name, func(t *testing.T) {
 			resp, err := c.RunShellCommand(ctx, space, createdSbx.Name, &v1.RunShellCommandRequest{
-				Command:     tc.Command,
-				SplitOutput: tc.Split,
+				Command:        tc.Command,
+				Argv:           tc.Argv,
+				Cwd:            tc.Cwd,
+				Env:            tc.Env,
+				Stdin:          tc.Stdin,
+				User:           tc.User,
+				SplitOutput:    tc.Split,
+				TimeoutSeconds: tc.TimeoutSeconds,
 			})
 			require.NoError(t, err, "Running shell command")
 			require.Equal(t, tc.ExpectedOutput, resp.Output, "output")
 			require.Equal(t, tc.ExpectedStdout, resp.Stdout, "stdout")
 			require.Equal(t, tc.ExpectedStderr, resp.Stderr, "stderr")
+			require.Equal(t, tc.ExpectedExitCode, resp.ExitCode, "exit code")
+			require.Equal(t, tc.ExpectedTimedOut, resp.TimedOut, "timed out")
+		})
+	}
+
+	for _, tc := range shellCases {
+		t.Run("stream/"+tc.Name, func(t *testing.T) {
+			streamed := map[v1.ToolOutputStream]string{}
+			var result *v1.RunShellCommandResult
+			for ev, err := range c.RunShellCommandStream(ctx, space, createdSbx.Name, &v1.RunShellCommandRequest{
+				Command:        tc.Command,
+				Argv:           tc.Argv,
+				Cwd:            tc.Cwd,
+				Env:            tc.Env,
+				Stdin:          tc.Stdin,
+				User:           tc.User,
+				SplitOutput:    tc.Split,
+				TimeoutSeconds: tc.TimeoutSeconds,
+			}) {
+				require.NoError(t, err, "Streaming shell command")
+				switch ev.Type {
+				case v1.ToolStreamEventOutput:
+					streamed[*ev.Stream] += ev.Data
+				case v1.ToolStreamEventResult:
+					result = ev.Result
+				}
+			}
+			require.NotNil(t, result, "result")
+			require.Equal(t, tc.ExpectedExitCode, result.ExitCode, "exit code")
+			require.Equal(t, tc.ExpectedTimedOut, result.TimedOut, "timed out")
+			require.Equal(t, tc.ExpectedOutput, streamed[v1.ToolOutputStreamOutput], "output")
+			require.Equal(t, tc.ExpectedStdout, streamed[v1.ToolOutputStreamStdout], "stdout")
+			require.Equal(t, tc.ExpectedStderr, streamed[v1.ToolOutputStreamStderr], "stderr")
 		})
 	}
 }
@@ -114,3 +195,810 @@ func TestClientV1NoOptions(t *testing.T) {
 	c := clientv1.NewClient(cfg.SandboxAIBaseURL)
 	require.NoError(t, c.CheckHealth(ctx))
 }
+
+func TestClientV1Spaces(t *testing.T) {
+	httpc := &http.Client{Timeout: 60 * time.Second}
+	c := clientv1.NewClient(cfg.SandboxAIBaseURL, clientv1.WithHTTPClient(httpc))
+
+	const space = "e2e-spaces"
+	createdSpace, err := c.CreateSpace(ctx, &v1.CreateSpaceRequest{
+		Name: space,
+		Spec: &v1.SpaceSpec{Description: "Created by e2e tests"},
+	})
+	require.NoError(t, err, "Creating space")
+	require.Equal(t, space, createdSpace.Name)
+
+	gottenSpace, err := c.GetSpace(ctx, space)
+	require.NoError(t, err, "Getting space")
+	require.Equal(t, "Created by e2e tests", gottenSpace.Spec.Description)
+
+	sbx, err := c.CreateSandbox(ctx, space, &v1.CreateSandboxRequest{
+		Spec: v1.SandboxSpec{Image: cfg.BoxImage},
+	})
+	require.NoError(t, err, "Creating sandbox in space")
+
+	_, err = c.GetSandbox(ctx, "default", sbx.Name)
+	require.ErrorIs(t, err, clientv1.ErrSandboxNotFound, "Sandbox should not be visible from another space")
+
+	require.NoError(t, c.DeleteSpace(ctx, space), "Deleting space")
+
+	_, err = c.GetSpace(ctx, space)
+	require.ErrorIs(t, err, clientv1.ErrSpaceNotFound)
+	_, err = c.GetSandbox(ctx, space, sbx.Name)
+	require.ErrorIs(t, err, clientv1.ErrSandboxNotFound, "Deleting a space should delete its sandboxes")
+
+	_, err = c.CreateSandbox(ctx, space, &v1.CreateSandboxRequest{
+		Spec: v1.SandboxSpec{Image: cfg.BoxImage},
+	})
+	require.Error(t, err, "Creating a sandbox in a deleted space should fail")
+}
+
+func TestClientV1Files(t *testing.T) {
+	httpc := &http.Client{Timeout: 60 * time.Second}
+	c := clientv1.NewClient(cfg.SandboxAIBaseURL, clientv1.WithHTTPClient(httpc))
+
+	const space = "default"
+	sbx, err := c.CreateSandbox(ctx, space, &v1.CreateSandboxRequest{
+		Spec: v1.SandboxSpec{Image: cfg.BoxImage},
+	})
+	require.NoError(t, err, "Creating sandbox")
+	t.Cleanup(func() {
+		require.NoError(t, c.DeleteSandbox(context.Background(), space, sbx.Name), "Deleting sandbox")
+	})
+	_, err = c.WaitForReady(ctx, space, sbx.Name)
+	require.NoError(t, err, "Waiting for sandbox to become ready")
+
+	// Binary content with a NUL byte and invalid UTF-8.
+	content := []byte{0x00, 0xff, 'h', 'i', '\n'}
+	written, err := c.WriteFile(ctx, space, sbx.Name, &v1.WriteFileRequest{
+		Path:    "/tmp/e2e/nested/data.bin",
+		Content: content,
+		Mode:    0o600,
+	})
+	require.NoError(t, err, "Writing file")
+	require.Equal(t, v1.FileTypeFile, written.Info.Type)
+	require.EqualValues(t, len(content), written.Info.Size)
+	require.EqualValues(t, 0o600, written.Info.Mode)
+
+	read, err := c.ReadFile(ctx, space, sbx.Name, &v1.ReadFileRequest{Path: "/tmp/e2e/nested/data.bin"})
+	require.NoError(t, err, "Reading file")
+	require.Equal(t, content, read.Content)
+
+	listed, err := c.ListDir(ctx, space, sbx.Name, &v1.ListDirRequest{Path: "/tmp/e2e"})
+	require.NoError(t, err, "Listing directory")
+	require.Len(t, listed.Entries, 1)
+	require.Equal(t, "nested", listed.Entries[0].Name)
+	require.Equal(t, "/tmp/e2e/nested", listed.Entries[0].Path)
+	require.Equal(t, v1.FileTypeDirectory, listed.Entries[0].Type)
+
+	stat, err := c.Stat(ctx, space, sbx.Name, &v1.StatRequest{Path: "/tmp/e2e/nested"})
+	require.NoError(t, err, "Stat")
+	require.Equal(t, v1.FileTypeDirectory, stat.Info.Type)
+
+	_, err = c.ReadFile(ctx, space, sbx.Name, &v1.ReadFileRequest{Path: "/tmp/e2e/missing"})
+	require.ErrorIs(t, err, clientv1.ErrPathNotFound)
+
+	err = c.DeletePath(ctx, space, sbx.Name, &v1.DeletePathRequest{Path: "/tmp/e2e"})
+	require.Error(t, err, "Deleting a non-empty directory without recursive should fail")
+	require.NoError(t, c.DeletePath(ctx, space, sbx.Name, &v1.DeletePathRequest{Path: "/tmp/e2e", Recursive: true}), "Deleting directory")
+	_, err = c.Stat(ctx, space, sbx.Name, &v1.StatRequest{Path: "/tmp/e2e"})
+	require.ErrorIs(t, err, clientv1.ErrPathNotFound)
+}
+
+func TestClientV1Archive(t *testing.T) {
+	httpc := &http.Client{Timeout: 60 * time.Second}
+	c := clientv1.NewClient(cfg.SandboxAIBaseURL, clientv1.WithHTTPClient(httpc))
+
+	const space = "default"
+	sbx, err := c.CreateSandbox(ctx, space, &v1.CreateSandboxRequest{
+		Spec: v1.SandboxSpec{Image: cfg.BoxImage},
+	})
+	require.NoError(t, err, "Creating sandbox")
+	t.Cleanup(func() {
+		require.NoError(t, c.DeleteSandbox(context.Background(), space, sbx.Name), "Deleting sandbox")
+	})
+	_, err = c.WaitForReady(ctx, space, sbx.Name)
+	require.NoError(t, err, "Waiting for sandbox to become ready")
+
+	src := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(src, "sub"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "a.txt"), []byte("a"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "sub", "b.txt"), []byte("b"), 0o644))
+
+	require.NoError(t, c.UploadDir(ctx, space, sbx.Name, src, "/tmp/e2e-archive"), "Uploading directory")
+
+	read, err := c.ReadFile(ctx, space, sbx.Name, &v1.ReadFileRequest{Path: "/tmp/e2e-archive/sub/b.txt"})
+	require.NoError(t, err, "Reading uploaded file")
+	require.Equal(t, []byte("b"), read.Content)
+
+	dst := t.TempDir()
+	require.NoError(t, c.DownloadDir(ctx, space, sbx.Name, "/tmp/e2e-archive", dst), "Downloading directory")
+	content, err := os.ReadFile(filepath.Join(dst, "a.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "a", string(content))
+	content, err = os.ReadFile(filepath.Join(dst, "sub", "b.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "b", string(content))
+
+	err = c.DownloadDir(ctx, space, sbx.Name, "/tmp/missing", dst)
+	require.ErrorIs(t, err, clientv1.ErrPathNotFound)
+}
+
+func TestClientV1Processes(t *testing.T) {
+	httpc := &http.Client{Timeout: 60 * time.Second}
+	c := clientv1.NewClient(cfg.SandboxAIBaseURL, clientv1.WithHTTPClient(httpc))
+
+	const space = "default"
+	sbx, err := c.CreateSandbox(ctx, space, &v1.CreateSandboxRequest{
+		Spec: v1.SandboxSpec{Image: cfg.BoxImage},
+	})
+	require.NoError(t, err, "Creating sandbox")
+	t.Cleanup(func() {
+		require.NoError(t, c.DeleteSandbox(context.Background(), space, sbx.Name), "Deleting sandbox")
+	})
+	_, err = c.WaitForReady(ctx, space, sbx.Name)
+	require.NoError(t, err, "Waiting for sandbox to become ready")
+
+	proc, err := c.CreateProcess(ctx, space, sbx.Name, &v1.CreateProcessRequest{
+		Spec: v1.ProcessSpec{Command: "echo started; echo oops >&2; trap 'echo stopping; exit 3' TERM; while true; do sleep 0.1; done"},
+	})
+	require.NoError(t, err, "Creating process")
+	require.NotEmpty(t, proc.ID)
+	require.Equal(t, v1.ProcessStateRunning, proc.Status.State)
+
+	require.Eventually(t, func() bool {
+		output, err := c.GetProcessOutput(ctx, space, sbx.Name, proc.ID, nil)
+		require.NoError(t, err)
+		return output.Data == "started\n"
+	}, 10*time.Second, 100*time.Millisecond, "Waiting for stdout")
+	stderr, err := c.GetProcessOutput(ctx, space, sbx.Name, proc.ID, &v1.GetProcessOutputParams{Stream: v1.GetProcessOutputParamsStreamStderr})
+	require.NoError(t, err)
+	require.Equal(t, "oops\n", stderr.Data)
+
+	list, err := c.ListProcesses(ctx, space, sbx.Name)
+	require.NoError(t, err)
+	require.Len(t, list.Items, 1)
+
+	_, err = c.SignalProcess(ctx, space, sbx.Name, proc.ID, &v1.SignalProcessRequest{Signal: "SIGTERM"})
+	require.NoError(t, err, "Signaling process")
+	require.Eventually(t, func() bool {
+		proc, err = c.GetProcess(ctx, space, sbx.Name, proc.ID)
+		require.NoError(t, err)
+		return proc.Status.State == v1.ProcessStateExited
+	}, 10*time.Second, 100*time.Millisecond, "Waiting for exit")
+	require.Equal(t, 3, *proc.Status.ExitCode)
+
+	output, err := c.GetProcessOutput(ctx, space, sbx.Name, proc.ID, &v1.GetProcessOutputParams{Offset: int64(len("started\n"))})
+	require.NoError(t, err)
+	require.Equal(t, "stopping\n", output.Data)
+	require.True(t, output.EOF)
+
+	sleeper, err := c.CreateProcess(ctx, space, sbx.Name, &v1.CreateProcessRequest{
+		Spec: v1.ProcessSpec{Argv: []string{"sleep", "600"}},
+	})
+	require.NoError(t, err, "Creating process")
+	killed, err := c.KillProcess(ctx, space, sbx.Name, sleeper.ID)
+	require.NoError(t, err, "Killing process")
+	require.Equal(t, v1.ProcessStateExited, killed.Status.State)
+	require.Equal(t, 128+9, *killed.Status.ExitCode)
+
+	_, err = c.GetProcess(ctx, space, sbx.Name, "missing")
+	require.ErrorIs(t, err, clientv1.ErrProcessNotFound)
+}
+
+func TestClientV1Terminal(t *testing.T) {
+	httpc := &http.Client{Timeout: 60 * time.Second}
+	c := clientv1.NewClient(cfg.SandboxAIBaseURL, clientv1.WithHTTPClient(httpc))
+
+	const space = "default"
+	sbx, err := c.CreateSandbox(ctx, space, &v1.CreateSandboxRequest{
+		Spec: v1.SandboxSpec{Image: cfg.BoxImage},
+	})
+	require.NoError(t, err, "Creating sandbox")
+	t.Cleanup(func() {
+		require.NoError(t, c.DeleteSandbox(context.Background(), space, sbx.Name), "Deleting sandbox")
+	})
+	_, err = c.WaitForReady(ctx, space, sbx.Name)
+	require.NoError(t, err, "Waiting for sandbox to become ready")
+
+	term, err := c.DialTerminal(ctx, space, sbx.Name, &v1.AttachTerminalParams{Rows: 24, Cols: 80})
+	require.NoError(t, err, "Opening terminal")
+	defer term.Close()
+
+	require.NoError(t, term.Resize(30, 100), "Resizing terminal")
+	_, err = term.Write([]byte("stty size; echo hello-$((1+2)); exit 5\n"))
+	require.NoError(t, err, "Writing input")
+
+	output, err := io.ReadAll(term)
+	require.NoError(t, err, "Reading output")
+	require.Contains(t, string(output), "30 100")
+	require.Contains(t, string(output), "hello-3")
+	exitCode, exited := term.ExitCode()
+	require.True(t, exited)
+	require.Equal(t, 5, exitCode)
+
+	_, err = c.DialTerminal(ctx, space, "missing", nil)
+	require.ErrorIs(t, err, clientv1.ErrSandboxNotFound)
+}
+
+func TestClientV1Ports(t *testing.T) {
+	httpc := &http.Client{Timeout: 60 * time.Second}
+	c := clientv1.NewClient(cfg.SandboxAIBaseURL, clientv1.WithHTTPClient(httpc))
+
+	const space = "default"
+	sbx, err := c.CreateSandbox(ctx, space, &v1.CreateSandboxRequest{
+		Spec: v1.SandboxSpec{Image: cfg.BoxImage},
+	})
+	require.NoError(t, err, "Creating sandbox")
+	t.Cleanup(func() {
+		require.NoError(t, c.DeleteSandbox(context.Background(), space, sbx.Name), "Deleting sandbox")
+	})
+	_, err = c.WaitForReady(ctx, space, sbx.Name)
+	require.NoError(t, err, "Waiting for sandbox to become ready")
+
+	portURL := fmt.Sprintf("%s/spaces/%s/sandboxes/%s/ports/3000", cfg.SandboxAIBaseURL, space, sbx.Name)
+
+	resp, err := httpc.Get(portURL + "/")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusBadGateway, resp.StatusCode, "Nothing is listening yet")
+
+	// Only listen on the loopback interface of the sandbox.
+	_, err = c.WriteFile(ctx, space, sbx.Name, &v1.WriteFileRequest{Path: "/tmp/site/hello.txt", Content: []byte("hello from the sandbox")})
+	require.NoError(t, err)
+	_, err = c.CreateProcess(ctx, space, sbx.Name, &v1.CreateProcessRequest{
+		Spec: v1.ProcessSpec{Argv: []string{"python3", "-m", "http.server", "3000", "--bind", "127.0.0.1", "--directory", "/tmp/site"}},
+	})
+	require.NoError(t, err, "Starting server")
+
+	require.Eventually(t, func() bool {
+		resp, err := httpc.Get(portURL + "/hello.txt")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode == http.StatusOK && string(body) == "hello from the sandbox"
+	}, 30*time.Second, 200*time.Millisecond, "Waiting for the server to respond through the proxy")
+}
+
+func TestClientV1PortForward(t *testing.T) {
+	httpc := &http.Client{Timeout: 60 * time.Second}
+	c := clientv1.NewClient(cfg.SandboxAIBaseURL, clientv1.WithHTTPClient(httpc))
+
+	const space = "default"
+	sbx, err := c.CreateSandbox(ctx, space, &v1.CreateSandboxRequest{
+		Spec: v1.SandboxSpec{Image: cfg.BoxImage},
+	})
+	require.NoError(t, err, "Creating sandbox")
+	t.Cleanup(func() {
+		require.NoError(t, c.DeleteSandbox(context.Background(), space, sbx.Name), "Deleting sandbox")
+	})
+	_, err = c.WaitForReady(ctx, space, sbx.Name)
+	require.NoError(t, err, "Waiting for sandbox to become ready")
+
+	_, err = c.DialPort(ctx, space, sbx.Name, 3000)
+	require.ErrorIs(t, err, clientv1.ErrPortNotListening, "Nothing is listening yet")
+
+	_, err = c.WriteFile(ctx, space, sbx.Name, &v1.WriteFileRequest{Path: "/tmp/site/hello.txt", Content: []byte("hello from the sandbox")})
+	require.NoError(t, err)
+	_, err = c.CreateProcess(ctx, space, sbx.Name, &v1.CreateProcessRequest{
+		Spec: v1.ProcessSpec{Argv: []string{"python3", "-m", "http.server", "3000", "--bind", "127.0.0.1", "--directory", "/tmp/site"}},
+	})
+	require.NoError(t, err, "Starting server")
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	fwdCtx, cancel := context.WithCancel(ctx)
+	served := make(chan error, 1)
+	go func() { served <- c.ServePortForward(fwdCtx, space, sbx.Name, 3000, ln) }()
+
+	localURL := fmt.Sprintf("http://%s/hello.txt", ln.Addr())
+	require.Eventually(t, func() bool {
+		resp, err := httpc.Get(localURL)
+		if err != nil {
+			// The connection is closed while the server is starting.
+			return false
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode == http.StatusOK && string(body) == "hello from the sandbox"
+	}, 30*time.Second, 200*time.Millisecond, "Waiting for the server to respond through the forwarded port")
+
+	cancel()
+	require.NoError(t, <-served)
+}
+
+func TestClientV1Resources(t *testing.T) {
+	httpc := &http.Client{Timeout: 60 * time.Second}
+	c := clientv1.NewClient(cfg.SandboxAIBaseURL, clientv1.WithHTTPClient(httpc))
+
+	const space = "e2e-resources"
+	_, err := c.CreateSpace(ctx, &v1.CreateSpaceRequest{
+		Name: space,
+		Spec: &v1.SpaceSpec{Resources: &v1.SpaceResources{
+			Default: &v1.SandboxResources{Memory: 256 << 20},
+			Max: &v1.SandboxResources{
+				CPUs:    2,
+				PIDs:    256,
+				Ulimits: []v1.Ulimit{{Name: "nofile", Soft: 4096, Hard: 4096}},
+			},
+		}},
+	})
+	require.NoError(t, err, "Creating space")
+	t.Cleanup(func() {
+		require.NoError(t, c.DeleteSpace(context.Background(), space), "Deleting space")
+	})
+
+	_, err = c.CreateSandbox(ctx, space, &v1.CreateSandboxRequest{
+		Spec: v1.SandboxSpec{Image: cfg.BoxImage, Resources: &v1.SandboxResources{CPUs: 4}},
+	})
+	require.Error(t, err, "Requesting more than the maximum should fail")
+
+	sbx, err := c.CreateSandbox(ctx, space, &v1.CreateSandboxRequest{
+		Spec: v1.SandboxSpec{Image: cfg.BoxImage, Resources: &v1.SandboxResources{CPUs: 0.5}},
+	})
+	require.NoError(t, err, "Creating sandbox")
+	_, err = c.WaitForReady(ctx, space, sbx.Name)
+	require.NoError(t, err, "Waiting for sandbox to become ready")
+
+	gotten, err := c.GetSandbox(ctx, space, sbx.Name)
+	require.NoError(t, err)
+	require.NotNil(t, gotten.Spec.Resources)
+	require.Equal(t, 0.5, gotten.Spec.Resources.CPUs, "Requested")
+	require.EqualValues(t, 256<<20, gotten.Spec.Resources.Memory, "From the space default")
+	require.EqualValues(t, 256, gotten.Spec.Resources.PIDs, "From the space maximum")
+
+	result, err := c.RunShellCommand(ctx, space, sbx.Name, &v1.RunShellCommandRequest{Command: "ulimit -n"})
+	require.NoError(t, err)
+	require.Equal(t, "4096\n", result.Output)
+}
+
+func TestClientV1NetworkModes(t *testing.T) {
+	httpc := &http.Client{Timeout: 60 * time.Second}
+	c := clientv1.NewClient(cfg.SandboxAIBaseURL, clientv1.WithHTTPClient(httpc))
+
+	const space = "e2e-network"
+	_, err := c.CreateSpace(ctx, &v1.CreateSpaceRequest{Name: space})
+	require.NoError(t, err, "Creating space")
+	t.Cleanup(func() {
+		require.NoError(t, c.DeleteSpace(context.Background(), space), "Deleting space")
+	})
+
+	// Exits with 0 if a TCP connection to a public address can be opened.
+	const connectCmd = `python3 -c 'import socket; socket.create_connection(("1.1.1.1", 53), timeout=5)'`
+
+	for _, mode := range []v1.SandboxNetworkMode{v1.NetworkModeNone, v1.NetworkModeInternal} {
+		t.Run(string(mode), func(t *testing.T) {
+			sbx, err := c.CreateSandbox(ctx, space, &v1.CreateSandboxRequest{
+				Spec: v1.SandboxSpec{Image: cfg.BoxImage, Network: &v1.SandboxNetwork{Mode: mode}},
+			})
+			require.NoError(t, err, "Creating sandbox")
+			sbx, err = c.WaitForReady(ctx, space, sbx.Name)
+			require.NoError(t, err, "Waiting for sandbox to become ready")
+			require.NotNil(t, sbx.Spec.Network)
+			require.Equal(t, mode, sbx.Spec.Network.Mode)
+
+			result, err := c.RunShellCommand(ctx, space, sbx.Name, &v1.RunShellCommandRequest{Command: connectCmd})
+			require.NoError(t, err, "Tool calls should work without network access")
+			require.NotEqual(t, 0, result.ExitCode, "Internet should not be reachable: %s", result.Output)
+
+			if mode == v1.NetworkModeNone {
+				result, err := c.RunShellCommand(ctx, space, sbx.Name, &v1.RunShellCommandRequest{Command: "ls /sys/class/net"})
+				require.NoError(t, err)
+				require.Equal(t, "lo", strings.TrimSpace(result.Output), "Only the loopback interface should exist")
+			}
+		})
+	}
+
+	_, err = c.CreateSandbox(ctx, space, &v1.CreateSandboxRequest{
+		Spec: v1.SandboxSpec{Image: cfg.BoxImage, Network: &v1.SandboxNetwork{Mode: "host"}},
+	})
+	require.Error(t, err, "Unsupported modes should be rejected")
+}
+
+func TestClientV1Egress(t *testing.T) {
+	httpc := &http.Client{Timeout: 60 * time.Second}
+	c := clientv1.NewClient(cfg.SandboxAIBaseURL, clientv1.WithHTTPClient(httpc))
+
+	// A stand-in for an upstream server on the internet. The egress proxy
+	// runs on this host so it can reach it on the loopback interface.
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "hello from upstream")
+	}))
+	t.Cleanup(upstream.Close)
+
+	const space = "default"
+	_, err := c.CreateSandbox(ctx, space, &v1.CreateSandboxRequest{
+		Spec: v1.SandboxSpec{
+			Image: cfg.BoxImage,
+			Network: &v1.SandboxNetwork{
+				Mode:        v1.NetworkModeBridge,
+				EgressAllow: []string{"127.0.0.1"},
+			},
+		},
+	})
+	require.Error(t, err, "Bridged sandboxes could bypass the proxy")
+
+	sbx, err := c.CreateSandbox(ctx, space, &v1.CreateSandboxRequest{
+		Spec: v1.SandboxSpec{
+			Image:   cfg.BoxImage,
+			Network: &v1.SandboxNetwork{EgressAllow: []string{"127.0.0.1"}},
+		},
+	})
+	require.NoError(t, err, "Creating sandbox")
+	t.Cleanup(func() {
+		require.NoError(t, c.DeleteSandbox(context.Background(), space, sbx.Name), "Deleting sandbox")
+	})
+	sbx, err = c.WaitForReady(ctx, space, sbx.Name)
+	require.NoError(t, err, "Waiting for sandbox to become ready")
+	require.Equal(t, []string{"127.0.0.1"}, sbx.Spec.Network.EgressAllow)
+	require.Equal(t, v1.NetworkModeInternal, sbx.Spec.Network.Mode, "The default with egress_allow")
+
+	// Connections that do not go through the proxy fail.
+	result, err := c.RunShellCommand(ctx, space, sbx.Name, &v1.RunShellCommandRequest{
+		Command: `python3 -c 'import socket; socket.create_connection(("1.1.1.1", 53), timeout=5)'`,
+	})
+	require.NoError(t, err)
+	require.NotEqual(t, 0, result.ExitCode, "Direct connections should fail: %s", result.Output)
+
+	// NO_PROXY is cleared because the sandbox's own loopback addresses are
+	// not proxied by default.
+	fetch := func(u string) *v1.RunShellCommandResult {
+		result, err := c.RunShellCommand(ctx, space, sbx.Name, &v1.RunShellCommandRequest{
+			Command: fmt.Sprintf(`NO_PROXY= no_proxy= python3 -c 'import urllib.request; print(urllib.request.urlopen("%s").read().decode())'`, u),
+		})
+		require.NoError(t, err)
+		return result
+	}
+
+	result = fetch(upstream.URL + "/")
+	require.Equal(t, 0, result.ExitCode, result.Output)
+	require.Equal(t, "hello from upstream\n", result.Output)
+
+	result = fetch("http://example.com/")
+	require.NotEqual(t, 0, result.ExitCode, "Hosts that are not allowed should be denied")
+	require.Contains(t, result.Output, "403")
+
+	list, err := c.ListEgressConnections(ctx, space, sbx.Name)
+	require.NoError(t, err)
+	require.Len(t, list.Items, 2)
+	require.True(t, list.Items[0].Allowed)
+	require.Equal(t, "127.0.0.1", list.Items[0].Host)
+	require.False(t, list.Items[1].Allowed)
+	require.Equal(t, "example.com", list.Items[1].Host)
+}
+
+func TestClientV1Cassette(t *testing.T) {
+	httpc := &http.Client{Timeout: 60 * time.Second}
+	c := clientv1.NewClient(cfg.SandboxAIBaseURL, clientv1.WithHTTPClient(httpc))
+
+	// A stand-in for an upstream server on the internet.
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "recorded at %d", time.Now().UnixNano())
+	}))
+	defer upstream.Close()
+
+	const space = "default"
+	cassette := fmt.Sprintf("e2e-%d", time.Now().UnixNano())
+	run := func() (v1.SandboxCassetteMode, string) {
+		sbx, err := c.CreateSandbox(ctx, space, &v1.CreateSandboxRequest{
+			Spec: v1.SandboxSpec{
+				Image: cfg.BoxImage,
+				Network: &v1.SandboxNetwork{
+					EgressAllow: []string{"127.0.0.1"},
+					Cassette:    &v1.SandboxCassette{Name: cassette},
+				},
+			},
+		})
+		require.NoError(t, err, "Creating sandbox")
+		defer func() {
+			require.NoError(t, c.DeleteSandbox(context.Background(), space, sbx.Name), "Deleting sandbox")
+		}()
+		sbx, err = c.WaitForReady(ctx, space, sbx.Name)
+		require.NoError(t, err, "Waiting for sandbox to become ready")
+		require.Equal(t, v1.NetworkModeInternal, sbx.Spec.Network.Mode, "Cassettes are only hermetic on internal networks")
+
+		result, err := c.RunShellCommand(ctx, space, sbx.Name, &v1.RunShellCommandRequest{
+			Command: fmt.Sprintf(`NO_PROXY= no_proxy= python3 -c 'import urllib.request; print(urllib.request.urlopen("%s/").read().decode())'`, upstream.URL),
+		})
+		require.NoError(t, err)
+		require.Equal(t, 0, result.ExitCode, result.Output)
+		return sbx.Spec.Network.Cassette.Mode, result.Output
+	}
+
+	mode, recorded := run()
+	require.Equal(t, v1.CassetteModeRecord, mode)
+
+	// Replaying does not connect to the upstream server.
+	upstream.Close()
+	mode, replayed := run()
+	require.Equal(t, v1.CassetteModeReplay, mode)
+	require.Equal(t, recorded, replayed)
+}
+
+func TestClientV1Volumes(t *testing.T) {
+	httpc := &http.Client{Timeout: 60 * time.Second}
+	c := clientv1.NewClient(cfg.SandboxAIBaseURL, clientv1.WithHTTPClient(httpc))
+
+	const space = "e2e-volumes"
+	_, err := c.CreateSpace(ctx, &v1.CreateSpaceRequest{Name: space})
+	require.NoError(t, err, "Creating space")
+	t.Cleanup(func() {
+		require.NoError(t, c.DeleteSpace(context.Background(), space), "Deleting space")
+	})
+
+	vol, err := c.CreateVolume(ctx, space, &v1.CreateVolumeRequest{Name: "data", Labels: map[string]string{"purpose": "e2e"}})
+	require.NoError(t, err, "Creating volume")
+	require.Equal(t, "data", vol.Name)
+	require.Empty(t, vol.Status.MountedBy)
+	_, err = c.CreateVolume(ctx, space, &v1.CreateVolumeRequest{Name: "data"})
+	require.ErrorIs(t, err, clientv1.ErrVolumeAlreadyExists)
+
+	run := func(command string, readOnly bool) (*v1.Sandbox, *v1.RunShellCommandResult) {
+		sbx, err := c.CreateSandbox(ctx, space, &v1.CreateSandboxRequest{
+			Spec: v1.SandboxSpec{
+				Image:  cfg.BoxImage,
+				Mounts: []v1.Mount{{Type: v1.MountTypeVolume, Source: "data", Target: "/data", ReadOnly: readOnly}},
+			},
+		})
+		require.NoError(t, err, "Creating sandbox")
+		_, err = c.WaitForReady(ctx, space, sbx.Name)
+		require.NoError(t, err, "Waiting for sandbox to become ready")
+		result, err := c.RunShellCommand(ctx, space, sbx.Name, &v1.RunShellCommandRequest{Command: command})
+		require.NoError(t, err)
+		return sbx, result
+	}
+
+	sbx, result := run("echo persisted > /data/file", false)
+	require.Equal(t, 0, result.ExitCode, result.Output)
+	require.Equal(t, []v1.Mount{{Type: v1.MountTypeVolume, Source: "data", Target: "/data"}}, sbx.Spec.Mounts)
+
+	vol, err = c.GetVolume(ctx, space, "data")
+	require.NoError(t, err)
+	require.Equal(t, []string{sbx.Name}, vol.Status.MountedBy)
+	require.ErrorIs(t, c.DeleteVolume(ctx, space, "data"), clientv1.ErrVolumeInUse, "Mounted volumes cannot be deleted")
+
+	require.NoError(t, c.DeleteSandbox(ctx, space, sbx.Name), "Deleting sandbox")
+
+	sbx, result = run("cat /data/file && touch /data/other", true)
+	require.Equal(t, "persisted\n", strings.SplitAfter(result.Output, "\n")[0], "Data should outlive the sandbox")
+	require.NotEqual(t, 0, result.ExitCode, "Read-only mounts cannot be written")
+	require.NoError(t, c.DeleteSandbox(ctx, space, sbx.Name), "Deleting sandbox")
+
+	list, err := c.ListVolumes(ctx, space)
+	require.NoError(t, err)
+	require.Len(t, list.Items, 1)
+	require.Equal(t, map[string]string{"purpose": "e2e"}, list.Items[0].Labels)
+
+	_, err = c.CreateSandbox(ctx, space, &v1.CreateSandboxRequest{
+		Spec: v1.SandboxSpec{
+			Image:  cfg.BoxImage,
+			Mounts: []v1.Mount{{Type: v1.MountTypeBind, Source: "/etc", Target: "/host-etc"}},
+		},
+	})
+	require.Error(t, err, "Host paths must be allowed")
+
+	require.NoError(t, c.DeleteVolume(ctx, space, "data"))
+	_, err = c.GetVolume(ctx, space, "data")
+	require.ErrorIs(t, err, clientv1.ErrVolumeNotFound)
+}
+
+func TestClientV1Snapshots(t *testing.T) {
+	httpc := &http.Client{Timeout: 60 * time.Second}
+	c := clientv1.NewClient(cfg.SandboxAIBaseURL, clientv1.WithHTTPClient(httpc))
+
+	const space = "e2e-snapshots"
+	_, err := c.CreateSpace(ctx, &v1.CreateSpaceRequest{Name: space})
+	require.NoError(t, err, "Creating space")
+	t.Cleanup(func() {
+		require.NoError(t, c.DeleteSpace(context.Background(), space), "Deleting space")
+	})
+
+	_, err = c.CreateVolume(ctx, space, &v1.CreateVolumeRequest{Name: "data"})
+	require.NoError(t, err, "Creating volume")
+
+	run := func(volume, command string) {
+		sbx, err := c.CreateSandbox(ctx, space, &v1.CreateSandboxRequest{
+			Spec: v1.SandboxSpec{
+				Image:  cfg.BoxImage,
+				Mounts: []v1.Mount{{Type: v1.MountTypeVolume, Source: volume, Target: "/data"}},
+			},
+		})
+		require.NoError(t, err, "Creating sandbox")
+		_, err = c.WaitForReady(ctx, space, sbx.Name)
+		require.NoError(t, err, "Waiting for sandbox to become ready")
+		result, err := c.RunShellCommand(ctx, space, sbx.Name, &v1.RunShellCommandRequest{Command: command})
+		require.NoError(t, err)
+		require.Equal(t, 0, result.ExitCode, result.Output)
+		require.NoError(t, c.DeleteSandbox(ctx, space, sbx.Name), "Deleting sandbox")
+	}
+
+	run("data", "mkdir /data/dir && echo original > /data/dir/file")
+
+	snapshot, err := c.SnapshotVolume(ctx, space, "data", &v1.CreateSnapshotRequest{Name: "v1", Labels: map[string]string{"purpose": "e2e"}})
+	require.NoError(t, err, "Snapshotting volume")
+	require.Equal(t, "v1", snapshot.Name)
+	require.Equal(t, "data", snapshot.Volume)
+	require.Positive(t, snapshot.SizeBytes)
+	_, err = c.SnapshotVolume(ctx, space, "data", &v1.CreateSnapshotRequest{Name: "v1"})
+	require.ErrorIs(t, err, clientv1.ErrSnapshotAlreadyExists)
+	_, err = c.SnapshotVolume(ctx, space, "missing", &v1.CreateSnapshotRequest{})
+	require.ErrorIs(t, err, clientv1.ErrVolumeNotFound)
+
+	run("data", "echo modified > /data/dir/file")
+
+	generated, err := c.SnapshotVolume(ctx, space, "data", &v1.CreateSnapshotRequest{})
+	require.NoError(t, err, "Snapshotting volume with a generated name")
+	require.True(t, strings.HasPrefix(generated.Name, "data-"), generated.Name)
+
+	list, err := c.ListSnapshots(ctx, space, "data")
+	require.NoError(t, err)
+	require.Len(t, list.Items, 2)
+	require.Equal(t, "v1", list.Items[0].Name, "Snapshots should be listed oldest first")
+	list, err = c.ListSnapshots(ctx, space, "other")
+	require.NoError(t, err)
+	require.Empty(t, list.Items)
+
+	_, err = c.CreateVolume(ctx, space, &v1.CreateVolumeRequest{Name: "restored", Snapshot: "v1"})
+	require.NoError(t, err, "Creating volume from snapshot")
+	run("restored", `test "$(cat /data/dir/file)" = original`)
+
+	_, err = c.CreateVolume(ctx, space, &v1.CreateVolumeRequest{Name: "missing", Snapshot: "missing"})
+	require.Error(t, err, "Snapshots must exist")
+
+	require.NoError(t, c.DeleteSnapshot(ctx, space, "v1"))
+	_, err = c.GetSnapshot(ctx, space, "v1")
+	require.ErrorIs(t, err, clientv1.ErrSnapshotNotFound)
+	_, err = c.GetSnapshot(ctx, space, generated.Name)
+	require.NoError(t, err)
+}
+
+func TestClientV1Expiry(t *testing.T) {
+	httpc := &http.Client{Timeout: 60 * time.Second}
+	c := clientv1.NewClient(cfg.SandboxAIBaseURL, clientv1.WithHTTPClient(httpc))
+
+	const space = "e2e-expiry"
+	_, err := c.CreateSpace(ctx, &v1.CreateSpaceRequest{Name: space})
+	require.NoError(t, err, "Creating space")
+	t.Cleanup(func() {
+		require.NoError(t, c.DeleteSpace(context.Background(), space), "Deleting space")
+	})
+
+	watchCtx, cancelWatch := context.WithTimeout(ctx, time.Minute)
+	defer cancelWatch()
+	events := make(chan *v1.SandboxEvent)
+	go func() {
+		defer close(events)
+		for ev, err := range c.WatchSandboxes(watchCtx, space, nil) {
+			if err != nil {
+				return
+			}
+			select {
+			case events <- ev:
+			case <-watchCtx.Done():
+				return
+			}
+		}
+	}()
+	// Give the watch time to connect.
+	time.Sleep(time.Second)
+
+	sbx, err := c.CreateSandbox(ctx, space, &v1.CreateSandboxRequest{
+		Spec: v1.SandboxSpec{Image: cfg.BoxImage, TTL: "1h", IdleTimeout: "3s"},
+	})
+	require.NoError(t, err, "Creating sandbox")
+	require.Equal(t, "1h0m0s", sbx.Spec.TTL)
+	require.Equal(t, "3s", sbx.Spec.IdleTimeout)
+	sbx, err = c.WaitForReady(ctx, space, sbx.Name)
+	require.NoError(t, err, "Waiting for sandbox to become ready")
+	require.NotNil(t, sbx.Status.ExpiresAt)
+	require.WithinDuration(t, time.Now().Add(3*time.Second), *sbx.Status.ExpiresAt, 3*time.Second)
+
+	var expired *v1.SandboxEvent
+	for ev := range events {
+		if ev.Name == sbx.Name && ev.Type == v1.SandboxEventExpired {
+			expired = ev
+			break
+		}
+	}
+	require.NotNil(t, expired, "Expected an Expired event")
+	require.Equal(t, "IdleTimeout", expired.Reason)
+
+	require.Eventually(t, func() bool {
+		list, err := c.ListSandboxes(ctx, space, nil)
+		require.NoError(t, err)
+		return !slices.ContainsFunc(list.Items, func(item v1.Sandbox) bool { return item.Name == sbx.Name })
+	}, 30*time.Second, time.Second, "Expired sandboxes should be deleted")
+
+	// Deleted expired sandboxes can still be gotten for a while.
+	gotten, err := c.GetSandbox(ctx, space, sbx.Name)
+	require.NoError(t, err)
+	require.Equal(t, v1.SandboxPhaseExpired, *gotten.Status.Phase)
+	require.Equal(t, "IdleTimeout", gotten.Status.Reason)
+	_, err = c.RunShellCommand(ctx, space, sbx.Name, &v1.RunShellCommandRequest{Command: "true"})
+	require.ErrorIs(t, err, clientv1.ErrSandboxNotFound, "Expired sandboxes do not serve tool calls")
+
+	_, err = c.CreateSandbox(ctx, space, &v1.CreateSandboxRequest{
+		Spec: v1.SandboxSpec{Image: cfg.BoxImage, TTL: "soon"},
+	})
+	require.Error(t, err, "Invalid durations should be rejected")
+}
+
+func TestClientV1PauseResume(t *testing.T) {
+	httpc := &http.Client{Timeout: 60 * time.Second}
+	c := clientv1.NewClient(cfg.SandboxAIBaseURL, clientv1.WithHTTPClient(httpc))
+
+	const space = "default"
+	sbx, err := c.CreateSandbox(ctx, space, &v1.CreateSandboxRequest{Spec: v1.SandboxSpec{Image: cfg.BoxImage}})
+	require.NoError(t, err, "Creating sandbox")
+	t.Cleanup(func() {
+		require.NoError(t, c.DeleteSandbox(context.Background(), space, sbx.Name), "Deleting paused sandbox")
+	})
+	_, err = c.WaitForReady(ctx, space, sbx.Name)
+	require.NoError(t, err, "Waiting for sandbox to become ready")
+
+	result, err := c.RunShellCommand(ctx, space, sbx.Name, &v1.RunShellCommandRequest{Command: "echo before > /tmp/state"})
+	require.NoError(t, err)
+	require.Equal(t, 0, result.ExitCode, result.Output)
+
+	paused, err := c.PauseSandbox(ctx, space, sbx.Name)
+	require.NoError(t, err, "Pausing sandbox")
+	require.Equal(t, v1.SandboxPhasePaused, *paused.Status.Phase)
+	_, err = c.PauseSandbox(ctx, space, sbx.Name)
+	require.NoError(t, err, "Pausing a paused sandbox is not an error")
+
+	_, err = c.RunShellCommand(ctx, space, sbx.Name, &v1.RunShellCommandRequest{Command: "true"})
+	require.ErrorIs(t, err, clientv1.ErrSandboxPaused)
+	_, err = c.ReadFile(ctx, space, sbx.Name, &v1.ReadFileRequest{Path: "/tmp/state"})
+	require.ErrorIs(t, err, clientv1.ErrSandboxPaused)
+
+	resumed, err := c.ResumeSandbox(ctx, space, sbx.Name)
+	require.NoError(t, err, "Resuming sandbox")
+	require.Equal(t, v1.SandboxPhaseReady, *resumed.Status.Phase)
+
+	result, err = c.RunShellCommand(ctx, space, sbx.Name, &v1.RunShellCommandRequest{Command: "cat /tmp/state"})
+	require.NoError(t, err)
+	require.Equal(t, "before\n", result.Output, "State should survive a pause")
+
+	_, err = c.PauseSandbox(ctx, space, sbx.Name)
+	require.NoError(t, err, "Pausing sandbox before deleting it")
+}
+
+func TestClientV1IdleStop(t *testing.T) {
+	httpc := &http.Client{Timeout: 60 * time.Second}
+	c := clientv1.NewClient(cfg.SandboxAIBaseURL, clientv1.WithHTTPClient(httpc))
+
+	const space = "default"
+	sbx, err := c.CreateSandbox(ctx, space, &v1.CreateSandboxRequest{
+		Spec: v1.SandboxSpec{Image: cfg.BoxImage, IdleTimeout: "3s", IdlePolicy: v1.IdlePolicyStop},
+	})
+	require.NoError(t, err, "Creating sandbox")
+	require.Equal(t, v1.IdlePolicyStop, sbx.Spec.IdlePolicy)
+	t.Cleanup(func() {
+		require.NoError(t, c.DeleteSandbox(context.Background(), space, sbx.Name), "Deleting sandbox")
+	})
+	_, err = c.WaitForReady(ctx, space, sbx.Name)
+	require.NoError(t, err, "Waiting for sandbox to become ready")
+
+	result, err := c.RunShellCommand(ctx, space, sbx.Name, &v1.RunShellCommandRequest{Command: "echo before > /tmp/state"})
+	require.NoError(t, err)
+	require.Equal(t, 0, result.ExitCode, result.Output)
+
+	require.Eventually(t, func() bool {
+		sbx, err := c.GetSandbox(ctx, space, sbx.Name)
+		require.NoError(t, err, "Idle sandboxes should be stopped, not deleted")
+		return *sbx.Status.Phase == v1.SandboxPhaseStopped && sbx.Status.Reason == "IdleStopped"
+	}, 30*time.Second, time.Second, "Idle sandbox should be stopped")
+
+	result, err = c.RunShellCommand(ctx, space, sbx.Name, &v1.RunShellCommandRequest{Command: "cat /tmp/state"})
+	require.NoError(t, err, "Tool calls should start a stopped sandbox")
+	require.Equal(t, "before\n", result.Output, "The filesystem should survive a stop")
+
+	started, err := c.GetSandbox(ctx, space, sbx.Name)
+	require.NoError(t, err)
+	require.Equal(t, v1.SandboxPhaseReady, *started.Status.Phase)
+
+	_, err = c.CreateSandbox(ctx, space, &v1.CreateSandboxRequest{
+		Spec: v1.SandboxSpec{Image: cfg.BoxImage, IdlePolicy: v1.IdlePolicyStop},
+	})
+	require.Error(t, err, "An idle_policy without an idle_timeout should be rejected")
+}
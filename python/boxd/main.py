@@ -1,14 +1,45 @@
-from fastapi import FastAPI, HTTPException
+from fastapi import FastAPI, HTTPException, Request, Response, WebSocket
+from fastapi.responses import JSONResponse, StreamingResponse
 from IPython.core.interactiveshell import InteractiveShell
 from contextlib import redirect_stdout, redirect_stderr
+from datetime import datetime, timezone
+from typing import Dict, Optional
+import asyncio
+import codecs
+import ctypes
+import errno
 import io
-import subprocess
+import os
+import pwd
+import secrets
+import shutil
+import signal
+import stat
+import threading
 
 from sandboxai.api.v1 import (
+    CreateProcessRequest,
+    DeletePathRequest,
+    Error,
+    FileInfo,
+    FileType,
+    IPythonCellStreamEvent,
+    ListDirRequest,
+    ListDirResult,
+    Process,
+    ProcessList,
+    ProcessOutput,
+    ProcessSpec,
+    ProcessState,
+    ProcessStatus,
     RunIPythonCellRequest,
     RunIPythonCellResult,
     RunShellCommandRequest,
     RunShellCommandResult,
+    ShellCommandStreamEvent,
+    SignalProcessRequest,
+    ToolOutputStream,
+    ToolStreamEventType,
 )
 
 # Initialize FastAPI app
@@ -21,6 +52,534 @@ app = FastAPI(
 # Initialize IPython shell
 ipy = InteractiveShell.instance()
 
+# The IPython shell and stdout/stderr redirection are global, so only one
+# cell can run at a time.
+ipy_lock = threading.Lock()
+
+# Size of the chunks read from a command's output pipes.
+STREAM_CHUNK_SIZE = 4096
+
+# How long to wait for the output pipes of a killed command to close. They
+# stay open if a process that escaped the process group holds them.
+KILL_GRACE_SECONDS = 5
+
+# How often to check whether the client of a non-streaming request has
+# disconnected.
+DISCONNECT_POLL_INTERVAL = 0.5
+
+# Only the most recent output of each stream of a background process is kept.
+PROCESS_OUTPUT_LIMIT = 1 << 20
+
+# Exited background processes are forgotten (oldest first) beyond this many.
+MAX_EXITED_PROCESSES = 100
+
+# Size of the chunks relayed from a port connection.
+RELAY_CHUNK_SIZE = 64 << 10
+
+# Listing a directory stops after this many entries.
+MAX_LIST_DIR_ENTRIES = 10000
+
+
+def ipython_result(result, **fields) -> RunIPythonCellResult:
+    """Convert an IPython ExecutionResult into a RunIPythonCellResult."""
+    err = result.error_before_exec or result.error_in_exec
+    return RunIPythonCellResult(
+        success=result.success,
+        error_name=type(err).__name__ if err is not None else None,
+        error_value=str(err) if err is not None else None,
+        **fields,
+    )
+
+
+def sse(event) -> str:
+    """Format an event as a Server-Sent Event."""
+    return f"data: {event.model_dump_json(exclude_none=True)}\n\n"
+
+
+def deadline_for(timeout_seconds: Optional[int]) -> Optional[float]:
+    if not timeout_seconds:
+        return None
+    return asyncio.get_running_loop().time() + timeout_seconds
+
+
+async def next_item(queue: asyncio.Queue, deadline: Optional[float]):
+    """Get the next item from the queue, raising TimeoutError after the deadline."""
+    if deadline is None:
+        return await queue.get()
+    timeout = max(deadline - asyncio.get_running_loop().time(), 0)
+    return await asyncio.wait_for(queue.get(), timeout)
+
+
+class QueueWriter(io.TextIOBase):
+    """A file-like object that sends writes to an asyncio queue from another thread."""
+
+    def __init__(self, loop, queue, stream: ToolOutputStream):
+        self.loop = loop
+        self.queue = queue
+        self.stream = stream
+
+    def write(self, s: str) -> int:
+        if s:
+            self.loop.call_soon_threadsafe(self.queue.put_nowait, (self.stream, s))
+        return len(s)
+
+
+class KernelBusyError(Exception):
+    """Raised for cells that were interrupted before another cell finished."""
+
+
+class CellRunner:
+    """
+    Runs an IPython cell in a dedicated thread so that it can be interrupted.
+
+    The kernel is interrupted by raising KeyboardInterrupt in the thread, which
+    takes effect the next time the cell executes Python bytecode (i.e. it will
+    not interrupt a long blocking call into C code).
+    """
+
+    def __init__(self, code: str, stdout, stderr, queue: asyncio.Queue):
+        self.loop = asyncio.get_running_loop()
+        self.future = self.loop.create_future()
+        self.queue = queue
+        self.mtx = threading.Lock()
+        self.thread_id = None
+        self.interrupted = False
+        threading.Thread(target=self.run, args=(code, stdout, stderr), daemon=True).start()
+
+    def run(self, code: str, stdout, stderr):
+        result, err = None, None
+        try:
+            with ipy_lock:
+                try:
+                    with self.mtx:
+                        if self.interrupted:
+                            raise KernelBusyError("the kernel was busy with another cell")
+                        self.thread_id = threading.get_ident()
+                    with redirect_stdout(stdout), redirect_stderr(stderr):
+                        result = ipy.run_cell(code)
+                except BaseException as e:
+                    err = e
+                finally:
+                    with self.mtx:
+                        self.thread_id = None
+                        # Discard an interrupt that arrived after the cell finished.
+                        set_async_exc(threading.get_ident(), None)
+        finally:
+            self.loop.call_soon_threadsafe(self.done, result, err)
+
+    def done(self, result, err):
+        # Sentinel that marks the end of the output.
+        self.queue.put_nowait(None)
+        if self.future.done():
+            return
+        if result is not None:
+            self.future.set_result(result)
+        elif isinstance(err, KernelBusyError):
+            self.future.set_exception(err)
+        else:
+            self.future.set_exception(RuntimeError(f"Failed to run cell: {err!r}"))
+
+    def interrupt(self):
+        with self.mtx:
+            if self.interrupted:
+                return
+            self.interrupted = True
+            if self.thread_id is not None:
+                set_async_exc(self.thread_id, KeyboardInterrupt)
+
+
+def set_async_exc(thread_id: int, exc):
+    ctypes.pythonapi.PyThreadState_SetAsyncExc(
+        ctypes.c_ulong(thread_id), ctypes.py_object(exc) if exc else None
+    )
+
+
+async def run_cell(request: RunIPythonCellRequest):
+    """
+    Run an IPython cell, yielding (stream, data) output chunks followed by the
+    RunIPythonCellResult. The kernel is interrupted if the cell exceeds its
+    timeout or if the generator is closed before the cell finishes.
+    """
+    loop = asyncio.get_running_loop()
+    queue = asyncio.Queue()
+    if request.split_output:
+        stdout = QueueWriter(loop, queue, ToolOutputStream.stdout)
+        stderr = QueueWriter(loop, queue, ToolOutputStream.stderr)
+    else:
+        stdout = stderr = QueueWriter(loop, queue, ToolOutputStream.output)
+
+    runner = CellRunner(request.code, stdout, stderr, queue)
+    deadline = deadline_for(request.timeout_seconds)
+    timed_out = False
+    try:
+        while True:
+            try:
+                item = await next_item(queue, None if timed_out else deadline)
+            except asyncio.TimeoutError:
+                timed_out = True
+                runner.interrupt()
+                continue
+            if item is None:
+                break
+            yield item
+        try:
+            result = await runner.future
+        except KernelBusyError as e:
+            # The timeout passed while the cell was queued behind another.
+            yield RunIPythonCellResult(
+                success=False,
+                error_name="TimeoutError",
+                error_value=str(e),
+                timed_out=True,
+            )
+            return
+    finally:
+        if not runner.future.done():
+            runner.interrupt()
+
+    yield ipython_result(result, timed_out=timed_out or None)
+
+
+def exit_code(proc) -> int:
+    """Report processes killed by a signal the same way as a shell would."""
+    return proc.returncode if proc.returncode >= 0 else 128 - proc.returncode
+
+
+def kill_process_group(proc):
+    try:
+        os.killpg(proc.pid, signal.SIGKILL)
+    except ProcessLookupError:
+        pass
+
+
+async def wait_killed(proc):
+    """
+    Wait for a killed process. asyncio only reports the exit once the output
+    pipes are closed as well, so they are closed if they are still held open
+    after KILL_GRACE_SECONDS.
+    """
+    try:
+        await asyncio.wait_for(proc.wait(), KILL_GRACE_SECONDS)
+    except asyncio.TimeoutError:
+        proc._transport.close()
+        await proc.wait()
+
+
+def process_options(request: RunShellCommandRequest | ProcessSpec) -> dict:
+    """
+    Convert the exec options of a request into keyword arguments for
+    asyncio.create_subprocess_*. Raises ValueError if the request is invalid.
+    """
+    if bool(request.command) == bool(request.argv):
+        raise ValueError("exactly one of command or argv must be set")
+
+    options = {}
+    env = None
+    if request.user or request.env:
+        env = dict(os.environ)
+    if request.user:
+        try:
+            pw = pwd.getpwnam(request.user)
+        except KeyError:
+            raise ValueError(f"unknown user: {request.user!r}")
+        options.update(
+            user=pw.pw_uid,
+            group=pw.pw_gid,
+            extra_groups=os.getgrouplist(pw.pw_name, pw.pw_gid),
+        )
+        env.update(HOME=pw.pw_dir, USER=pw.pw_name, LOGNAME=pw.pw_name)
+    if request.env:
+        env.update(request.env)
+    if env is not None:
+        options["env"] = env
+    if request.cwd:
+        # Checked up front so that streaming requests can fail with a status.
+        if not os.path.isdir(request.cwd):
+            raise ValueError(f"cwd: not a directory: {request.cwd!r}")
+        options["cwd"] = request.cwd
+    return options
+
+
+async def run_shell(request: RunShellCommandRequest):
+    """
+    Run a shell command, yielding (stream, data) output chunks followed by the
+    RunShellCommandResult. The command (and any processes it started) is killed
+    if it exceeds its timeout or if the generator is closed before it exits.
+    """
+    options = process_options(request)
+    options.update(
+        stdin=(
+            asyncio.subprocess.PIPE
+            if request.stdin is not None
+            else asyncio.subprocess.DEVNULL
+        ),
+        stdout=asyncio.subprocess.PIPE,
+        stderr=(
+            asyncio.subprocess.PIPE
+            if request.split_output
+            else asyncio.subprocess.STDOUT  # Redirect stderr to stdout
+        ),
+        # Run in a new process group so that child processes can be killed.
+        start_new_session=True,
+    )
+    if request.argv:
+        proc = await asyncio.create_subprocess_exec(*request.argv, **options)
+    else:
+        proc = await asyncio.create_subprocess_shell(request.command, **options)
+
+    queue = asyncio.Queue()
+
+    async def feed(data: str):
+        try:
+            proc.stdin.write(data.encode())
+            await proc.stdin.drain()
+        except (BrokenPipeError, ConnectionResetError):
+            # The command exited without reading all of its input.
+            pass
+        finally:
+            proc.stdin.close()
+
+    async def pump(pipe, stream: ToolOutputStream):
+        decoder = codecs.getincrementaldecoder("utf-8")(errors="replace")
+        while chunk := await pipe.read(STREAM_CHUNK_SIZE):
+            if data := decoder.decode(chunk):
+                await queue.put((stream, data))
+        if data := decoder.decode(b"", final=True):
+            await queue.put((stream, data))
+
+    if request.split_output:
+        pumps = [
+            pump(proc.stdout, ToolOutputStream.stdout),
+            pump(proc.stderr, ToolOutputStream.stderr),
+        ]
+    else:
+        pumps = [pump(proc.stdout, ToolOutputStream.output)]
+    if request.stdin is not None:
+        pumps.append(feed(request.stdin))
+    reader = asyncio.ensure_future(asyncio.gather(*pumps))
+    # Sentinel that marks the end of the output.
+    reader.add_done_callback(lambda _: queue.put_nowait(None))
+
+    deadline = deadline_for(request.timeout_seconds)
+    timed_out = False
+    try:
+        while True:
+            try:
+                item = await next_item(queue, deadline)
+            except asyncio.TimeoutError:
+                if timed_out:
+                    # The pipes are held open by a process that escaped the
+                    # process group.
+                    break
+                timed_out = True
+                kill_process_group(proc)
+                deadline = asyncio.get_running_loop().time() + KILL_GRACE_SECONDS
+                continue
+            if item is None:
+                break
+            yield item
+        if timed_out:
+            await wait_killed(proc)
+        else:
+            await proc.wait()
+    finally:
+        if proc.returncode is None:
+            kill_process_group(proc)
+            await wait_killed(proc)
+        reader.cancel()
+
+    yield RunShellCommandResult(exit_code=exit_code(proc), timed_out=timed_out or None)
+
+
+async def collect(output, http_request: Request):
+    """
+    Collect the output of run_cell or run_shell. Returns the output per stream
+    and the result, or None if the client disconnected first (in which case the
+    generator is closed to stop the work).
+    """
+    chunks = {}
+    result = None
+
+    async def consume():
+        nonlocal result
+        async for item in output:
+            if isinstance(item, tuple):
+                stream, data = item
+                chunks.setdefault(stream.value, []).append(data)
+            else:
+                result = item
+
+    async def wait_for_disconnect():
+        while not await http_request.is_disconnected():
+            await asyncio.sleep(DISCONNECT_POLL_INTERVAL)
+
+    consumer = asyncio.ensure_future(consume())
+    watcher = asyncio.ensure_future(wait_for_disconnect())
+    disconnected = True
+    try:
+        await asyncio.wait({consumer, watcher}, return_when=asyncio.FIRST_COMPLETED)
+        disconnected = not consumer.done()
+    finally:
+        watcher.cancel()
+        if not consumer.done():
+            consumer.cancel()
+            await asyncio.gather(consumer, return_exceptions=True)
+            await output.aclose()
+    if disconnected:
+        return None, None
+    consumer.result()
+    return {stream: "".join(data) for stream, data in chunks.items()}, result
+
+
+class OutputBuffer:
+    """
+    The most recent output of a stream, addressed by byte offsets from the
+    start of the stream.
+    """
+
+    def __init__(self, limit: int = PROCESS_OUTPUT_LIMIT):
+        self.limit = limit
+        self.data = bytearray()
+        # The offset of data[0] in the stream.
+        self.start = 0
+
+    @property
+    def end(self) -> int:
+        return self.start + len(self.data)
+
+    def write(self, chunk: bytes):
+        self.data += chunk
+        if (excess := len(self.data) - self.limit) > 0:
+            del self.data[:excess]
+            self.start += excess
+
+    def read(self, offset: int, final: bool) -> tuple[int, bytes]:
+        """
+        Return the buffered output from offset (or from the oldest buffered
+        byte, if offset is no longer buffered) along with its actual offset.
+        Unless final, an incomplete UTF-8 sequence at the end is held back so
+        that it is not split between reads.
+        """
+        offset = min(max(offset, self.start), self.end)
+        data = bytes(self.data[offset - self.start :])
+        if not final:
+            data = data[: complete_utf8_len(data)]
+        return offset, data
+
+
+def complete_utf8_len(data: bytes) -> int:
+    """Return the length of data without a trailing incomplete UTF-8 sequence."""
+    for i in range(1, min(len(data), 4) + 1):
+        b = data[-i]
+        if b & 0xC0 == 0x80:
+            # Continuation byte, keep looking for the lead byte.
+            continue
+        if b >= 0xF0:
+            needed = 4
+        elif b >= 0xE0:
+            needed = 3
+        elif b >= 0xC0:
+            needed = 2
+        else:
+            needed = 1
+        return len(data) - i if needed > i else len(data)
+    return len(data)
+
+
+class ManagedProcess:
+    """A background process and its buffered output."""
+
+    def __init__(self, spec: ProcessSpec, proc):
+        self.id = secrets.token_hex(6)
+        self.spec = spec
+        self.proc = proc
+        self.started_at = datetime.now(timezone.utc)
+        self.exited_at = None
+        self.output = {
+            ToolOutputStream.stdout: OutputBuffer(),
+            ToolOutputStream.stderr: OutputBuffer(),
+        }
+        # The pipes can outlive the process if it started children that
+        # inherited them, so the output is tracked separately from the exit.
+        self.reader = asyncio.ensure_future(
+            asyncio.gather(
+                self.pump(proc.stdout, ToolOutputStream.stdout),
+                self.pump(proc.stderr, ToolOutputStream.stderr),
+            )
+        )
+        self.waiter = asyncio.ensure_future(self.wait())
+
+    async def pump(self, pipe, stream: ToolOutputStream):
+        while chunk := await pipe.read(STREAM_CHUNK_SIZE):
+            self.output[stream].write(chunk)
+
+    async def wait(self):
+        await self.proc.wait()
+        self.exited_at = datetime.now(timezone.utc)
+        prune_processes()
+
+    @property
+    def running(self) -> bool:
+        return self.exited_at is None
+
+    def model(self) -> Process:
+        return Process(
+            id=self.id,
+            spec=self.spec,
+            status=ProcessStatus(
+                state=ProcessState.Running if self.running else ProcessState.Exited,
+                pid=self.proc.pid,
+                exit_code=None if self.running else exit_code(self.proc),
+                started_at=self.started_at,
+                exited_at=self.exited_at,
+            ),
+        )
+
+    def read(self, stream: ToolOutputStream, offset: int) -> ProcessOutput:
+        buf = self.output[stream]
+        final = self.reader.done()
+        start, data = buf.read(offset, final)
+        next_offset = start + len(data)
+        return ProcessOutput(
+            stream=stream.value,
+            offset=start,
+            next_offset=next_offset,
+            data=data.decode(errors="replace"),
+            truncated=(offset < start) or None,
+            eof=(not self.running and final and next_offset == buf.end) or None,
+        )
+
+
+# Background processes by ID, oldest first.
+processes: Dict[str, ManagedProcess] = {}
+
+
+def prune_processes():
+    exited = [p for p in processes.values() if not p.running]
+    for p in exited[: max(len(exited) - MAX_EXITED_PROCESSES, 0)]:
+        del processes[p.id]
+
+
+class ProcessNotFound(Exception):
+    pass
+
+
+@app.exception_handler(ProcessNotFound)
+async def process_not_found(request: Request, exc: ProcessNotFound):
+    return JSONResponse(
+        status_code=404,
+        content=Error(
+            message=f"process not found: {exc}", reason="ProcessNotFound"
+        ).model_dump(),
+    )
+
+
+def get_managed_process(id: str) -> ManagedProcess:
+    try:
+        return processes[id]
+    except KeyError:
+        raise ProcessNotFound(id)
+
 
 @app.get(
     "/healthz",
@@ -36,7 +595,7 @@ async def healthz():
     response_model=RunIPythonCellResult,
     summary="Invoke a cell in a stateful IPython (Jupyter) kernel",
 )
-async def run_ipython_cell(request: RunIPythonCellRequest):
+async def run_ipython_cell(request: RunIPythonCellRequest, http_request: Request):
     """
     Execute code in an IPython kernel and return the results.
 
@@ -47,27 +606,13 @@ async def run_ipython_cell(request: RunIPythonCellRequest):
         The execution results including output, stdout, and stderr
     """
     try:
-        if request.split_output:
-            # Capture stdout and stderr separately
-            stdout_buf = io.StringIO()
-            stderr_buf = io.StringIO()
-
-            with redirect_stdout(stdout_buf), redirect_stderr(stderr_buf):
-                ipy.run_cell(request.code)
-
-            return RunIPythonCellResult(
-                stdout=stdout_buf.getvalue(), stderr=stderr_buf.getvalue()
-            )
-        else:
-            # Capture combined output
-            output_buf = io.StringIO()
-            with redirect_stdout(output_buf), redirect_stderr(output_buf):
-                ipy.run_cell(request.code)
-
-            return RunIPythonCellResult(output=output_buf.getvalue())
-
+        output, result = await collect(run_cell(request), http_request)
     except Exception as e:
         raise HTTPException(status_code=500, detail=str(e))
+    if result is None:
+        # The client disconnected and the kernel was interrupted.
+        return Response(status_code=499)
+    return result.model_copy(update=output)
 
 
 @app.post(
@@ -75,42 +620,383 @@ async def run_ipython_cell(request: RunIPythonCellRequest):
     response_model=RunShellCommandResult,
     summary="Invoke a shell command.",
 )
-async def run_shell_command(request: RunShellCommandRequest):
+async def run_shell_command(request: RunShellCommandRequest, http_request: Request):
     """
     Execute a shell command and return the results.
     """
     try:
-        output, stdout, stderr = None, None, None
-        if request.split_output:
-            # Split output mode: capture stdout and stderr separately
-            result = subprocess.run(
-                request.command,
-                shell=True,
-                stdout=subprocess.PIPE,
-                stderr=subprocess.PIPE,
-                text=True,
+        output, result = await collect(run_shell(request), http_request)
+    except ValueError as e:
+        raise HTTPException(status_code=400, detail=str(e))
+    except OSError as e:
+        # For example, the program or working directory does not exist.
+        raise HTTPException(
+            status_code=400, detail=f"Failed to execute shell command: {e}"
+        )
+    except Exception as e:
+        raise HTTPException(
+            status_code=500, detail=f"Failed to execute shell command: {str(e)}"
+        )
+    if result is None:
+        # The client disconnected and the command was killed.
+        return Response(status_code=499)
+    return result.model_copy(update=output)
+
+
+@app.post(
+    "/tools:run_ipython_cell_stream",
+    summary="Invoke a cell in a stateful IPython (Jupyter) kernel, streaming the output as it is produced",
+    response_class=StreamingResponse,
+)
+async def run_ipython_cell_stream(request: RunIPythonCellRequest):
+    """
+    Execute code in an IPython kernel and stream the output as Server-Sent Events.
+    The kernel is interrupted if the client disconnects.
+    """
+
+    async def events():
+        output = run_cell(request)
+        try:
+            async for item in output:
+                if isinstance(item, tuple):
+                    stream, data = item
+                    yield sse(
+                        IPythonCellStreamEvent(
+                            type=ToolStreamEventType.Output, stream=stream, data=data
+                        )
+                    )
+                else:
+                    yield sse(
+                        IPythonCellStreamEvent(
+                            type=ToolStreamEventType.Result, result=item
+                        )
+                    )
+        except Exception as e:
+            yield sse(IPythonCellStreamEvent(type=ToolStreamEventType.Error, data=str(e)))
+        finally:
+            await output.aclose()
+
+    return StreamingResponse(events(), media_type="text/event-stream")
+
+
+@app.post(
+    "/tools:run_shell_command_stream",
+    summary="Invoke a shell command, streaming the output as it is produced.",
+    response_class=StreamingResponse,
+)
+async def run_shell_command_stream(request: RunShellCommandRequest):
+    """
+    Execute a shell command and stream the output as Server-Sent Events.
+    The command is killed if the client disconnects.
+    """
+    try:
+        process_options(request)
+    except ValueError as e:
+        raise HTTPException(status_code=400, detail=str(e))
+
+    async def events():
+        output = run_shell(request)
+        try:
+            async for item in output:
+                if isinstance(item, tuple):
+                    stream, data = item
+                    yield sse(
+                        ShellCommandStreamEvent(
+                            type=ToolStreamEventType.Output, stream=stream, data=data
+                        )
+                    )
+                else:
+                    yield sse(
+                        ShellCommandStreamEvent(
+                            type=ToolStreamEventType.Result, result=item
+                        )
+                    )
+        except Exception as e:
+            yield sse(
+                ShellCommandStreamEvent(
+                    type=ToolStreamEventType.Error,
+                    data=f"Failed to execute shell command: {str(e)}",
+                )
             )
-            stdout = result.stdout
-            stderr = result.stderr
+        finally:
+            await output.aclose()
+
+    return StreamingResponse(events(), media_type="text/event-stream")
+
+
+@app.get(
+    "/processes",
+    response_model=ProcessList,
+    summary="List the background processes",
+)
+async def list_processes():
+    return ProcessList(items=[p.model() for p in processes.values()])
+
+
+@app.post(
+    "/processes",
+    status_code=201,
+    response_model=Process,
+    summary="Start a background process",
+)
+async def create_process(request: CreateProcessRequest):
+    """
+    Start a process that runs independently of the request. Its stdout and
+    stderr are buffered and can be read with GET /processes/{id}/output.
+    """
+    try:
+        options = process_options(request.spec)
+    except ValueError as e:
+        raise HTTPException(status_code=400, detail=str(e))
+    options.update(
+        stdin=asyncio.subprocess.DEVNULL,
+        stdout=asyncio.subprocess.PIPE,
+        stderr=asyncio.subprocess.PIPE,
+        # Run in a new process group so that child processes can be signaled.
+        start_new_session=True,
+    )
+    try:
+        if request.spec.argv:
+            proc = await asyncio.create_subprocess_exec(*request.spec.argv, **options)
         else:
-            # Combined output mode: redirect stderr to stdout
-            result = subprocess.run(
-                request.command,
-                shell=True,
-                stdout=subprocess.PIPE,
-                stderr=subprocess.STDOUT,  # Redirect stderr to stdout
-                text=True,
-            )
-            output = result.stdout
+            proc = await asyncio.create_subprocess_shell(request.spec.command, **options)
+    except OSError as e:
+        # For example, the program or working directory does not exist.
+        raise HTTPException(status_code=400, detail=f"Failed to start process: {e}")
+
+    p = ManagedProcess(request.spec, proc)
+    processes[p.id] = p
+    return p.model()
 
-        return RunShellCommandResult(
-            output=output, stdout=stdout, stderr=stderr, return_code=result.returncode
+
+@app.get(
+    "/processes/{id}",
+    response_model=Process,
+    summary="Get a background process",
+)
+async def get_process(id: str):
+    return get_managed_process(id).model()
+
+
+@app.get(
+    "/processes/{id}/output",
+    response_model=ProcessOutput,
+    response_model_exclude_none=True,
+    summary="Read the buffered output of a background process",
+)
+async def get_process_output(id: str, stream: str = "stdout", offset: int = 0):
+    p = get_managed_process(id)
+    if stream not in (ToolOutputStream.stdout.value, ToolOutputStream.stderr.value):
+        raise HTTPException(status_code=400, detail="stream must be stdout or stderr")
+    if offset < 0:
+        raise HTTPException(status_code=400, detail="offset must not be negative")
+    return p.read(ToolOutputStream(stream), offset)
+
+
+@app.post(
+    "/processes/{id}:signal",
+    response_model=Process,
+    summary="Send a signal to a background process",
+)
+async def signal_process(id: str, request: SignalProcessRequest):
+    """Send a signal to the process and any processes it started."""
+    p = get_managed_process(id)
+    name = request.signal.upper()
+    if not name.startswith("SIG"):
+        name = "SIG" + name
+    try:
+        sig = signal.Signals[name]
+    except KeyError:
+        raise HTTPException(
+            status_code=400, detail=f"unknown signal: {request.signal!r}"
         )
+    if not p.running:
+        raise HTTPException(status_code=409, detail="the process has already exited")
+    try:
+        os.killpg(p.proc.pid, sig)
+    except ProcessLookupError:
+        pass
+    return p.model()
 
-    except Exception as e:
+
+@app.post(
+    "/processes/{id}:kill",
+    response_model=Process,
+    summary="Kill a background process",
+)
+async def kill_process(id: str):
+    """Kill the process and any processes it started, and wait for it to exit."""
+    p = get_managed_process(id)
+    if p.running:
+        kill_process_group(p.proc)
+        await asyncio.shield(p.waiter)
+    return p.model()
+
+
+# Files are read and written by sandboxaid with the Docker archive API. Listing
+# and deleting are done here: the archive API can only list a directory by
+# archiving everything under it and it cannot delete at all.
+
+
+class PathNotFound(Exception):
+    pass
+
+
+@app.exception_handler(PathNotFound)
+async def path_not_found(request: Request, exc: PathNotFound):
+    return JSONResponse(
+        status_code=404,
+        content=Error(
+            message=f"path not found: {exc}", reason="PathNotFound"
+        ).model_dump(),
+    )
+
+
+def file_type(mode: int) -> FileType:
+    if stat.S_ISREG(mode):
+        return FileType.File
+    if stat.S_ISDIR(mode):
+        return FileType.Directory
+    if stat.S_ISLNK(mode):
+        return FileType.Symlink
+    return FileType.Other
+
+
+def file_info(path: str, st: os.stat_result) -> FileInfo:
+    info = FileInfo(
+        name=os.path.basename(path),
+        path=path,
+        type=file_type(st.st_mode),
+        size=st.st_size,
+        mode=stat.S_IMODE(st.st_mode),
+        modified_at=datetime.fromtimestamp(st.st_mtime, timezone.utc),
+    )
+    if info.type == FileType.Symlink:
+        info.link_target = os.readlink(path)
+    return info
+
+
+@app.post(
+    "/tools:list_dir",
+    response_model=ListDirResult,
+    response_model_exclude_none=True,
+    summary="List the direct children of a directory",
+)
+def list_dir(request: ListDirRequest):
+    """
+    Only the first MAX_LIST_DIR_ENTRIES entries (in no particular order) are
+    listed, truncated is set if there are more. Symlinks to directories are
+    followed.
+    """
+    entries = []
+    truncated = False
+    try:
+        with os.scandir(request.path) as it:
+            for entry in it:
+                if len(entries) == MAX_LIST_DIR_ENTRIES:
+                    truncated = True
+                    break
+                path = os.path.join(request.path, entry.name)
+                try:
+                    entries.append(file_info(path, entry.stat(follow_symlinks=False)))
+                except FileNotFoundError:
+                    # Deleted while listing.
+                    continue
+    except FileNotFoundError:
+        raise PathNotFound(request.path)
+    except NotADirectoryError:
+        raise HTTPException(status_code=400, detail=f"not a directory: {request.path}")
+    entries.sort(key=lambda e: e.name)
+    return ListDirResult(entries=entries, truncated=truncated or None)
+
+
+@app.post(
+    "/tools:delete_path",
+    status_code=204,
+    summary="Delete a file or directory",
+)
+def delete_path(request: DeletePathRequest):
+    """
+    Symlinks are not followed. Directories must be empty unless recursive is
+    set.
+    """
+    if os.path.realpath(request.path) == "/":
         raise HTTPException(
-            status_code=500, detail=f"Failed to execute shell command: {str(e)}"
+            status_code=400, detail="the root directory cannot be deleted"
         )
+    try:
+        st = os.lstat(request.path)
+        if not stat.S_ISDIR(st.st_mode):
+            os.remove(request.path)
+        elif request.recursive:
+            shutil.rmtree(request.path)
+        else:
+            os.rmdir(request.path)
+    except FileNotFoundError:
+        raise PathNotFound(request.path)
+    except OSError as e:
+        if e.errno == errno.ENOTEMPTY:
+            raise HTTPException(
+                status_code=409, detail=f"directory not empty: {request.path}"
+            )
+        raise HTTPException(status_code=500, detail=f"deleting {request.path}: {e}")
+    return Response(status_code=204)
+
+
+@app.websocket("/ports/{port}:connect")
+async def connect_port(websocket: WebSocket, port: int):
+    """
+    Relay a TCP connection to a port in the sandbox over a WebSocket. This
+    reaches servers that only listen on the loopback interface.
+
+    Binary messages carry the data. An empty message marks the end of the
+    data in that direction (a TCP half-close). The handshake is rejected
+    (HTTP 403) if nothing is listening on the port.
+    """
+    try:
+        reader, writer = await asyncio.open_connection("localhost", port)
+    except OSError:
+        await websocket.close()
+        return
+    await websocket.accept()
+
+    async def upstream() -> bool:
+        """Relay from the client to the port. Returns True if the client disconnected."""
+        while True:
+            message = await websocket.receive()
+            if message["type"] == "websocket.disconnect":
+                return True
+            data = message.get("bytes") or b""
+            try:
+                if not data:
+                    writer.write_eof()
+                    return False
+                writer.write(data)
+                await writer.drain()
+            except ConnectionError:
+                return False
+
+    async def downstream():
+        """Relay from the port to the client."""
+        try:
+            while chunk := await reader.read(RELAY_CHUNK_SIZE):
+                await websocket.send_bytes(chunk)
+        except ConnectionError:
+            pass
+        await websocket.send_bytes(b"")
+
+    down = asyncio.ensure_future(downstream())
+    try:
+        if await upstream():
+            down.cancel()
+        await asyncio.gather(down, return_exceptions=True)
+    finally:
+        writer.close()
+        try:
+            await websocket.close()
+        except RuntimeError:
+            # The client already disconnected.
+            pass
 
 
 if __name__ == "__main__":
@@ -4,13 +4,131 @@
 
 from __future__ import annotations
 
-from typing import Dict, Optional
+from datetime import datetime
+from enum import Enum
+from typing import Dict, List, Optional
 
-from pydantic import BaseModel, Field
+from pydantic import Base64Bytes, BaseModel, Field
 
 
 class Error(BaseModel):
     message: str = Field(..., description="The error message.")
+    reason: Optional[str] = Field(
+        None,
+        description="A machine readable reason for the error, set when the status code alone is ambiguous.\n\n* PathNotFound - The path was not found in the sandbox.\n* ProcessNotFound - The process was not found in the sandbox.\n* PortNotListening - Nothing in the sandbox is listening on the port.\n* SandboxPaused - The sandbox is paused and must be resumed before tool calls can be made.\n* SandboxNotRunning - The sandbox is not running, for example because it is still Pending or was stopped. The message includes its phase and reason.\n",
+    )
+
+
+class Ulimit(BaseModel):
+    name: str = Field(
+        ...,
+        description='The name of the limit without the RLIMIT_ prefix, in lower case (for example "nofile").',
+    )
+    soft: int = Field(..., description="The soft limit.", ge=0)
+    hard: int = Field(
+        ..., description="The hard limit. Must be at least the soft limit.", ge=0
+    )
+
+
+class SandboxResources(BaseModel):
+    cpus: Optional[float] = Field(
+        None,
+        description="The number of CPU cores the sandbox can use, may be fractional (for example 0.5).",
+        ge=0,
+    )
+    memory: Optional[int] = Field(
+        None, description="The memory limit in bytes.", ge=0
+    )
+    swap: Optional[int] = Field(
+        None,
+        description="The swap that can be used in addition to memory, in bytes. Only applies if memory is limited, in which case no swap is allowed by default.",
+        ge=0,
+    )
+    pids: Optional[int] = Field(
+        None,
+        description="The maximum number of processes (and threads).",
+        ge=0,
+    )
+    shm_size: Optional[int] = Field(
+        None, description="The size of /dev/shm in bytes. Defaults to 64 MiB.", ge=0
+    )
+    ulimits: Optional[List[Ulimit]] = Field(
+        None, description="Process limits (see setrlimit(2))."
+    )
+
+
+class SpaceResources(BaseModel):
+    default: Optional[SandboxResources] = None
+    max: Optional[SandboxResources] = None
+
+
+class NetworkMode(Enum):
+    none = "none"
+    internal = "internal"
+    bridge = "bridge"
+
+
+class CassetteMode(Enum):
+    auto = "auto"
+    record = "record"
+    replay = "replay"
+
+
+class SandboxCassette(BaseModel):
+    name: str = Field(..., description="The name of the cassette.")
+    mode: Optional[CassetteMode] = Field(
+        None,
+        description="Defaults to auto. Sandboxes report the mode that was chosen.\n\n* auto - Replay if the cassette exists, otherwise record it.\n* record - Record the cassette, replacing it if it exists.\n* replay - Replay the cassette, which must exist.\n",
+    )
+
+
+class SandboxNetwork(BaseModel):
+    mode: Optional[NetworkMode] = Field(
+        None,
+        description="How the sandbox is connected to the network. Defaults to\ninternal if egress_allow or a cassette is set, otherwise to\nbridge.\n\n* none - No network access. The sandbox only has a loopback interface and is reached by sandboxaid through docker exec.\n* internal - No internet access. The sandbox can reach the other internal sandboxes in its space and be reached by sandboxaid.\n* bridge - Full network access.\n\nEach space has a dedicated Docker network for the internal and\nbridge modes. Sandboxes that are not bridged cannot publish\nports, sandboxaid reaches them through docker exec instead.\n",
+    )
+    egress_allow: Optional[List[str]] = Field(
+        None,
+        description='Destinations that the sandbox can connect to through the egress\nproxy of sandboxaid, which is configured with the HTTP_PROXY and\nHTTPS_PROXY environment variables. Each entry is a domain (for\nexample "pypi.org"), a wildcard matching its subdomains (for\nexample "*.pythonhosted.org"), an IP address, or a CIDR range.\nDomains only allow public addresses, private and loopback\naddresses must be allowed by address. The proxy must be the only\nway out, so it cannot be used with the bridge or none modes.\n\nSandboxes reach the proxy at the gateway of their network, so\nsandboxaid must run on the Docker host. Creating the sandbox\nfails with status 400 when Docker runs in a VM (for example with\nDocker Desktop or colima).\n',
+    )
+    cassette: Optional[SandboxCassette] = None
+
+
+class SpaceSpec(BaseModel):
+    description: Optional[str] = Field(
+        None, description="A human readable description of the space."
+    )
+    resources: Optional[SpaceResources] = None
+
+
+class SpaceStatus(BaseModel):
+    created_at: Optional[datetime] = Field(
+        None, description="The time the space was created."
+    )
+
+
+class MountType(Enum):
+    volume = "volume"
+    bind = "bind"
+
+
+class Mount(BaseModel):
+    type: MountType = Field(
+        ...,
+        description="What to mount.\n\n* volume - A volume in the space of the sandbox.\n* bind - A path on the Docker host. Only paths in the\n  directories that sandboxaid allows (SANDBOXAID_BIND_MOUNT_ALLOW)\n  can be mounted, after resolving symlinks, so sandboxaid must\n  run on the Docker host.\n",
+    )
+    source: str = Field(
+        ..., description="The name of the volume or the absolute path on the host."
+    )
+    target: str = Field(
+        ..., description="The absolute path in the sandbox to mount at."
+    )
+    read_only: Optional[bool] = Field(None, description="Mount read-only.")
+
+
+class IdlePolicy(Enum):
+    delete = "delete"
+    stop = "stop"
 
 
 class SandboxSpec(BaseModel):
@@ -20,10 +138,90 @@ class SandboxSpec(BaseModel):
     env: Optional[Dict[str, str]] = Field(
         None, description="Environment variables for the sandbox."
     )
+    resources: Optional[SandboxResources] = None
+    network: Optional[SandboxNetwork] = None
+    mounts: Optional[List[Mount]] = Field(
+        None,
+        description="Volumes and host paths that are mounted into the sandbox.",
+    )
+    ttl: Optional[str] = Field(
+        None,
+        description='Delete the sandbox once it has existed for this long, as a duration\n(for example "1h"). Unset means the sandbox is never deleted because\nof its age.\n',
+    )
+    idle_timeout: Optional[str] = Field(
+        None,
+        description='Apply the idle_policy once the sandbox has not served a tool call\nfor this long, as a duration (for example "10m"). Idleness is\nmeasured from the last tool call (or from when the sandbox became\nready if it never served one). Unset means nothing happens to idle\nsandboxes.\n',
+    )
+    idle_policy: Optional[IdlePolicy] = Field(
+        None,
+        description="What happens to the sandbox once its idle_timeout passes. Defaults\nto delete.\n\n* delete - Delete the sandbox.\n* stop - Stop the sandbox container without deleting it. Its\n  filesystem is kept (but not its memory or processes) and the\n  next tool call starts it again, waiting for it to become ready\n  before the call is made (the call fails with a 503 if it does\n  not). The ttl still applies.\n",
+    )
+
+
+class SandboxPhase(Enum):
+    Pending = "Pending"
+    Ready = "Ready"
+    Stopped = "Stopped"
+    Failed = "Failed"
+    Paused = "Paused"
+    Expired = "Expired"
+
+
+class SandboxStartupTiming(BaseModel):
+    create_container_ms: Optional[int] = Field(
+        None, description="Time spent creating the container."
+    )
+    start_container_ms: Optional[int] = Field(
+        None, description="Time spent starting the container."
+    )
+    wait_for_ready_ms: Optional[int] = Field(
+        None,
+        description="Time spent waiting for the sandbox to pass its health check after starting.",
+    )
+    total_ms: Optional[int] = Field(
+        None,
+        description="Total time from the create request to the sandbox becoming ready.",
+    )
 
 
 class SandboxStatus(BaseModel):
-    pass
+    phase: Optional[SandboxPhase] = None
+    reason: Optional[str] = Field(
+        None,
+        description='A brief CamelCase reason for the current phase (for example "OOMKilled", "IdleStopped", or "TTLExpired" and "IdleTimeout" for expired sandboxes).',
+    )
+    message: Optional[str] = Field(
+        None,
+        description="A human readable message with details about the current phase.",
+    )
+    created_at: Optional[datetime] = Field(
+        None, description="The time the sandbox was created."
+    )
+    started_at: Optional[datetime] = Field(
+        None, description="The time the sandbox container was last started."
+    )
+    ready_at: Optional[datetime] = Field(
+        None, description="The time the sandbox became ready to serve tool calls."
+    )
+    finished_at: Optional[datetime] = Field(
+        None, description="The time the sandbox container last exited."
+    )
+    exit_code: Optional[int] = Field(
+        None,
+        description="The exit code of the sandbox container. Only set once the container has exited.",
+    )
+    oom_killed: Optional[bool] = Field(
+        None,
+        description="True if the sandbox container was killed because it ran out of memory.",
+    )
+    last_activity_at: Optional[datetime] = Field(
+        None, description="The time of the last tool call made to the sandbox."
+    )
+    expires_at: Optional[datetime] = Field(
+        None,
+        description="The time the sandbox will be deleted because of its ttl or idle_timeout, if no tool calls are made before then.",
+    )
+    startup: Optional[SandboxStartupTiming] = None
 
 
 class RunIPythonCellRequest(BaseModel):
@@ -32,6 +230,11 @@ class RunIPythonCellRequest(BaseModel):
         False,
         description="Set to true to split the output into stdout and stderr. If set, the output field in the response will be empty and the stdout and stderr fields will be populated.",
     )
+    timeout_seconds: Optional[int] = Field(
+        None,
+        ge=0,
+        description="Interrupt the kernel if the cell runs for longer than this many seconds. Zero (the default) means no timeout.",
+    )
 
 
 class RunIPythonCellResult(BaseModel):
@@ -44,14 +247,56 @@ class RunIPythonCellResult(BaseModel):
     stderr: Optional[str] = Field(
         None, description="The stderr from the IPython kernel."
     )
+    success: bool = Field(
+        ...,
+        description="False if the cell raised an exception (or failed to compile).",
+    )
+    error_name: Optional[str] = Field(
+        None,
+        description='The name of the exception raised by the cell, for example "ZeroDivisionError".',
+    )
+    error_value: Optional[str] = Field(
+        None, description="The string value of the exception raised by the cell."
+    )
+    timed_out: Optional[bool] = Field(
+        None,
+        description="True if the kernel was interrupted because the cell exceeded timeout_seconds.",
+    )
 
 
 class RunShellCommandRequest(BaseModel):
-    command: str = Field(..., description="The command to execute.")
+    command: Optional[str] = Field(
+        None, description="The command to execute with the shell (/bin/sh -c)."
+    )
+    argv: Optional[List[str]] = Field(
+        None,
+        description="The program and arguments to execute directly, without a shell. Use this to avoid quoting issues.",
+    )
+    cwd: Optional[str] = Field(
+        None,
+        description="The working directory to run the command in. Defaults to the working directory of the sandbox.",
+    )
+    env: Optional[Dict[str, str]] = Field(
+        None,
+        description="Environment variables to set for the command, in addition to (or overriding) the environment of the sandbox.",
+    )
+    stdin: Optional[str] = Field(
+        None,
+        description="Data to write to the standard input of the command. If not set, standard input is empty.",
+    )
+    user: Optional[str] = Field(
+        None,
+        description="The name of the user to run the command as. Defaults to the user that the sandbox runs as.",
+    )
     split_output: Optional[bool] = Field(
         False,
         description="Set to true to split the output into stdout and stderr. If set, the output field in the response will be empty and the stdout and stderr fields will be populated.",
     )
+    timeout_seconds: Optional[int] = Field(
+        None,
+        ge=0,
+        description="Kill the command if it runs for longer than this many seconds. Zero (the default) means no timeout.",
+    )
 
 
 class RunShellCommandResult(BaseModel):
@@ -62,6 +307,57 @@ class RunShellCommandResult(BaseModel):
     stderr: Optional[str] = Field(
         None, description="The stderr from the shell command."
     )
+    exit_code: int = Field(..., description="The exit code of the shell command.")
+    timed_out: Optional[bool] = Field(
+        None,
+        description="True if the command was killed because it exceeded timeout_seconds.",
+    )
+
+
+
+class ToolStreamEventType(Enum):
+    Output = "Output"
+    Result = "Result"
+    Error = "Error"
+
+
+class ToolOutputStream(Enum):
+    output = "output"
+    stdout = "stdout"
+    stderr = "stderr"
+
+
+class ShellCommandStreamEvent(BaseModel):
+    type: ToolStreamEventType
+    stream: Optional[ToolOutputStream] = None
+    data: Optional[str] = Field(
+        None,
+        description="The output chunk (Output events) or error message (Error events).",
+    )
+    result: Optional[RunShellCommandResult] = None
+
+
+class IPythonCellStreamEvent(BaseModel):
+    type: ToolStreamEventType
+    stream: Optional[ToolOutputStream] = None
+    data: Optional[str] = Field(
+        None,
+        description="The output chunk (Output events) or error message (Error events).",
+    )
+    result: Optional[RunIPythonCellResult] = None
+
+class CreateSpaceRequest(BaseModel):
+    name: str = Field(
+        ...,
+        description="The name of the space. Must consist of lower case alphanumeric characters or '-', and must start and end with an alphanumeric character.",
+    )
+    spec: Optional[SpaceSpec] = None
+
+
+class Space(BaseModel):
+    name: Optional[str] = Field(None, description="The name of the space.")
+    spec: SpaceSpec
+    status: Optional[SpaceStatus] = None
 
 
 class CreateSandboxRequest(BaseModel):
@@ -69,14 +365,339 @@ class CreateSandboxRequest(BaseModel):
         None,
         description="The name of the sandbox. If not specified, will be generated automatically.",
     )
+    labels: Optional[Dict[str, str]] = Field(
+        None,
+        description="Key/value pairs that can be used to organize and select sandboxes.",
+    )
     spec: SandboxSpec
 
 
 class Sandbox(BaseModel):
     name: Optional[str] = Field(None, description="The name of the sandbox.")
+    labels: Optional[Dict[str, str]] = Field(
+        None,
+        description="Key/value pairs that can be used to organize and select sandboxes.",
+    )
     uid: Optional[str] = Field(
         None,
         description="An identifier that is unique to the instance (in time) of the sandbox.",
     )
     spec: SandboxSpec
     status: Optional[SandboxStatus] = None
+
+
+class SandboxList(BaseModel):
+    items: List[Sandbox] = Field(..., description="The sandboxes in this page.")
+    next_page_token: Optional[str] = Field(
+        None,
+        description="A token that can be passed as page_token to retrieve the next page. Empty if there are no more results.",
+    )
+
+
+class SandboxEventType(Enum):
+    Created = "Created"
+    Ready = "Ready"
+    Failed = "Failed"
+    Stopped = "Stopped"
+    OOMKilled = "OOMKilled"
+    Deleted = "Deleted"
+    Expired = "Expired"
+    Paused = "Paused"
+    Resumed = "Resumed"
+
+
+class SandboxEvent(BaseModel):
+    type: SandboxEventType
+    space: str = Field(..., description="The space the sandbox lives in.")
+    name: str = Field(..., description="The name of the sandbox.")
+    uid: Optional[str] = Field(None, description="The UID of the sandbox.")
+    time: datetime = Field(..., description="The time the event occurred.")
+    exit_code: Optional[int] = Field(
+        None,
+        description="The exit code of the sandbox container (Stopped events only).",
+    )
+    reason: Optional[str] = Field(
+        None,
+        description='A brief CamelCase reason for the event (for example "IdleTimeout" for Expired events).',
+    )
+    message: Optional[str] = Field(
+        None,
+        description="A human readable message with details about the event.",
+    )
+    resume_token: Optional[str] = Field(
+        None,
+        description="An opaque token that can be used to resume a watch after this event.",
+    )
+
+
+class FileType(Enum):
+    File = "File"
+    Directory = "Directory"
+    Symlink = "Symlink"
+    Other = "Other"
+
+
+class FileInfo(BaseModel):
+    name: str = Field(..., description="The base name of the file.")
+    path: str = Field(..., description="The absolute path of the file.")
+    type: FileType
+    size: int = Field(..., description="The size of the file in bytes.")
+    mode: int = Field(
+        ...,
+        description="The Unix permission bits of the file, for example 420 (0644).",
+    )
+    modified_at: datetime = Field(
+        ..., description="The last modification time of the file."
+    )
+    link_target: Optional[str] = Field(
+        None, description="The target of the link (Symlink only)."
+    )
+
+
+class WriteFileRequest(BaseModel):
+    path: str = Field(..., description="The absolute path of the file.")
+    content: Base64Bytes = Field(
+        ..., description="The content of the file (base64 encoded)."
+    )
+    mode: Optional[int] = Field(
+        None,
+        description="The Unix permission bits of the file. Defaults to 420 (0644).",
+    )
+
+
+class WriteFileResult(BaseModel):
+    info: FileInfo
+
+
+class ReadFileRequest(BaseModel):
+    path: str = Field(
+        ..., description="The absolute path of the file. Symlinks are followed."
+    )
+
+
+class ReadFileResult(BaseModel):
+    info: FileInfo
+    content: Base64Bytes = Field(
+        ..., description="The content of the file (base64 encoded)."
+    )
+
+
+class ListDirRequest(BaseModel):
+    path: str = Field(
+        ..., description="The absolute path of the directory. Symlinks are followed."
+    )
+
+
+class ListDirResult(BaseModel):
+    entries: List[FileInfo]
+    truncated: Optional[bool] = Field(
+        None,
+        description="True if the directory has more entries than the maximum that can be listed (10000).",
+    )
+
+
+class StatRequest(BaseModel):
+    path: str = Field(
+        ..., description="The absolute path. Symlinks are not followed."
+    )
+
+
+class StatResult(BaseModel):
+    info: FileInfo
+
+
+class DeletePathRequest(BaseModel):
+    path: str = Field(
+        ...,
+        description="The absolute path of the file or directory. Symlinks are not followed (the link itself is deleted).",
+    )
+    recursive: Optional[bool] = Field(
+        None,
+        description="Delete directories and their contents. If not set, only empty directories can be deleted.",
+    )
+
+
+class ProcessSpec(BaseModel):
+    command: Optional[str] = Field(
+        None, description="The command to execute with the shell (/bin/sh -c)."
+    )
+    argv: Optional[List[str]] = Field(
+        None,
+        description="The program and arguments to execute directly, without a shell.",
+    )
+    cwd: Optional[str] = Field(
+        None,
+        description="The working directory to run the process in. Defaults to the working directory of the sandbox.",
+    )
+    env: Optional[Dict[str, str]] = Field(
+        None,
+        description="Environment variables to set for the process, in addition to (or overriding) the environment of the sandbox.",
+    )
+    user: Optional[str] = Field(
+        None,
+        description="The name of the user to run the process as. Defaults to the user that the sandbox runs as.",
+    )
+
+
+class ProcessState(Enum):
+    Running = "Running"
+    Exited = "Exited"
+
+
+class ProcessStatus(BaseModel):
+    state: ProcessState
+    pid: int = Field(..., description="The process ID inside the sandbox.")
+    exit_code: Optional[int] = Field(
+        None,
+        description="The exit code of the process. Only set once the process has exited. Processes killed by a signal report 128 plus the signal number.",
+    )
+    started_at: datetime = Field(..., description="The time the process was started.")
+    exited_at: Optional[datetime] = Field(
+        None, description="The time the process exited."
+    )
+
+
+class Process(BaseModel):
+    id: Optional[str] = Field(
+        None, description="The ID of the process, assigned when it is started."
+    )
+    spec: ProcessSpec
+    status: Optional[ProcessStatus] = None
+
+
+class ProcessList(BaseModel):
+    items: List[Process]
+
+
+class CreateProcessRequest(BaseModel):
+    spec: ProcessSpec
+
+
+class ProcessOutput(BaseModel):
+    stream: str = Field(
+        ..., description="The stream the output was read from (stdout or stderr)."
+    )
+    offset: int = Field(
+        ...,
+        description="The byte offset of the start of data in the stream. Greater than the requested offset if truncated is set.",
+    )
+    next_offset: int = Field(..., description="The byte offset to read from next.")
+    data: str = Field(
+        ...,
+        description="The output, decoded as UTF-8 (invalid sequences are replaced).",
+    )
+    truncated: Optional[bool] = Field(
+        None,
+        description="True if output between the requested offset and offset is no longer buffered.",
+    )
+    eof: Optional[bool] = Field(
+        None,
+        description="True if the process has exited and all of its output has been read.",
+    )
+
+
+class SignalProcessRequest(BaseModel):
+    signal: str = Field(
+        ...,
+        description='The name of the signal, for example "SIGTERM" or "SIGINT".',
+    )
+
+
+class TerminalMessageType(Enum):
+    Resize = "Resize"
+    Exit = "Exit"
+
+
+class TerminalMessage(BaseModel):
+    type: TerminalMessageType
+    rows: Optional[int] = Field(
+        None, description="The height of the terminal (Resize only)."
+    )
+    cols: Optional[int] = Field(
+        None, description="The width of the terminal (Resize only)."
+    )
+    exit_code: Optional[int] = Field(
+        None, description="The exit code of the process (Exit only)."
+    )
+
+
+class EgressConnection(BaseModel):
+    time: datetime = Field(..., description="The time of the connection.")
+    method: str = Field(
+        ...,
+        description="CONNECT for tunnelled (HTTPS) connections, otherwise the method of the HTTP request.",
+    )
+    host: str = Field(..., description="The requested host.")
+    port: int = Field(..., description="The requested port.")
+    url: Optional[str] = Field(
+        None, description="The requested URL (HTTP requests only)."
+    )
+    address: Optional[str] = Field(
+        None,
+        description="The IP address that was connected to (allowed connections only).",
+    )
+    allowed: bool = Field(..., description="True if the connection was allowed.")
+    message: Optional[str] = Field(
+        None, description="Why the connection was denied or failed."
+    )
+
+
+class EgressConnectionList(BaseModel):
+    items: List[EgressConnection]
+
+
+class CreateVolumeRequest(BaseModel):
+    name: str = Field(
+        ...,
+        description="The name of the volume. Must consist of lower case alphanumeric characters or '-', and must start and end with an alphanumeric character.",
+    )
+    labels: Optional[Dict[str, str]] = Field(
+        None, description="Labels for the volume."
+    )
+    snapshot: Optional[str] = Field(
+        None,
+        description="The name of a snapshot in the space to fill the volume with.",
+    )
+
+
+class VolumeStatus(BaseModel):
+    created_at: datetime = Field(..., description="The time the volume was created.")
+    mounted_by: List[str] = Field(
+        ..., description="The names of the sandboxes that mount the volume."
+    )
+
+
+class Volume(BaseModel):
+    name: str = Field(..., description="The name of the volume.")
+    labels: Optional[Dict[str, str]] = Field(
+        None, description="Labels for the volume."
+    )
+    status: VolumeStatus
+
+
+class VolumeList(BaseModel):
+    items: List[Volume]
+
+
+class CreateSnapshotRequest(BaseModel):
+    name: Optional[str] = Field(
+        None,
+        description="The name of the snapshot, following the same rules as volume names. Generated from the name of the volume and the time if empty.",
+    )
+    labels: Optional[Dict[str, str]] = Field(
+        None, description="Labels for the snapshot."
+    )
+
+
+class Snapshot(BaseModel):
+    name: str = Field(..., description="The name of the snapshot.")
+    volume: str = Field(..., description="The name of the volume that the snapshot was taken of.")
+    labels: Optional[Dict[str, str]] = Field(
+        None, description="Labels for the snapshot."
+    )
+    created_at: datetime = Field(..., description="The time the snapshot was taken.")
+    size_bytes: int = Field(..., description="The size of the stored (compressed) tarball.")
+
+
+class SnapshotList(BaseModel):
+    items: List[Snapshot]
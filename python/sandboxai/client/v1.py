@@ -3,6 +3,7 @@ import requests
 
 from sandboxai.api.v1 import (
     Sandbox,
+    SandboxPhase,
     CreateSandboxRequest,
     RunIPythonCellRequest,
     RunIPythonCellResult,
@@ -27,6 +28,11 @@ class SandboxNotFoundError(Exception):
         super().__init__(message)
 
 
+class SandboxNotReadyError(Exception):
+    def __init__(self, message: str):
+        super().__init__(message)
+
+
 class HttpClient:
     """
     A Python client for interacting with the SandboxAI API, using typed models from v1.py.
@@ -81,12 +87,63 @@ class HttpClient:
 
         Returns:
             Sandbox: The newly created sandbox, as returned by the API.
+                     It is typically still starting up (Pending), use
+                     wait_for_ready() before running tools in it.
         """
         endpoint = f"{self.base_url}/spaces/{space}/sandboxes"
         response = self.session.post(endpoint, json=req.model_dump())
-        _validate_response(response, 201)
+        _validate_response(response, 202)
         return Sandbox.model_validate(response.json())
 
+    def wait_for_ready(self, space: str, name: str, timeout: int = 120) -> Sandbox:
+        """
+        Wait until a sandbox is ready to run tools.
+
+        Args:
+            space (str): Space where the sandbox lives.
+            name (str): Name of the sandbox.
+            timeout (int): Maximum number of seconds to wait.
+
+        Returns:
+            Sandbox: The ready sandbox.
+
+        Raises:
+            SandboxNotReadyError: If the sandbox failed to start or is
+                stopped, paused or expired.
+            TimeoutError: If the sandbox did not become ready in time.
+        """
+        endpoint = f"{self.base_url}/spaces/{space}/sandboxes/{name}"
+        deadline = time.time() + timeout
+        while True:
+            remaining = max(0, min(30, int(deadline - time.time())))
+            response = self.session.get(
+                endpoint, params={"wait": "Ready", "timeout": f"{remaining}s"}
+            )
+            if response.status_code == 404:
+                raise SandboxNotFoundError(
+                    f"Sandbox with name '{name}' not found in space '{space}'."
+                )
+            _validate_response(response, 200)
+            sandbox = Sandbox.model_validate(response.json())
+            status = sandbox.status
+            if status is None or status.phase is None:
+                return sandbox
+            if status.phase == SandboxPhase.Ready:
+                return sandbox
+            if status.phase in (
+                SandboxPhase.Failed,
+                SandboxPhase.Stopped,
+                SandboxPhase.Paused,
+                SandboxPhase.Expired,
+            ):
+                raise SandboxNotReadyError(
+                    f"Sandbox '{name}' is {status.phase.value}: {status.reason}: {status.message}"
+                )
+            if time.time() >= deadline:
+                raise TimeoutError(
+                    f"Sandbox '{name}' did not become ready within {timeout} seconds."
+                )
+
     def get_sandbox(self, space: str, name: str) -> Sandbox:
         """
         Retrieve an existing sandbox by its ID.
@@ -79,6 +79,15 @@ class Sandbox:
         )
         self.name = created.name
         self.image = created.spec.image
+        try:
+            self.client.wait_for_ready(self.space, self.name)
+        except Exception:
+            # Do not leak sandboxes that failed or timed out while starting.
+            try:
+                self.delete()
+            except Exception as e:
+                log.warning(f"Failed to delete sandbox '{self.name}': {e}")
+            raise
 
     def delete(self) -> None:
         if self.name:
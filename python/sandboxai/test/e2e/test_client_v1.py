@@ -41,10 +41,13 @@ def test_http_client_v1(client):
     assert sandbox.name is not None, "No name returned for created sandbox."
     assert sandbox.name != "", "Empty name returned for created sandbox."
 
+    # Wait for the sandbox to start up.
+    sandbox = client.wait_for_ready(space, sandbox.name)
+
     # Ensure sandbox retrieval works
     retrieved = client.get_sandbox(space, sandbox.name)
     assert sandbox.model_dump() == retrieved.model_dump(), (
-        "Created sandbox does not match retrieved sandbox."
+        "Ready sandbox does not match retrieved sandbox."
     )
 
     # Read test cases from files.
@@ -82,9 +85,20 @@ def test_http_client_v1(client):
                 if "expected_stderr" in tc:
                     assert tc["expected_stderr"] == (resp.stderr or "")
 
+            expected_error_name = tc.get("expected_error_name")
+            assert resp.success == (expected_error_name is None)
+            assert resp.error_name == expected_error_name
+
         for tc in shell_test_cases:
             req = RunShellCommandRequest(
-                command=tc["command"], split_output=tc.get("split", False)
+                command=tc.get("command"),
+                argv=tc.get("argv"),
+                cwd=tc.get("cwd"),
+                env=tc.get("env"),
+                stdin=tc.get("stdin"),
+                user=tc.get("user"),
+                split_output=tc.get("split", False),
+                timeout_seconds=tc.get("timeout_seconds"),
             )
             resp = client.run_shell_command(space, sandbox.name, req)
 
@@ -94,6 +108,8 @@ def test_http_client_v1(client):
                 assert tc["expected_stdout"] == (resp.stdout or "")
             if "expected_stderr" in tc:
                 assert tc["expected_stderr"] == (resp.stderr or "")
+            assert tc.get("expected_exit_code", 0) == resp.exit_code
+            assert tc.get("expected_timed_out", False) == bool(resp.timed_out)
 
     finally:
         client.delete_sandbox(space, sandbox.name)